@@ -45,6 +45,7 @@ const (
 	WorkflowCronjob   = "workflow"
 	WorkflowV4Cronjob = "workflow_v4"
 	TestingCronjob    = "test"
+	EnvSleepCronjob   = "env_sleep"
 )
 
 var (
@@ -116,6 +117,17 @@ const (
 	StatusPrepare        Status = "prepare"
 	StatusReject         Status = "reject"
 	StatusWaitingApprove Status = "waitforapprove"
+	StatusPaused         Status = "paused"
+)
+
+// FailureClass is the reason why a kubernetes job failed, used to decide whether to retry the job.
+type FailureClass string
+
+const (
+	FailureClassEvicted   FailureClass = "evicted"
+	FailureClassImagePull FailureClass = "image_pull"
+	FailureClassTimeout   FailureClass = "timeout"
+	FailureClassExitCode  FailureClass = "exit_code"
 )
 
 type TaskStatus string
@@ -161,6 +173,7 @@ const (
 	StepShell             StepType = "shell"
 	StepGit               StepType = "git"
 	StepDockerBuild       StepType = "docker_build"
+	StepImageBuild        StepType = "image_build"
 	StepDeploy            StepType = "deploy"
 	StepHelmDeploy        StepType = "helm_deploy"
 	StepCustomDeploy      StepType = "custom_deploy"
@@ -170,6 +183,12 @@ const (
 	StepJunitReport       StepType = "junit_report"
 	StepHtmlReport        StepType = "html_report"
 	StepTarArchive        StepType = "tar_archive"
+	StepCacheRestore      StepType = "cache_restore"
+	StepCacheSave         StepType = "cache_save"
+	StepArtifactUpload    StepType = "artifact_upload"
+	StepArtifactDownload  StepType = "artifact_download"
+	StepAttestation       StepType = "attestation"
+	StepTemplate          StepType = "template"
 	StepSonarCheck        StepType = "sonar_check"
 	StepDistributeImage   StepType = "distribute_image"
 )
@@ -201,6 +220,7 @@ const (
 	JobNacos                JobType = "nacos"
 	JobApollo               JobType = "apollo"
 	JobMeegoTransition      JobType = "meego-transition"
+	JobManualGate           JobType = "manual-gate"
 )
 
 const (
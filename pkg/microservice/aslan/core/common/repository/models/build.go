@@ -130,6 +130,12 @@ type DockerBuild struct {
 	TemplateID string `bson:"template_id"            json:"template_id"`
 	// TemplateName is the name of the template dockerfile
 	TemplateName string `bson:"template_name"        json:"template_name"`
+	// Builder is the tool used to build the image, docker or buildkit, default is docker
+	Builder string `bson:"builder,omitempty"          json:"builder,omitempty"`
+	// Platforms are the target platforms of a buildkit build, e.g. linux/amd64
+	Platforms []string `bson:"platforms,omitempty"    json:"platforms,omitempty"`
+	// EnableRegistryCache whether buildkit exports and imports layer cache from the registry
+	EnableRegistryCache bool `bson:"enable_registry_cache" json:"enable_registry_cache"`
 }
 
 type JenkinsBuild struct {
@@ -177,6 +183,23 @@ type KeyVal struct {
 	Type         ParameterSettingType `bson:"type,omitempty"            json:"type,omitempty"              yaml:"type"`
 	ChoiceOption []string             `bson:"choice_option,omitempty"   json:"choice_option,omitempty"     yaml:"choice_option,omitempty"`
 	IsCredential bool                 `bson:"is_credential"             json:"is_credential"               yaml:"is_credential"`
+	AsFile       bool                 `bson:"as_file,omitempty"         json:"as_file,omitempty"           yaml:"as_file,omitempty"` // credential provided as a file, the variable holds the file path
+}
+
+// CredentialValues returns the values of the credential variables and params, which must never show up in logs.
+func CredentialValues(kvs []*KeyVal, params []*Param) []string {
+	values := make([]string, 0)
+	for _, kv := range kvs {
+		if kv.IsCredential && kv.Value != "" {
+			values = append(values, kv.Value)
+		}
+	}
+	for _, param := range params {
+		if param.IsCredential && param.Value != "" {
+			values = append(values, param.Value)
+		}
+	}
+	return values
 }
 
 type Item struct {
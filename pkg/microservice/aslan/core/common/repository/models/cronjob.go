@@ -34,10 +34,18 @@ type Cronjob struct {
 	WorkflowArgs   *WorkflowTaskArgs  `bson:"workflow_args,omitempty"             json:"workflow_args,omitempty"`
 	WorkflowV4Args *WorkflowV4        `bson:"workflow_v4_args"                    json:"workflow_v4_args"`
 	TestArgs       *TestTaskArgs      `bson:"test_args,omitempty"                 json:"test_args,omitempty"`
+	EnvArgs        *EnvArgs           `bson:"env_args,omitempty"                  json:"env_args,omitempty"`
 	JobType        string             `bson:"job_type"                            json:"job_type"`
 	Enabled        bool               `bson:"enabled"                             json:"enabled"`
 }
 
+// EnvArgs is the args of env sleep cronjob, action is sleep or wake
+type EnvArgs struct {
+	ProductName string `bson:"product_name" json:"product_name"`
+	EnvName     string `bson:"env_name"     json:"env_name"`
+	Action      string `bson:"action"       json:"action"`
+}
+
 func (Cronjob) TableName() string {
 	return "cronjob"
 }
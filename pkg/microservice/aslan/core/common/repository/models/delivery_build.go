@@ -23,18 +23,19 @@ import (
 )
 
 type DeliveryBuild struct {
-	ID          primitive.ObjectID  `bson:"_id,omitempty"                json:"id,omitempty"`
-	ReleaseID   primitive.ObjectID  `bson:"release_id"           json:"releaseId"`
-	ServiceName string              `bson:"service_name"         json:"serviceName"`
-	ImageInfo   *DeliveryImage      `bson:"image_info"           json:"imageInfo"`
-	ImageName   string              `bson:"image_name"           json:"imageName"`
-	PackageInfo *DeliveryPackage    `bson:"package_info"         json:"packageInfo"`
-	Issues      []*JiraIssue        `bson:"issues"               json:"issues"`
-	Commits     []*types.Repository `bson:"commits"              json:"commits"`
-	StartTime   int64               `bson:"start_time"           json:"start_time,omitempty"`
-	EndTime     int64               `bson:"end_time"             json:"end_time,omitempty"`
-	CreatedAt   int64               `bson:"created_at"           json:"created_at"`
-	DeletedAt   int64               `bson:"deleted_at"           json:"deleted_at"`
+	ID          primitive.ObjectID   `bson:"_id,omitempty"                json:"id,omitempty"`
+	ReleaseID   primitive.ObjectID   `bson:"release_id"           json:"releaseId"`
+	ServiceName string               `bson:"service_name"         json:"serviceName"`
+	ImageInfo   *DeliveryImage       `bson:"image_info"           json:"imageInfo"`
+	ImageName   string               `bson:"image_name"           json:"imageName"`
+	PackageInfo *DeliveryPackage     `bson:"package_info"         json:"packageInfo"`
+	Issues      []*JiraIssue         `bson:"issues"               json:"issues"`
+	Commits     []*types.Repository  `bson:"commits"              json:"commits"`
+	Attestation *DeliveryAttestation `bson:"attestation,omitempty" json:"attestation,omitempty"`
+	StartTime   int64                `bson:"start_time"           json:"start_time,omitempty"`
+	EndTime     int64                `bson:"end_time"             json:"end_time,omitempty"`
+	CreatedAt   int64                `bson:"created_at"           json:"created_at"`
+	DeletedAt   int64                `bson:"deleted_at"           json:"deleted_at"`
 }
 
 // JiraIssue ...
@@ -63,6 +64,20 @@ type DeliveryImage struct {
 	UpdateTime    string `bson:"update_time"     json:"updateTime"`
 }
 
+// DeliveryAttestation is the provenance of the built image: its SBOM and the signature of the image digest.
+type DeliveryAttestation struct {
+	Digest             string `bson:"digest"              json:"digest"`
+	SBOMFormat         string `bson:"sbom_format"         json:"sbomFormat"`
+	SBOMObjectKey      string `bson:"sbom_object_key"     json:"sbomObjectKey"`
+	SBOMChecksum       string `bson:"sbom_checksum"       json:"sbomChecksum"`
+	Payload            string `bson:"payload"             json:"payload"`
+	Signature          string `bson:"signature"           json:"signature"`
+	SignatureAlgorithm string `bson:"signature_algorithm" json:"signatureAlgorithm"`
+	SigningKeyID       string `bson:"signing_key_id"      json:"signingKeyId"`
+	PublicKey          string `bson:"public_key"          json:"publicKey"`
+	AttestationRef     string `bson:"attestation_ref"     json:"attestationRef"`
+}
+
 type DeliveryPackage struct {
 	PackageFileLocation string `bson:"package_file_location"        json:"packageFileLocation"`
 	PackageFileName     string `bson:"package_file_name"            json:"packageFileName"`
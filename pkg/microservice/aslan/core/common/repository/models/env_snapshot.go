@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	templatemodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/template"
+)
+
+// EnvSnapshot is an immutable copy of an environment taken after it's updated,
+// the environment can be rolled back to any of its snapshots.
+type EnvSnapshot struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"          json:"id,omitempty"`
+	ProductName string             `bson:"product_name"           json:"product_name"`
+	EnvName     string             `bson:"env_name"               json:"env_name"`
+	// Revision increases in an environment, it starts from 1
+	Revision int64 `bson:"revision"               json:"revision"`
+	// EnvRevision is the revision of the product template used by the environment
+	EnvRevision    int64                  `bson:"env_revision"           json:"env_revision"`
+	Source         string                 `bson:"source"                 json:"source"`
+	Services       [][]*ProductService    `bson:"services"               json:"services"`
+	DeployStrategy map[string]string      `bson:"deploy_strategy"        json:"deploy_strategy"`
+	RenderSet      *EnvSnapshotRenderSet  `bson:"render_set"             json:"render_set"`
+	EnvResources   []*EnvSnapshotResource `bson:"env_resources"          json:"env_resources"`
+	Description    string                 `bson:"description"            json:"description"`
+	CreatedBy      string                 `bson:"created_by"             json:"created_by"`
+	CreateTime     int64                  `bson:"create_time"            json:"create_time"`
+}
+
+// EnvSnapshotRenderSet keeps the content of the renderset, the renderset revision is only for reference
+// since a new revision is created when rolling back.
+type EnvSnapshotRenderSet struct {
+	Name             string                          `bson:"name"                   json:"name"`
+	Revision         int64                           `bson:"revision"               json:"revision"`
+	DefaultValues    string                          `bson:"default_values"         json:"default_values"`
+	YamlData         *templatemodels.CustomYaml      `bson:"yaml_data,omitempty"    json:"yaml_data,omitempty"`
+	ServiceVariables []*templatemodels.ServiceRender `bson:"service_variables"      json:"service_variables"`
+	ChartInfos       []*templatemodels.ServiceRender `bson:"chart_infos"            json:"chart_infos"`
+}
+
+// EnvSnapshotResource is an env config such as ConfigMap, Secret, Ingress and PVC tracked by EnvResource.
+type EnvSnapshotResource struct {
+	Type     string `bson:"type"                   json:"type"`
+	Name     string `bson:"name"                   json:"name"`
+	YamlData string `bson:"yaml_data"              json:"yaml_data"`
+}
+
+func (EnvSnapshot) TableName() string {
+	return "env_snapshot"
+}
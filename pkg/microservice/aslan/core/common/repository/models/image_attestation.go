@@ -0,0 +1,36 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// ImageAttestation records the attestation generated by the build job for an image,
+// delivery versions look it up by the image to carry the provenance of the released images.
+type ImageAttestation struct {
+	ID                  primitive.ObjectID `bson:"_id,omitempty"     json:"id,omitempty"`
+	Image               string             `bson:"image"             json:"image"`
+	ProjectName         string             `bson:"project_name"      json:"project_name"`
+	WorkflowName        string             `bson:"workflow_name"     json:"workflow_name"`
+	TaskID              int64              `bson:"task_id"           json:"task_id"`
+	JobName             string             `bson:"job_name"          json:"job_name"`
+	CreateTime          int64              `bson:"create_time"       json:"create_time"`
+	DeliveryAttestation `bson:",inline"           json:",inline"`
+}
+
+func (ImageAttestation) TableName() string {
+	return "image_attestation"
+}
@@ -40,6 +40,7 @@ type Notification struct {
 	IsTest       bool                `bson:"is_test"                      json:"is_test"`
 	IsScanning   bool                `bson:"is_scanning"                  json:"is_scanning"`
 	IsWorkflowV4 bool                `bson:"is_workflowv4"                json:"is_workflowv4"`
+	IsPreviewEnv bool                `bson:"is_preview_env,omitempty"     json:"is_preview_env,omitempty"`
 	ErrInfo      string              `bson:"err_info"                     json:"err_info"`
 	PrTask       *PrTaskInfo         `bson:"pr_task_info,omitempty"       json:"pr_task_info,omitempty"`
 	Label        string              `bson:"label"                        json:"label"  `
@@ -53,6 +54,9 @@ type PrTaskInfo struct {
 	EnvName          string `bson:"env_name,omitempty"                  json:"env_name,omitempty"`
 	EnvRecyclePolicy string `bson:"env_recycle_policy,omitempty"        json:"env_recycle_policy,omitempty"`
 	ProductName      string `bson:"product_name,omitempty"              json:"product_name,omitempty"`
+	PreviewEnvName   string `bson:"preview_env_name,omitempty"          json:"preview_env_name,omitempty"`
+	PreviewURL       string `bson:"preview_url,omitempty"               json:"preview_url,omitempty"`
+	PreviewServices  string `bson:"preview_services,omitempty"          json:"preview_services,omitempty"`
 }
 
 type NotificationTask struct {
@@ -158,12 +162,28 @@ func (n *Notification) CreateCommentBody() (comment string, err error) {
 		}
 	}
 
+	if n.IsPreviewEnv {
+		// only the preview environment is shown in the comment
+		tmplSource = ""
+	}
+
 	if n.PrTask != nil {
 		if n.PrTask.EnvName != "" {
 			content := fmt.Sprintf("生成基准环境：[%s]({{$.BaseURI}}/v1/projects/detail/%s/envs/detail?envName=%s) 状态：%s \n\n", n.PrTask.EnvName, n.PrTask.ProductName, n.PrTask.EnvName, n.PrTask.EnvStatus)
 			tmplSource = fmt.Sprintf("%s%s", content, tmplSource)
 		}
 
+		if n.PrTask.PreviewEnvName != "" {
+			content := fmt.Sprintf("预览环境：[%s]({{$.BaseURI}}/v1/projects/detail/%s/envs/detail?envName=%s) 状态：%s \n\n", n.PrTask.PreviewEnvName, n.PrTask.ProductName, n.PrTask.PreviewEnvName, n.PrTask.EnvStatus)
+			if n.PrTask.PreviewServices != "" {
+				content = fmt.Sprintf("%s包含服务：%s \n\n", content, n.PrTask.PreviewServices)
+			}
+			if n.PrTask.PreviewURL != "" {
+				content = fmt.Sprintf("%s预览地址：%s \n\n", content, n.PrTask.PreviewURL)
+			}
+			tmplSource = fmt.Sprintf("%s%s", content, tmplSource)
+		}
+
 		if n.PrTask.EnvRecyclePolicy != "" {
 			policyName := getEnvRecyclePolicy(n.PrTask.EnvRecyclePolicy)
 			content := fmt.Sprintf("根据策略清理环境：[%s]({{$.BaseURI}}/v1/projects/detail/%s/envs/detail?envName=%s) 回收策略：%s \n\n", n.PrTask.EnvName, n.PrTask.ProductName, n.PrTask.EnvName, policyName)
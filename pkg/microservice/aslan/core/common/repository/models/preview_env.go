@@ -0,0 +1,46 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// PreviewEnv is a sub environment created for a pull request by the workflow webhook,
+// it is deleted when the pull request is closed or it has been idle for too long.
+type PreviewEnv struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"        json:"id,omitempty"`
+	ProductName    string             `bson:"product_name"         json:"product_name"`
+	EnvName        string             `bson:"env_name"             json:"env_name"`
+	BaseEnv        string             `bson:"base_env"             json:"base_env"`
+	WorkflowName   string             `bson:"workflow_name"        json:"workflow_name"`
+	HookName       string             `bson:"hook_name"            json:"hook_name"`
+	CodehostID     int                `bson:"codehost_id"          json:"codehost_id"`
+	RepoOwner      string             `bson:"repo_owner"           json:"repo_owner"`
+	RepoName       string             `bson:"repo_name"            json:"repo_name"`
+	PR             int                `bson:"pr"                   json:"pr"`
+	Services       []string           `bson:"services"             json:"services"`
+	URL            string             `bson:"url"                  json:"url"`
+	NotificationID string             `bson:"notification_id"      json:"notification_id"`
+	// IdleTTL is in hours
+	IdleTTL        int64  `bson:"idle_ttl"             json:"idle_ttl"`
+	LastActiveTime int64  `bson:"last_active_time"     json:"last_active_time"`
+	CreatedBy      string `bson:"created_by"           json:"created_by"`
+	CreateTime     int64  `bson:"create_time"          json:"create_time"`
+}
+
+func (PreviewEnv) TableName() string {
+	return "preview_env"
+}
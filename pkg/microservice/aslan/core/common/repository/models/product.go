@@ -71,6 +71,32 @@ type Product struct {
 	// For production environment
 	Production bool   `json:"production" bson:"production"`
 	Alias      string `json:"alias" bson:"alias"`
+
+	// SleepSchedule scales the environment down to zero and restores it at regular intervals
+	SleepSchedule *EnvSleepSchedule `bson:"sleep_schedule,omitempty" json:"sleep_schedule,omitempty"`
+	// SleepState records the workloads changed when the environment went to sleep
+	SleepState *EnvSleepState `bson:"sleep_state,omitempty"    json:"-"`
+}
+
+// EnvSleepSchedule defines when an environment goes to sleep and wakes up, the crons are
+// standard crontab expressions, such as "0 20 * * 1-5"
+type EnvSleepSchedule struct {
+	Enabled   bool   `bson:"enabled"    json:"enabled"`
+	SleepCron string `bson:"sleep_cron" json:"sleep_cron"`
+	WakeCron  string `bson:"wake_cron"  json:"wake_cron"`
+}
+
+type EnvSleepState struct {
+	SleepTime int64               `bson:"sleep_time" json:"sleep_time"`
+	Workloads []*EnvSleepWorkload `bson:"workloads"  json:"workloads"`
+	CronJobs  []string            `bson:"cron_jobs"  json:"cron_jobs"`
+}
+
+// EnvSleepWorkload is a Deployment or StatefulSet scaled to zero, Replicas is the count before sleeping
+type EnvSleepWorkload struct {
+	Type     string `bson:"type"     json:"type"`
+	Name     string `bson:"name"     json:"name"`
+	Replicas int32  `bson:"replicas" json:"replicas"`
 }
 
 type CreateUpdateCommonEnvCfgArgs struct {
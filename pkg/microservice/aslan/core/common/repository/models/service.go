@@ -73,6 +73,7 @@ type Service struct {
 	HealthChecks     []*PmHealthCheck `bson:"health_checks,omitempty"        json:"health_checks,omitempty"`
 	WorkloadType     string           `bson:"workload_type,omitempty"        json:"workload_type,omitempty"` // WorkloadType is set in host projects
 	EnvName          string           `bson:"env_name,omitempty"             json:"env_name,omitempty"`
+	DependsOn        []string         `bson:"depends_on"                     json:"depends_on"` // services which must be ready before this service is deployed in an environment
 	TemplateID       string           `bson:"template_id,omitempty"          json:"template_id,omitempty"`
 	AutoSync         bool             `bson:"auto_sync"                      json:"auto_sync"`
 }
@@ -0,0 +1,51 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// ServiceDrift is the latest drift report of a service in an environment,
+// it records the differences between the rendered service and the live objects in the cluster.
+type ServiceDrift struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"        json:"id,omitempty"`
+	ProductName string             `bson:"product_name"         json:"product_name"`
+	EnvName     string             `bson:"env_name"             json:"env_name"`
+	ServiceName string             `bson:"service_name"         json:"service_name"`
+	Drifted     bool               `bson:"drifted"              json:"drifted"`
+	Resources   []*DriftResource   `bson:"resources"            json:"resources"`
+	Error       string             `bson:"error"                json:"error"`
+	CheckTime   int64              `bson:"check_time"           json:"check_time"`
+}
+
+type DriftResource struct {
+	Kind string `bson:"kind"                 json:"kind"`
+	Name string `bson:"name"                 json:"name"`
+	// Missing means the object is rendered but not found in the cluster.
+	Missing bool          `bson:"missing"              json:"missing"`
+	Fields  []*DriftField `bson:"fields"               json:"fields"`
+}
+
+type DriftField struct {
+	// Path is like containers[nginx].env[PORT]
+	Path    string `bson:"path"                 json:"path"`
+	Desired string `bson:"desired"              json:"desired"`
+	Live    string `bson:"live"                 json:"live"`
+}
+
+func (ServiceDrift) TableName() string {
+	return "service_drift"
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// StepTemplate is a sequence of steps shared across workflows, it is used by a template step of a freestyle job,
+// which expands to the steps of the template when the workflow task is created.
+// Every update saves a new version, so that the template steps pinned to a version keep working.
+type StepTemplate struct {
+	ID          primitive.ObjectID   `bson:"_id,omitempty"          json:"id,omitempty"          yaml:"id,omitempty"`
+	Name        string               `bson:"name"                   json:"name"                  yaml:"name"`
+	Version     int64                `bson:"version"                json:"version"               yaml:"version"`
+	Description string               `bson:"description"            json:"description"           yaml:"description"`
+	Inputs      []*StepTemplateInput `bson:"inputs"                 json:"inputs"                yaml:"inputs"`
+	// Outputs are written by the steps of the template, they are added to the outputs of the job using the template.
+	Outputs    []*Output `bson:"outputs"                json:"outputs"               yaml:"outputs"`
+	Steps      []*Step   `bson:"steps"                  json:"steps"                 yaml:"steps"`
+	UpdateBy   string    `bson:"update_by"              json:"update_by"             yaml:"update_by"`
+	CreateTime int64     `bson:"create_time"            json:"create_time"           yaml:"create_time"`
+}
+
+type StepTemplateInputType string
+
+const (
+	StepTemplateInputString StepTemplateInputType = "string"
+	StepTemplateInputBool   StepTemplateInputType = "bool"
+	StepTemplateInputNumber StepTemplateInputType = "number"
+)
+
+// StepTemplateInput is referenced as {{.inputs.<name>}} in the specs of the template steps.
+type StepTemplateInput struct {
+	Name        string                `bson:"name"                   json:"name"                  yaml:"name"`
+	Description string                `bson:"description"            json:"description"           yaml:"description"`
+	Type        StepTemplateInputType `bson:"type"                   json:"type"                  yaml:"type"`
+	Default     string                `bson:"default"                json:"default"               yaml:"default"`
+	Required    bool                  `bson:"required"               json:"required"              yaml:"required"`
+}
+
+// StepTemplateSpec is the spec of a template step.
+type StepTemplateSpec struct {
+	TemplateName string `bson:"template_name"          json:"template_name"         yaml:"template_name"`
+	// Version of the template, the latest version is used if it is not positive.
+	Version int64     `bson:"version"                json:"version"               yaml:"version"`
+	Inputs  []*KeyVal `bson:"inputs"                 json:"inputs"                yaml:"inputs"`
+}
+
+func (StepTemplate) TableName() string {
+	return "step_template"
+}
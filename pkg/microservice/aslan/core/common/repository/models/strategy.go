@@ -25,6 +25,9 @@ const (
 	// 工作流任务的留存
 	WorkflowTaskRetention     CapacityTarget = "WorkflowTaskRetention"
 	DefaultWorkflowRemainDays int            = 365
+	// 依赖缓存的配额
+	DependencyCacheQuota            CapacityTarget = "DependencyCacheQuota"
+	DefaultDependencyCacheSizeInMiB int64          = 10240
 )
 
 var DefaultWorkflowTaskRetention = &CapacityStrategy{
@@ -34,17 +37,30 @@ var DefaultWorkflowTaskRetention = &CapacityStrategy{
 	},
 }
 
+var DefaultDependencyCacheQuota = &CapacityStrategy{
+	Target: DependencyCacheQuota,
+	Quota: &QuotaConfig{
+		MaxSizeInMiB: DefaultDependencyCacheSizeInMiB,
+	},
+}
+
 // RetentionConfig 资源留存相关的配置
 type RetentionConfig struct {
 	MaxDays  int `bson:"max_days"      json:"max_days"`  // 最多几天
 	MaxItems int `bson:"max_items"     json:"max_items"` // 最多几条
 }
 
+// QuotaConfig 资源占用空间相关的配置
+type QuotaConfig struct {
+	MaxSizeInMiB int64 `bson:"max_size_in_mib" json:"max_size_in_mib"` // 每个项目最多占用的空间，超出后按最近最少使用淘汰
+}
+
 // CapacityStrategy 系统配额策略
 type CapacityStrategy struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty"  json:"id,omitempty"`
 	Target    CapacityTarget     `bson:"target"         json:"target"` // 配额策略的对象，不重复
 	Retention *RetentionConfig   `bson:"retention"      json:"retention,omitempty"`
+	Quota     *QuotaConfig       `bson:"quota"          json:"quota,omitempty"`
 }
 
 func (CapacityStrategy) TableName() string {
@@ -70,6 +70,8 @@ type Product struct {
 	CustomTarRule              *CustomRule          `bson:"custom_tar_rule,omitempty"           json:"custom_tar_rule,omitempty"`
 	DeliveryVersionHook        *DeliveryVersionHook `bson:"delivery_version_hook"               json:"delivery_version_hook"`
 	Public                     bool                 `bson:"public,omitempty"                    json:"public"`
+	// WorkflowTaskQuota is the max number of running workflow v4 tasks of the project, 0 means no limit.
+	WorkflowTaskQuota int `bson:"workflow_task_quota"                 json:"workflow_task_quota"`
 }
 
 type ServiceInfo struct {
@@ -0,0 +1,40 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// TestJobStat is the test result and coverage of one testing job run.
+type TestJobStat struct {
+	TestName     string              `bson:"test_name"          json:"test_name"`
+	ProjectName  string              `bson:"project_name"       json:"project_name"`
+	WorkflowName string              `bson:"workflow_name"      json:"workflow_name"`
+	TaskID       int64               `bson:"task_id"            json:"task_id"`
+	JobName      string              `bson:"job_name"           json:"job_name"`
+	Tests        int                 `bson:"tests"              json:"tests"`
+	Failures     int                 `bson:"failures"           json:"failures"`
+	Errors       int                 `bson:"errors"             json:"errors"`
+	Skips        int                 `bson:"skips"              json:"skips"`
+	Coverage     *types.TestCoverage `bson:"coverage,omitempty" json:"coverage,omitempty"`
+	CreateTime   int64               `bson:"create_time"        json:"create_time"`
+}
+
+func (TestJobStat) TableName() string {
+	return "test_job_stat"
+}
@@ -37,6 +37,11 @@ type Testing struct {
 	UpdateBy    string              `bson:"update_by"                json:"update_by"`
 	// Junit 测试报告
 	TestResultPath string `bson:"test_result_path"         json:"test_result_path"`
+	// Junit 测试报告格式, junit 或 go-test-json
+	TestResultFormat string `bson:"test_result_format"       json:"test_result_format"`
+	// 覆盖率报告, 格式为 cobertura 或 jacoco
+	CoverageFormat string `bson:"coverage_format"          json:"coverage_format"`
+	CoveragePath   string `bson:"coverage_path"            json:"coverage_path"`
 	// html 测试报告
 	TestReportPath string `bson:"test_report_path"         json:"test_report_path"`
 	Threshold      int    `bson:"threshold"                json:"threshold"`
@@ -98,6 +103,8 @@ type PreTest struct {
 	// EnableProxy
 	EnableProxy bool   `bson:"enable_proxy"           json:"enable_proxy"`
 	ClusterID   string `bson:"cluster_id"             json:"cluster_id"`
+	// Services run next to the test container, such as the databases integration tests depend on
+	Services []*ServiceContainer `bson:"services,omitempty"     json:"services,omitempty"`
 
 	// TODO: Deprecated.
 	Namespace string `bson:"namespace"              json:"namespace"`
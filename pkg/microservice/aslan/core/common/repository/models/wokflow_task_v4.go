@@ -17,12 +17,15 @@ limitations under the License.
 package models
 
 import (
+	"context"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
 )
 
 type WorkflowTask struct {
@@ -52,6 +55,14 @@ type WorkflowTask struct {
 	IsRestart           bool               `bson:"is_restart"                json:"is_restart"`
 	MultiRun            bool               `bson:"multi_run"                 json:"multi_run"`
 	ShareStorages       []*ShareStorage    `bson:"share_storages"            json:"share_storages"`
+	// OriginTaskID is the task whose failed jobs are re-run by this task.
+	OriginTaskID int64 `bson:"origin_task_id,omitempty" json:"origin_task_id,omitempty"`
+	// RetryTaskIDs is the tasks re-running the failed jobs of this task.
+	RetryTaskIDs []int64 `bson:"retry_task_ids,omitempty" json:"retry_task_ids,omitempty"`
+	// Paused stops the task from starting new jobs until it is resumed.
+	Paused      bool              `bson:"paused"                json:"paused"`
+	Priority    int               `bson:"priority"              json:"priority"`
+	Concurrency *ConcurrencyGroup `bson:"concurrency,omitempty" json:"concurrency,omitempty"`
 }
 
 func (WorkflowTask) TableName() string {
@@ -83,6 +94,36 @@ type JobTask struct {
 	Retry      int64         `bson:"retry"               json:"retry"`
 	Spec       interface{}   `bson:"spec"                json:"spec"`
 	Outputs    []*Output     `bson:"outputs"             json:"outputs"`
+	// DependsOn is the keys of the job tasks this job waits for, only set when the workflow uses depends_on.
+	DependsOn []string `bson:"depends_on,omitempty" json:"depends_on,omitempty"`
+	When      string   `bson:"when,omitempty"       json:"when,omitempty"`
+	// MatrixKey is shared by the jobs expanded from the same matrix.
+	MatrixKey string `bson:"matrix_key,omitempty" json:"matrix_key,omitempty"`
+	FailFast  bool   `bson:"fail_fast,omitempty"  json:"fail_fast,omitempty"`
+	// Attempts is the failed runs of the job before the current one.
+	Attempts []*JobAttempt `bson:"attempts,omitempty"   json:"attempts,omitempty"`
+	// FromTaskID is set when the job passed in the task it is re-run from, the job does not run again.
+	FromTaskID int64 `bson:"from_task_id,omitempty" json:"from_task_id,omitempty"`
+	// HtmlReport is where the html report of the job is archived.
+	HtmlReport *HtmlReport `bson:"html_report,omitempty" json:"html_report,omitempty"`
+}
+
+type HtmlReport struct {
+	// S3DestDir is the object storage directory holding the report and its assets.
+	S3DestDir  string `bson:"s3_dest_dir"         json:"s3_dest_dir"`
+	ReportFile string `bson:"report_file"         json:"report_file"`
+}
+
+type JobAttempt struct {
+	Attempt      int                 `bson:"attempt"             json:"attempt"`
+	K8sJobName   string              `bson:"k8s_job_name"        json:"k8s_job_name"`
+	Status       config.Status       `bson:"status"              json:"status"`
+	FailureClass config.FailureClass `bson:"failure_class"       json:"failure_class"`
+	Error        string              `bson:"error"               json:"error"`
+	StartTime    int64               `bson:"start_time"          json:"start_time"`
+	EndTime      int64               `bson:"end_time"            json:"end_time"`
+	// LogName is the job name to get the log of the attempt.
+	LogName string `bson:"log_name"            json:"log_name"`
 }
 
 type JobTaskCustomDeploySpec struct {
@@ -104,6 +145,7 @@ type JobTaskDeploySpec struct {
 	ServiceModule      string              `bson:"service_module"                   json:"service_module"                      yaml:"service_module"`
 	SkipCheckRunStatus bool                `bson:"skip_check_run_status"            json:"skip_check_run_status"               yaml:"skip_check_run_status"`
 	Image              string              `bson:"image"                            json:"image"                               yaml:"image"`
+	ImageDigest        string              `bson:"image_digest,omitempty"           json:"image_digest,omitempty"              yaml:"image_digest,omitempty"`
 	ClusterID          string              `bson:"cluster_id"                       json:"cluster_id"                          yaml:"cluster_id"`
 	Timeout            int                 `bson:"timeout"                          json:"timeout"                             yaml:"timeout"`
 	ReplaceResources   []Resource          `bson:"replace_resources"                json:"replace_resources"                   yaml:"replace_resources"`
@@ -132,6 +174,7 @@ type JobTaskHelmDeploySpec struct {
 type ImageAndServiceModule struct {
 	ServiceModule string `bson:"service_module"                     json:"service_module"                        yaml:"service_module"`
 	Image         string `bson:"image"                              json:"image"                                 yaml:"image"`
+	ImageDigest   string `bson:"image_digest,omitempty"             json:"image_digest,omitempty"                yaml:"image_digest,omitempty"`
 }
 
 type JobTaskFreestyleSpec struct {
@@ -139,6 +182,32 @@ type JobTaskFreestyleSpec struct {
 	Steps      []*StepTask   `bson:"steps"               json:"steps"             yaml:"steps"`
 }
 
+// MatrixSuffix returns the escaped matrix values in the key of the job, e.g. ".linux.amd64",
+// it is empty if the job is not expanded from a matrix.
+func (j *JobTask) MatrixSuffix() string {
+	if j.MatrixKey == "" {
+		return ""
+	}
+	return strings.TrimPrefix(j.Key, j.MatrixKey)
+}
+
+// UseNFSDependencyCache tells whether any cache step of the job keeps its caches in the NFS cache of the cluster.
+func (s *JobTaskFreestyleSpec) UseNFSDependencyCache() bool {
+	for _, stepTask := range s.Steps {
+		if stepTask.StepType != config.StepCacheRestore && stepTask.StepType != config.StepCacheSave {
+			continue
+		}
+		cacheSpec := &step.StepCacheSpec{}
+		if err := IToi(stepTask.Spec, cacheSpec); err != nil {
+			continue
+		}
+		if cacheSpec.MediumType == types.NFSMedium {
+			return true
+		}
+	}
+	return false
+}
+
 type JobTaskPluginSpec struct {
 	Properties JobProperties   `bson:"properties"          json:"properties"        yaml:"properties"`
 	Plugin     *PluginTemplate `bson:"plugin"              json:"plugin"            yaml:"plugin"`
@@ -295,6 +364,15 @@ type JobTaskJiraSpec struct {
 	TargetStatus string     `bson:"target_status" json:"target_status" yaml:"target_status"`
 }
 
+type JobTaskManualGateSpec struct {
+	Description string   `bson:"description"  json:"description"  yaml:"description"`
+	Timeout     int64    `bson:"timeout"      json:"timeout"      yaml:"timeout"`
+	Users       []*User  `bson:"users"        json:"users"        yaml:"users"`
+	Params      []*Param `bson:"params"       json:"params"       yaml:"params"`
+	Submitter   string   `bson:"submitter"    json:"submitter"    yaml:"submitter"`
+	SubmitTime  int64    `bson:"submit_time"  json:"submit_time"  yaml:"submit_time"`
+}
+
 type JobTaskNacosSpec struct {
 	NacosID       string       `bson:"nacos_id"         json:"nacos_id"         yaml:"nacos_id"`
 	NamespaceID   string       `bson:"namespace_id"     json:"namespace_id"     yaml:"namespace_id"`
@@ -367,6 +445,17 @@ type StepTask struct {
 	Error     string          `bson:"error"          json:"error"        yaml:"error"`
 	StepType  config.StepType `bson:"type"           json:"type"         yaml:"type"`
 	Onfailure bool            `bson:"on_failure"     json:"on_failure"   yaml:"on_failure"`
+	When      string          `bson:"when,omitempty" json:"when,omitempty" yaml:"-"`
+	// Timeout of the step in minutes, no timeout besides the job timeout if it is not positive.
+	Timeout         int64  `bson:"timeout,omitempty"           json:"timeout,omitempty"           yaml:"timeout"`
+	Retry           int    `bson:"retry,omitempty"             json:"retry,omitempty"             yaml:"retry"`
+	ContinueOnError bool   `bson:"continue_on_error,omitempty" json:"continue_on_error,omitempty" yaml:"continue_on_error"`
+	WorkingDir      string `bson:"working_dir,omitempty"       json:"working_dir,omitempty"       yaml:"working_dir"`
+	// Status, StartTime and EndTime are reported by the job executor after the job ends.
+	Status    config.Status `bson:"status,omitempty"     json:"status,omitempty"     yaml:"-"`
+	StartTime int64         `bson:"start_time,omitempty" json:"start_time,omitempty" yaml:"-"`
+	EndTime   int64         `bson:"end_time,omitempty"   json:"end_time,omitempty"   yaml:"-"`
+	Attempts  int           `bson:"attempts,omitempty"   json:"attempts,omitempty"   yaml:"-"`
 	// step input params,differ form steps
 	Spec interface{} `bson:"spec"           json:"spec"   yaml:"spec"`
 	// step output results,like testing results,differ form steps
@@ -386,9 +475,14 @@ type WorkflowTaskCtx struct {
 	WorkflowTaskCreatorEmail  string
 	WorkflowTaskCreatorMobile string
 	WorkflowKeyVals           []*KeyVal
-	GlobalContextGet          func(key string) (string, bool)
+	WorkflowParams            []*Param
+	HookPayload               *HookPayload
+	GlobalContextGet          func(key string) (string, bool, error)
 	GlobalContextSet          func(key, value string)
-	GlobalContextEach         func(f func(k, v string) bool)
+	GlobalContextEach         func(f func(k, v string) bool) error
 	ClusterIDAdd              func(clusterID string)
-	SetStatus                 func(status config.Status)
+	// SetWaiting marks a job or stage waiting for approval or resumption, the task status is derived from all the waiting ones.
+	SetWaiting func(status config.Status, waiting bool)
+	// WaitIfPaused blocks until the task is resumed if it is paused.
+	WaitIfPaused func(ctx context.Context) error
 }
@@ -93,6 +93,8 @@ type MainHookRepo struct {
 	Label         string                 `bson:"label"                     json:"label"`
 	Revision      string                 `bson:"revision"                  json:"revision"`
 	IsRegular     bool                   `bson:"is_regular"                json:"is_regular"`
+	// ChangedFiles is only set when matching a webhook event.
+	ChangedFiles []string `bson:"-"                         json:"-"`
 }
 
 func (m *MainHookRepo) GetRepoNamespace() string {
@@ -125,6 +127,7 @@ type Schedule struct {
 	WorkflowArgs   *WorkflowTaskArgs   `bson:"workflow_args,omitempty"       json:"workflow_args,omitempty"`
 	TestArgs       *TestTaskArgs       `bson:"test_args,omitempty"           json:"test_args,omitempty"`
 	WorkflowV4Args *WorkflowV4         `bson:"workflow_v4_args"              json:"workflow_v4_args"`
+	EnvArgs        *EnvArgs            `bson:"env_args,omitempty"            json:"env_args,omitempty"`
 	Type           config.ScheduleType `bson:"type"                          json:"type"`
 	Cron           string              `bson:"cron"                          json:"cron"`
 	IsModified     bool                `bson:"-"                             json:"-"`
@@ -362,17 +365,20 @@ type TestArgs struct {
 }
 
 type HookPayload struct {
-	Owner          string `bson:"owner"            json:"owner,omitempty"`
-	Repo           string `bson:"repo"             json:"repo,omitempty"`
-	Branch         string `bson:"branch"           json:"branch,omitempty"`
-	Ref            string `bson:"ref"              json:"ref,omitempty"`
-	IsPr           bool   `bson:"is_pr"            json:"is_pr,omitempty"`
-	CheckRunID     int64  `bson:"check_run_id"     json:"check_run_id,omitempty"`
-	MergeRequestID string `bson:"merge_request_id" json:"merge_request_id,omitempty"`
-	CommitID       string `bson:"commit_id"        json:"commit_id,omitempty"`
-	DeliveryID     string `bson:"delivery_id"      json:"delivery_id,omitempty"`
-	CodehostID     int    `bson:"codehost_id"      json:"codehost_id"`
-	EventType      string `bson:"event_type"       json:"event_type"`
+	Owner          string   `bson:"owner"            json:"owner,omitempty"`
+	Repo           string   `bson:"repo"             json:"repo,omitempty"`
+	Branch         string   `bson:"branch"           json:"branch,omitempty"`
+	Ref            string   `bson:"ref"              json:"ref,omitempty"`
+	IsPr           bool     `bson:"is_pr"            json:"is_pr,omitempty"`
+	CheckRunID     int64    `bson:"check_run_id"     json:"check_run_id,omitempty"`
+	MergeRequestID string   `bson:"merge_request_id" json:"merge_request_id,omitempty"`
+	CommitID       string   `bson:"commit_id"        json:"commit_id,omitempty"`
+	DeliveryID     string   `bson:"delivery_id"      json:"delivery_id,omitempty"`
+	CodehostID     int      `bson:"codehost_id"      json:"codehost_id"`
+	EventType      string   `bson:"event_type"       json:"event_type"`
+	Tag            string   `bson:"tag"              json:"tag,omitempty"`
+	Labels         []string `bson:"labels"           json:"labels,omitempty"`
+	ChangedFiles   []string `bson:"changed_files"    json:"changed_files,omitempty"`
 }
 
 type TargetArgs struct {
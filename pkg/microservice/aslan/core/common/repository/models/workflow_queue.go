@@ -34,6 +34,13 @@ type WorkflowQueue struct {
 	TaskRevoker         string             `bson:"task_revoker,omitempty"                     json:"task_revoker,omitempty"`
 	CreateTime          int64              `bson:"create_time"                                json:"create_time,omitempty"`
 	MultiRun            bool               `bson:"multi_run"                                  json:"multi_run"`
+	// Owner is the aslan replica which is running the task, it must renew the lease before LeaseExpireTime,
+	// otherwise the task will be taken over by other replicas.
+	Owner           string `bson:"owner,omitempty"                            json:"owner,omitempty"`
+	LeaseExpireTime int64  `bson:"lease_expire_time,omitempty"                json:"lease_expire_time,omitempty"`
+	// Priority and Concurrency decide which waiting task can be queued, see WorfklowTaskSender.
+	Priority    int               `bson:"priority"                                   json:"priority"`
+	Concurrency *ConcurrencyGroup `bson:"concurrency,omitempty"                      json:"concurrency,omitempty"`
 }
 
 func (WorkflowQueue) TableName() string {
@@ -26,6 +26,7 @@ import (
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/lark"
 	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/job"
 )
 
 type WorkflowV4 struct {
@@ -52,6 +53,26 @@ type WorkflowV4 struct {
 	HookPayload     *HookPayload             `bson:"hook_payload"        yaml:"-"                   json:"hook_payload,omitempty"`
 	BaseName        string                   `bson:"base_name"           yaml:"-"                   json:"base_name"`
 	ShareStorages   []*ShareStorage          `bson:"share_storages"      yaml:"share_storages"      json:"share_storages"`
+	// Priority decides the order of the waiting tasks, tasks with higher priority run first.
+	Priority    int               `bson:"priority"              yaml:"priority"              json:"priority"`
+	Concurrency *ConcurrencyGroup `bson:"concurrency,omitempty" yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+}
+
+// ConcurrencyGroup limits the running tasks of the workflows sharing the same group name.
+type ConcurrencyGroup struct {
+	Name string `bson:"name"               yaml:"name"               json:"name"`
+	// Limit is the max number of running tasks in the group, default is 1.
+	Limit int `bson:"limit"              yaml:"limit"              json:"limit"`
+	// CancelInProgress cancels the tasks of the group still waiting in the queue when a new task is created,
+	// the tasks already running are not cancelled.
+	CancelInProgress bool `bson:"cancel_in_progress" yaml:"cancel_in_progress" json:"cancel_in_progress"`
+}
+
+func (g *ConcurrencyGroup) GetLimit() int {
+	if g.Limit <= 0 {
+		return 1
+	}
+	return g.Limit
 }
 
 type WorkflowStage struct {
@@ -80,6 +101,8 @@ type LarkApproval struct {
 	Timeout      int                 `bson:"timeout"                     yaml:"timeout"                    json:"timeout"`
 	ApprovalID   string              `bson:"approval_id"                 yaml:"approval_id"                json:"approval_id"`
 	ApproveUsers []*LarkApprovalUser `bson:"approve_users"               yaml:"approve_users"              json:"approve_users"`
+	// InstanceCode is the lark approval instance created by the task, used to resume the approval after aslan restarted
+	InstanceCode string `bson:"instance_code"               yaml:"-"                          json:"instance_code"`
 }
 
 type LarkApprovalUser struct {
@@ -103,7 +126,12 @@ type Job struct {
 	// only for webhook workflow args to skip some tasks.
 	Skipped   bool                `bson:"skipped"      yaml:"skipped"    json:"skipped"`
 	RunPolicy config.JobRunPolicy `bson:"run_policy"   yaml:"run_policy" json:"run_policy"`
-	Spec      interface{}         `bson:"spec"         yaml:"spec"       json:"spec"`
+	// DependsOn is the names of the jobs this job waits for, it can refer to jobs in other stages.
+	// a job without DependsOn waits for the jobs in the previous stage as before.
+	DependsOn []string `bson:"depends_on"   yaml:"depends_on,omitempty" json:"depends_on,omitempty"`
+	// When is an expression evaluated before the job runs, the job is skipped if it is false.
+	When string      `bson:"when"         yaml:"when,omitempty"       json:"when,omitempty"`
+	Spec interface{} `bson:"spec"         yaml:"spec"       json:"spec"`
 }
 
 type CustomDeployJobSpec struct {
@@ -133,11 +161,41 @@ type FreestyleJobSpec struct {
 	Properties *JobProperties `bson:"properties"     yaml:"properties"    json:"properties"`
 	Steps      []*Step        `bson:"steps"          yaml:"steps"         json:"steps"`
 	Outputs    []*Output      `bson:"outputs"        yaml:"outputs"       json:"outputs"`
+	Matrix     *JobMatrix     `bson:"matrix"         yaml:"matrix,omitempty"  json:"matrix,omitempty"`
 }
 
 type ZadigBuildJobSpec struct {
 	DockerRegistryID string             `bson:"docker_registry_id"     yaml:"docker_registry_id"     json:"docker_registry_id"`
 	ServiceAndBuilds []*ServiceAndBuild `bson:"service_and_builds"     yaml:"service_and_builds"     json:"service_and_builds"`
+	Matrix           *JobMatrix         `bson:"matrix"                 yaml:"matrix,omitempty"       json:"matrix,omitempty"`
+	Retry            int64              `bson:"retry"                  yaml:"retry,omitempty"        json:"retry,omitempty"`
+	RetryPolicy      *RetryPolicy       `bson:"retry_policy"           yaml:"retry_policy,omitempty" json:"retry_policy,omitempty"`
+	Attestation      *BuildAttestation  `bson:"attestation"            yaml:"attestation,omitempty"  json:"attestation,omitempty"`
+}
+
+// BuildAttestation generates the SBOM of the built images and signs them after they are pushed.
+// The attestation is pushed with the docker daemon, so the builds using the buildkit builder are not supported.
+type BuildAttestation struct {
+	Enabled bool `bson:"enabled"        yaml:"enabled"       json:"enabled"`
+	// SBOMFormat is spdx-json or cyclonedx-json.
+	SBOMFormat string `bson:"sbom_format"    yaml:"sbom_format"   json:"sbom_format"`
+	// SBOMSource is image or workspace, the SBOM is generated from the pushed image by default.
+	SBOMSource string `bson:"sbom_source"    yaml:"sbom_source"   json:"sbom_source"`
+	// SigningKeyID is the id of the private key used to sign the images.
+	SigningKeyID string `bson:"signing_key_id" yaml:"signing_key_id" json:"signing_key_id"`
+}
+
+// JobMatrix runs a job once for every combination of the axis values, the values are set as env vars of the job.
+type JobMatrix struct {
+	Axes []*MatrixAxis `bson:"axes"           yaml:"axes"          json:"axes"`
+	// FailFast cancels the other jobs of the matrix once one of them failed.
+	FailFast bool `bson:"fail_fast"      yaml:"fail_fast"     json:"fail_fast"`
+}
+
+type MatrixAxis struct {
+	// Name is the env var name of the axis.
+	Name   string   `bson:"name"           yaml:"name"          json:"name"`
+	Values []string `bson:"values"         yaml:"values"        json:"values"`
 }
 
 type ServiceAndBuild struct {
@@ -146,9 +204,12 @@ type ServiceAndBuild struct {
 	BuildName        string              `bson:"build_name"          yaml:"build_name"       json:"build_name"`
 	Image            string              `bson:"-"                   yaml:"-"                json:"image"`
 	Package          string              `bson:"-"                   yaml:"-"                json:"package"`
+	ImageDigest      string              `bson:"-"                   yaml:"-"                json:"image_digest,omitempty"`
 	KeyVals          []*KeyVal           `bson:"key_vals"            yaml:"key_vals"         json:"key_vals"`
 	Repos            []*types.Repository `bson:"repos"               yaml:"repos"            json:"repos"`
 	ShareStorageInfo *ShareStorageInfo   `bson:"share_storage_info"   yaml:"share_storage_info"   json:"share_storage_info"`
+	// MatrixImages is the image of every matrix cell keyed by the job task key, Image is the first of them.
+	MatrixImages []*KeyVal `bson:"-"                   yaml:"-"                json:"matrix_images,omitempty"`
 }
 
 type ZadigDeployJobSpec struct {
@@ -166,6 +227,9 @@ type ServiceAndImage struct {
 	ServiceName   string `bson:"service_name"        yaml:"service_name"     json:"service_name"`
 	ServiceModule string `bson:"service_module"      yaml:"service_module"   json:"service_module"`
 	Image         string `bson:"image"               yaml:"image"            json:"image"`
+	// ImageDigest pins the deployed image to the digest pushed by the build job, it is empty if the
+	// image is not built by buildkit.
+	ImageDigest string `bson:"image_digest,omitempty"   yaml:"image_digest,omitempty"   json:"image_digest,omitempty"`
 }
 
 type ZadigDistributeImageJobSpec struct {
@@ -195,6 +259,8 @@ type DistributeTarget struct {
 
 type ZadigTestingJobSpec struct {
 	TestModules []*TestModule `bson:"test_modules"     yaml:"test_modules"     json:"test_modules"`
+	Retry       int64         `bson:"retry"            yaml:"retry,omitempty"  json:"retry,omitempty"`
+	RetryPolicy *RetryPolicy  `bson:"retry_policy"     yaml:"retry_policy,omitempty" json:"retry_policy,omitempty"`
 }
 
 type TestModule struct {
@@ -374,6 +440,16 @@ type MeegoWorkItemTransition struct {
 	Status         string `bson:"status"           json:"status"           yaml:"status,omitempty"`
 }
 
+type ManualGateJobSpec struct {
+	Description string `bson:"description"  json:"description"  yaml:"description"`
+	// Timeout is the minutes to wait for the input, default is 60.
+	Timeout int64 `bson:"timeout"      json:"timeout"      yaml:"timeout"`
+	// Users can submit the input, at least one user is required.
+	Users []*User `bson:"users"        json:"users"        yaml:"users"`
+	// Params are the inputs of the gate, they are saved as the outputs of the job.
+	Params []*Param `bson:"params"       json:"params"       yaml:"params"`
+}
+
 type IstioJobTarget struct {
 	WorkloadName       string `bson:"workload_name"             json:"workload_name"             yaml:"workload_name"`
 	ContainerName      string `bson:"container_name"            json:"container_name"            yaml:"container_name"`
@@ -394,6 +470,7 @@ type NacosJobSpec struct {
 type JobProperties struct {
 	Timeout         int64               `bson:"timeout"                json:"timeout"               yaml:"timeout"`
 	Retry           int64               `bson:"retry"                  json:"retry"                 yaml:"retry"`
+	RetryPolicy     *RetryPolicy        `bson:"retry_policy,omitempty" json:"retry_policy,omitempty" yaml:"retry_policy,omitempty"`
 	ResourceRequest setting.Request     `bson:"res_req"                json:"res_req"               yaml:"res_req"`
 	ResReqSpec      setting.RequestSpec `bson:"res_req_spec"           json:"res_req_spec"          yaml:"res_req_spec"`
 	ClusterID       string              `bson:"cluster_id"             json:"cluster_id"            yaml:"cluster_id"`
@@ -413,21 +490,67 @@ type JobProperties struct {
 	CacheEnable         bool                 `bson:"cache_enable"           json:"cache_enable"          yaml:"cache_enable"`
 	CacheDirType        types.CacheDirType   `bson:"cache_dir_type"         json:"cache_dir_type"        yaml:"cache_dir_type"`
 	CacheUserDir        string               `bson:"cache_user_dir"         json:"cache_user_dir"        yaml:"cache_user_dir"`
+	DependencyCache     *types.Cache         `bson:"dependency_cache,omitempty" json:"dependency_cache,omitempty" yaml:"-"`
 	ShareStorageInfo    *ShareStorageInfo    `bson:"share_storage_info"     json:"share_storage_info"    yaml:"share_storage_info"`
 	ShareStorageDetails []*StorageDetail     `bson:"share_storage_details"  json:"share_storage_details" yaml:"-"`
 	UseHostDockerDaemon bool                 `bson:"use_host_docker_daemon,omitempty" json:"use_host_docker_daemon,omitempty" yaml:"use_host_docker_daemon"`
+	Services            []*ServiceContainer  `bson:"services,omitempty"     json:"services,omitempty"    yaml:"services,omitempty"`
+}
+
+// ServiceContainer is a service such as MySQL or Redis running next to the job container in the same pod,
+// steps reach it by localhost and the ports, which are exposed to steps as <NAME>_HOST and <NAME>_PORT variables.
+// Steps start after all the ports of the services accept connections.
+type ServiceContainer struct {
+	Name    string    `bson:"name"              json:"name"              yaml:"name"`
+	Image   string    `bson:"image"             json:"image"             yaml:"image"`
+	Command []string  `bson:"command,omitempty" json:"command,omitempty" yaml:"command,omitempty"`
+	Args    []string  `bson:"args,omitempty"    json:"args,omitempty"    yaml:"args,omitempty"`
+	Envs    []*KeyVal `bson:"envs"              json:"envs"              yaml:"envs"`
+	Ports   []int32   `bson:"ports"             json:"ports"             yaml:"ports"`
+	// ReadinessCommand is run in the service container to probe the readiness, the first port is probed if it is empty.
+	ReadinessCommand string              `bson:"readiness_command"  json:"readiness_command"  yaml:"readiness_command"`
+	ResReqSpec       setting.RequestSpec `bson:"res_req_spec"       json:"res_req_spec"       yaml:"res_req_spec"`
+}
+
+// ServiceContainerNames returns the names of the services.
+func ServiceContainerNames(services []*ServiceContainer) []string {
+	names := make([]string, 0, len(services))
+	for _, svc := range services {
+		names = append(names, svc.Name)
+	}
+	return names
+}
+
+// RetryPolicy decides when and how long to wait before a failed job runs again, the max retry count is JobProperties.Retry.
+type RetryPolicy struct {
+	// Interval is the seconds to wait before the first retry, it is doubled for every following retry.
+	Interval int64 `bson:"interval"        json:"interval"        yaml:"interval"`
+	// MaxInterval is the max seconds to wait before a retry.
+	MaxInterval int64 `bson:"max_interval"    json:"max_interval"    yaml:"max_interval"`
+	// RetryOn is the failure classes to retry, all failures are retried if it is empty.
+	RetryOn []config.FailureClass `bson:"retry_on"        json:"retry_on"        yaml:"retry_on"`
 }
 
 type Step struct {
 	Name     string          `bson:"name"           json:"name"             yaml:"name"`
 	Timeout  int64           `bson:"timeout"        json:"timeout"          yaml:"timeout"`
 	StepType config.StepType `bson:"type"           json:"type"             yaml:"type"`
-	Spec     interface{}     `bson:"spec"           json:"spec"             yaml:"spec"`
+	// Retry is the max times the step runs again after it fails or times out.
+	Retry int `bson:"retry,omitempty"             json:"retry,omitempty"             yaml:"retry,omitempty"`
+	// ContinueOnError keeps the job running and passed when the step fails.
+	ContinueOnError bool `bson:"continue_on_error,omitempty" json:"continue_on_error,omitempty" yaml:"continue_on_error,omitempty"`
+	// WorkingDir is relative to the workspace, the step runs in the workspace if it is empty.
+	WorkingDir string `bson:"working_dir,omitempty"       json:"working_dir,omitempty"       yaml:"working_dir,omitempty"`
+	// When is an expression evaluated before the job runs, the step is skipped if it is false.
+	When string      `bson:"when"           json:"when,omitempty"   yaml:"when,omitempty"`
+	Spec interface{} `bson:"spec"           json:"spec"             yaml:"spec"`
 }
 
 type Output struct {
 	Name        string `bson:"name"           json:"name"             yaml:"name"`
 	Description string `bson:"description"    json:"description"      yaml:"description"`
+	// Type is one of string, json and file, default is string.
+	Type job.OutputType `bson:"type,omitempty" json:"type,omitempty"   yaml:"type,omitempty"`
 }
 
 type WorkflowV4Hook struct {
@@ -439,6 +562,17 @@ type WorkflowV4Hook struct {
 	Description         string              `bson:"description,omitempty"     json:"description,omitempty"`
 	Repos               []*types.Repository `bson:"-"                         json:"repos,omitempty"`
 	WorkflowArg         *WorkflowV4         `bson:"workflow_arg"              json:"workflow_arg"`
+	PreviewEnv          *PreviewEnvSetting  `bson:"preview_env,omitempty"     json:"preview_env,omitempty"`
+}
+
+// PreviewEnvSetting deploys the pull request into a sub environment of BaseEnv which only contains the changed services.
+type PreviewEnvSetting struct {
+	Enabled bool   `bson:"enabled"                   json:"enabled"`
+	BaseEnv string `bson:"base_env"                  json:"base_env"`
+	// IdleTTL is in hours, the preview environment is deleted if no task is triggered during the period
+	IdleTTL int64 `bson:"idle_ttl"                  json:"idle_ttl"`
+	// URLTemplate supports {{.EnvName}}, {{.BaseEnv}}, {{.PR}} and {{.ProjectName}}, the environment page is used if it is empty
+	URLTemplate string `bson:"url_template"              json:"url_template"`
 }
 
 type JiraHook struct {
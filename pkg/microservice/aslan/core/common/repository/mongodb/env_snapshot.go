@@ -0,0 +1,134 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type EnvSnapshotColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewEnvSnapshotColl() *EnvSnapshotColl {
+	name := models.EnvSnapshot{}.TableName()
+	return &EnvSnapshotColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *EnvSnapshotColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *EnvSnapshotColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys: bson.D{
+			bson.E{Key: "product_name", Value: 1},
+			bson.E{Key: "env_name", Value: 1},
+			bson.E{Key: "revision", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+// Create sets the next revision of the environment to the snapshot and inserts it.
+func (c *EnvSnapshotColl) Create(args *models.EnvSnapshot) error {
+	rev, err := NewCounterColl().GetNextSeq(fmt.Sprintf("env_snapshot:%s:%s", args.ProductName, args.EnvName))
+	if err != nil {
+		return err
+	}
+	args.Revision = rev
+	args.CreateTime = time.Now().Unix()
+	_, err = c.InsertOne(context.TODO(), args)
+	return err
+}
+
+func (c *EnvSnapshotColl) Find(productName, envName string, revision int64) (*models.EnvSnapshot, error) {
+	resp := new(models.EnvSnapshot)
+	query := bson.M{"product_name": productName, "env_name": envName, "revision": revision}
+	return resp, c.FindOne(context.TODO(), query).Decode(resp)
+}
+
+// FindLatest returns nil if the environment has no snapshots.
+func (c *EnvSnapshotColl) FindLatest(productName, envName string) (*models.EnvSnapshot, error) {
+	resp := new(models.EnvSnapshot)
+	query := bson.M{"product_name": productName, "env_name": envName}
+	err := c.FindOne(context.TODO(), query, options.FindOne().SetSort(bson.D{{"revision", -1}})).Decode(resp)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return resp, err
+}
+
+// List returns the snapshots of the environment from the newest, all snapshots are returned if pageSize is 0.
+func (c *EnvSnapshotColl) List(productName, envName string, page, pageSize int) ([]*models.EnvSnapshot, int64, error) {
+	resp := make([]*models.EnvSnapshot, 0)
+	query := bson.M{"product_name": productName, "env_name": envName}
+	opts := options.Find().SetSort(bson.D{{"revision", -1}})
+	if pageSize > 0 {
+		opts.SetSkip(int64((page - 1) * pageSize)).SetLimit(int64(pageSize))
+	}
+
+	cursor, err := c.Collection.Find(context.TODO(), query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := cursor.All(context.TODO(), &resp); err != nil {
+		return nil, 0, err
+	}
+	count, err := c.CountDocuments(context.TODO(), query)
+	return resp, count, err
+}
+
+// Prune keeps the latest snapshots of the environment and deletes the older ones.
+func (c *EnvSnapshotColl) Prune(productName, envName string, keep int) error {
+	query := bson.M{"product_name": productName, "env_name": envName}
+	oldest := new(models.EnvSnapshot)
+	opts := options.FindOne().SetSort(bson.D{{"revision", -1}}).SetSkip(int64(keep - 1))
+	if err := c.FindOne(context.TODO(), query, opts).Decode(oldest); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return err
+	}
+	query["revision"] = bson.M{"$lt": oldest.Revision}
+	_, err := c.DeleteMany(context.TODO(), query)
+	return err
+}
+
+func (c *EnvSnapshotColl) Delete(productName, envName string) error {
+	query := bson.M{"product_name": productName, "env_name": envName}
+	_, err := c.DeleteMany(context.TODO(), query)
+	return err
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type ImageAttestationColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewImageAttestationColl() *ImageAttestationColl {
+	name := models.ImageAttestation{}.TableName()
+	return &ImageAttestationColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *ImageAttestationColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *ImageAttestationColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys:    bson.D{{"image", 1}, {"create_time", -1}},
+		Options: options.Index().SetUnique(false),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+
+	return err
+}
+
+func (c *ImageAttestationColl) Create(obj *models.ImageAttestation) error {
+	if obj == nil {
+		return fmt.Errorf("nil object")
+	}
+
+	_, err := c.InsertOne(context.TODO(), obj)
+	return err
+}
+
+// FindLatest returns the latest attestation of the image, an image tag may be pushed more than once.
+func (c *ImageAttestationColl) FindLatest(image string) (*models.ImageAttestation, error) {
+	resp := new(models.ImageAttestation)
+	opt := options.FindOne().SetSort(bson.D{{"create_time", -1}})
+	if err := c.FindOne(context.TODO(), bson.M{"image": image}, opt).Decode(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type LeaseColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewLeaseColl() *LeaseColl {
+	name := models.Lease{}.TableName()
+	return &LeaseColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *LeaseColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *LeaseColl) EnsureIndex(_ context.Context) error {
+	return nil
+}
+
+// Acquire tries to get or renew the lease for the holder, it returns false if the lease
+// is held by others and not expired yet.
+func (c *LeaseColl) Acquire(name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	query := bson.M{
+		"_id": name,
+		"$or": []bson.M{
+			{"holder": holder},
+			{"expire_time": bson.M{"$lt": now.Unix()}},
+		},
+	}
+	change := bson.M{"$set": bson.M{
+		"holder":      holder,
+		"expire_time": now.Add(ttl).Unix(),
+	}}
+
+	_, err := c.UpdateOne(context.TODO(), query, change, options.Update().SetUpsert(true))
+	if err != nil {
+		// the lease exists and is held by others, so the upsert conflicts with the existing _id.
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Release gives up the lease if it is held by the holder.
+func (c *LeaseColl) Release(name, holder string) error {
+	query := bson.M{"_id": name, "holder": holder}
+	_, err := c.DeleteOne(context.TODO(), query)
+	return err
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type PreviewEnvListOption struct {
+	ProductName string
+	RepoOwner   string
+	RepoName    string
+	PR          int
+}
+
+type PreviewEnvColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewPreviewEnvColl() *PreviewEnvColl {
+	name := models.PreviewEnv{}.TableName()
+	return &PreviewEnvColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *PreviewEnvColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *PreviewEnvColl) EnsureIndex(ctx context.Context) error {
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "product_name", Value: 1},
+				bson.E{Key: "env_name", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "repo_owner", Value: 1},
+				bson.E{Key: "repo_name", Value: 1},
+				bson.E{Key: "pr", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+	}
+
+	_, err := c.Indexes().CreateMany(ctx, mod)
+	return err
+}
+
+// Find returns nil if the preview environment does not exist.
+func (c *PreviewEnvColl) Find(productName, envName string) (*models.PreviewEnv, error) {
+	resp := new(models.PreviewEnv)
+	query := bson.M{"product_name": productName, "env_name": envName}
+	err := c.FindOne(context.TODO(), query).Decode(resp)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return resp, err
+}
+
+func (c *PreviewEnvColl) Create(args *models.PreviewEnv) error {
+	args.ID = primitive.NilObjectID
+	_, err := c.InsertOne(context.TODO(), args)
+	return err
+}
+
+func (c *PreviewEnvColl) Update(args *models.PreviewEnv) error {
+	query := bson.M{"_id": args.ID}
+	change := bson.M{"$set": bson.M{
+		"services":         args.Services,
+		"url":              args.URL,
+		"notification_id":  args.NotificationID,
+		"idle_ttl":         args.IdleTTL,
+		"last_active_time": args.LastActiveTime,
+	}}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
+func (c *PreviewEnvColl) List(opt *PreviewEnvListOption) ([]*models.PreviewEnv, error) {
+	resp := make([]*models.PreviewEnv, 0)
+	query := bson.M{}
+	if opt.ProductName != "" {
+		query["product_name"] = opt.ProductName
+	}
+	if opt.RepoOwner != "" {
+		query["repo_owner"] = opt.RepoOwner
+	}
+	if opt.RepoName != "" {
+		query["repo_name"] = opt.RepoName
+	}
+	if opt.PR > 0 {
+		query["pr"] = opt.PR
+	}
+	cursor, err := c.Collection.Find(context.TODO(), query, options.Find().SetSort(bson.D{{"create_time", -1}}))
+	if err != nil {
+		return nil, err
+	}
+	return resp, cursor.All(context.TODO(), &resp)
+}
+
+func (c *PreviewEnvColl) Delete(productName, envName string) error {
+	query := bson.M{"product_name": productName, "env_name": envName}
+	_, err := c.DeleteOne(context.TODO(), query)
+	return err
+}
@@ -448,6 +448,36 @@ func (c *ProductColl) UpdateProductRecycleDay(envName, productName string, recyc
 	return err
 }
 
+func (c *ProductColl) UpdateSleepSchedule(envName, productName string, schedule *models.EnvSleepSchedule) error {
+	query := bson.M{"env_name": envName, "product_name": productName}
+
+	change := bson.M{"$set": bson.M{
+		"sleep_schedule": schedule,
+	}}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+
+	return err
+}
+
+// UpdateSleepState updates the status and sleep state of an environment at the same time, state is removed if it is nil
+func (c *ProductColl) UpdateSleepState(envName, productName, status string, state *models.EnvSleepState) error {
+	query := bson.M{"env_name": envName, "product_name": productName}
+
+	change := bson.M{"$set": bson.M{
+		"status":      status,
+		"sleep_state": state,
+	}}
+	if state == nil {
+		change = bson.M{
+			"$set":   bson.M{"status": status},
+			"$unset": bson.M{"sleep_state": ""},
+		}
+	}
+	_, err := c.UpdateOne(context.TODO(), query, change)
+
+	return err
+}
+
 func (c *ProductColl) UpdateProductAlias(envName, productName, alias string) error {
 	query := bson.M{"env_name": envName, "product_name": productName}
 
@@ -350,6 +350,21 @@ func (c *ServiceColl) UpdateServiceVariables(args *models.Service) error {
 	return err
 }
 
+// UpdateServiceDependencies updates the dependencies of all the revisions of the service, so that the environments
+// running the earlier revisions follow them too
+func (c *ServiceColl) UpdateServiceDependencies(args *models.Service) error {
+	if args == nil {
+		return errors.New("nil ServiceTmplObject")
+	}
+	args.ProductName = strings.TrimSpace(args.ProductName)
+	args.ServiceName = strings.TrimSpace(args.ServiceName)
+
+	query := bson.M{"product_name": args.ProductName, "service_name": args.ServiceName}
+	change := bson.M{"$set": bson.M{"depends_on": args.DependsOn}}
+	_, err := c.UpdateMany(context.TODO(), query, change)
+	return err
+}
+
 func (c *ServiceColl) UpdateServiceContainers(args *models.Service) error {
 	if args == nil {
 		return errors.New("nil ServiceTmplObject")
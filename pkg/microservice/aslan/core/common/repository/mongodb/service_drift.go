@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type ServiceDriftColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewServiceDriftColl() *ServiceDriftColl {
+	name := models.ServiceDrift{}.TableName()
+	return &ServiceDriftColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *ServiceDriftColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *ServiceDriftColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys: bson.D{
+			bson.E{Key: "product_name", Value: 1},
+			bson.E{Key: "env_name", Value: 1},
+			bson.E{Key: "service_name", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+	return err
+}
+
+// Upsert replaces the drift report of the service.
+func (c *ServiceDriftColl) Upsert(args *models.ServiceDrift) error {
+	query := bson.M{"product_name": args.ProductName, "env_name": args.EnvName, "service_name": args.ServiceName}
+	args.ID = primitive.NilObjectID
+	_, err := c.ReplaceOne(context.TODO(), query, args, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (c *ServiceDriftColl) List(productName, envName string) ([]*models.ServiceDrift, error) {
+	resp := make([]*models.ServiceDrift, 0)
+	query := bson.M{"product_name": productName, "env_name": envName}
+	cursor, err := c.Collection.Find(context.TODO(), query, options.Find().SetSort(bson.D{{"service_name", 1}}))
+	if err != nil {
+		return nil, err
+	}
+	return resp, cursor.All(context.TODO(), &resp)
+}
+
+// DeleteStale removes the reports of the services no longer in the environment.
+func (c *ServiceDriftColl) DeleteStale(productName, envName string, serviceNames []string) error {
+	query := bson.M{"product_name": productName, "env_name": envName, "service_name": bson.M{"$nin": serviceNames}}
+	_, err := c.DeleteMany(context.TODO(), query)
+	return err
+}
+
+// Delete removes all the reports of the environment.
+func (c *ServiceDriftColl) Delete(productName, envName string) error {
+	query := bson.M{"product_name": productName, "env_name": envName}
+	_, err := c.DeleteMany(context.TODO(), query)
+	return err
+}
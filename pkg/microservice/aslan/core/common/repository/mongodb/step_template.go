@@ -0,0 +1,111 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type StepTemplateColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewStepTemplateColl() *StepTemplateColl {
+	name := models.StepTemplate{}.TableName()
+	return &StepTemplateColl{
+		Collection: mongotool.Database(config.MongoDatabase()).Collection(name),
+		coll:       name,
+	}
+}
+
+func (c *StepTemplateColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *StepTemplateColl) EnsureIndex(ctx context.Context) error {
+	mod := mongo.IndexModel{
+		Keys:    bson.D{{"name", 1}, {"version", 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := c.Indexes().CreateOne(ctx, mod)
+
+	return err
+}
+
+func (c *StepTemplateColl) Create(obj *models.StepTemplate) error {
+	if obj == nil {
+		return fmt.Errorf("nil object")
+	}
+
+	_, err := c.InsertOne(context.TODO(), obj)
+	return err
+}
+
+// Find returns the given version of the template, or the latest version if the version is not positive.
+func (c *StepTemplateColl) Find(name string, version int64) (*models.StepTemplate, error) {
+	resp := new(models.StepTemplate)
+	query := bson.M{"name": name}
+	opt := options.FindOne().SetSort(bson.D{{"version", -1}})
+	if version > 0 {
+		query["version"] = version
+	}
+
+	err := c.FindOne(context.TODO(), query, opt).Decode(resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// List returns all versions of the templates sorted by name and version in descending order,
+// all versions of a template are listed if the name is not empty.
+func (c *StepTemplateColl) List(name string) ([]*models.StepTemplate, error) {
+	resp := make([]*models.StepTemplate, 0)
+	query := bson.M{}
+	if name != "" {
+		query["name"] = name
+	}
+	opt := options.Find().SetSort(bson.D{{"name", 1}, {"version", -1}})
+
+	cursor, err := c.Collection.Find(context.TODO(), query, opt)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.TODO(), &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// DeleteByName deletes all versions of the template.
+func (c *StepTemplateColl) DeleteByName(name string) error {
+	_, err := c.DeleteMany(context.TODO(), bson.M{"name": name})
+	return err
+}
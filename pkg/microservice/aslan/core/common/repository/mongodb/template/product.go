@@ -306,6 +306,7 @@ func (c *ProductColl) Update(productName string, args *template.Product) error {
 		"custom_image_rule":     args.CustomImageRule,
 		"delivery_version_hook": args.DeliveryVersionHook,
 		"public":                args.Public,
+		"workflow_task_quota":   args.WorkflowTaskQuota,
 	}}
 
 	_, err := c.UpdateOne(context.TODO(), query, change)
@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	mongotool "github.com/koderover/zadig/pkg/tool/mongo"
+)
+
+type TestJobStatOption struct {
+	TestName     string
+	ProjectNames []string
+	StartTime    int64
+	EndTime      int64
+	WithCoverage bool
+}
+
+type TestJobStatColl struct {
+	*mongo.Collection
+
+	coll string
+}
+
+func NewTestJobStatColl() *TestJobStatColl {
+	name := models.TestJobStat{}.TableName()
+	return &TestJobStatColl{Collection: mongotool.Database(config.MongoDatabase()).Collection(name), coll: name}
+}
+
+func (c *TestJobStatColl) GetCollectionName() string {
+	return c.coll
+}
+
+func (c *TestJobStatColl) EnsureIndex(ctx context.Context) error {
+	mod := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				bson.E{Key: "test_name", Value: 1},
+				bson.E{Key: "create_time", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+		{
+			Keys: bson.D{
+				bson.E{Key: "project_name", Value: 1},
+				bson.E{Key: "create_time", Value: 1},
+			},
+			Options: options.Index().SetUnique(false),
+		},
+	}
+
+	_, err := c.Indexes().CreateMany(ctx, mod)
+	return err
+}
+
+func (c *TestJobStatColl) Create(args *models.TestJobStat) error {
+	if args == nil {
+		return errors.New("nil testJobStat args")
+	}
+
+	_, err := c.InsertOne(context.TODO(), args)
+	return err
+}
+
+// List returns the stats sorted by create time in ascending order.
+func (c *TestJobStatColl) List(opt *TestJobStatOption) ([]*models.TestJobStat, error) {
+	resp := make([]*models.TestJobStat, 0)
+	query := bson.M{}
+	if opt.TestName != "" {
+		query["test_name"] = opt.TestName
+	}
+	if len(opt.ProjectNames) > 0 {
+		query["project_name"] = bson.M{"$in": opt.ProjectNames}
+	}
+	if opt.StartTime > 0 || opt.EndTime > 0 {
+		timeQuery := bson.M{}
+		if opt.StartTime > 0 {
+			timeQuery["$gte"] = opt.StartTime
+		}
+		if opt.EndTime > 0 {
+			timeQuery["$lte"] = opt.EndTime
+		}
+		query["create_time"] = timeQuery
+	}
+	if opt.WithCoverage {
+		query["coverage"] = bson.M{"$exists": true}
+	}
+
+	findOption := options.Find().SetSort(bson.D{{"create_time", 1}})
+	cursor, err := c.Collection.Find(context.TODO(), query, findOption)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.TODO(), &resp)
+	return resp, err
+}
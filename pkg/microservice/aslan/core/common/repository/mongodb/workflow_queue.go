@@ -19,6 +19,7 @@ package mongodb
 import (
 	"context"
 	"errors"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -124,3 +125,77 @@ func (c *WorkflowQueueColl) Update(args *models.WorkflowQueue) error {
 	_, err := c.UpdateOne(context.TODO(), query, change)
 	return err
 }
+
+// AcquireLease sets the owner of the queue task if it has no owner or the lease of the previous owner expired.
+func (c *WorkflowQueueColl) AcquireLease(args *models.WorkflowQueue, owner string, ttl time.Duration) (bool, error) {
+	if args == nil {
+		return false, errors.New("nil workflow queue")
+	}
+
+	now := time.Now()
+	query := bson.M{
+		"task_id":       args.TaskID,
+		"workflow_name": args.WorkflowName,
+		"create_time":   args.CreateTime,
+		"$or": []bson.M{
+			{"owner": bson.M{"$exists": false}},
+			{"owner": ""},
+			{"lease_expire_time": bson.M{"$lt": now.Unix()}},
+		},
+	}
+	change := bson.M{"$set": bson.M{
+		"owner":             owner,
+		"lease_expire_time": now.Add(ttl).Unix(),
+	}}
+
+	res, err := c.UpdateOne(context.TODO(), query, change)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+// RenewLease extends the lease of the queue task, it returns false if the task is owned by others now.
+func (c *WorkflowQueueColl) RenewLease(args *models.WorkflowQueue, owner string, ttl time.Duration) (bool, error) {
+	if args == nil {
+		return false, errors.New("nil workflow queue")
+	}
+
+	query := bson.M{"task_id": args.TaskID, "workflow_name": args.WorkflowName, "owner": owner}
+	change := bson.M{"$set": bson.M{
+		"lease_expire_time": time.Now().Add(ttl).Unix(),
+	}}
+
+	res, err := c.UpdateOne(context.TODO(), query, change)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// ListClaimable lists the queue tasks in the given status which have no owner or whose owner's lease expired.
+func (c *WorkflowQueueColl) ListClaimable(status []config.Status) ([]*models.WorkflowQueue, error) {
+	query := bson.M{
+		"status": bson.M{"$in": status},
+		"$or": []bson.M{
+			{"owner": bson.M{"$exists": false}},
+			{"owner": ""},
+			{"lease_expire_time": bson.M{"$lt": time.Now().Unix()}},
+		},
+	}
+
+	var resp []*models.WorkflowQueue
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{"create_time", 1}})
+	cursor, err := c.Collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cursor.All(ctx, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
@@ -184,7 +184,7 @@ func (c *WorkflowTaskv4Coll) FindTodoTasksByWorkflowName(workflowName string) ([
 
 func (c *WorkflowTaskv4Coll) InCompletedTasks() ([]*models.WorkflowTask, error) {
 	ret := make([]*models.WorkflowTask, 0)
-	query := bson.M{"status": bson.M{"$in": []string{"created", "running"}}}
+	query := bson.M{"status": bson.M{"$in": []string{"created", "running", "queued", "waitforapprove", "paused"}}}
 	query["is_deleted"] = false
 
 	opt := options.Find()
@@ -242,6 +242,15 @@ func (c *WorkflowTaskv4Coll) Update(idString string, obj *models.WorkflowTask) e
 	return err
 }
 
+// AddRetryTask records the task re-running the failed jobs of the task.
+func (c *WorkflowTaskv4Coll) AddRetryTask(workflowName string, taskID, retryTaskID int64) error {
+	query := bson.M{"workflow_name": workflowName, "task_id": taskID}
+	change := bson.M{"$push": bson.M{"retry_task_ids": retryTaskID}}
+
+	_, err := c.UpdateOne(context.TODO(), query, change)
+	return err
+}
+
 func (c *WorkflowTaskv4Coll) DeleteByWorkflowName(workflowName string) error {
 	query := bson.M{"workflow_name": workflowName}
 	change := bson.M{"$set": bson.M{
@@ -143,6 +143,23 @@ func (c *WorkflowV4Coll) ListByProjectNames(projects []string) ([]*models.Workfl
 	return resp, nil
 }
 
+// ListByStepTemplate lists the workflows which have template steps using the step template.
+func (c *WorkflowV4Coll) ListByStepTemplate(templateName string) ([]*models.WorkflowV4, error) {
+	resp := make([]*models.WorkflowV4, 0)
+	query := bson.M{"stages.jobs.spec.steps": bson.M{"$elemMatch": bson.M{
+		"type":               config.StepTemplate,
+		"spec.template_name": templateName,
+	}}}
+	cursor, err := c.Collection.Find(context.TODO(), query)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.TODO(), &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
 func (c *WorkflowV4Coll) BulkCreate(args []*models.WorkflowV4) error {
 	if len(args) == 0 {
 		return nil
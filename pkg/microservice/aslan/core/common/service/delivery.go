@@ -21,7 +21,9 @@ import (
 	"time"
 
 	"github.com/hashicorp/go-multierror"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
@@ -134,6 +136,8 @@ func GetSubTaskContent(deliveryVersion *commonmodels.DeliveryVersion, pipelineTa
 		return
 	}
 	stageArray := pipelineTask.Stages
+	// images whose attestations are already recorded with the builds
+	attestedImages := sets.NewString()
 	for _, subStage := range stageArray {
 		taskType := subStage.TaskType
 		taskStatus := subStage.Status
@@ -156,6 +160,7 @@ func GetSubTaskContent(deliveryVersion *commonmodels.DeliveryVersion, pipelineTa
 				deliveryBuild.EndTime = buildInfo.EndTime
 				imageName := buildInfo.JobCtx.Image
 				deliveryBuild.ImageName = imageName
+				deliveryBuild.Attestation = GetImageAttestation(imageName)
 				deliveryBuild.Commits = buildInfo.JobCtx.Builds
 				//packageInfo
 				if buildInfo.JobCtx.FileArchiveCtx != nil {
@@ -191,6 +196,9 @@ func GetSubTaskContent(deliveryVersion *commonmodels.DeliveryVersion, pipelineTa
 					log.Errorf("get buildInfo InsertDeliveryBuild failed ! err:%v", err)
 					continue
 				}
+				if deliveryBuild.Attestation != nil {
+					attestedImages.Insert(imageName)
+				}
 			}
 		case config.TaskDeploy:
 			if taskStatus != config.StatusPassed {
@@ -240,6 +248,10 @@ func GetSubTaskContent(deliveryVersion *commonmodels.DeliveryVersion, pipelineTa
 					log.Errorf("get deployInfo InsertDeliveryDeploy failed ! err:%v", err)
 					continue
 				}
+				// the deployed image may be built by another workflow
+				if !attestedImages.Has(deployInfo.Image) && InsertDeliveryAttestation(deliveryVersion.ID, deployInfo.ServiceName, deployInfo.Image, log) {
+					attestedImages.Insert(deployInfo.Image)
+				}
 			}
 			deliveryVersion.ProductEnvInfo = productEnvInfo
 			err = updateDeliveryVersion(deliveryVersion, log)
@@ -389,6 +401,38 @@ func getProductEnvInfo(pipelineTask *taskmodels.Task, log *zap.SugaredLogger) (*
 	return product, product.GetGroupServiceNames(), nil
 }
 
+// GetImageAttestation returns the latest attestation generated for the image, or nil if the image is not attested.
+func GetImageAttestation(image string) *commonmodels.DeliveryAttestation {
+	if image == "" {
+		return nil
+	}
+	attestation, err := commonrepo.NewImageAttestationColl().FindLatest(image)
+	if err != nil {
+		return nil
+	}
+	return &attestation.DeliveryAttestation
+}
+
+// InsertDeliveryAttestation records the attestation of the released image, it returns false if the image is not attested.
+func InsertDeliveryAttestation(releaseID primitive.ObjectID, serviceName, image string, log *zap.SugaredLogger) bool {
+	attestation := GetImageAttestation(image)
+	if attestation == nil {
+		return false
+	}
+	err := commonrepo.NewDeliveryBuildColl().Insert(&commonmodels.DeliveryBuild{
+		ReleaseID:   releaseID,
+		ServiceName: serviceName,
+		ImageName:   image,
+		Attestation: attestation,
+		CreatedAt:   time.Now().Unix(),
+	})
+	if err != nil {
+		log.Errorf("failed to insert attestation of image %s, err: %s", image, err)
+		return false
+	}
+	return true
+}
+
 func insertDeliveryBuild(args *commonmodels.DeliveryBuild, log *zap.SugaredLogger) error {
 	err := commonrepo.NewDeliveryBuildColl().Insert(args)
 	if err != nil {
@@ -0,0 +1,147 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envsnapshot
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/setting"
+)
+
+// envSnapshotLimit is the number of snapshots kept for an environment, the older ones are pruned.
+const envSnapshotLimit = 50
+
+// CreateEnvSnapshot saves the current state of the environment as a new snapshot,
+// nothing is saved if the environment is unchanged since the latest snapshot or it's being changed.
+func CreateEnvSnapshot(productName, envName, userName, description string, log *zap.SugaredLogger) error {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return fmt.Errorf("failed to find env %s/%s: %s", productName, envName, err)
+	}
+	if env.Source == setting.SourceFromExternal || env.Source == setting.SourceFromPM {
+		return nil
+	}
+	switch env.Status {
+	case setting.ProductStatusCreating, setting.ProductStatusUpdating, setting.ProductStatusDeleting:
+		return nil
+	}
+
+	snapshot := &models.EnvSnapshot{
+		ProductName:    productName,
+		EnvName:        envName,
+		EnvRevision:    env.Revision,
+		Source:         env.Source,
+		Services:       make([][]*models.ProductService, 0),
+		DeployStrategy: env.ServiceDeployStrategy,
+		EnvResources:   make([]*models.EnvSnapshotResource, 0),
+		Description:    description,
+		CreatedBy:      userName,
+	}
+	for _, group := range env.Services {
+		snapshotGroup := make([]*models.ProductService, 0)
+		for _, svc := range group {
+			snapshotGroup = append(snapshotGroup, &models.ProductService{
+				ServiceName: svc.ServiceName,
+				ProductName: svc.ProductName,
+				Type:        svc.Type,
+				Revision:    svc.Revision,
+				Containers:  svc.Containers,
+			})
+		}
+		snapshot.Services = append(snapshot.Services, snapshotGroup)
+	}
+
+	if env.Render != nil {
+		renderSet, err := commonrepo.NewRenderSetColl().Find(&commonrepo.RenderSetFindOption{
+			Name:        env.Render.Name,
+			Revision:    env.Render.Revision,
+			ProductTmpl: productName,
+			EnvName:     envName,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to find renderset %s/%d: %s", env.Render.Name, env.Render.Revision, err)
+		}
+		snapshot.RenderSet = &models.EnvSnapshotRenderSet{
+			Name:             renderSet.Name,
+			Revision:         renderSet.Revision,
+			DefaultValues:    renderSet.DefaultValues,
+			YamlData:         renderSet.YamlData,
+			ServiceVariables: renderSet.ServiceVariables,
+			ChartInfos:       renderSet.ChartInfos,
+		}
+	}
+
+	resources, err := commonrepo.NewEnvResourceColl().ListLatestResource(&commonrepo.QueryEnvResourceOption{ProductName: productName, EnvName: envName})
+	if err != nil {
+		return fmt.Errorf("failed to list env resources: %s", err)
+	}
+	for _, resource := range resources {
+		latest, err := commonrepo.NewEnvResourceColl().Find(&commonrepo.QueryEnvResourceOption{
+			ProductName: productName,
+			EnvName:     envName,
+			Name:        resource.ID.Name,
+			Type:        resource.ID.Type,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to find env resource %s/%s: %s", resource.ID.Type, resource.ID.Name, err)
+		}
+		snapshot.EnvResources = append(snapshot.EnvResources, &models.EnvSnapshotResource{
+			Type:     latest.Type,
+			Name:     latest.Name,
+			YamlData: latest.YamlData,
+		})
+	}
+
+	latest, err := commonrepo.NewEnvSnapshotColl().FindLatest(productName, envName)
+	if err != nil {
+		return fmt.Errorf("failed to find latest snapshot: %s", err)
+	}
+	if latest != nil && snapshotContent(latest) == snapshotContent(snapshot) {
+		return nil
+	}
+
+	if err := commonrepo.NewEnvSnapshotColl().Create(snapshot); err != nil {
+		return fmt.Errorf("failed to create snapshot: %s", err)
+	}
+	log.Infof("snapshot %d of env %s/%s is created", snapshot.Revision, productName, envName)
+	if err := commonrepo.NewEnvSnapshotColl().Prune(productName, envName, envSnapshotLimit); err != nil {
+		log.Errorf("failed to prune snapshots of env %s/%s: %s", productName, envName, err)
+	}
+	return nil
+}
+
+// snapshotContent returns the part of the snapshot which affects the environment.
+func snapshotContent(snapshot *models.EnvSnapshot) string {
+	content := struct {
+		Services       [][]*models.ProductService    `json:"services"`
+		DeployStrategy map[string]string             `json:"deploy_strategy"`
+		RenderSet      *models.EnvSnapshotRenderSet  `json:"render_set"`
+		EnvResources   []*models.EnvSnapshotResource `json:"env_resources"`
+	}{snapshot.Services, snapshot.DeployStrategy, nil, snapshot.EnvResources}
+	if snapshot.RenderSet != nil {
+		renderSet := *snapshot.RenderSet
+		renderSet.Revision = 0
+		content.RenderSet = &renderSet
+	}
+	bs, _ := json.Marshal(content)
+	return string(bs)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envsnapshot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func TestSnapshotContent(t *testing.T) {
+	newSnapshot := func() *models.EnvSnapshot {
+		return &models.EnvSnapshot{
+			ProductName: "demo",
+			EnvName:     "dev",
+			Revision:    1,
+			Services: [][]*models.ProductService{
+				{{ServiceName: "web", Revision: 1, Containers: []*models.Container{{Name: "web", Image: "web:v1"}}}},
+			},
+			DeployStrategy: map[string]string{"web": "deploy"},
+			RenderSet:      &models.EnvSnapshotRenderSet{Name: "dev", Revision: 3, DefaultValues: "replicas: 1"},
+			EnvResources:   []*models.EnvSnapshotResource{{Type: "ConfigMap", Name: "web-conf", YamlData: "a: 1"}},
+			Description:    "first",
+			CreatedBy:      "admin",
+			CreateTime:     1672531200,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(snapshot *models.EnvSnapshot)
+		equal  bool
+	}{
+		{
+			name: "metadata and renderset revision are ignored",
+			modify: func(snapshot *models.EnvSnapshot) {
+				snapshot.Revision = 2
+				snapshot.RenderSet.Revision = 4
+				snapshot.Description = "second"
+				snapshot.CreatedBy = "someone"
+				snapshot.CreateTime = 1672617600
+			},
+			equal: true,
+		},
+		{
+			name: "image changed",
+			modify: func(snapshot *models.EnvSnapshot) {
+				snapshot.Services[0][0].Containers[0].Image = "web:v2"
+			},
+		},
+		{
+			name: "variables changed",
+			modify: func(snapshot *models.EnvSnapshot) {
+				snapshot.RenderSet.DefaultValues = "replicas: 2"
+			},
+		},
+		{
+			name: "deploy strategy changed",
+			modify: func(snapshot *models.EnvSnapshot) {
+				snapshot.DeployStrategy["web"] = "import"
+			},
+		},
+		{
+			name: "env resource changed",
+			modify: func(snapshot *models.EnvSnapshot) {
+				snapshot.EnvResources[0].YamlData = "a: 2"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot := newSnapshot()
+			tt.modify(snapshot)
+			assert.Equal(t, tt.equal, snapshotContent(newSnapshot()) == snapshotContent(snapshot))
+		})
+	}
+}
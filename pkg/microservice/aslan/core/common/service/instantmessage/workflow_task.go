@@ -447,6 +447,8 @@ func getJobTaskTplExec(tplcontent string, args *jobTaskNotification) (string, er
 				return "Apollo 配置变更"
 			case string(config.JobMeegoTransition):
 				return "飞书工作项状态变更"
+			case string(config.JobManualGate):
+				return "人工确认"
 			default:
 				return string(jobType)
 			}
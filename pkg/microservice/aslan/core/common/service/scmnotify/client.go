@@ -31,6 +31,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/gitee"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/github"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/shared/client/systemconfig"
 	"github.com/koderover/zadig/pkg/tool/gerrit"
@@ -187,6 +188,25 @@ func (c *Client) Comment(notify *models.Notification) error {
 		if err != nil {
 			return fmt.Errorf("failed to comment gitee due to %s/%d %v", notify.ProjectID, notify.PrID, err)
 		}
+	} else if strings.ToLower(codeHostDetail.Type) == setting.SourceFromGithub {
+		cli := github.NewClient(codeHostDetail.AccessToken, config.ProxyHTTPSAddr(), codeHostDetail.EnableProxy)
+		if notify.CommentID == "" {
+			// create comment
+			issueComment, err := cli.CreateIssueComment(context.Background(), notify.RepoOwner, notify.RepoName, notify.PrID, comment)
+			if err != nil {
+				return fmt.Errorf("failed to comment github due to %s/%d %v", notify.ProjectID, notify.PrID, err)
+			}
+			notify.CommentID = strconv.FormatInt(issueComment.GetID(), 10)
+		} else {
+			// update comment
+			commentID, err := strconv.ParseInt(notify.CommentID, 10, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse commentID %v,err: %s", notify.CommentID, err)
+			}
+			if _, err = cli.EditIssueComment(context.Background(), notify.RepoOwner, notify.RepoName, commentID, comment); err != nil {
+				return fmt.Errorf("failed to comment github due to %s/%d %v", notify.ProjectID, notify.PrID, err)
+			}
+		}
 	} else {
 		return fmt.Errorf("non gitlab source not supported to comment")
 	}
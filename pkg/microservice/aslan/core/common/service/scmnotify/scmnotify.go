@@ -651,6 +651,58 @@ func (s *Service) UpdateEnvAndTaskWebhookComment(workflowArgs *models.WorkflowTa
 	return nil
 }
 
+// UpdatePreviewEnvWebhookComment comments the preview environment of the pull request,
+// a new comment is created if notificationID is empty, otherwise the existing one is updated.
+func (s *Service) UpdatePreviewEnvWebhookComment(
+	mainRepo *models.MainHookRepo, notificationID string, prID int, baseURI string, prTaskInfo *models.PrTaskInfo, logger *zap.SugaredLogger,
+) (*models.Notification, error) {
+	prTaskInfo.EnvStatus = convertStatus(prTaskInfo.EnvStatus)
+
+	if notificationID != "" {
+		notification, err := s.Coll.Find(notificationID)
+		if err != nil {
+			logger.Errorf("UpdatePreviewEnvWebhookComment can't find notification by id %s %s", notificationID, err)
+			return nil, err
+		}
+		notification.PrTask = prTaskInfo
+		if err = s.Client.Comment(notification); err != nil {
+			logger.Errorf("UpdatePreviewEnvWebhookComment failed to comment %s, %v", notification.ToString(), err)
+			return nil, err
+		}
+		if err = s.Coll.Upsert(notification); err != nil {
+			logger.Errorf("UpdatePreviewEnvWebhookComment can't upsert notification by id %s", notification.ID)
+			return nil, err
+		}
+		return notification, nil
+	}
+
+	if mainRepo == nil {
+		return nil, fmt.Errorf("repo of the preview environment is not specified")
+	}
+	notification := &models.Notification{
+		CodehostID:   mainRepo.CodehostID,
+		PrID:         prID,
+		ProjectID:    strings.TrimLeft(mainRepo.GetRepoNamespace()+"/"+mainRepo.RepoName, "/"),
+		BaseURI:      baseURI,
+		IsPreviewEnv: true,
+		PrTask:       prTaskInfo,
+		Label:        mainRepo.GetLabelValue(),
+		Revision:     mainRepo.Revision,
+		RepoOwner:    mainRepo.RepoOwner,
+		RepoName:     mainRepo.RepoName,
+	}
+
+	if err := s.Client.Comment(notification); err != nil {
+		logger.Errorf("failed to comment to %s %v", notification.ToString(), err)
+		return nil, err
+	} else if err := s.Coll.Create(notification); err != nil {
+		logger.Errorf("failed to save %s %v", notification.ToString(), err)
+		return nil, err
+	}
+
+	return notification, nil
+}
+
 func (s *Service) CreateGitCheckForWorkflowV4(workflowArgs *models.WorkflowV4, taskID int64, log *zap.SugaredLogger) error {
 	hook := workflowArgs.HookPayload
 
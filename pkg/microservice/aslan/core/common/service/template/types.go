@@ -90,3 +90,13 @@ type BuildTemplateReference struct {
 	ProjectName   string   `json:"project_name"`
 	ServiceModule []string `json:"service_module"`
 }
+
+type StepTemplateReference struct {
+	WorkflowName        string `json:"workflow_name"`
+	WorkflowDisplayName string `json:"workflow_display_name"`
+	ProjectName         string `json:"project_name"`
+	JobName             string `json:"job_name"`
+	StepName            string `json:"step_name"`
+	// Version is 0 if the step uses the latest version
+	Version int64 `json:"version"`
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"sort"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	templaterepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb/template"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+// taskScheduler decides which pending tasks can be queued in one round of scheduling.
+// The system workflow concurrency and MultiRun only count the running and queued tasks, while the
+// concurrency groups and the project quotas also count the tasks waiting for approval or paused.
+type taskScheduler struct {
+	concurrency   int
+	active        []*commonmodels.WorkflowQueue
+	started       []*commonmodels.WorkflowQueue
+	pending       []*commonmodels.WorkflowQueue
+	projectQuotas map[string]int
+}
+
+func newTaskScheduler(queues []*commonmodels.WorkflowQueue, concurrency int) *taskScheduler {
+	s := &taskScheduler{
+		concurrency:   concurrency,
+		projectQuotas: make(map[string]int),
+	}
+	for _, q := range queues {
+		switch q.Status {
+		case config.StatusRunning, config.StatusQueued:
+			s.active = append(s.active, q)
+			s.started = append(s.started, q)
+		case config.StatusWaitingApprove, config.StatusPaused:
+			s.started = append(s.started, q)
+		case config.StatusWaiting, config.StatusBlocked:
+			s.pending = append(s.pending, q)
+		}
+	}
+	sortPendingTasks(s.pending)
+	return s
+}
+
+// sortPendingTasks sorts the tasks by priority, tasks with the same priority are sorted by create time.
+func sortPendingTasks(tasks []*commonmodels.WorkflowQueue) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Priority != tasks[j].Priority {
+			return tasks[i].Priority > tasks[j].Priority
+		}
+		return tasks[i].CreateTime < tasks[j].CreateTime
+	})
+}
+
+func (s *taskScheduler) hasAgentAvailable() bool {
+	return len(s.active) < s.concurrency
+}
+
+func (s *taskScheduler) canRun(t *commonmodels.WorkflowQueue) bool {
+	if !t.MultiRun {
+		for _, q := range s.active {
+			if q.WorkflowName == t.WorkflowName {
+				return false
+			}
+		}
+	}
+	if t.Concurrency != nil && t.Concurrency.Name != "" {
+		count := 0
+		for _, q := range s.started {
+			if q.Concurrency != nil && q.Concurrency.Name == t.Concurrency.Name {
+				count++
+			}
+		}
+		if count >= t.Concurrency.GetLimit() {
+			return false
+		}
+	}
+	if quota := s.getProjectQuota(t.ProjectName); quota > 0 {
+		count := 0
+		for _, q := range s.started {
+			if q.ProjectName == t.ProjectName {
+				count++
+			}
+		}
+		if count >= quota {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *taskScheduler) add(t *commonmodels.WorkflowQueue) {
+	t.Status = config.StatusQueued
+	s.active = append(s.active, t)
+	s.started = append(s.started, t)
+}
+
+func (s *taskScheduler) getProjectQuota(projectName string) int {
+	if quota, ok := s.projectQuotas[projectName]; ok {
+		return quota
+	}
+	quota := 0
+	project, err := templaterepo.NewProductColl().Find(projectName)
+	if err != nil {
+		// workflows of the deploy center do not belong to any project.
+		log.Debugf("find project %s error: %v", projectName, err)
+	} else {
+		quota = project.WorkflowTaskQuota
+	}
+	s.projectQuotas[projectName] = quota
+	return quota
+}
+
+// cancelSupersededTasks cancels the queued tasks of the concurrency group created before the task.
+func cancelSupersededTasks(t *commonmodels.WorkflowTask) {
+	if t.Concurrency == nil || t.Concurrency.Name == "" || !t.Concurrency.CancelInProgress {
+		return
+	}
+	logger := log.SugaredLogger()
+	for _, q := range ListTasks() {
+		if !isSuperseded(q, t) {
+			continue
+		}
+		logger.Infof("task %s:%d of concurrency group %s is superseded by task %s:%d", q.WorkflowName, q.TaskID, t.Concurrency.Name, t.WorkflowName, t.TaskID)
+		if err := CancelWorkflowTask(t.TaskCreator, q.WorkflowName, q.TaskID, logger); err != nil {
+			logger.Errorf("cancel superseded task %s:%d error: %v", q.WorkflowName, q.TaskID, err)
+		}
+	}
+}
+
+// isSuperseded returns true if the task in the queue has not started and is created before the task of the same concurrency group.
+func isSuperseded(q *commonmodels.WorkflowQueue, t *commonmodels.WorkflowTask) bool {
+	switch q.Status {
+	case config.StatusWaiting, config.StatusBlocked, config.StatusQueued:
+	default:
+		return false
+	}
+	if q.Concurrency == nil || q.Concurrency.Name != t.Concurrency.Name {
+		return false
+	}
+	if q.WorkflowName == t.WorkflowName {
+		return q.TaskID < t.TaskID
+	}
+	return q.CreateTime <= t.CreateTime
+}
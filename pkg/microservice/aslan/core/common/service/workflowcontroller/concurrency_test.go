@@ -0,0 +1,197 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func TestSortPendingTasks(t *testing.T) {
+	tasks := []*commonmodels.WorkflowQueue{
+		{WorkflowName: "a", Priority: 0, CreateTime: 1},
+		{WorkflowName: "b", Priority: 10, CreateTime: 3},
+		{WorkflowName: "c", Priority: 0, CreateTime: 0},
+		{WorkflowName: "d", Priority: 10, CreateTime: 2},
+		{WorkflowName: "e", Priority: -1, CreateTime: 0},
+		{WorkflowName: "f", Priority: 0, CreateTime: 1},
+	}
+	sortPendingTasks(tasks)
+	names := []string{}
+	for _, task := range tasks {
+		names = append(names, task.WorkflowName)
+	}
+	assert.Equal(t, []string{"d", "b", "c", "a", "f", "e"}, names)
+}
+
+func TestTaskSchedulerCanRun(t *testing.T) {
+	group := func(name string, limit int) *commonmodels.ConcurrencyGroup {
+		return &commonmodels.ConcurrencyGroup{Name: name, Limit: limit}
+	}
+	tests := []struct {
+		name          string
+		queues        []*commonmodels.WorkflowQueue
+		projectQuotas map[string]int
+		task          *commonmodels.WorkflowQueue
+		want          bool
+	}{
+		{
+			name:   "empty queue",
+			queues: []*commonmodels.WorkflowQueue{},
+			task:   &commonmodels.WorkflowQueue{WorkflowName: "a", ProjectName: "p"},
+			want:   true,
+		},
+		{
+			name: "same workflow running without multi run",
+			queues: []*commonmodels.WorkflowQueue{
+				{WorkflowName: "a", ProjectName: "p", Status: config.StatusRunning},
+			},
+			task: &commonmodels.WorkflowQueue{WorkflowName: "a", ProjectName: "p"},
+			want: false,
+		},
+		{
+			name: "same workflow running with multi run",
+			queues: []*commonmodels.WorkflowQueue{
+				{WorkflowName: "a", ProjectName: "p", Status: config.StatusRunning},
+			},
+			task: &commonmodels.WorkflowQueue{WorkflowName: "a", ProjectName: "p", MultiRun: true},
+			want: true,
+		},
+		{
+			name: "same workflow waiting for approval without multi run",
+			queues: []*commonmodels.WorkflowQueue{
+				{WorkflowName: "a", ProjectName: "p", Status: config.StatusWaitingApprove},
+			},
+			task: &commonmodels.WorkflowQueue{WorkflowName: "a", ProjectName: "p"},
+			want: true,
+		},
+		{
+			name: "concurrency group full",
+			queues: []*commonmodels.WorkflowQueue{
+				{WorkflowName: "a", ProjectName: "p", Status: config.StatusPaused, Concurrency: group("deploy", 0)},
+			},
+			task: &commonmodels.WorkflowQueue{WorkflowName: "b", ProjectName: "p", Concurrency: group("deploy", 0)},
+			want: false,
+		},
+		{
+			name: "concurrency group not full",
+			queues: []*commonmodels.WorkflowQueue{
+				{WorkflowName: "a", ProjectName: "p", Status: config.StatusRunning, Concurrency: group("deploy", 2)},
+				{WorkflowName: "c", ProjectName: "p", Status: config.StatusRunning, Concurrency: group("test", 1)},
+			},
+			task: &commonmodels.WorkflowQueue{WorkflowName: "b", ProjectName: "p", Concurrency: group("deploy", 2)},
+			want: true,
+		},
+		{
+			name: "waiting tasks are not counted",
+			queues: []*commonmodels.WorkflowQueue{
+				{WorkflowName: "a", ProjectName: "p", Status: config.StatusWaiting, Concurrency: group("deploy", 1)},
+			},
+			task: &commonmodels.WorkflowQueue{WorkflowName: "b", ProjectName: "p", Concurrency: group("deploy", 1)},
+			want: true,
+		},
+		{
+			name: "project quota reached",
+			queues: []*commonmodels.WorkflowQueue{
+				{WorkflowName: "a", ProjectName: "p", Status: config.StatusRunning},
+				{WorkflowName: "b", ProjectName: "p", Status: config.StatusWaitingApprove},
+			},
+			projectQuotas: map[string]int{"p": 2},
+			task:          &commonmodels.WorkflowQueue{WorkflowName: "c", ProjectName: "p"},
+			want:          false,
+		},
+		{
+			name: "project quota of another project",
+			queues: []*commonmodels.WorkflowQueue{
+				{WorkflowName: "a", ProjectName: "p", Status: config.StatusRunning},
+			},
+			projectQuotas: map[string]int{"p": 1, "q": 1},
+			task:          &commonmodels.WorkflowQueue{WorkflowName: "c", ProjectName: "q"},
+			want:          true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTaskScheduler(tt.queues, 10)
+			s.projectQuotas["p"] = 0
+			for project, quota := range tt.projectQuotas {
+				s.projectQuotas[project] = quota
+			}
+			assert.Equal(t, tt.want, s.canRun(tt.task))
+		})
+	}
+}
+
+func TestIsSuperseded(t *testing.T) {
+	group := &commonmodels.ConcurrencyGroup{Name: "deploy", CancelInProgress: true}
+	task := &commonmodels.WorkflowTask{WorkflowName: "a", TaskID: 5, CreateTime: 100, Concurrency: group}
+	tests := []struct {
+		name  string
+		queue *commonmodels.WorkflowQueue
+		want  bool
+	}{
+		{
+			name:  "earlier waiting task of the workflow",
+			queue: &commonmodels.WorkflowQueue{WorkflowName: "a", TaskID: 4, CreateTime: 90, Status: config.StatusWaiting, Concurrency: group},
+			want:  true,
+		},
+		{
+			name:  "earlier blocked task of another workflow",
+			queue: &commonmodels.WorkflowQueue{WorkflowName: "b", TaskID: 9, CreateTime: 90, Status: config.StatusBlocked, Concurrency: group},
+			want:  true,
+		},
+		{
+			name:  "running task",
+			queue: &commonmodels.WorkflowQueue{WorkflowName: "a", TaskID: 4, CreateTime: 90, Status: config.StatusRunning, Concurrency: group},
+			want:  false,
+		},
+		{
+			name:  "task waiting for approval",
+			queue: &commonmodels.WorkflowQueue{WorkflowName: "a", TaskID: 4, CreateTime: 90, Status: config.StatusWaitingApprove, Concurrency: group},
+			want:  false,
+		},
+		{
+			name:  "the task itself",
+			queue: &commonmodels.WorkflowQueue{WorkflowName: "a", TaskID: 5, CreateTime: 100, Status: config.StatusWaiting, Concurrency: group},
+			want:  false,
+		},
+		{
+			name:  "later task of another workflow",
+			queue: &commonmodels.WorkflowQueue{WorkflowName: "b", TaskID: 1, CreateTime: 110, Status: config.StatusWaiting, Concurrency: group},
+			want:  false,
+		},
+		{
+			name:  "another concurrency group",
+			queue: &commonmodels.WorkflowQueue{WorkflowName: "b", TaskID: 1, CreateTime: 90, Status: config.StatusWaiting, Concurrency: &commonmodels.ConcurrencyGroup{Name: "test"}},
+			want:  false,
+		},
+		{
+			name:  "no concurrency group",
+			queue: &commonmodels.WorkflowQueue{WorkflowName: "b", TaskID: 1, CreateTime: 90, Status: config.StatusWaiting},
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isSuperseded(tt.queue, task))
+		})
+	}
+}
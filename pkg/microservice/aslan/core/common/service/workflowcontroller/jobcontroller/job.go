@@ -32,6 +32,9 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/envsnapshot"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/util/expression"
 	"github.com/koderover/zadig/pkg/util/rand"
 )
 
@@ -41,6 +44,13 @@ type JobCtl interface {
 	Clean(ctx context.Context)
 }
 
+// JobResumer is implemented by jobs running in a kubernetes job, so the controller
+// can re-attach to the job after aslan restarted instead of running it again.
+type JobResumer interface {
+	// Resume returns false if the kubernetes job can not be found anymore.
+	Resume(ctx context.Context) bool
+}
+
 func initJobCtl(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) JobCtl {
 	var jobCtl JobCtl
 	switch job.JobType {
@@ -78,6 +88,8 @@ func initJobCtl(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTas
 		jobCtl = NewApolloJobCtl(job, workflowCtx, ack, logger)
 	case string(config.JobMeegoTransition):
 		jobCtl = NewMeegoTransitionJobCtl(job, workflowCtx, ack, logger)
+	case string(config.JobManualGate):
+		jobCtl = NewManualGateJobCtl(job, workflowCtx, ack, logger)
 	default:
 		jobCtl = NewFreestyleJobCtl(job, workflowCtx, ack, logger)
 	}
@@ -85,19 +97,63 @@ func initJobCtl(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTas
 }
 
 func runJob(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) {
-	// render global variables for every job.
-	workflowCtx.GlobalContextEach(func(k, v string) bool {
-		b, _ := json.Marshal(job)
-		v = strings.Trim(v, "\n")
-		replacedString := strings.ReplaceAll(string(b), k, v)
-		if err := json.Unmarshal([]byte(replacedString), &job); err != nil {
-			logger.Errorf("unmarshal job error: %v", err)
+	// job already finished before aslan restarted.
+	if job.Status == config.StatusPassed || job.Status == config.StatusSkipped {
+		logger.Infof("skip finished job: %s,status: %s", job.Name, job.Status)
+		return
+	}
+	resume := job.K8sJobName != "" && (job.Status == config.StatusPrepare || job.Status == config.StatusRunning)
+	if !resume {
+		// the task may be paused while the jobs before this job were running.
+		if err := workflowCtx.WaitIfPaused(ctx); err != nil {
+			logger.Infof("job: %s will not run: %v", job.Name, err)
+			job.Status = config.StatusCancelled
+			job.Error = err.Error()
+			job.StartTime = time.Now().Unix()
+			job.EndTime = job.StartTime
+			ack()
+			return
 		}
-		return true
-	})
-	job.Status = config.StatusPrepare
-	job.StartTime = time.Now().Unix()
-	job.K8sJobName = getJobName(workflowCtx.WorkflowName, workflowCtx.TaskID)
+	}
+	if !resume && job.When != "" {
+		run, err := evalWhen(job.When, workflowCtx)
+		if err != nil {
+			logError(job, fmt.Sprintf("job: %s check when condition error: %v", job.Name, err), logger)
+			job.StartTime = time.Now().Unix()
+			job.EndTime = job.StartTime
+			ack()
+			return
+		}
+		if !run {
+			logger.Infof("skip job: %s, when condition %q is false", job.Name, job.When)
+			job.Status = config.StatusSkipped
+			ack()
+			return
+		}
+	}
+	if !resume {
+		// render global variables for every job.
+		err := workflowCtx.GlobalContextEach(func(k, v string) bool {
+			b, _ := json.Marshal(job)
+			// escape the value since it is rendered into the json of the job, json outputs contain quotes.
+			escaped, _ := json.Marshal(strings.Trim(v, "\n"))
+			replacedString := strings.ReplaceAll(string(b), k, string(escaped[1:len(escaped)-1]))
+			if err := json.Unmarshal([]byte(replacedString), &job); err != nil {
+				logger.Errorf("unmarshal job error: %v", err)
+			}
+			return true
+		})
+		if err != nil {
+			logError(job, fmt.Sprintf("job: %s render global variables error: %v", job.Name, err), logger)
+			job.StartTime = time.Now().Unix()
+			job.EndTime = job.StartTime
+			ack()
+			return
+		}
+		job.Status = config.StatusPrepare
+		job.StartTime = time.Now().Unix()
+		job.K8sJobName = getJobName(workflowCtx.WorkflowName, workflowCtx.TaskID)
+	}
 	ack()
 
 	logger.Infof("start job: %s,status: %s", job.Name, job.Status)
@@ -114,16 +170,108 @@ func runJob(ctx context.Context, job *commonmodels.JobTask, workflowCtx *commonm
 	}()
 	jobCtl := initJobCtl(job, workflowCtx, logger, ack)
 
+	if resume {
+		if resumer, ok := jobCtl.(JobResumer); ok && resumer.Resume(ctx) {
+			return
+		}
+		logger.Infof("job %s can not be resumed, run it again", job.Name)
+		job.Status = config.StatusPrepare
+		job.StartTime = time.Now().Unix()
+		job.K8sJobName = getJobName(workflowCtx.WorkflowName, workflowCtx.TaskID)
+		ack()
+	}
 	jobCtl.Run(ctx)
 }
 
+// evalWhen evaluates the when condition of a job or step.
+func evalWhen(when string, workflowCtx *commonmodels.WorkflowTaskCtx) (bool, error) {
+	variables, err := getWhenVariables(workflowCtx)
+	if err != nil {
+		return false, err
+	}
+	return expression.Eval(when, variables)
+}
+
+// getWhenVariables returns the variables which can be used in the when conditions of jobs and steps.
+func getWhenVariables(workflowCtx *commonmodels.WorkflowTaskCtx) (map[string]interface{}, error) {
+	resp := map[string]interface{}{
+		"project":          workflowCtx.ProjectName,
+		"workflow.name":    workflowCtx.WorkflowName,
+		"workflow.task.id": fmt.Sprintf("%d", workflowCtx.TaskID),
+	}
+	for _, param := range workflowCtx.WorkflowParams {
+		resp[strings.Join([]string{"workflow", "params", param.Name}, ".")] = param.Value
+	}
+	for _, kv := range workflowCtx.WorkflowKeyVals {
+		resp[strings.Join([]string{"workflow", "keyvals", kv.Key}, ".")] = kv.Value
+	}
+	// job outputs are saved in global context as {{.job.<key>.output.<name>}}.
+	err := workflowCtx.GlobalContextEach(func(k, v string) bool {
+		if strings.HasPrefix(k, "{{.") && strings.HasSuffix(k, "}}") {
+			resp[strings.TrimSuffix(strings.TrimPrefix(k, "{{."), "}}")] = strings.Trim(v, "\n")
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	payload := workflowCtx.HookPayload
+	if payload == nil {
+		resp["trigger.event"] = "manual"
+		return resp, nil
+	}
+	branch := payload.Branch
+	if branch == "" {
+		branch = strings.TrimPrefix(payload.Ref, "refs/heads/")
+	}
+	resp["trigger.event"] = payload.EventType
+	resp["trigger.is_pr"] = payload.IsPr
+	resp["trigger.branch"] = branch
+	resp["trigger.ref"] = payload.Ref
+	resp["trigger.tag"] = payload.Tag
+	resp["trigger.pr"] = payload.MergeRequestID
+	resp["trigger.commit_id"] = payload.CommitID
+	resp["trigger.owner"] = payload.Owner
+	resp["trigger.repo"] = payload.Repo
+	resp["trigger.labels"] = payload.Labels
+	resp["trigger.changed_files"] = payload.ChangedFiles
+	return resp, nil
+}
+
+// remainingTimeout returns the time left for a job which may have been started before aslan restarted.
+func remainingTimeout(job *commonmodels.JobTask, timeoutMinutes int64) time.Duration {
+	timeout := time.Duration(timeoutMinutes) * time.Minute
+	if job.StartTime == 0 {
+		return timeout
+	}
+	elapsed := time.Since(time.Unix(job.StartTime, 0))
+	if elapsed >= timeout {
+		return 0
+	}
+	return timeout - elapsed
+}
+
 func RunJobs(ctx context.Context, jobs []*commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) {
+	if hasJobDependency(jobs) {
+		runJobsDAG(ctx, jobs, workflowCtx, concurrency, logger, ack)
+		return
+	}
 	if concurrency == 1 {
+		// the jobs of a matrix are adjacent, when one of them failed the rest of the matrix still runs,
+		// or is cancelled if the matrix is fail fast, the jobs after the matrix will not run.
+		matrix := newMatrixGroups(ctx)
+		failedMatrix := ""
 		for _, job := range jobs {
-			runJob(ctx, job, workflowCtx, logger, ack)
-			if jobStatusFailed(job.Status) {
+			if ctx.Err() != nil || (failedMatrix != "" && job.MatrixKey != failedMatrix) {
 				return
 			}
+			matrix.runJob(job, workflowCtx, logger, ack)
+			if jobStatusFailed(job.Status) {
+				if job.MatrixKey == "" {
+					return
+				}
+				failedMatrix = job.MatrixKey
+			}
 		}
 		return
 	}
@@ -131,6 +279,148 @@ func RunJobs(ctx context.Context, jobs []*commonmodels.JobTask, workflowCtx *com
 	jobPool.Run()
 }
 
+// matrixGroups cancels the other jobs expanded from the same matrix when a fail fast job failed.
+type matrixGroups struct {
+	sync.Mutex
+	ctx    context.Context
+	groups map[string]*matrixGroup
+}
+
+type matrixGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newMatrixGroups(ctx context.Context) *matrixGroups {
+	return &matrixGroups{ctx: ctx, groups: map[string]*matrixGroup{}}
+}
+
+func (m *matrixGroups) get(key string) *matrixGroup {
+	m.Lock()
+	defer m.Unlock()
+	if group, ok := m.groups[key]; ok {
+		return group
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.groups[key] = &matrixGroup{ctx: ctx, cancel: cancel}
+	return m.groups[key]
+}
+
+func (m *matrixGroups) runJob(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) {
+	if job.MatrixKey == "" || !job.FailFast {
+		runJob(m.ctx, job, workflowCtx, logger, ack)
+		return
+	}
+	group := m.get(job.MatrixKey)
+	started := job.Status == config.StatusPrepare || job.Status == config.StatusRunning
+	if group.ctx.Err() != nil && m.ctx.Err() == nil && !started {
+		logger.Infof("job: %s will not run since another job of matrix %s failed", job.Name, job.MatrixKey)
+		job.Status = config.StatusCancelled
+		job.Error = fmt.Sprintf("cancelled since another job of matrix %s failed", job.MatrixKey)
+		job.StartTime = time.Now().Unix()
+		job.EndTime = job.StartTime
+		ack()
+		return
+	}
+	runJob(group.ctx, job, workflowCtx, logger, ack)
+	if job.Status == config.StatusFailed || job.Status == config.StatusTimeout {
+		group.cancel()
+	}
+}
+
+func hasJobDependency(jobs []*commonmodels.JobTask) bool {
+	for _, job := range jobs {
+		if len(job.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// runJobsDAG starts every job as soon as all the jobs it depends on passed or skipped, at most concurrency jobs run at the same time.
+// jobs depending on a failed job will not run, dependencies not in jobs are treated as finished.
+func runJobsDAG(ctx context.Context, jobs []*commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	jobMap := make(map[string]*commonmodels.JobTask, len(jobs))
+	for _, job := range jobs {
+		jobMap[job.Key] = job
+	}
+	// finished is only accessed by the scheduling goroutine, the status of a job is safe to read after it finished.
+	finished := make(map[string]bool, len(jobs))
+	// blocked records the jobs skipped because of a failed dependency, their dependents are skipped too.
+	blocked := make(map[string]bool, len(jobs))
+	pending := jobs
+	doneChan := make(chan *commonmodels.JobTask)
+	running := 0
+	matrix := newMatrixGroups(ctx)
+
+	for {
+		waiting := []*commonmodels.JobTask{}
+		skipped := false
+		for _, job := range pending {
+			ready, failedDependency := true, ""
+			for _, dependency := range job.DependsOn {
+				depJob, ok := jobMap[dependency]
+				if !ok {
+					continue
+				}
+				if !finished[dependency] {
+					ready = false
+					continue
+				}
+				if blocked[dependency] || (depJob.Status != config.StatusPassed && depJob.Status != config.StatusSkipped) {
+					failedDependency = depJob.Name
+					break
+				}
+			}
+			if failedDependency != "" {
+				logger.Infof("job: %s will not run since its dependency %s failed", job.Name, failedDependency)
+				finishNotRunJob(job, config.StatusSkipped, fmt.Sprintf("dependency %s failed", failedDependency), ack)
+				finished[job.Key] = true
+				blocked[job.Key] = true
+				skipped = true
+				continue
+			}
+			if !ready || running >= concurrency || ctx.Err() != nil {
+				waiting = append(waiting, job)
+				continue
+			}
+			running++
+			go func(job *commonmodels.JobTask) {
+				matrix.runJob(job, workflowCtx, logger, ack)
+				doneChan <- job
+			}(job)
+		}
+		pending = waiting
+		// the dependents of the skipped jobs need to be checked again.
+		if skipped {
+			continue
+		}
+		if running == 0 {
+			// only the jobs waiting for a cancelled workflow are left.
+			for _, job := range pending {
+				logger.Infof("job: %s will not run since the workflow was canceled", job.Name)
+				finishNotRunJob(job, config.StatusCancelled, "workflow was canceled", ack)
+			}
+			return
+		}
+		job := <-doneChan
+		running--
+		finished[job.Key] = true
+	}
+}
+
+// finishNotRunJob sets the terminal status of a job which will never run, so its stage can finish.
+func finishNotRunJob(job *commonmodels.JobTask, status config.Status, reason string, ack func()) {
+	job.Status = status
+	job.Error = reason
+	job.StartTime = time.Now().Unix()
+	job.EndTime = job.StartTime
+	ack()
+}
+
 func CleanWorkflowJobs(ctx context.Context, workflowTask *commonmodels.WorkflowTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) {
 	for _, stage := range workflowTask.Stages {
 		for _, job := range stage.Jobs {
@@ -150,6 +440,7 @@ type Pool struct {
 	logger      *zap.SugaredLogger
 	ack         func()
 	ctx         context.Context
+	matrix      *matrixGroups
 	wg          sync.WaitGroup
 }
 
@@ -164,6 +455,7 @@ func NewPool(ctx context.Context, jobs []*commonmodels.JobTask, workflowCtx *com
 		logger:      logger,
 		ack:         ack,
 		ctx:         ctx,
+		matrix:      newMatrixGroups(ctx),
 	}
 }
 
@@ -188,7 +480,7 @@ func (p *Pool) Run() {
 // The work loop for any single goroutine.
 func (p *Pool) work() {
 	for job := range p.jobsChan {
-		runJob(p.ctx, job, p.workflowCtx, p.logger, p.ack)
+		p.matrix.runJob(job, p.workflowCtx, p.logger, p.ack)
 		p.wg.Done()
 	}
 }
@@ -268,6 +560,13 @@ func updateProductImageByNs(namespace, productName, serviceName string, targets
 	return nil
 }
 
+func snapshotEnvAfterDeploy(workflowCtx *commonmodels.WorkflowTaskCtx, env *commonmodels.Product, logger *zap.SugaredLogger) {
+	description := fmt.Sprintf("workflow %s #%d", workflowCtx.WorkflowName, workflowCtx.TaskID)
+	if err := envsnapshot.CreateEnvSnapshot(env.ProductName, env.EnvName, setting.SystemUser, description, logger); err != nil {
+		logger.Warnf("failed to create snapshot of env %s/%s: %s", env.ProductName, env.EnvName, err)
+	}
+}
+
 func getMatchedRegistries(image string, registries []*commonmodels.RegistryNamespace) []*commonmodels.RegistryNamespace {
 	resp := []*commonmodels.RegistryNamespace{}
 	for _, registry := range registries {
@@ -70,6 +70,16 @@ func NewDeployJobCtl(job *commonmodels.JobTask, workflowCtx *commonmodels.Workfl
 
 func (c *DeployJobCtl) Clean(ctx context.Context) {}
 
+// pinImageDigest appends the digest to the image so that the workload runs exactly the pushed image,
+// the image is returned as is if the digest is not a sha256 digest or the image is already pinned.
+func pinImageDigest(image, digest string) string {
+	digest = strings.TrimSpace(digest)
+	if !strings.HasPrefix(digest, "sha256:") || strings.Contains(image, "@") {
+		return image
+	}
+	return image + "@" + digest
+}
+
 func (c *DeployJobCtl) Run(ctx context.Context) {
 	c.job.Status = config.StatusRunning
 	c.ack()
@@ -88,6 +98,7 @@ func (c *DeployJobCtl) run(ctx context.Context) error {
 		err      error
 		replaced = false
 	)
+	c.jobTaskSpec.Image = pinImageDigest(c.jobTaskSpec.Image, c.jobTaskSpec.ImageDigest)
 	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
 		Name:    c.workflowCtx.ProjectName,
 		EnvName: c.jobTaskSpec.Env,
@@ -267,6 +278,7 @@ func (c *DeployJobCtl) run(ctx context.Context) error {
 	if err := updateProductImageByNs(env.Namespace, c.workflowCtx.ProjectName, c.jobTaskSpec.ServiceName, map[string]string{c.jobTaskSpec.ServiceModule: c.jobTaskSpec.Image}, c.logger); err != nil {
 		c.logger.Error(err)
 	}
+	snapshotEnvAfterDeploy(c.workflowCtx, env, c.logger)
 	c.job.Spec = c.jobTaskSpec
 	return nil
 }
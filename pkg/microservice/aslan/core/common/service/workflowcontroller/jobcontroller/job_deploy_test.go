@@ -0,0 +1,63 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinImageDigest(t *testing.T) {
+	const digest = "sha256:4b1a0d8e2f7c9e3a5b6d1c0f8e7a6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a"
+	tests := []struct {
+		name   string
+		image  string
+		digest string
+		want   string
+	}{
+		{
+			name:   "no digest",
+			image:  "registry.example.com/app/api:v1",
+			digest: "",
+			want:   "registry.example.com/app/api:v1",
+		},
+		{
+			name:   "pinned by digest",
+			image:  "registry.example.com/app/api:v1",
+			digest: digest + "\n",
+			want:   "registry.example.com/app/api:v1@" + digest,
+		},
+		{
+			name:   "already pinned",
+			image:  "registry.example.com/app/api:v1@" + digest,
+			digest: digest,
+			want:   "registry.example.com/app/api:v1@" + digest,
+		},
+		{
+			name:   "unrendered output",
+			image:  "registry.example.com/app/api:v1",
+			digest: "{{.job.build.api.api.output.IMAGE_DIGEST}}",
+			want:   "registry.example.com/app/api:v1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, pinImageDigest(tt.image, tt.digest))
+		})
+	}
+}
@@ -20,11 +20,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	crClient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -32,11 +34,15 @@ import (
 	zadigconfig "github.com/koderover/zadig/pkg/config"
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller/stepcontroller"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/dockerhost"
 	krkubeclient "github.com/koderover/zadig/pkg/tool/kube/client"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
 	"github.com/koderover/zadig/pkg/tool/kube/updater"
+	jobtypes "github.com/koderover/zadig/pkg/types/job"
+	steptypes "github.com/koderover/zadig/pkg/types/step"
 )
 
 const (
@@ -84,10 +90,22 @@ func (c *FreestyleJobCtl) Run(ctx context.Context) {
 		return
 	}
 	c.wait(ctx)
+	c.retry(ctx)
 	c.complete(ctx)
 }
 
-func (c *FreestyleJobCtl) prepare(ctx context.Context) error {
+// retry runs the kubernetes job again while it failed and the retry policy allows.
+func (c *FreestyleJobCtl) retry(ctx context.Context) {
+	for retryJob(ctx, c.job, &c.jobTaskSpec.Properties, c.workflowCtx, c.kubeclient, c.logger, c.ack) {
+		if err := c.run(ctx); err != nil {
+			return
+		}
+		c.wait(ctx)
+	}
+}
+
+// setDefaults fills the properties which are not persisted in the job spec, it is also needed when the job is resumed.
+func (c *FreestyleJobCtl) setDefaults() {
 	// set default timeout
 	if c.jobTaskSpec.Properties.Timeout <= 0 {
 		c.jobTaskSpec.Properties.Timeout = 600
@@ -100,8 +118,31 @@ func (c *FreestyleJobCtl) prepare(ctx context.Context) error {
 	if c.jobTaskSpec.Properties.ClusterID == "" {
 		c.jobTaskSpec.Properties.ClusterID = setting.LocalClusterID
 	}
+}
+
+func (c *FreestyleJobCtl) prepare(ctx context.Context) error {
+	c.setDefaults()
+	// skip the steps whose when condition is false.
+	steps := []*commonmodels.StepTask{}
+	for _, step := range c.jobTaskSpec.Steps {
+		if step.When == "" {
+			steps = append(steps, step)
+			continue
+		}
+		run, err := evalWhen(step.When, c.workflowCtx)
+		if err != nil {
+			logError(c.job, fmt.Sprintf("step: %s check when condition error: %v", step.Name, err), c.logger)
+			return err
+		}
+		if !run {
+			c.logger.Infof("skip step: %s of job: %s, when condition %q is false", step.Name, c.job.Name, step.When)
+			continue
+		}
+		steps = append(steps, step)
+	}
+	c.jobTaskSpec.Steps = steps
 	// init step configration.
-	if err := stepcontroller.PrepareSteps(ctx, c.workflowCtx, &c.jobTaskSpec.Properties.Paths, c.job.Name, c.jobTaskSpec.Steps, c.logger); err != nil {
+	if err := stepcontroller.PrepareSteps(ctx, c.workflowCtx, &c.jobTaskSpec.Properties.Paths, c.job, c.jobTaskSpec.Steps, c.logger); err != nil {
 		logError(c.job, err.Error(), c.logger)
 		return err
 	}
@@ -109,8 +150,27 @@ func (c *FreestyleJobCtl) prepare(ctx context.Context) error {
 	return nil
 }
 
-func (c *FreestyleJobCtl) run(ctx context.Context) error {
-	// get kube client
+// Resume re-attaches to the kubernetes job created before aslan restarted.
+// the steps have been prepared before the job was created, so only the defaults are set again.
+func (c *FreestyleJobCtl) Resume(ctx context.Context) bool {
+	c.setDefaults()
+	if err := c.initKubeClients(); err != nil {
+		c.logger.Errorf("resume job %s error: %v", c.job.Name, err)
+		return false
+	}
+	_, found, err := getter.GetJob(c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, c.kubeclient)
+	if err != nil || !found {
+		c.logger.Infof("k8s job %s of job %s not found, err: %v", c.job.K8sJobName, c.job.Name, err)
+		return false
+	}
+	c.logger.Infof("resume job %s with k8s job %s", c.job.Name, c.job.K8sJobName)
+	c.wait(ctx)
+	c.retry(ctx)
+	c.complete(ctx)
+	return true
+}
+
+func (c *FreestyleJobCtl) initKubeClients() error {
 	hubServerAddr := config.HubServerAddress()
 	switch c.jobTaskSpec.Properties.ClusterID {
 	case setting.LocalClusterID:
@@ -124,7 +184,6 @@ func (c *FreestyleJobCtl) run(ctx context.Context) error {
 
 		crClient, clientset, restConfig, apiServer, err := GetK8sClients(hubServerAddr, c.jobTaskSpec.Properties.ClusterID)
 		if err != nil {
-			logError(c.job, err.Error(), c.logger)
 			return err
 		}
 		c.kubeclient = crClient
@@ -132,6 +191,16 @@ func (c *FreestyleJobCtl) run(ctx context.Context) error {
 		c.restConfig = restConfig
 		c.apiServer = apiServer
 	}
+	return nil
+}
+
+func (c *FreestyleJobCtl) run(ctx context.Context) error {
+	// get kube client
+	hubServerAddr := config.HubServerAddress()
+	if err := c.initKubeClients(); err != nil {
+		logError(c.job, err.Error(), c.logger)
+		return err
+	}
 
 	// decide which docker host to use.
 	// TODO: do not use code in warpdrive moudule, should move to a public place
@@ -161,7 +230,15 @@ func (c *FreestyleJobCtl) run(ctx context.Context) error {
 
 	c.jobTaskSpec.Properties.DockerHost = dockerHost
 
-	jobCtxBytes, err := yaml.Marshal(BuildJobExcutorContext(c.jobTaskSpec, c.job, c.workflowCtx, c.logger))
+	jobCtx := BuildJobExcutorContext(c.jobTaskSpec, c.job, c.workflowCtx, c.logger)
+	signingKeyFiles, err := getSigningKeyFiles(c.jobTaskSpec.Steps)
+	if err != nil {
+		logError(c.job, err.Error(), c.logger)
+		return err
+	}
+	jobCtx.SecretFiles = append(jobCtx.SecretFiles, signingKeyFiles...)
+
+	jobCtxBytes, err := yaml.Marshal(jobCtx)
 	if err != nil {
 		msg := fmt.Sprintf("cannot Jobexcutor.Context data: %v", err)
 		logError(c.job, msg, c.logger)
@@ -222,7 +299,7 @@ func (c *FreestyleJobCtl) run(ctx context.Context) error {
 
 func (c *FreestyleJobCtl) wait(ctx context.Context) {
 	var err error
-	taskTimeout := time.After(time.Duration(c.jobTaskSpec.Properties.Timeout) * time.Minute)
+	taskTimeout := time.After(remainingTimeout(c.job, c.jobTaskSpec.Properties.Timeout))
 	c.job.Status, err = waitJobStart(ctx, c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, c.kubeclient, c.apiServer, taskTimeout, c.logger)
 	if err != nil {
 		c.job.Error = err.Error()
@@ -232,6 +309,11 @@ func (c *FreestyleJobCtl) wait(ctx context.Context) {
 	} else {
 		return
 	}
+	if len(c.jobTaskSpec.Properties.Services) > 0 {
+		signalCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go signalServicesReady(signalCtx, c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, c.kubeclient, c.clientset, c.restConfig, c.logger)
+	}
 	c.job.Status, c.job.Error = waitJobEndWithFile(ctx, taskTimeout, c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, true, c.kubeclient, c.clientset, c.restConfig, c.logger)
 }
 
@@ -257,24 +339,62 @@ func (c *FreestyleJobCtl) complete(ctx context.Context) {
 	if err := getJobOutputFromRunningPod(c.jobTaskSpec.Properties.Namespace, c.job.Name, c.job, c.workflowCtx, c.kubeclient, c.clientset, c.restConfig); err != nil {
 		c.logger.Error(err)
 	}
+	if err := getStepResultsFromRunningPod(c.jobTaskSpec.Properties.Namespace, c.job.Name, c.job, c.jobTaskSpec.Steps, c.kubeclient, c.clientset, c.restConfig); err != nil {
+		c.logger.Error(err)
+	}
 
-	if err := saveContainerLog(c.jobTaskSpec.Properties.Namespace, c.jobTaskSpec.Properties.ClusterID, c.workflowCtx.WorkflowName, c.job.Name, c.workflowCtx.TaskID, jobLabel, c.kubeclient); err != nil {
+	if err := saveContainerLog(c.jobTaskSpec.Properties.Namespace, c.jobTaskSpec.Properties.ClusterID, c.workflowCtx.WorkflowName, c.job.Name, c.workflowCtx.TaskID, jobLabel, getJobSecrets(&c.jobTaskSpec.Properties, c.workflowCtx), c.kubeclient); err != nil {
 		c.logger.Error(err)
 		if c.job.Error == "" {
 			c.job.Error = err.Error()
 		}
 		return
 	}
-	if err := stepcontroller.SummarizeSteps(ctx, c.workflowCtx, &c.jobTaskSpec.Properties.Paths, c.job.Name, c.jobTaskSpec.Steps, c.logger); err != nil {
+	if err := stepcontroller.SummarizeSteps(ctx, c.workflowCtx, &c.jobTaskSpec.Properties.Paths, c.job, c.jobTaskSpec.Steps, c.logger); err != nil {
 		c.logger.Error(err)
 		c.job.Error = err.Error()
 		return
 	}
 }
 
+// getSigningKeyFiles returns the signing keys used by the attestation steps as secret files,
+// so that the keys are never saved in the workflow task.
+func getSigningKeyFiles(steps []*commonmodels.StepTask) ([]string, error) {
+	var files []string
+	keyIDs := sets.NewString()
+	for _, stepTask := range steps {
+		if stepTask.StepType != config.StepAttestation {
+			continue
+		}
+		yamlString, err := yaml.Marshal(stepTask.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("marshal attestation spec error: %v", err)
+		}
+		attestationSpec := &steptypes.StepAttestationSpec{}
+		if err := yaml.Unmarshal(yamlString, attestationSpec); err != nil {
+			return nil, fmt.Errorf("unmarshal attestation spec error: %v", err)
+		}
+		if keyIDs.Has(attestationSpec.SigningKeyID) {
+			continue
+		}
+		keyIDs.Insert(attestationSpec.SigningKeyID)
+		privateKey, err := commonrepo.NewPrivateKeyColl().Find(commonrepo.FindPrivateKeyOption{ID: attestationSpec.SigningKeyID})
+		if err != nil {
+			return nil, fmt.Errorf("find signing key %s error: %v", attestationSpec.SigningKeyID, err)
+		}
+		// the private key is stored base64 encoded, the job executor decodes it.
+		files = append(files, strings.Join([]string{steptypes.GetSigningKeyFileName(attestationSpec.SigningKeyID), privateKey.PrivateKey}, "="))
+	}
+	return files, nil
+}
+
 func BuildJobExcutorContext(jobTaskSpec *commonmodels.JobTaskFreestyleSpec, job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger) *JobContext {
-	var envVars, secretEnvVars []string
+	var envVars, secretEnvVars, secretFiles []string
 	for _, env := range jobTaskSpec.Properties.Envs {
+		if env.IsCredential && env.AsFile {
+			secretFiles = append(secretFiles, strings.Join([]string{env.Key, env.Value}, "="))
+			continue
+		}
 		if env.IsCredential {
 			secretEnvVars = append(secretEnvVars, strings.Join([]string{env.Key, env.Value}, "="))
 			continue
@@ -282,20 +402,58 @@ func BuildJobExcutorContext(jobTaskSpec *commonmodels.JobTaskFreestyleSpec, job
 		envVars = append(envVars, strings.Join([]string{env.Key, env.Value}, "="))
 	}
 
+	// services share the network of the job pod, so they are reached by localhost.
+	for _, svc := range jobTaskSpec.Properties.Services {
+		prefix := serviceEnvPrefix(svc.Name)
+		envVars = append(envVars, fmt.Sprintf("%s_HOST=localhost", prefix))
+		if len(svc.Ports) > 0 {
+			envVars = append(envVars, fmt.Sprintf("%s_PORT=%d", prefix, svc.Ports[0]))
+		}
+	}
+
 	outputs := []string{}
+	outputTypes := map[string]jobtypes.OutputType{}
 	for _, output := range job.Outputs {
 		outputs = append(outputs, output.Name)
+		if output.Type != "" {
+			outputTypes[output.Name] = output.Type
+		}
+	}
+
+	// large outputs are saved in the default object storage.
+	var outputStorage *steptypes.S3
+	outputStorageID := ""
+	if len(outputs) > 0 {
+		if modelS3, err := commonrepo.NewS3StorageColl().FindDefault(); err != nil {
+			logger.Warnf("find default s3 storage for job outputs error: %v", err)
+		} else {
+			outputStorage = modelS3toS3(modelS3)
+			outputStorageID = modelS3.ID.Hex()
+		}
 	}
 
 	return &JobContext{
-		Name:         job.Name,
-		Envs:         envVars,
-		SecretEnvs:   secretEnvVars,
-		WorkflowName: workflowCtx.WorkflowName,
-		Workspace:    workflowCtx.Workspace,
-		TaskID:       workflowCtx.TaskID,
-		Outputs:      outputs,
-		Steps:        jobTaskSpec.Steps,
-		Paths:        jobTaskSpec.Properties.Paths,
+		Name:            job.Name,
+		Envs:            envVars,
+		SecretEnvs:      secretEnvVars,
+		SecretFiles:     secretFiles,
+		Credentials:     commonmodels.CredentialValues(workflowCtx.WorkflowKeyVals, workflowCtx.WorkflowParams),
+		WaitServices:    len(jobTaskSpec.Properties.Services) > 0,
+		WorkflowName:    workflowCtx.WorkflowName,
+		Workspace:       workflowCtx.Workspace,
+		TaskID:          workflowCtx.TaskID,
+		Outputs:         outputs,
+		OutputTypes:     outputTypes,
+		OutputStorage:   outputStorage,
+		OutputStorageID: outputStorageID,
+		Steps:           jobTaskSpec.Steps,
+		Paths:           jobTaskSpec.Properties.Paths,
 	}
 }
+
+var serviceEnvRegexp = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// serviceEnvPrefix turns the service name into the prefix of its env variables, e.g. my-sql -> MY_SQL.
+func serviceEnvPrefix(name string) string {
+	return serviceEnvRegexp.ReplaceAllString(strings.ToUpper(name), "_")
+}
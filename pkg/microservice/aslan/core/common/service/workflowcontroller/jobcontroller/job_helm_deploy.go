@@ -88,6 +88,9 @@ func (c *HelmDeployJobCtl) Clean(ctx context.Context) {}
 func (c *HelmDeployJobCtl) Run(ctx context.Context) {
 	c.job.Status = config.StatusRunning
 	c.ack()
+	for _, imageAndModule := range c.jobTaskSpec.ImageAndModules {
+		imageAndModule.Image = pinImageDigest(imageAndModule.Image, imageAndModule.ImageDigest)
+	}
 
 	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{
 		Name:    c.workflowCtx.ProjectName,
@@ -371,6 +374,7 @@ func (c *HelmDeployJobCtl) Run(ctx context.Context) {
 		if err := updateProductImageByNs(env.Namespace, c.workflowCtx.ProjectName, c.jobTaskSpec.ServiceName, deploytargets, c.logger); err != nil {
 			c.logger.Error(err)
 		}
+		snapshotEnvAfterDeploy(c.workflowCtx, env, c.logger)
 	}()
 	done := make(chan bool)
 	go func(chan bool) {
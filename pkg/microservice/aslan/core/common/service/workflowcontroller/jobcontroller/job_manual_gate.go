@@ -0,0 +1,167 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types/job"
+)
+
+// manualGateMap records the manual gate jobs waiting for input on this replica.
+var manualGateMap sync.Map
+
+type manualGateInput struct {
+	userName string
+	values   map[string]string
+}
+
+type ManualGateJobCtl struct {
+	job         *commonmodels.JobTask
+	workflowCtx *commonmodels.WorkflowTaskCtx
+	logger      *zap.SugaredLogger
+	jobTaskSpec *commonmodels.JobTaskManualGateSpec
+	ack         func()
+	inputChan   chan *manualGateInput
+	submitOnce  sync.Once
+}
+
+func NewManualGateJobCtl(job *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, ack func(), logger *zap.SugaredLogger) *ManualGateJobCtl {
+	jobTaskSpec := &commonmodels.JobTaskManualGateSpec{}
+	if err := commonmodels.IToi(job.Spec, jobTaskSpec); err != nil {
+		logger.Error(err)
+	}
+	job.Spec = jobTaskSpec
+	return &ManualGateJobCtl{
+		job:         job,
+		workflowCtx: workflowCtx,
+		logger:      logger,
+		ack:         ack,
+		jobTaskSpec: jobTaskSpec,
+		inputChan:   make(chan *manualGateInput, 1),
+	}
+}
+
+func getManualGateKey(workflowName, jobName string, taskID int64) string {
+	return fmt.Sprintf("%s-%d-%s", workflowName, taskID, jobName)
+}
+
+func (c *ManualGateJobCtl) Clean(ctx context.Context) {}
+
+func (c *ManualGateJobCtl) Run(ctx context.Context) {
+	if c.jobTaskSpec.Timeout <= 0 {
+		c.jobTaskSpec.Timeout = 60
+	}
+	gateKey := getManualGateKey(c.workflowCtx.WorkflowName, c.job.Name, c.workflowCtx.TaskID)
+	manualGateMap.Store(gateKey, c)
+	defer manualGateMap.Delete(gateKey)
+
+	c.job.Status = config.StatusWaitingApprove
+	c.workflowCtx.SetWaiting(config.StatusWaitingApprove, true)
+	defer c.workflowCtx.SetWaiting(config.StatusWaitingApprove, false)
+
+	timeout := time.After(remainingTimeout(c.job, c.jobTaskSpec.Timeout))
+	select {
+	case <-ctx.Done():
+		c.job.Status = config.StatusCancelled
+		c.job.Error = "workflow was canceled"
+	case <-timeout:
+		c.job.Status = config.StatusTimeout
+		c.job.Error = "no input was submitted before timeout"
+	case input := <-c.inputChan:
+		for _, param := range c.jobTaskSpec.Params {
+			param.Value = input.values[param.Name]
+			c.workflowCtx.GlobalContextSet(job.GetJobOutputKey(c.job.Key, param.Name), param.Value)
+		}
+		c.jobTaskSpec.Submitter = input.userName
+		c.jobTaskSpec.SubmitTime = time.Now().Unix()
+		c.job.Status = config.StatusPassed
+	}
+}
+
+func (c *ManualGateJobCtl) submit(userName, userID string, params []*commonmodels.Param) error {
+	values, err := CheckManualGateInput(c.jobTaskSpec, userName, userID, params)
+	if err != nil {
+		return err
+	}
+	submitted := false
+	c.submitOnce.Do(func() {
+		c.inputChan <- &manualGateInput{userName: userName, values: values}
+		submitted = true
+	})
+	if !submitted {
+		return fmt.Errorf("job %s has been submitted already", c.job.Name)
+	}
+	return nil
+}
+
+// SubmitManualGate passes the input to the manual gate job waiting on this replica,
+// it returns false if the job is not waiting on this replica.
+func SubmitManualGate(workflowName, jobName string, taskID int64, userName, userID string, params []*commonmodels.Param) (bool, error) {
+	value, ok := manualGateMap.Load(getManualGateKey(workflowName, jobName, taskID))
+	if !ok {
+		return false, nil
+	}
+	ctl, ok := value.(*ManualGateJobCtl)
+	if !ok {
+		return true, fmt.Errorf("manual gate job type mismatched, id: %d, workflow name: %s, job name: %s", taskID, workflowName, jobName)
+	}
+	return true, ctl.submit(userName, userID, params)
+}
+
+// CheckManualGateInput checks whether the user is one of the gate users, and returns the values of the gate params,
+// params not submitted use the values in the workflow.
+func CheckManualGateInput(spec *commonmodels.JobTaskManualGateSpec, userName, userID string, params []*commonmodels.Param) (map[string]string, error) {
+	allowed := false
+	for _, user := range spec.Users {
+		if user.UserID != "" && user.UserID == userID {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("user %s has no authority to submit", userName)
+	}
+	submitted := make(map[string]string, len(params))
+	for _, param := range params {
+		submitted[param.Name] = param.Value
+	}
+	values := make(map[string]string, len(spec.Params))
+	for _, param := range spec.Params {
+		value, ok := submitted[param.Name]
+		if !ok {
+			value = param.Value
+		}
+		delete(submitted, param.Name)
+		if param.ParamsType == "choice" && !sets.NewString(param.ChoiceOption...).Has(value) {
+			return nil, fmt.Errorf("value %q of param %s is not in the choices", value, param.Name)
+		}
+		values[param.Name] = value
+	}
+	for name := range submitted {
+		return nil, fmt.Errorf("param %s not found", name)
+	}
+	return values, nil
+}
@@ -32,6 +32,7 @@ import (
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	"github.com/koderover/zadig/pkg/setting"
 	krkubeclient "github.com/koderover/zadig/pkg/tool/kube/client"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
 	"github.com/koderover/zadig/pkg/tool/kube/updater"
 )
 
@@ -62,7 +63,8 @@ func NewPluginsJobCtl(job *commonmodels.JobTask, workflowCtx *commonmodels.Workf
 	}
 }
 
-func (c *PluginJobCtl) prepare(ctx context.Context) {
+// setDefaults fills the properties which are not persisted in the job spec, it is also needed when the job is resumed.
+func (c *PluginJobCtl) setDefaults() {
 	// set default timeout
 	if c.jobTaskSpec.Properties.Timeout <= 0 {
 		c.jobTaskSpec.Properties.Timeout = 600
@@ -80,16 +82,45 @@ func (c *PluginJobCtl) prepare(ctx context.Context) {
 func (c *PluginJobCtl) Clean(ctx context.Context) {}
 
 func (c *PluginJobCtl) Run(ctx context.Context) {
-	c.prepare(ctx)
+	c.setDefaults()
 	if err := c.run(ctx); err != nil {
 		return
 	}
 	c.wait(ctx)
+	c.retry(ctx)
 	c.complete(ctx)
 }
 
-func (c *PluginJobCtl) run(ctx context.Context) error {
-	// get kube client
+// retry runs the kubernetes job again while it failed and the retry policy allows.
+func (c *PluginJobCtl) retry(ctx context.Context) {
+	for retryJob(ctx, c.job, &c.jobTaskSpec.Properties, c.workflowCtx, c.kubeclient, c.logger, c.ack) {
+		if err := c.run(ctx); err != nil {
+			return
+		}
+		c.wait(ctx)
+	}
+}
+
+// Resume re-attaches to the kubernetes job created before aslan restarted.
+func (c *PluginJobCtl) Resume(ctx context.Context) bool {
+	c.setDefaults()
+	if err := c.initKubeClients(); err != nil {
+		c.logger.Errorf("resume job %s error: %v", c.job.Name, err)
+		return false
+	}
+	_, found, err := getter.GetJob(c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, c.kubeclient)
+	if err != nil || !found {
+		c.logger.Infof("k8s job %s of job %s not found, err: %v", c.job.K8sJobName, c.job.Name, err)
+		return false
+	}
+	c.logger.Infof("resume job %s with k8s job %s", c.job.Name, c.job.K8sJobName)
+	c.wait(ctx)
+	c.retry(ctx)
+	c.complete(ctx)
+	return true
+}
+
+func (c *PluginJobCtl) initKubeClients() error {
 	hubServerAddr := config.HubServerAddress()
 	switch c.jobTaskSpec.Properties.ClusterID {
 	case setting.LocalClusterID:
@@ -103,7 +134,6 @@ func (c *PluginJobCtl) run(ctx context.Context) error {
 
 		crClient, clientset, restConfig, apiServer, err := GetK8sClients(hubServerAddr, c.jobTaskSpec.Properties.ClusterID)
 		if err != nil {
-			logError(c.job, err.Error(), c.logger)
 			return err
 		}
 		c.kubeclient = crClient
@@ -111,6 +141,15 @@ func (c *PluginJobCtl) run(ctx context.Context) error {
 		c.restConfig = restConfig
 		c.apiServer = apiServer
 	}
+	return nil
+}
+
+func (c *PluginJobCtl) run(ctx context.Context) error {
+	// get kube client
+	if err := c.initKubeClients(); err != nil {
+		logError(c.job, err.Error(), c.logger)
+		return err
+	}
 
 	jobLabel := &JobLabel{
 		JobType: string(c.job.JobType),
@@ -149,7 +188,7 @@ func (c *PluginJobCtl) run(ctx context.Context) error {
 
 func (c *PluginJobCtl) wait(ctx context.Context) {
 	var err error
-	timeout := time.After(time.Duration(c.jobTaskSpec.Properties.Timeout) * time.Minute)
+	timeout := time.After(remainingTimeout(c.job, c.jobTaskSpec.Properties.Timeout))
 	c.job.Status, err = waitJobStart(ctx, c.jobTaskSpec.Properties.Namespace, c.job.K8sJobName, c.kubeclient, c.apiServer, timeout, c.logger)
 	if err != nil {
 		c.logger.Errorf("wait job start error: %v", err)
@@ -184,7 +223,7 @@ func (c *PluginJobCtl) complete(ctx context.Context) {
 		c.job.Error = err.Error()
 	}
 
-	if err := saveContainerLog(c.jobTaskSpec.Properties.Namespace, c.jobTaskSpec.Properties.ClusterID, c.workflowCtx.WorkflowName, c.job.Name, c.workflowCtx.TaskID, jobLabel, c.kubeclient); err != nil {
+	if err := saveContainerLog(c.jobTaskSpec.Properties.Namespace, c.jobTaskSpec.Properties.ClusterID, c.workflowCtx.WorkflowName, c.job.Name, c.workflowCtx.TaskID, jobLabel, getJobSecrets(&c.jobTaskSpec.Properties, c.workflowCtx), c.kubeclient); err != nil {
 		c.logger.Error(err)
 		if c.job.Error == "" {
 			c.job.Error = err.Error()
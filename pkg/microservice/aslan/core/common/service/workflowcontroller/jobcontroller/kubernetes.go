@@ -31,6 +31,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hashicorp/go-multierror"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
 	batchv1 "k8s.io/api/batch/v1"
@@ -39,6 +40,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -61,7 +63,9 @@ import (
 	s3tool "github.com/koderover/zadig/pkg/tool/s3"
 	commontypes "github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
 	"github.com/koderover/zadig/pkg/util"
+	"github.com/koderover/zadig/pkg/util/mask"
 )
 
 const (
@@ -76,6 +80,10 @@ const (
 
 	defaultRetryCount    = 3
 	defaultRetryInterval = time.Second * 3
+
+	// resources of a job service without its own resource spec, in m and Mi
+	defaultServiceCpuLimit    = 500
+	defaultServiceMemoryLimit = 512
 )
 
 func GetK8sClients(hubServerAddr, clusterID string) (crClient.Client, kubernetes.Interface, *rest.Config, crClient.Reader, error) {
@@ -490,10 +498,93 @@ func buildJob(jobType, jobImage, jobName, clusterID, currentNamespace string, re
 			SubPath:   jobTaskSpec.Properties.Cache.NFSProperties.Subpath,
 		})
 	}
+
+	if dependencyCache := jobTaskSpec.Properties.DependencyCache; dependencyCache != nil && dependencyCache.MediumType == commontypes.NFSMedium {
+		volumeName := "dependency-cache"
+		job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: dependencyCache.NFSProperties.PVC,
+				},
+			},
+		})
+		// all the workflows of the project share the caches in the project dir.
+		job.Spec.Template.Spec.Containers[0].VolumeMounts = append(job.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      volumeName,
+			MountPath: setting.DependencyCacheMountDir,
+			SubPath:   path.Join(setting.DependencyCachePrefix, workflowCtx.ProjectName),
+		})
+	}
+
+	job.Spec.Template.Spec.Containers = append(job.Spec.Template.Spec.Containers, buildServiceContainers(jobTaskSpec.Properties.Services)...)
 	ensureVolumeMounts(job)
 	return job, nil
 }
 
+// buildServiceContainers renders the job services as sidecars of the job container,
+// the job container must stay the first container since the dog food file is checked in it.
+// Every service only takes its own resource spec rather than the resource class of the job.
+func buildServiceContainers(services []*commonmodels.ServiceContainer) []corev1.Container {
+	containers := make([]corev1.Container, 0, len(services))
+	for _, svc := range services {
+		container := corev1.Container{
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Name:            serviceContainerName(svc.Name),
+			Image:           svc.Image,
+			Command:         svc.Command,
+			Args:            svc.Args,
+			Resources:       getServiceResourceRequirements(svc.ResReqSpec),
+		}
+		for _, env := range svc.Envs {
+			container.Env = append(container.Env, corev1.EnvVar{Name: env.Key, Value: env.Value})
+		}
+		for _, port := range svc.Ports {
+			container.Ports = append(container.Ports, corev1.ContainerPort{ContainerPort: port, Protocol: corev1.ProtocolTCP})
+		}
+
+		probe := &corev1.Probe{
+			PeriodSeconds:    3,
+			FailureThreshold: 3,
+		}
+		switch {
+		case svc.ReadinessCommand != "":
+			probe.Exec = &corev1.ExecAction{Command: []string{"/bin/sh", "-c", svc.ReadinessCommand}}
+			container.ReadinessProbe = probe
+		case len(svc.Ports) > 0:
+			probe.TCPSocket = &corev1.TCPSocketAction{Port: intstr.FromInt(int(svc.Ports[0]))}
+			container.ReadinessProbe = probe
+		}
+		containers = append(containers, container)
+	}
+	return containers
+}
+
+func getServiceResourceRequirements(resReqSpec setting.RequestSpec) corev1.ResourceRequirements {
+	if resReqSpec.CpuLimit <= 0 {
+		resReqSpec.CpuLimit = defaultServiceCpuLimit
+	}
+	if resReqSpec.MemoryLimit <= 0 {
+		resReqSpec.MemoryLimit = defaultServiceMemoryLimit
+	}
+	return generateResourceRequirements(setting.DefineRequest, resReqSpec)
+}
+
+// serviceContainerName returns the name of the sidecar container of a job service.
+func serviceContainerName(name string) string {
+	return "svc-" + strings.ToLower(name)
+}
+
+// getContainerTerminatedState returns the terminated state of the container, nil if it is not terminated.
+func getContainerTerminatedState(pod *corev1.Pod, containerName string) *corev1.ContainerStateTerminated {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == containerName {
+			return status.State.Terminated
+		}
+	}
+	return nil
+}
+
 func BuildCleanJob(jobName, clusterID, workflowName string, taskID int64) (*batchv1.Job, error) {
 	workspace := "/workspace"
 	shareStorageDir := commontypes.GetShareStorageSubPathPrefix(workflowName, taskID)
@@ -933,6 +1024,14 @@ func waitJobEndWithFile(ctx context.Context, taskTimeout <-chan time.Time, names
 					if ipod.Failed() {
 						return config.StatusFailed, ""
 					}
+					// the pod keeps running after the job container exits if there are service sidecars
+					if terminated := getContainerTerminatedState(pod, ipod.ContainerNames()[0]); terminated != nil && !ipod.Finished() {
+						xl.Infof("Container %s of pod %s exited with code %d, stop to wait %s.", ipod.ContainerNames()[0], ipod.Name, terminated.ExitCode, job.Name)
+						if terminated.ExitCode != 0 {
+							return config.StatusFailed, terminated.Message
+						}
+						return config.StatusPassed, ""
+					}
 					if !ipod.Finished() {
 						jobStatus, exists, err = checkDogFoodExistsInContainerWithRetry(clientset, restConfig, namespace, ipod.Name, ipod.ContainerNames()[0], defaultRetryCount, defaultRetryInterval)
 						if err != nil {
@@ -970,6 +1069,61 @@ func waitJobEndWithFile(ctx context.Context, taskTimeout <-chan time.Time, names
 	}
 }
 
+// signalServicesReady creates the services ready file in the job container once all the service containers pass
+// their readiness probes, the job executor waits for the file before it runs the steps.
+func signalServicesReady(ctx context.Context, namespace, jobName string, kubeClient crClient.Client, clientset kubernetes.Interface, restConfig *rest.Config, xl *zap.SugaredLogger) {
+	ticker := time.NewTicker(defaultRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pods, err := getter.ListPods(namespace, labels.Set{"job-name": jobName}.AsSelector(), kubeClient)
+		if err != nil {
+			xl.Warnf("failed to find pod with label job-name=%s %v", jobName, err)
+			continue
+		}
+		for _, pod := range pods {
+			if !serviceContainersReady(pod) {
+				continue
+			}
+			_, stderr, success, err := podexec.KubeExec(clientset, restConfig, podexec.ExecOptions{
+				Command:       []string{"/bin/sh", "-c", fmt.Sprintf("touch %s", job.JobServicesReadyFile)},
+				Namespace:     namespace,
+				PodName:       pod.Name,
+				ContainerName: pod.Spec.Containers[0].Name,
+			})
+			if err != nil || !success {
+				xl.Warnf("failed to signal the services of job %s are ready: %v %s", jobName, err, stderr)
+				continue
+			}
+			xl.Infof("Services of job %s are ready.", jobName)
+			return
+		}
+	}
+}
+
+// serviceContainersReady tells whether all the service containers of the running pod are ready,
+// the first container is the job container.
+func serviceContainersReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning || len(pod.Spec.Containers) == 0 {
+		return false
+	}
+	ready := make(map[string]bool, len(pod.Status.ContainerStatuses))
+	for _, status := range pod.Status.ContainerStatuses {
+		ready[status.Name] = status.Ready
+	}
+	for _, container := range pod.Spec.Containers[1:] {
+		if !ready[container.Name] {
+			return false
+		}
+	}
+	return true
+}
+
 func getJobOutputFromTerminalMsg(namespace, containerName string, jobTask *commonmodels.JobTask, workflowCtx *commonmodels.WorkflowTaskCtx, kubeClient crClient.Client) error {
 	jobLabel := &JobLabel{
 		JobType: string(jobTask.JobType),
@@ -1035,14 +1189,164 @@ func getJobOutputFromRunningPod(namespace, containerName string, jobTask *common
 	return nil
 }
 
+// getStepResultsFromRunningPod reads the step results written by the job executor, and fills the status and timings of the steps.
+func getStepResultsFromRunningPod(namespace, containerName string, jobTask *commonmodels.JobTask, steps []*commonmodels.StepTask, kubeClient crClient.Client, clientset kubernetes.Interface, restConfig *rest.Config) error {
+	jobLabel := &JobLabel{
+		JobType: string(jobTask.JobType),
+		JobName: jobTask.K8sJobName,
+	}
+	pods, err := getter.ListPods(namespace, labels.Set(getJobLabels(jobLabel)).AsSelector(), kubeClient)
+	if err != nil {
+		return err
+	}
+	results := []*job.StepResult{}
+	for _, pod := range pods {
+		stdout, _, success, err := podexec.KubeExec(clientset, restConfig, podexec.ExecOptions{
+			Command:       []string{"/bin/sh", "-c", fmt.Sprintf("test -f %[1]s && cat %[1]s", job.JobStepResultFile)},
+			Namespace:     namespace,
+			PodName:       pod.Name,
+			ContainerName: containerName,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to exec pod: %v", err)
+		}
+		if !success {
+			return nil
+		}
+		if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+			return err
+		}
+		break
+	}
+
+	resultMap := make(map[string]*job.StepResult, len(results))
+	for _, result := range results {
+		resultMap[result.Name] = result
+	}
+	for _, step := range steps {
+		result, ok := resultMap[step.Name]
+		if !ok {
+			continue
+		}
+		step.Status = config.Status(result.Status)
+		step.StartTime = result.StartTime
+		step.EndTime = result.EndTime
+		step.Attempts = result.Attempts
+		if result.Error != "" {
+			step.Error = result.Error
+		}
+	}
+	return nil
+}
+
 func writeOutputs(outputs []*job.JobOutput, outputKey string, workflowCtx *commonmodels.WorkflowTaskCtx) {
 	// write jobs output info to globalcontext so other job can use like this {{.job.jobKey.output.outputName}}
 	for _, output := range outputs {
-		workflowCtx.GlobalContextSet(job.GetJobOutputKey(outputKey, output.Name), output.Value)
+		value := output.Value
+		// only the reference of a large output is kept in the task, it is downloaded when it is rendered.
+		if output.ObjectKey != "" {
+			value = job.GetOutputRef(output.StorageID, output.ObjectKey)
+		}
+		workflowCtx.GlobalContextSet(job.GetJobOutputKey(outputKey, output.Name), value)
+	}
+}
+
+// DownloadOutput gets the output value saved in the object storage since it is too large for the termination message,
+// outputs saved without the storage id are in the default storage.
+func DownloadOutput(storageID, objectKey string) (string, error) {
+	storage, client, err := newOutputStorageClient(storageID)
+	if err != nil {
+		return "", err
+	}
+	filename, err := util.GenerateTmpFile()
+	if err != nil {
+		return "", err
 	}
+	defer os.Remove(filename)
+	if err := client.Download(storage.Bucket, objectKey, filename); err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// DeleteOutputs deletes the large outputs saved in the object storage by the task, the outputs of the jobs carried
+// over from another task by retrying are left to that task.
+func DeleteOutputs(workflowName string, taskID int64, globalContext map[string]string) error {
+	taskDir := fmt.Sprintf("/%s/%d/", workflowName, taskID)
+	objectKeys := make(map[string][]string)
+	for _, value := range globalContext {
+		storageID, objectKey, ok := job.ParseOutputRef(value)
+		if !ok || !strings.Contains("/"+objectKey, taskDir) {
+			continue
+		}
+		objectKeys[storageID] = append(objectKeys[storageID], objectKey)
+	}
+
+	errs := &multierror.Error{}
+	for storageID, keys := range objectKeys {
+		storage, client, err := newOutputStorageClient(storageID)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		if err := client.DeleteObjects(storage.Bucket, keys); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("delete outputs of %s/%d error: %v", workflowName, taskID, err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func newOutputStorageClient(storageID string) (*commonmodels.S3Storage, *s3tool.Client, error) {
+	var (
+		storage *commonmodels.S3Storage
+		err     error
+	)
+	if storageID == "" {
+		storage, err = commonrepo.NewS3StorageColl().FindDefault()
+	} else {
+		storage, err = commonrepo.NewS3StorageColl().Find(storageID)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("find s3 storage %q error: %v", storageID, err)
+	}
+	forcedPathStyle := true
+	if storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3tool.NewClient(storage.Endpoint, storage.Ak, storage.Sk, storage.Region, storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create s3 client error: %v", err)
+	}
+	return storage, client, nil
+}
+
+func modelS3toS3(modelS3 *commonmodels.S3Storage) *step.S3 {
+	resp := &step.S3{
+		Ak:        modelS3.Ak,
+		Sk:        modelS3.Sk,
+		Endpoint:  modelS3.Endpoint,
+		Bucket:    modelS3.Bucket,
+		Subfolder: modelS3.Subfolder,
+		Insecure:  modelS3.Insecure,
+		Provider:  modelS3.Provider,
+		Region:    modelS3.Region,
+	}
+	if modelS3.Insecure {
+		resp.Protocol = "http"
+	}
+	return resp
+}
+
+// getJobSecrets returns the credentials visible to the job, which are masked in the job log.
+func getJobSecrets(properties *commonmodels.JobProperties, workflowCtx *commonmodels.WorkflowTaskCtx) []string {
+	return append(commonmodels.CredentialValues(properties.Envs, nil), commonmodels.CredentialValues(workflowCtx.WorkflowKeyVals, workflowCtx.WorkflowParams)...)
 }
 
-func saveContainerLog(namespace, clusterID, workflowName, jobName string, taskID int64, jobLabel *JobLabel, kubeClient crClient.Client) error {
+func saveContainerLog(namespace, clusterID, workflowName, jobName string, taskID int64, jobLabel *JobLabel, secrets []string, kubeClient crClient.Client) error {
 	selector := labels.Set(getJobLabels(jobLabel)).AsSelector()
 	pods, err := getter.ListPods(namespace, selector, kubeClient)
 	if err != nil {
@@ -1072,6 +1376,8 @@ func saveContainerLog(namespace, clusterID, workflowName, jobName string, taskID
 	if err := containerlog.GetContainerLogs(namespace, pods[0].Name, pods[0].Spec.Containers[0].Name, false, int64(0), buf, clientSet); err != nil {
 		return fmt.Errorf("failed to get container logs: %s", err)
 	}
+	// the job executor has masked the secrets, mask them again in case the log is printed by anything else in the pod.
+	buf = bytes.NewBufferString(mask.NewMasker(secrets).Mask(buf.String()))
 
 	store, err := commonrepo.NewS3StorageColl().FindDefault()
 	if err != nil {
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestServiceContainersReady(t *testing.T) {
+	newPod := func(phase corev1.PodPhase, ready map[string]bool) *corev1.Pod {
+		pod := &corev1.Pod{
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "job"}, {Name: "svc-mysql"}, {Name: "svc-redis"}}},
+			Status: corev1.PodStatus{
+				Phase: phase,
+			},
+		}
+		for name, isReady := range ready {
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{Name: name, Ready: isReady})
+		}
+		return pod
+	}
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want bool
+	}{
+		{
+			name: "pending pod",
+			pod:  newPod(corev1.PodPending, nil),
+			want: false,
+		},
+		{
+			name: "all services ready",
+			pod:  newPod(corev1.PodRunning, map[string]bool{"job": true, "svc-mysql": true, "svc-redis": true}),
+			want: true,
+		},
+		{
+			name: "job container is not checked",
+			pod:  newPod(corev1.PodRunning, map[string]bool{"job": false, "svc-mysql": true, "svc-redis": true}),
+			want: true,
+		},
+		{
+			name: "one service not ready",
+			pod:  newPod(corev1.PodRunning, map[string]bool{"job": true, "svc-mysql": true, "svc-redis": false}),
+			want: false,
+		},
+		{
+			name: "service status missing",
+			pod:  newPod(corev1.PodRunning, map[string]bool{"job": true, "svc-mysql": true}),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, serviceContainersReady(tt.pod))
+		})
+	}
+}
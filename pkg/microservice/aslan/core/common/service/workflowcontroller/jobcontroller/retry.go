@@ -0,0 +1,156 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	crClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	"github.com/koderover/zadig/pkg/types/job"
+)
+
+const (
+	defaultRetryBackoff    = 10 * time.Second
+	defaultMaxRetryBackoff = 5 * time.Minute
+)
+
+var imagePullReasons = sets.NewString("ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull")
+
+// retryJob records the failed run of the job as an attempt and waits for the backoff,
+// it returns true if the kubernetes job should be created again.
+func retryJob(ctx context.Context, jobTask *commonmodels.JobTask, properties *commonmodels.JobProperties, workflowCtx *commonmodels.WorkflowTaskCtx, kubeClient crClient.Client, logger *zap.SugaredLogger, ack func()) bool {
+	if jobTask.Status != config.StatusFailed && jobTask.Status != config.StatusTimeout {
+		return false
+	}
+	if int64(len(jobTask.Attempts)) >= properties.Retry {
+		return false
+	}
+	failureClass := getFailureClass(jobTask.Status, properties.Namespace, jobTask.K8sJobName, kubeClient)
+	if !shouldRetryOn(properties.RetryPolicy, failureClass) {
+		logger.Infof("job %s failed with %s, which is not retried", jobTask.Name, failureClass)
+		return false
+	}
+
+	attempt := &commonmodels.JobAttempt{
+		Attempt:      len(jobTask.Attempts) + 1,
+		K8sJobName:   jobTask.K8sJobName,
+		Status:       jobTask.Status,
+		FailureClass: failureClass,
+		Error:        jobTask.Error,
+		StartTime:    jobTask.StartTime,
+		EndTime:      time.Now().Unix(),
+		LogName:      job.GetJobAttemptLogName(jobTask.Name, len(jobTask.Attempts)+1),
+	}
+	jobLabel := &JobLabel{
+		JobType: string(jobTask.JobType),
+		JobName: jobTask.K8sJobName,
+	}
+	if err := saveContainerLog(properties.Namespace, properties.ClusterID, workflowCtx.WorkflowName, attempt.LogName, workflowCtx.TaskID, jobLabel, getJobSecrets(properties, workflowCtx), kubeClient); err != nil {
+		logger.Errorf("save log of job %s attempt %d error: %v", jobTask.Name, attempt.Attempt, err)
+	}
+	jobTask.Attempts = append(jobTask.Attempts, attempt)
+
+	backoff := getRetryBackoff(properties.RetryPolicy, attempt.Attempt)
+	logger.Infof("job %s failed with %s, retry %d/%d in %s", jobTask.Name, failureClass, attempt.Attempt, properties.Retry, backoff)
+	jobTask.Status = config.StatusPrepare
+	jobTask.Error = ""
+	ack()
+	select {
+	case <-ctx.Done():
+		// keep the kubernetes job of the last attempt, it is cleaned up when the job completes.
+		jobTask.Status = config.StatusCancelled
+		return false
+	case <-time.After(backoff):
+	}
+
+	if err := ensureDeleteJob(properties.Namespace, jobLabel, kubeClient); err != nil {
+		logger.Error(err)
+	}
+	if err := ensureDeleteConfigMap(properties.Namespace, jobLabel, kubeClient); err != nil {
+		logger.Error(err)
+	}
+	jobTask.StartTime = time.Now().Unix()
+	jobTask.K8sJobName = getJobName(workflowCtx.WorkflowName, workflowCtx.TaskID)
+	ack()
+	return true
+}
+
+// getFailureClass tells why the kubernetes job failed from the status of its pods.
+func getFailureClass(status config.Status, namespace, jobName string, kubeClient crClient.Client) config.FailureClass {
+	if status == config.StatusTimeout {
+		return config.FailureClassTimeout
+	}
+	pods, err := getter.ListPods(namespace, labels.Set{"job-name": jobName}.AsSelector(), kubeClient)
+	if err != nil {
+		return config.FailureClassExitCode
+	}
+	return classifyPodFailure(pods)
+}
+
+// classifyPodFailure returns evicted or image_pull if any pod of the job failed for it, otherwise exit_code.
+func classifyPodFailure(pods []*corev1.Pod) config.FailureClass {
+	for _, pod := range pods {
+		if pod.Status.Reason == "Evicted" {
+			return config.FailureClassEvicted
+		}
+		for _, containerStatus := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			if containerStatus.State.Waiting != nil && imagePullReasons.Has(containerStatus.State.Waiting.Reason) {
+				return config.FailureClassImagePull
+			}
+		}
+	}
+	return config.FailureClassExitCode
+}
+
+func shouldRetryOn(policy *commonmodels.RetryPolicy, failureClass config.FailureClass) bool {
+	if policy == nil || len(policy.RetryOn) == 0 {
+		return true
+	}
+	for _, retryOn := range policy.RetryOn {
+		if retryOn == failureClass {
+			return true
+		}
+	}
+	return false
+}
+
+// getRetryBackoff doubles the interval for every retry, until it reaches the max interval.
+func getRetryBackoff(policy *commonmodels.RetryPolicy, attempt int) time.Duration {
+	interval, maxInterval := defaultRetryBackoff, defaultMaxRetryBackoff
+	if policy != nil && policy.Interval > 0 {
+		interval = time.Duration(policy.Interval) * time.Second
+	}
+	if policy != nil && policy.MaxInterval > 0 {
+		maxInterval = time.Duration(policy.MaxInterval) * time.Second
+	}
+	for i := 1; i < attempt && interval < maxInterval; i++ {
+		interval *= 2
+	}
+	if interval > maxInterval {
+		return maxInterval
+	}
+	return interval
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobcontroller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func TestClassifyPodFailure(t *testing.T) {
+	waiting := func(reason string) corev1.ContainerStatus {
+		return corev1.ContainerStatus{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}}}
+	}
+	tests := []struct {
+		name string
+		pods []*corev1.Pod
+		want config.FailureClass
+	}{
+		{
+			name: "no pod",
+			want: config.FailureClassExitCode,
+		},
+		{
+			name: "container exited",
+			pods: []*corev1.Pod{{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}},
+			}}}},
+			want: config.FailureClassExitCode,
+		},
+		{
+			name: "pod evicted",
+			pods: []*corev1.Pod{{Status: corev1.PodStatus{Reason: "Evicted"}}},
+			want: config.FailureClassEvicted,
+		},
+		{
+			name: "image pull back off",
+			pods: []*corev1.Pod{{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{waiting("ImagePullBackOff")}}}},
+			want: config.FailureClassImagePull,
+		},
+		{
+			name: "init container image not found",
+			pods: []*corev1.Pod{{Status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{waiting("ErrImagePull")}}}},
+			want: config.FailureClassImagePull,
+		},
+		{
+			name: "container waiting for other reason",
+			pods: []*corev1.Pod{{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{waiting("CrashLoopBackOff")}}}},
+			want: config.FailureClassExitCode,
+		},
+		{
+			name: "evicted pod after failed pod",
+			pods: []*corev1.Pod{
+				{Status: corev1.PodStatus{}},
+				{Status: corev1.PodStatus{Reason: "Evicted"}},
+			},
+			want: config.FailureClassEvicted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyPodFailure(tt.pods))
+		})
+	}
+}
+
+func TestShouldRetryOn(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       *commonmodels.RetryPolicy
+		failureClass config.FailureClass
+		want         bool
+	}{
+		{
+			name:         "no policy",
+			failureClass: config.FailureClassExitCode,
+			want:         true,
+		},
+		{
+			name:         "policy without retry on",
+			policy:       &commonmodels.RetryPolicy{Interval: 5},
+			failureClass: config.FailureClassTimeout,
+			want:         true,
+		},
+		{
+			name:         "failure class in retry on",
+			policy:       &commonmodels.RetryPolicy{RetryOn: []config.FailureClass{config.FailureClassEvicted, config.FailureClassImagePull}},
+			failureClass: config.FailureClassImagePull,
+			want:         true,
+		},
+		{
+			name:         "failure class not in retry on",
+			policy:       &commonmodels.RetryPolicy{RetryOn: []config.FailureClass{config.FailureClassEvicted}},
+			failureClass: config.FailureClassExitCode,
+			want:         false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, shouldRetryOn(tt.policy, tt.failureClass))
+		})
+	}
+}
+
+func TestGetRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *commonmodels.RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{
+			name:    "default first retry",
+			attempt: 1,
+			want:    defaultRetryBackoff,
+		},
+		{
+			name:    "default third retry",
+			attempt: 3,
+			want:    4 * defaultRetryBackoff,
+		},
+		{
+			name:    "default capped",
+			attempt: 10,
+			want:    defaultMaxRetryBackoff,
+		},
+		{
+			name:    "custom interval",
+			policy:  &commonmodels.RetryPolicy{Interval: 3},
+			attempt: 2,
+			want:    6 * time.Second,
+		},
+		{
+			name:    "custom max interval",
+			policy:  &commonmodels.RetryPolicy{Interval: 20, MaxInterval: 30},
+			attempt: 2,
+			want:    30 * time.Second,
+		},
+		{
+			name:    "interval larger than max interval",
+			policy:  &commonmodels.RetryPolicy{Interval: 60, MaxInterval: 30},
+			attempt: 1,
+			want:    30 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getRetryBackoff(tt.policy, tt.attempt))
+		})
+	}
+}
@@ -18,6 +18,8 @@ package jobcontroller
 
 import (
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
 )
 
 type JobContext struct {
@@ -29,6 +31,12 @@ type JobContext struct {
 	Envs EnvVar `yaml:"envs"`
 	// SecretEnvs 用户注入敏感信息环境变量, value不能在stdout stderr中输出 [optional]
 	SecretEnvs EnvVar `yaml:"secret_envs"`
+	// SecretFiles 以文件形式提供的敏感信息, 文件只有当前用户可读, 同名环境变量的值为文件路径 [optional]
+	SecretFiles EnvVar `yaml:"secret_files"`
+	// Credentials 其他需要在日志中脱敏的敏感信息, 如工作流的敏感参数 [optional]
+	Credentials []string `yaml:"credentials"`
+	// WaitServices 在执行步骤前等待服务容器就绪, aslan 在服务容器就绪后创建 JobServicesReadyFile [optional]
+	WaitServices bool `yaml:"wait_services"`
 	// WorkflowName
 	WorkflowName string `yaml:"workflow_name"`
 	// TaskID
@@ -38,6 +46,12 @@ type JobContext struct {
 
 	Steps   []*commonmodels.StepTask `yaml:"steps"`
 	Outputs []string                 `yaml:"outputs"`
+	// OutputTypes is the type of the outputs, outputs not in it are strings.
+	OutputTypes map[string]job.OutputType `yaml:"output_types"`
+	// OutputStorage saves the output values too large for the termination message.
+	OutputStorage *step.S3 `yaml:"output_storage"`
+	// OutputStorageID is the id of OutputStorage, it is kept with the outputs saved in it.
+	OutputStorageID string `yaml:"output_storage_id"`
 }
 
 type EnvVar []string
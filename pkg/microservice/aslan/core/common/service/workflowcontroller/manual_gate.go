@@ -0,0 +1,79 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"fmt"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller/jobcontroller"
+)
+
+type ManualGateMessage struct {
+	WorkflowName string                `json:"workflow_name"`
+	JobName      string                `json:"job_name"`
+	TaskID       int64                 `json:"task_id"`
+	UserName     string                `json:"user_name"`
+	UserID       string                `json:"user_id"`
+	Params       []*commonmodels.Param `json:"params"`
+}
+
+// SubmitManualGate submits the input of a manual gate job and lets the workflow go on.
+func SubmitManualGate(workflowName, jobName, userName, userID string, taskID int64, params []*commonmodels.Param) error {
+	found, err := jobcontroller.SubmitManualGate(workflowName, jobName, taskID, userName, userID, params)
+	if err != nil || found {
+		return err
+	}
+	// the task may be running on another aslan replica, check the persisted job and send the input to the owner.
+	if err := checkPersistedManualGate(workflowName, jobName, userName, userID, taskID, params); err != nil {
+		return err
+	}
+	return publishManualGateMessage(&ManualGateMessage{
+		WorkflowName: workflowName,
+		JobName:      jobName,
+		TaskID:       taskID,
+		UserName:     userName,
+		UserID:       userID,
+		Params:       params,
+	})
+}
+
+func checkPersistedManualGate(workflowName, jobName, userName, userID string, taskID int64, params []*commonmodels.Param) error {
+	task, err := mongodb.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+	if err != nil {
+		return fmt.Errorf("workflow %s ID %d not found: %v", workflowName, taskID, err)
+	}
+	for _, stage := range task.Stages {
+		for _, job := range stage.Jobs {
+			if job.Name != jobName {
+				continue
+			}
+			if job.JobType != string(config.JobManualGate) || job.Status != config.StatusWaitingApprove {
+				return fmt.Errorf("workflow %s ID %d job %s is not waiting for input", workflowName, taskID, jobName)
+			}
+			spec := &commonmodels.JobTaskManualGateSpec{}
+			if err := commonmodels.IToi(job.Spec, spec); err != nil {
+				return err
+			}
+			_, err := jobcontroller.CheckManualGateInput(spec, userName, userID, params)
+			return err
+		}
+	}
+	return fmt.Errorf("workflow %s ID %d job %s not found", workflowName, taskID, jobName)
+}
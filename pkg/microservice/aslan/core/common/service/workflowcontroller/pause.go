@@ -0,0 +1,155 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+)
+
+// pauseMap records the controllers of the tasks running on this replica, so they can be paused and resumed.
+var pauseMap sync.Map
+
+type PauseMessage struct {
+	WorkflowName string `json:"workflow_name"`
+	TaskID       int64  `json:"task_id"`
+	UserName     string `json:"user_name"`
+	Pause        bool   `json:"pause"`
+}
+
+// pauseSwitch stops the jobs from starting while the task is paused, the running jobs are not affected.
+type pauseSwitch struct {
+	sync.Mutex
+	paused bool
+	resume chan struct{}
+}
+
+func newPauseSwitch(paused bool) *pauseSwitch {
+	s := &pauseSwitch{}
+	s.set(paused)
+	return s
+}
+
+// set returns false if the switch is already in the state.
+func (s *pauseSwitch) set(paused bool) bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.paused == paused {
+		return false
+	}
+	s.paused = paused
+	if paused {
+		s.resume = make(chan struct{})
+	} else {
+		close(s.resume)
+	}
+	return true
+}
+
+// wait returns a channel closed when the task is resumed, or nil if the task is not paused.
+func (s *pauseSwitch) wait() <-chan struct{} {
+	s.Lock()
+	defer s.Unlock()
+	if !s.paused {
+		return nil
+	}
+	return s.resume
+}
+
+func (c *workflowCtl) setPaused(paused bool) bool {
+	if !c.pause.set(paused) {
+		return false
+	}
+	c.workflowTask.Paused = paused
+	c.ack()
+	return true
+}
+
+// waitIfPaused is called before a job starts, it blocks until the task is resumed or cancelled.
+func (c *workflowCtl) waitIfPaused(ctx context.Context) error {
+	resume := c.pause.wait()
+	if resume == nil {
+		return nil
+	}
+	c.logger.Infof("workflow %s:%d paused", c.workflowTask.WorkflowName, c.workflowTask.TaskID)
+	c.setWaiting(config.StatusPaused, true)
+	defer c.setWaiting(config.StatusPaused, false)
+	select {
+	case <-ctx.Done():
+		return errors.New("workflow was canceled")
+	case <-resume:
+		c.logger.Infof("workflow %s:%d resumed", c.workflowTask.WorkflowName, c.workflowTask.TaskID)
+		return nil
+	}
+}
+
+// PauseWorkflowTask pauses the task after its running jobs finished, or resumes a paused task.
+func PauseWorkflowTask(userName, workflowName string, taskID int64, pause bool, logger *zap.SugaredLogger) error {
+	t, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+	if err != nil {
+		logger.Errorf("[%s] task: %s:%d not found", userName, workflowName, taskID)
+		return err
+	}
+	if t.Status != config.StatusRunning && t.Status != config.StatusWaitingApprove && t.Status != config.StatusPaused {
+		return fmt.Errorf("task: %s:%d is %s, only running task can be paused or resumed", workflowName, taskID, t.Status)
+	}
+	if t.Paused == pause {
+		return fmt.Errorf("task: %s:%d is already %s", workflowName, taskID, pauseAction(pause))
+	}
+
+	found, err := pauseLocalTask(workflowName, taskID, pause)
+	if err != nil || found {
+		return err
+	}
+	// the task may be running on another aslan replica.
+	logger.Infof("task %s:%d not running on this replica, send pause message", workflowName, taskID)
+	return publishPauseMessage(&PauseMessage{
+		WorkflowName: workflowName,
+		TaskID:       taskID,
+		UserName:     userName,
+		Pause:        pause,
+	})
+}
+
+func pauseLocalTask(workflowName string, taskID int64, pause bool) (bool, error) {
+	value, ok := pauseMap.Load(getTaskKey(workflowName, taskID))
+	if !ok {
+		return false, nil
+	}
+	ctl, ok := value.(*workflowCtl)
+	if !ok {
+		return true, fmt.Errorf("workflow controller type mismatched, id: %d, workflow name: %s", taskID, workflowName)
+	}
+	if !ctl.setPaused(pause) {
+		return true, fmt.Errorf("task: %s:%d is already %s", workflowName, taskID, pauseAction(pause))
+	}
+	return true, nil
+}
+
+func pauseAction(pause bool) string {
+	if pause {
+		return "paused"
+	}
+	return "resumed"
+}
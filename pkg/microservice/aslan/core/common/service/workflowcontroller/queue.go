@@ -17,7 +17,6 @@ limitations under the License.
 package workflowcontroller
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -58,6 +57,7 @@ func PendingTasks() []*commonmodels.WorkflowQueue {
 			tasks = append(tasks, t)
 		}
 	}
+	sortPendingTasks(tasks)
 	return tasks
 }
 
@@ -75,7 +75,11 @@ func CreateTask(t *commonmodels.WorkflowTask) error {
 		log.Errorf("create workflow task v4 error: %v", err)
 		return err
 	}
-	return Push(t)
+	if err := Push(t); err != nil {
+		return err
+	}
+	cancelSupersededTasks(t)
+	return nil
 }
 
 func UpdateTask(t *commonmodels.WorkflowTask) error {
@@ -112,14 +116,19 @@ func Push(t *commonmodels.WorkflowTask) error {
 
 func InitWorkflowController() {
 	InitQueue()
+	if err := subscribeWorkflowMessages(); err != nil {
+		log.Errorf("subscribe workflow messages error: %v", err)
+	}
 	go WorfklowTaskSender()
+	go WorkflowTaskClaimer()
+	go renewTaskLeases()
 }
 
 func InitQueue() error {
 	log := log.SugaredLogger()
 
 	// 从数据库查找未完成的任务
-	// status = created, running
+	// status = created, running, queued, waitforapprove, paused
 	tasks, err := commonrepo.NewworkflowTaskv4Coll().InCompletedTasks()
 	if err != nil {
 		log.Errorf("find [InCompletedTasks] error: %v", err)
@@ -127,65 +136,66 @@ func InitQueue() error {
 	}
 
 	for _, task := range tasks {
-		// 如果 Queue 重新初始化, 取消所有 running tasks
-		if err := CancelWorkflowTask(setting.DefaultTaskRevoker, task.WorkflowName, task.TaskID, log); err != nil {
-			log.Errorf("[CancelRunningTask] error: %v", err)
-			continue
+		// tasks still in queue will be resumed by the replica which claims them, see WorkflowTaskClaimer.
+		// stages and jobs already finished will be skipped.
+		if err := checkResumable(task); err != nil {
+			log.Errorf("workflow task %s:%d can not be resumed: %v, cancel it", task.WorkflowName, task.TaskID, err)
+			if err := CancelWorkflowTask(setting.DefaultTaskRevoker, task.WorkflowName, task.TaskID, log); err != nil {
+				log.Errorf("[CancelRunningTask] error: %v", err)
+			}
 		}
 	}
 	return nil
 }
 
-// WorfklowTaskSender 监控warpdrive空闲情况, 如果有空闲, 则发现下一个waiting task给warpdrive
-// 并将task状态设置为queued
+func checkResumable(task *commonmodels.WorkflowTask) error {
+	if len(task.Stages) == 0 {
+		return fmt.Errorf("task %s:%d has no stages to resume", task.WorkflowName, task.TaskID)
+	}
+	queues, err := commonrepo.NewWorkflowQueueColl().List(&commonrepo.ListWorfklowQueueOption{WorkflowName: task.WorkflowName})
+	if err != nil {
+		return fmt.Errorf("list workflow queue error: %v", err)
+	}
+	for _, q := range queues {
+		if q.TaskID == task.TaskID {
+			return nil
+		}
+	}
+	return fmt.Errorf("task %s:%d not found in queue", task.WorkflowName, task.TaskID)
+}
+
+// WorfklowTaskSender 监控warpdrive空闲情况, 如果有空闲, 则按优先级发送等待中的task给warpdrive
+// 并将task状态设置为queued, 只有持有 scheduler lease 的副本会调度任务
 func WorfklowTaskSender() {
 	for {
 		time.Sleep(time.Second * 3)
 
+		if !isSchedulerLeader() {
+			continue
+		}
+
 		sysSetting, err := commonrepo.NewSystemSettingColl().Get()
 		if err != nil {
 			log.Errorf("get system stettings error: %v", err)
-		}
-		//c.checkAgents()
-		if !hasAgentAvaiable(int(sysSetting.WorkflowConcurrency)) {
 			continue
 		}
-		t, err := NextWaitingTask()
-		if err != nil {
-			// no waiting task found
-			blockTasks, err := BlockedTaskQueue()
-			if err != nil {
-				//no blocked task found
+		scheduler := newTaskScheduler(ListTasks(), int(sysSetting.WorkflowConcurrency))
+		for _, t := range scheduler.pending {
+			if !scheduler.hasAgentAvailable() {
+				break
+			}
+			if !scheduler.canRun(t) {
 				continue
 			}
-			for _, blockTask := range blockTasks {
-				if hasAgentAvaiable(int(sysSetting.WorkflowConcurrency)) {
-					//判断相同的工作流是否正在运行
-					if ParallelRunningAndQueuedTasks(blockTask) {
-						continue
-					}
-					// update agent and queue
-					if err := updateQueueAndRunTask(blockTask, int(sysSetting.BuildConcurrency)); err != nil {
-						continue
-					}
-				} else {
-					break
-				}
+			// update agent and queue
+			if err := updateQueueToQueued(t); err != nil {
+				continue
 			}
-			continue
-		}
-		// update agent and queue
-		if err := updateQueueAndRunTask(t, int(sysSetting.BuildConcurrency)); err != nil {
-			continue
+			scheduler.add(t)
 		}
-
 	}
 }
 
-func hasAgentAvaiable(workflowConcurrency int) bool {
-	return len(RunningAndQueuedTasks()) < int(workflowConcurrency)
-}
-
 func RunningAndQueuedTasks() []*commonmodels.WorkflowQueue {
 	tasks := make([]*commonmodels.WorkflowQueue, 0)
 	for _, t := range ListTasks() {
@@ -206,51 +216,8 @@ func ListTasks() []*commonmodels.WorkflowQueue {
 	return queues
 }
 
-// NextWaitingTask 查询下一个等待的task
-func NextWaitingTask() (*commonmodels.WorkflowQueue, error) {
-	opt := &commonrepo.ListWorfklowQueueOption{
-		Status: config.StatusWaiting,
-	}
-
-	tasks, err := commonrepo.NewWorkflowQueueColl().List(opt)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, t := range tasks {
-		return t, nil
-	}
-
-	return nil, errors.New("no waiting task found")
-}
-
-func BlockedTaskQueue() ([]*commonmodels.WorkflowQueue, error) {
-	opt := &commonrepo.ListWorfklowQueueOption{
-		Status: config.StatusBlocked,
-	}
-
-	queues, err := commonrepo.NewWorkflowQueueColl().List(opt)
-	if err != nil || len(queues) == 0 {
-		return nil, errors.New("no blocked task found")
-	}
-
-	return queues, nil
-}
-
-func ParallelRunningAndQueuedTasks(currentTask *commonmodels.WorkflowQueue) bool {
-	for _, t := range ListTasks() {
-		// task状态为TaskQueued说明task已经被send到nsq,wd已经开始处理但是没有返回ack
-		if t.Status != config.StatusRunning && t.Status != config.StatusQueued {
-			continue
-		}
-		if t.WorkflowName == currentTask.WorkflowName {
-			return true
-		}
-	}
-	return false
-}
-
-func updateQueueAndRunTask(t *commonmodels.WorkflowQueue, jobConcurrency int) error {
+// updateQueueToQueued marks the task as queued, it will be claimed and run by one of the replicas.
+func updateQueueToQueued(t *commonmodels.WorkflowQueue) error {
 	logger := log.SugaredLogger()
 	// 更新队列状态为TaskQueued
 	workflowTask, err := commonrepo.NewworkflowTaskv4Coll().Find(t.WorkflowName, t.TaskID)
@@ -263,8 +230,6 @@ func updateQueueAndRunTask(t *commonmodels.WorkflowQueue, jobConcurrency int) er
 		logger.Errorf("%s:%d update t status error", t.WorkflowName, t.TaskID)
 		return fmt.Errorf("%s:%d update t status error", t.WorkflowName, t.TaskID)
 	}
-	ctx := context.Background()
-	go NewWorkflowController(workflowTask, logger).Run(ctx, jobConcurrency)
 	return nil
 }
 
@@ -287,6 +252,8 @@ func ConvertTaskToQueue(task *commonmodels.WorkflowTask) *commonmodels.WorkflowQ
 		TaskRevoker:         task.TaskRevoker,
 		CreateTime:          task.CreateTime,
 		MultiRun:            task.MultiRun,
+		Priority:            task.Priority,
+		Concurrency:         task.Concurrency,
 	}
 }
 
@@ -0,0 +1,288 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	nsqservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/nsq"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller/jobcontroller"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+// Every aslan replica runs the workflow tasks it claimed from the WorkflowQueue and keeps renewing the
+// lease of them, a task whose lease expired will be taken over by another replica.
+// Only the replica holding the scheduler lease decides which waiting tasks can be queued.
+const (
+	workflowSchedulerLease = "workflow-v4-scheduler"
+	leaseTTL               = 30 * time.Second
+	leaseRenewInterval     = 10 * time.Second
+)
+
+// runningTaskMap records the queue tasks running on this replica.
+var runningTaskMap sync.Map
+
+type runningTask struct {
+	queue *commonmodels.WorkflowQueue
+	ctl   *workflowCtl
+	// cancel stops the task running on this replica, it is called when the lease is taken by another replica.
+	cancel context.CancelFunc
+}
+
+type ApproveMessage struct {
+	WorkflowName string `json:"workflow_name"`
+	StageName    string `json:"stage_name"`
+	TaskID       int64  `json:"task_id"`
+	UserName     string `json:"user_name"`
+	UserID       string `json:"user_id"`
+	Comment      string `json:"comment"`
+	Approve      bool   `json:"approve"`
+}
+
+func getTaskKey(workflowName string, taskID int64) string {
+	return fmt.Sprintf("%s-%d", workflowName, taskID)
+}
+
+func isSchedulerLeader() bool {
+	acquired, err := commonrepo.NewLeaseColl().Acquire(workflowSchedulerLease, config.PodName(), leaseTTL)
+	if err != nil {
+		log.Errorf("acquire workflow scheduler lease error: %v", err)
+		return false
+	}
+	return acquired
+}
+
+// WorkflowTaskClaimer claims the queued tasks and the tasks left by a dead replica, and runs them on this replica.
+func WorkflowTaskClaimer() {
+	for {
+		time.Sleep(time.Second * 3)
+
+		sysSetting, err := commonrepo.NewSystemSettingColl().Get()
+		if err != nil {
+			log.Errorf("get system stettings error: %v", err)
+			continue
+		}
+		queues, err := commonrepo.NewWorkflowQueueColl().ListClaimable([]config.Status{config.StatusQueued, config.StatusRunning, config.StatusWaitingApprove, config.StatusPaused})
+		if err != nil {
+			log.Errorf("list claimable workflow queue error: %v", err)
+			continue
+		}
+		for _, q := range queues {
+			claimAndRunTask(q, int(sysSetting.BuildConcurrency))
+		}
+	}
+}
+
+func claimAndRunTask(q *commonmodels.WorkflowQueue, jobConcurrency int) {
+	logger := log.SugaredLogger()
+	taskKey := getTaskKey(q.WorkflowName, q.TaskID)
+	if _, ok := runningTaskMap.Load(taskKey); ok {
+		return
+	}
+	acquired, err := commonrepo.NewWorkflowQueueColl().AcquireLease(q, config.PodName(), leaseTTL)
+	if err != nil {
+		logger.Errorf("%s:%d acquire lease error: %v", q.WorkflowName, q.TaskID, err)
+		return
+	}
+	if !acquired {
+		return
+	}
+	workflowTask, err := commonrepo.NewworkflowTaskv4Coll().Find(q.WorkflowName, q.TaskID)
+	if err != nil {
+		logger.Errorf("%s:%d get workflow task error: %v", q.WorkflowName, q.TaskID, err)
+		return
+	}
+	if q.Status != config.StatusQueued {
+		logger.Infof("take over workflow task %s:%d, previous owner: %s", q.WorkflowName, q.TaskID, q.Owner)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctl := NewWorkflowController(workflowTask, logger)
+	runningTaskMap.Store(taskKey, &runningTask{queue: q, ctl: ctl, cancel: cancel})
+	go func() {
+		defer runningTaskMap.Delete(taskKey)
+		defer cancel()
+		ctl.Run(ctx, jobConcurrency)
+	}()
+}
+
+// renewTaskLeases keeps the tasks running on this replica from being taken over.
+func renewTaskLeases() {
+	for {
+		time.Sleep(leaseRenewInterval)
+
+		runningTaskMap.Range(func(key, value interface{}) bool {
+			t, ok := value.(*runningTask)
+			if !ok {
+				return true
+			}
+			q := t.queue
+			renewed, err := commonrepo.NewWorkflowQueueColl().RenewLease(q, config.PodName(), leaseTTL)
+			if err != nil {
+				log.Errorf("%s:%d renew lease error: %v", q.WorkflowName, q.TaskID, err)
+				return true
+			}
+			if !renewed {
+				// another replica may have taken over the task, stop it here so it is not run twice.
+				log.Warnf("%s:%d is no longer owned by %s, stop running it", q.WorkflowName, q.TaskID, config.PodName())
+				t.ctl.loseLease()
+				t.cancel()
+			}
+			return true
+		})
+	}
+}
+
+// subscribeWorkflowMessages uses an ephemeral channel per replica, so every replica receives the message
+// and only the one running the task handles it.
+func subscribeWorkflowMessages() error {
+	channel := fmt.Sprintf("%s#ephemeral", config.PodName())
+	if err := nsqservice.SubScribeSimple(setting.TopicWorkflowCancel, channel, &cancelMessageHandler{log: log.SugaredLogger()}); err != nil {
+		return fmt.Errorf("subscribe to %s error: %v", setting.TopicWorkflowCancel, err)
+	}
+	if err := nsqservice.SubScribeSimple(setting.TopicWorkflowApprove, channel, &approveMessageHandler{log: log.SugaredLogger()}); err != nil {
+		return fmt.Errorf("subscribe to %s error: %v", setting.TopicWorkflowApprove, err)
+	}
+	if err := nsqservice.SubScribeSimple(setting.TopicWorkflowPause, channel, &pauseMessageHandler{log: log.SugaredLogger()}); err != nil {
+		return fmt.Errorf("subscribe to %s error: %v", setting.TopicWorkflowPause, err)
+	}
+	if err := nsqservice.SubScribeSimple(setting.TopicWorkflowManualGate, channel, &manualGateMessageHandler{log: log.SugaredLogger()}); err != nil {
+		return fmt.Errorf("subscribe to %s error: %v", setting.TopicWorkflowManualGate, err)
+	}
+	return nil
+}
+
+func publishCancelMessage(msg *CancelMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return nsqservice.Publish(setting.TopicWorkflowCancel, b)
+}
+
+func publishApproveMessage(msg *ApproveMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return nsqservice.Publish(setting.TopicWorkflowApprove, b)
+}
+
+func publishPauseMessage(msg *PauseMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return nsqservice.Publish(setting.TopicWorkflowPause, b)
+}
+
+func publishManualGateMessage(msg *ManualGateMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return nsqservice.Publish(setting.TopicWorkflowManualGate, b)
+}
+
+type cancelMessageHandler struct {
+	log *zap.SugaredLogger
+}
+
+func (h *cancelMessageHandler) HandleMessage(message *nsq.Message) error {
+	var msg *CancelMessage
+	if err := json.Unmarshal(message.Body, &msg); err != nil {
+		h.log.Errorf("unmarshal cancel message error: %v", err)
+		return nil
+	}
+	found, err := cancelLocalTask(msg.PipelineName, msg.TaskID)
+	if err != nil {
+		h.log.Error(err)
+		return nil
+	}
+	if found {
+		h.log.Infof("[%s] cancel workflow task %s:%d", msg.Revoker, msg.PipelineName, msg.TaskID)
+	}
+	return nil
+}
+
+type approveMessageHandler struct {
+	log *zap.SugaredLogger
+}
+
+func (h *approveMessageHandler) HandleMessage(message *nsq.Message) error {
+	var msg *ApproveMessage
+	if err := json.Unmarshal(message.Body, &msg); err != nil {
+		h.log.Errorf("unmarshal approve message error: %v", err)
+		return nil
+	}
+	approveWithL, ok := globalApproveMap.getApproval(fmt.Sprintf("%s-%d-%s", msg.WorkflowName, msg.TaskID, msg.StageName))
+	if !ok {
+		return nil
+	}
+	if err := approveWithL.doApproval(msg.UserName, msg.UserID, msg.Comment, msg.Approve); err != nil {
+		h.log.Errorf("approve workflow %s ID %d stage %s error: %v", msg.WorkflowName, msg.TaskID, msg.StageName, err)
+	}
+	return nil
+}
+
+type pauseMessageHandler struct {
+	log *zap.SugaredLogger
+}
+
+func (h *pauseMessageHandler) HandleMessage(message *nsq.Message) error {
+	var msg *PauseMessage
+	if err := json.Unmarshal(message.Body, &msg); err != nil {
+		h.log.Errorf("unmarshal pause message error: %v", err)
+		return nil
+	}
+	found, err := pauseLocalTask(msg.WorkflowName, msg.TaskID, msg.Pause)
+	if err != nil {
+		h.log.Error(err)
+		return nil
+	}
+	if found {
+		h.log.Infof("[%s] %s workflow task %s:%d", msg.UserName, pauseAction(msg.Pause), msg.WorkflowName, msg.TaskID)
+	}
+	return nil
+}
+
+type manualGateMessageHandler struct {
+	log *zap.SugaredLogger
+}
+
+func (h *manualGateMessageHandler) HandleMessage(message *nsq.Message) error {
+	var msg *ManualGateMessage
+	if err := json.Unmarshal(message.Body, &msg); err != nil {
+		h.log.Errorf("unmarshal manual gate message error: %v", err)
+		return nil
+	}
+	if _, err := jobcontroller.SubmitManualGate(msg.WorkflowName, msg.JobName, msg.TaskID, msg.UserName, msg.UserID, msg.Params); err != nil {
+		h.log.Errorf("submit workflow %s ID %d job %s error: %v", msg.WorkflowName, msg.TaskID, msg.JobName, err)
+	}
+	return nil
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/instantmessage"
 	larkservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/lark"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller/jobcontroller"
 	"github.com/koderover/zadig/pkg/tool/lark"
 	"github.com/koderover/zadig/pkg/tool/log"
 )
@@ -58,8 +59,15 @@ type StageCtl interface {
 }
 
 func runStage(ctx context.Context, stage *commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) {
+	// stage already finished before aslan restarted.
+	if stage.Status == config.StatusPassed || stage.Status == config.StatusSkipped {
+		logger.Infof("skip finished stage: %s,status: %s", stage.Name, stage.Status)
+		return
+	}
 	stage.Status = config.StatusRunning
-	stage.StartTime = time.Now().Unix()
+	if stage.StartTime == 0 {
+		stage.StartTime = time.Now().Unix()
+	}
 	ack()
 	logger.Infof("start stage: %s,status: %s", stage.Name, stage.Status)
 	if err := waitForApprove(ctx, stage, workflowCtx, logger, ack); err != nil {
@@ -82,6 +90,10 @@ func runStage(ctx context.Context, stage *commonmodels.StageTask, workflowCtx *c
 }
 
 func RunStages(ctx context.Context, stages []*commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) {
+	if hasJobDependency(stages) {
+		runStagesDAG(ctx, stages, workflowCtx, concurrency, logger, ack)
+		return
+	}
 	for _, stage := range stages {
 		runStage(ctx, stage, workflowCtx, concurrency, logger, ack)
 		if statusFailed(stage.Status) {
@@ -90,13 +102,124 @@ func RunStages(ctx context.Context, stages []*commonmodels.StageTask, workflowCt
 	}
 }
 
+func hasJobDependency(stages []*commonmodels.StageTask) bool {
+	for _, stage := range stages {
+		for _, job := range stage.Jobs {
+			if len(job.DependsOn) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runStagesDAG runs the jobs of the stages between two approval stages together as a DAG,
+// stages are still used to wait for approvals and show the status of their jobs.
+func runStagesDAG(ctx context.Context, stages []*commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) {
+	segments := [][]*commonmodels.StageTask{}
+	for i, stage := range stages {
+		if i == 0 || (stage.Approval != nil && stage.Approval.Enabled) {
+			segments = append(segments, []*commonmodels.StageTask{})
+		}
+		segments[len(segments)-1] = append(segments[len(segments)-1], stage)
+	}
+	for _, segment := range segments {
+		runStageSegment(ctx, segment, workflowCtx, concurrency, logger, ack)
+		for _, stage := range segment {
+			if statusFailed(stage.Status) {
+				return
+			}
+		}
+	}
+}
+
+func runStageSegment(ctx context.Context, stages []*commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, concurrency int, logger *zap.SugaredLogger, ack func()) {
+	runningStages := []*commonmodels.StageTask{}
+	jobs := []*commonmodels.JobTask{}
+	for i, stage := range stages {
+		// stage already finished before aslan restarted.
+		if stage.Status == config.StatusPassed || stage.Status == config.StatusSkipped {
+			logger.Infof("skip finished stage: %s,status: %s", stage.Name, stage.Status)
+			continue
+		}
+		stage.Status = config.StatusRunning
+		if stage.StartTime == 0 {
+			stage.StartTime = time.Now().Unix()
+		}
+		ack()
+		logger.Infof("start stage: %s,status: %s", stage.Name, stage.Status)
+		// only the first stage of a segment can have approval.
+		if i == 0 {
+			if err := waitForApprove(ctx, stage, workflowCtx, logger, ack); err != nil {
+				stage.Error = err.Error()
+				stage.EndTime = time.Now().Unix()
+				logger.Errorf("finish stage: %s,status: %s", stage.Name, stage.Status)
+				ack()
+				return
+			}
+		}
+		runningStages = append(runningStages, stage)
+		jobs = append(jobs, stage.Jobs...)
+	}
+
+	jobcontroller.RunJobs(ctx, jobs, workflowCtx, concurrency, logger, ack)
+
+	for _, stage := range runningStages {
+		updateStageStatus(stage)
+		stage.EndTime = time.Now().Unix()
+		logger.Infof("finish stage: %s,status: %s", stage.Name, stage.Status)
+	}
+	ack()
+}
+
 func ApproveStage(workflowName, stageName, userName, userID, comment string, taskID int64, approve bool) error {
 	approveKey := fmt.Sprintf("%s-%d-%s", workflowName, taskID, stageName)
 	approveWithL, ok := globalApproveMap.getApproval(approveKey)
-	if !ok {
+	if ok {
+		return approveWithL.doApproval(userName, userID, comment, approve)
+	}
+	// the task may be running on another aslan replica, check the persisted approval and send it to the owner.
+	if err := checkPersistedApproval(workflowName, stageName, userName, userID, taskID); err != nil {
+		return err
+	}
+	return publishApproveMessage(&ApproveMessage{
+		WorkflowName: workflowName,
+		StageName:    stageName,
+		TaskID:       taskID,
+		UserName:     userName,
+		UserID:       userID,
+		Comment:      comment,
+		Approve:      approve,
+	})
+}
+
+func checkPersistedApproval(workflowName, stageName, userName, userID string, taskID int64) error {
+	task, err := mongodb.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+	if err != nil {
+		return fmt.Errorf("workflow %s ID %d not found: %v", workflowName, taskID, err)
+	}
+	if task.Status != config.StatusWaitingApprove {
 		return fmt.Errorf("workflow %s ID %d stage %s do not need approve", workflowName, taskID, stageName)
 	}
-	return approveWithL.doApproval(userName, userID, comment, approve)
+	for _, stage := range task.Stages {
+		if stage.Name != stageName {
+			continue
+		}
+		if stage.Approval == nil || !stage.Approval.Enabled || stage.Approval.NativeApproval == nil {
+			break
+		}
+		for _, user := range stage.Approval.NativeApproval.ApproveUsers {
+			if user.UserID != userID {
+				continue
+			}
+			if user.RejectOrApprove != "" {
+				return fmt.Errorf("%s have %s already", userName, user.RejectOrApprove)
+			}
+			return nil
+		}
+		return fmt.Errorf("user %s has no authority to approve", userName)
+	}
+	return fmt.Errorf("workflow %s ID %d stage %s do not need approve", workflowName, taskID, stageName)
 }
 
 func waitForApprove(ctx context.Context, stage *commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx, logger *zap.SugaredLogger, ack func()) error {
@@ -106,8 +229,12 @@ func waitForApprove(ctx context.Context, stage *commonmodels.StageTask, workflow
 	if !stage.Approval.Enabled {
 		return nil
 	}
-	workflowCtx.SetStatus(config.StatusWaitingApprove)
-	defer workflowCtx.SetStatus(config.StatusRunning)
+	// approval was done before aslan restarted.
+	if stage.Approval.NativeApproval != nil && stage.Approval.NativeApproval.RejectOrApprove == config.Approve {
+		return nil
+	}
+	workflowCtx.SetWaiting(config.StatusWaitingApprove, true)
+	defer workflowCtx.SetWaiting(config.StatusWaitingApprove, false)
 
 	switch stage.Approval.Type {
 	case config.NativeApproval:
@@ -192,42 +319,7 @@ func waitForLarkApprove(ctx context.Context, stage *commonmodels.StageTask, work
 		return errors.Wrapf(err, "get user lark id by mobile-%s", workflowCtx.WorkflowTaskCreatorMobile)
 	}
 
-	log.Infof("waitForLarkApprove: ApproveUsers num %d", len(approval.ApproveUsers))
-	detailURL := fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/custom/%s/%d?display_name=%s",
-		configbase.SystemAddress(),
-		workflowCtx.ProjectName,
-		workflowCtx.WorkflowName,
-		workflowCtx.TaskID,
-		url.QueryEscape(workflowCtx.WorkflowDisplayName),
-	)
-	descForm := ""
-	if stage.Approval.Description != "" {
-		descForm = fmt.Sprintf("\n描述: %s", stage.Approval.Description)
-	}
-	instance, err := client.CreateApprovalInstance(&lark.CreateApprovalInstanceArgs{
-		ApprovalCode: data.LarkDefaultApprovalCode,
-		UserOpenID:   userID,
-		ApproverIDList: func() (list []string) {
-			for _, user := range approval.ApproveUsers {
-				list = append(list, user.ID)
-			}
-			return list
-		}(),
-		FormContent: fmt.Sprintf("项目名称: %s\n工作流名称: %s\n阶段名称: %s%s\n\n更多详见: %s",
-			workflowCtx.ProjectName, workflowCtx.WorkflowDisplayName, stage.Name, descForm, detailURL),
-	})
-	if err != nil {
-		log.Errorf("waitForLarkApprove: create instance failed: %v", err)
-		stage.Status = config.StatusFailed
-		return errors.Wrap(err, "create approval instance")
-	}
-	log.Infof("waitForLarkApprove: create instance success, id %s", instance)
-
-	if err := instantmessage.NewWeChatClient().SendWorkflowTaskAproveNotifications(workflowCtx.WorkflowName, workflowCtx.TaskID); err != nil {
-		logger.Errorf("send approve notification failed, error: %v", err)
-	}
-
-	cancelApproval := func() {
+	cancelApproval := func(instance string) {
 		err := client.CancelApprovalInstance(&lark.CancelApprovalInstanceArgs{
 			ApprovalID: data.LarkDefaultApprovalCode,
 			InstanceID: instance,
@@ -237,6 +329,36 @@ func waitForLarkApprove(ctx context.Context, stage *commonmodels.StageTask, work
 			log.Errorf("cancel approval %s error: %v", instance, err)
 		}
 	}
+
+	// the approval instance was created before aslan restarted, the status callbacks may be missed, so query it from lark.
+	if approval.InstanceCode != "" {
+		status, err := client.GetApprovalInstanceStatus(approval.InstanceCode)
+		if err != nil {
+			log.Errorf("waitForLarkApprove: get instance %s status error: %v, create a new one", approval.InstanceCode, err)
+			cancelApproval(approval.InstanceCode)
+			approval.InstanceCode = ""
+		} else {
+			log.Infof("waitForLarkApprove: resume instance %s, status: %s", approval.InstanceCode, status)
+			larkservice.GetLarkApprovalManager(approval.ApprovalID).UpdateInstanceStatus(approval.InstanceCode, status)
+		}
+	}
+	if approval.InstanceCode == "" {
+		instance, err := createLarkApprovalInstance(client, data.LarkDefaultApprovalCode, userID, stage, workflowCtx)
+		if err != nil {
+			log.Errorf("waitForLarkApprove: create instance failed: %v", err)
+			stage.Status = config.StatusFailed
+			return errors.Wrap(err, "create approval instance")
+		}
+		log.Infof("waitForLarkApprove: create instance success, id %s", instance)
+		approval.InstanceCode = instance
+		ack()
+
+		if err := instantmessage.NewWeChatClient().SendWorkflowTaskAproveNotifications(workflowCtx.WorkflowName, workflowCtx.TaskID); err != nil {
+			logger.Errorf("send approve notification failed, error: %v", err)
+		}
+	}
+	instance := approval.InstanceCode
+
 	userUpdate := func(list []*commonmodels.LarkApprovalUser) []*commonmodels.LarkApprovalUser {
 		info, err := client.GetApprovalInstance(&lark.GetApprovalInstanceArgs{InstanceID: instance})
 		if err != nil {
@@ -262,11 +384,11 @@ func waitForLarkApprove(ctx context.Context, stage *commonmodels.StageTask, work
 		select {
 		case <-ctx.Done():
 			stage.Status = config.StatusCancelled
-			cancelApproval()
+			cancelApproval(instance)
 			return fmt.Errorf("workflow was canceled")
 		case <-timeout:
 			stage.Status = config.StatusCancelled
-			cancelApproval()
+			cancelApproval(instance)
 			return fmt.Errorf("workflow timeout")
 		default:
 			status := larkservice.GetLarkApprovalManager(approval.ApprovalID).GetInstanceStatus(instance)
@@ -288,6 +410,34 @@ func waitForLarkApprove(ctx context.Context, stage *commonmodels.StageTask, work
 	}
 }
 
+func createLarkApprovalInstance(client *lark.Client, approvalCode, userID string, stage *commonmodels.StageTask, workflowCtx *commonmodels.WorkflowTaskCtx) (string, error) {
+	approval := stage.Approval.LarkApproval
+	log.Infof("waitForLarkApprove: ApproveUsers num %d", len(approval.ApproveUsers))
+	detailURL := fmt.Sprintf("%s/v1/projects/detail/%s/pipelines/custom/%s/%d?display_name=%s",
+		configbase.SystemAddress(),
+		workflowCtx.ProjectName,
+		workflowCtx.WorkflowName,
+		workflowCtx.TaskID,
+		url.QueryEscape(workflowCtx.WorkflowDisplayName),
+	)
+	descForm := ""
+	if stage.Approval.Description != "" {
+		descForm = fmt.Sprintf("\n描述: %s", stage.Approval.Description)
+	}
+	return client.CreateApprovalInstance(&lark.CreateApprovalInstanceArgs{
+		ApprovalCode: approvalCode,
+		UserOpenID:   userID,
+		ApproverIDList: func() (list []string) {
+			for _, user := range approval.ApproveUsers {
+				list = append(list, user.ID)
+			}
+			return list
+		}(),
+		FormContent: fmt.Sprintf("项目名称: %s\n工作流名称: %s\n阶段名称: %s%s\n\n更多详见: %s",
+			workflowCtx.ProjectName, workflowCtx.WorkflowDisplayName, stage.Name, descForm, detailURL),
+	})
+}
+
 func statusFailed(status config.Status) bool {
 	if status == config.StatusCancelled || status == config.StatusFailed || status == config.StatusTimeout || status == config.StatusReject {
 		return true
@@ -309,11 +459,22 @@ func updateStageStatus(stage *commonmodels.StageTask) {
 
 	jobStatus := make([]int, len(stage.Jobs))
 
+	// jobs cancelled by a failed fail fast matrix job should not hide the failure.
+	failedMatrix := map[string]bool{}
+	for _, j := range stage.Jobs {
+		if j.FailFast && (j.Status == config.StatusFailed || j.Status == config.StatusTimeout) {
+			failedMatrix[j.MatrixKey] = true
+		}
+	}
+
 	for i, j := range stage.Jobs {
 		statusCode, ok := statusMap[j.Status]
 		if !ok {
 			statusCode = -1
 		}
+		if j.Status == config.StatusCancelled && failedMatrix[j.MatrixKey] {
+			statusCode = statusMap[config.StatusFailed]
+		}
 		jobStatus[i] = statusCode
 	}
 	var stageStatusCode int
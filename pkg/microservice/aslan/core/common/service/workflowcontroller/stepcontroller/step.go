@@ -33,10 +33,10 @@ type StepCtl interface {
 	AfterRun(ctx context.Context) error
 }
 
-func PrepareSteps(ctx context.Context, workflowCtx *commonmodels.WorkflowTaskCtx, jobPath *string, jobName string, steps []*commonmodels.StepTask, logger *zap.SugaredLogger) error {
+func PrepareSteps(ctx context.Context, workflowCtx *commonmodels.WorkflowTaskCtx, jobPath *string, job *commonmodels.JobTask, steps []*commonmodels.StepTask, logger *zap.SugaredLogger) error {
 	stepCtls := []StepCtl{}
 	for _, step := range steps {
-		stepCtl, err := instantiateStepCtl(step, workflowCtx, jobPath, jobName, logger)
+		stepCtl, err := instantiateStepCtl(step, workflowCtx, jobPath, job, logger)
 		if err != nil {
 			return err
 		}
@@ -50,10 +50,10 @@ func PrepareSteps(ctx context.Context, workflowCtx *commonmodels.WorkflowTaskCtx
 	return nil
 }
 
-func SummarizeSteps(ctx context.Context, workflowCtx *commonmodels.WorkflowTaskCtx, jobPath *string, jobName string, steps []*commonmodels.StepTask, logger *zap.SugaredLogger) error {
+func SummarizeSteps(ctx context.Context, workflowCtx *commonmodels.WorkflowTaskCtx, jobPath *string, job *commonmodels.JobTask, steps []*commonmodels.StepTask, logger *zap.SugaredLogger) error {
 	stepCtls := []StepCtl{}
 	for _, step := range steps {
-		stepCtl, err := instantiateStepCtl(step, workflowCtx, jobPath, jobName, logger)
+		stepCtl, err := instantiateStepCtl(step, workflowCtx, jobPath, job, logger)
 		if err != nil {
 			return err
 		}
@@ -67,7 +67,7 @@ func SummarizeSteps(ctx context.Context, workflowCtx *commonmodels.WorkflowTaskC
 	return nil
 }
 
-func instantiateStepCtl(step *commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx, jobPath *string, jobName string, logger *zap.SugaredLogger) (StepCtl, error) {
+func instantiateStepCtl(step *commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx, jobPath *string, job *commonmodels.JobTask, logger *zap.SugaredLogger) (StepCtl, error) {
 	var stepCtl StepCtl
 	var err error
 	switch step.StepType {
@@ -77,18 +77,28 @@ func instantiateStepCtl(step *commonmodels.StepTask, workflowCtx *commonmodels.W
 		stepCtl, err = NewShellCtl(step, logger)
 	case config.StepDockerBuild:
 		stepCtl, err = NewDockerBuildCtl(step, logger)
+	case config.StepImageBuild:
+		stepCtl, err = NewImageBuildCtl(step, logger)
 	case config.StepTools:
 		stepCtl, err = NewToolInstallCtl(step, jobPath, logger)
 	case config.StepArchive:
 		stepCtl, err = NewArchiveCtl(step, logger)
 	case config.StepJunitReport:
-		stepCtl, err = NewJunitReportCtl(step, logger)
+		stepCtl, err = NewJunitReportCtl(step, workflowCtx, job.Name, logger)
+	case config.StepHtmlReport:
+		stepCtl, err = NewHtmlReportCtl(step, logger)
 	case config.StepTarArchive:
 		stepCtl, err = NewTarArchiveCtl(step, logger)
+	case config.StepCacheRestore, config.StepCacheSave:
+		stepCtl, err = NewCacheCtl(step, workflowCtx, logger)
+	case config.StepArtifactUpload, config.StepArtifactDownload:
+		stepCtl, err = NewArtifactCtl(step, workflowCtx, job, logger)
+	case config.StepAttestation:
+		stepCtl, err = NewAttestationCtl(step, workflowCtx, job.Name, logger)
 	case config.StepSonarCheck:
 		stepCtl, err = NewSonarCheckCtl(step, logger)
 	case config.StepDistributeImage:
-		stepCtl, err = NewDistributeCtl(step, workflowCtx, jobName, logger)
+		stepCtl, err = NewDistributeCtl(step, workflowCtx, job.Name, logger)
 	default:
 		logger.Errorf("unknown step type: %s", step.StepType)
 		return stepCtl, fmt.Errorf("unknown step type: %s", step.StepType)
@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcontroller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+// artifact names are used as file names of the artifact archives
+var artifactNameRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+type artifactCtl struct {
+	step         *commonmodels.StepTask
+	uploadSpec   *step.StepArtifactUploadSpec
+	downloadSpec *step.StepArtifactDownloadSpec
+	workflowName string
+	taskID       int64
+	matrixSuffix string
+	log          *zap.SugaredLogger
+}
+
+// NewArtifactCtl serves both artifact_upload and artifact_download steps.
+func NewArtifactCtl(stepTask *commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx, job *commonmodels.JobTask, log *zap.SugaredLogger) (*artifactCtl, error) {
+	yamlString, err := yaml.Marshal(stepTask.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal artifact spec error: %v", err)
+	}
+	ctl := &artifactCtl{workflowName: workflowCtx.WorkflowName, taskID: workflowCtx.TaskID, matrixSuffix: job.MatrixSuffix(), log: log, step: stepTask}
+	if stepTask.StepType == config.StepArtifactUpload {
+		ctl.uploadSpec = &step.StepArtifactUploadSpec{}
+		if err := yaml.Unmarshal(yamlString, &ctl.uploadSpec); err != nil {
+			return nil, fmt.Errorf("unmarshal artifact upload spec error: %v", err)
+		}
+		stepTask.Spec = ctl.uploadSpec
+	} else {
+		ctl.downloadSpec = &step.StepArtifactDownloadSpec{}
+		if err := yaml.Unmarshal(yamlString, &ctl.downloadSpec); err != nil {
+			return nil, fmt.Errorf("unmarshal artifact download spec error: %v", err)
+		}
+		stepTask.Spec = ctl.downloadSpec
+	}
+	return ctl, nil
+}
+
+func (s *artifactCtl) PreRun(ctx context.Context) error {
+	modelS3, err := commonrepo.NewS3StorageColl().FindDefault()
+	if err != nil {
+		return err
+	}
+	// artifacts are addressed by the workflow task and the name, so they are always kept in the default storage.
+	if s.uploadSpec != nil {
+		if !artifactNameRegexp.MatchString(s.uploadSpec.Name) {
+			return fmt.Errorf("invalid artifact name: %s", s.uploadSpec.Name)
+		}
+		s.uploadSpec.ObjectDir = step.GetArtifactObjectDir(s.workflowName, s.taskID)
+		// jobs expanded from the same matrix must not overwrite the artifacts of each other.
+		s.uploadSpec.ObjectName = s.uploadSpec.Name + s.matrixSuffix
+		s.uploadSpec.S3Storage = modelS3toS3(modelS3)
+		s.step.Spec = s.uploadSpec
+		return nil
+	}
+	if !artifactNameRegexp.MatchString(s.downloadSpec.Name) {
+		return fmt.Errorf("invalid artifact name: %s", s.downloadSpec.Name)
+	}
+	task, err := commonrepo.NewworkflowTaskv4Coll().Find(s.workflowName, s.taskID)
+	if err != nil {
+		return fmt.Errorf("find workflow task %s-%d error: %v", s.workflowName, s.taskID, err)
+	}
+	s.downloadSpec.ObjectDir = step.GetArtifactObjectDir(s.workflowName, artifactTaskID(task.Stages, s.downloadSpec.Name, s.taskID))
+	s.downloadSpec.S3Storage = modelS3toS3(modelS3)
+	s.step.Spec = s.downloadSpec
+	return nil
+}
+
+func (s *artifactCtl) AfterRun(ctx context.Context) error {
+	return nil
+}
+
+// artifactTaskID returns the task the artifact is uploaded in, the jobs carried over to a retry task
+// do not run again and their artifacts are kept under the task they passed in.
+func artifactTaskID(stages []*commonmodels.StageTask, name string, taskID int64) int64 {
+	for _, stage := range stages {
+		for _, job := range stage.Jobs {
+			if job.FromTaskID == 0 {
+				continue
+			}
+			jobSpec := &commonmodels.JobTaskFreestyleSpec{}
+			if err := commonmodels.IToi(job.Spec, jobSpec); err != nil {
+				continue
+			}
+			for _, stepTask := range jobSpec.Steps {
+				if stepTask.StepType != config.StepArtifactUpload {
+					continue
+				}
+				uploadSpec := &step.StepArtifactUploadSpec{}
+				if err := commonmodels.IToi(stepTask.Spec, uploadSpec); err != nil {
+					continue
+				}
+				if uploadSpec.Name+job.MatrixSuffix() == name {
+					return job.FromTaskID
+				}
+			}
+		}
+	}
+	return taskID
+}
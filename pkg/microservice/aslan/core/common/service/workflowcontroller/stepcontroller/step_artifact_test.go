@@ -0,0 +1,69 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcontroller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+func TestArtifactTaskID(t *testing.T) {
+	uploadJob := func(key, matrixKey, name string, fromTaskID int64) *commonmodels.JobTask {
+		return &commonmodels.JobTask{
+			Key:        key,
+			MatrixKey:  matrixKey,
+			FromTaskID: fromTaskID,
+			Spec: &commonmodels.JobTaskFreestyleSpec{
+				Steps: []*commonmodels.StepTask{
+					{Name: "shell", StepType: config.StepShell, Spec: &step.StepShellSpec{}},
+					{Name: "upload", StepType: config.StepArtifactUpload, Spec: &step.StepArtifactUploadSpec{Name: name}},
+				},
+			},
+		}
+	}
+	stages := []*commonmodels.StageTask{
+		{
+			Name: "build",
+			Jobs: []*commonmodels.JobTask{
+				uploadJob("build", "", "bin", 3),
+				uploadJob("cross.linux.amd64", "cross", "cross-bin", 2),
+				uploadJob("docs", "", "docs", 0),
+			},
+		},
+	}
+	tests := []struct {
+		name   string
+		stages []*commonmodels.StageTask
+		want   int64
+	}{
+		{name: "bin", stages: stages, want: 3},
+		{name: "cross-bin.linux.amd64", stages: stages, want: 2},
+		{name: "docs", stages: stages, want: 5},
+		{name: "unknown", stages: stages, want: 5},
+		{name: "bin", stages: nil, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, artifactTaskID(tt.stages, tt.name, 5))
+		})
+	}
+}
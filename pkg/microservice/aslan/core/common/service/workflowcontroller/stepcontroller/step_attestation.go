@@ -0,0 +1,127 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcontroller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/setting"
+	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+type attestationCtl struct {
+	step            *commonmodels.StepTask
+	attestationSpec *step.StepAttestationSpec
+	workflowCtx     *commonmodels.WorkflowTaskCtx
+	jobName         string
+	log             *zap.SugaredLogger
+}
+
+func NewAttestationCtl(stepTask *commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx, jobName string, log *zap.SugaredLogger) (*attestationCtl, error) {
+	yamlString, err := yaml.Marshal(stepTask.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal attestation spec error: %v", err)
+	}
+	attestationSpec := &step.StepAttestationSpec{}
+	if err := yaml.Unmarshal(yamlString, &attestationSpec); err != nil {
+		return nil, fmt.Errorf("unmarshal attestation spec error: %v", err)
+	}
+	stepTask.Spec = attestationSpec
+	return &attestationCtl{attestationSpec: attestationSpec, workflowCtx: workflowCtx, jobName: jobName, log: log, step: stepTask}, nil
+}
+
+func (s *attestationCtl) PreRun(ctx context.Context) error {
+	if s.attestationSpec.SBOMFormat == "" {
+		s.attestationSpec.SBOMFormat = step.SBOMFormatSPDX
+	}
+	if s.attestationSpec.SBOMSource == "" {
+		s.attestationSpec.SBOMSource = step.SBOMSourceImage
+	}
+	// the key itself is never kept in the spec, it is provided to the job as a secret file.
+	if _, err := commonrepo.NewPrivateKeyColl().Find(commonrepo.FindPrivateKeyOption{ID: s.attestationSpec.SigningKeyID}); err != nil {
+		return fmt.Errorf("find signing key %s error: %v", s.attestationSpec.SigningKeyID, err)
+	}
+
+	modelS3, err := commonrepo.NewS3StorageColl().FindDefault()
+	if err != nil {
+		return err
+	}
+	s.attestationSpec.S3Storage = modelS3toS3(modelS3)
+	s.attestationSpec.ObjectDir = step.GetAttestationObjectDir(s.workflowCtx.WorkflowName, s.workflowCtx.TaskID, s.jobName, s.step.Name)
+	s.step.Spec = s.attestationSpec
+	return nil
+}
+
+// AfterRun records the attestation of the image, so that the delivery versions releasing the image carry it.
+func (s *attestationCtl) AfterRun(ctx context.Context) error {
+	if s.step.Status != "" && s.step.Status != config.StatusPassed {
+		return nil
+	}
+	storage := s.attestationSpec.S3Storage
+	if storage == nil {
+		return nil
+	}
+	forcedPathStyle := true
+	if storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3tool.NewClient(storage.Endpoint, storage.Ak, storage.Sk, storage.Region, storage.Insecure, forcedPathStyle)
+	if err != nil {
+		s.log.Errorf("NewClient err:%v", err)
+		return err
+	}
+	result := &step.AttestationResult{}
+	resultObjectKey := step.GetAttestationObjectKey(storage.Subfolder, s.attestationSpec.ObjectDir, step.AttestationResultFile)
+	if err := downloadJSON(client, storage.Bucket, resultObjectKey, result); err != nil {
+		s.log.Errorf("download attestation result of step %s error: %v", s.step.Name, err)
+		return err
+	}
+	attestation := &commonmodels.ImageAttestation{
+		Image:        result.Image,
+		ProjectName:  s.workflowCtx.ProjectName,
+		WorkflowName: s.workflowCtx.WorkflowName,
+		TaskID:       s.workflowCtx.TaskID,
+		JobName:      s.jobName,
+		CreateTime:   time.Now().Unix(),
+		DeliveryAttestation: commonmodels.DeliveryAttestation{
+			Digest:             result.Digest,
+			SBOMFormat:         result.SBOMFormat,
+			SBOMObjectKey:      result.SBOMObjectKey,
+			SBOMChecksum:       result.SBOMChecksum,
+			Payload:            result.Payload,
+			Signature:          result.Signature,
+			SignatureAlgorithm: result.SignatureAlgorithm,
+			SigningKeyID:       s.attestationSpec.SigningKeyID,
+			PublicKey:          result.PublicKey,
+			AttestationRef:     result.AttestationRef,
+		},
+	}
+	if err := commonrepo.NewImageAttestationColl().Create(attestation); err != nil {
+		s.log.Errorf("create image attestation of %s error: %v", result.Image, err)
+		return err
+	}
+	return nil
+}
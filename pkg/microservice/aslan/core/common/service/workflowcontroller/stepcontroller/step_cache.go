@@ -0,0 +1,83 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcontroller
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+type cacheCtl struct {
+	step        *commonmodels.StepTask
+	cacheSpec   *step.StepCacheSpec
+	projectName string
+	log         *zap.SugaredLogger
+}
+
+// NewCacheCtl serves both cache_restore and cache_save steps.
+func NewCacheCtl(stepTask *commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx, log *zap.SugaredLogger) (*cacheCtl, error) {
+	yamlString, err := yaml.Marshal(stepTask.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal cache spec error: %v", err)
+	}
+	cacheSpec := &step.StepCacheSpec{}
+	if err := yaml.Unmarshal(yamlString, &cacheSpec); err != nil {
+		return nil, fmt.Errorf("unmarshal cache spec error: %v", err)
+	}
+	stepTask.Spec = cacheSpec
+	return &cacheCtl{cacheSpec: cacheSpec, projectName: workflowCtx.ProjectName, log: log, step: stepTask}, nil
+}
+
+func (s *cacheCtl) PreRun(ctx context.Context) error {
+	// caches are shared by all the workflows in a project, so that the quota is counted per project.
+	if s.cacheSpec.MediumType == types.NFSMedium {
+		// the project dir of the volume is mounted by the job.
+		s.cacheSpec.CacheDir = setting.DependencyCacheMountDir
+	} else {
+		s.cacheSpec.MediumType = types.ObjectMedium
+		s.cacheSpec.CacheDir = path.Join(setting.DependencyCachePrefix, s.projectName)
+		if s.cacheSpec.S3Storage == nil {
+			modelS3, err := commonrepo.NewS3StorageColl().FindDefault()
+			if err != nil {
+				return err
+			}
+			s.cacheSpec.S3Storage = modelS3toS3(modelS3)
+		}
+	}
+
+	strategy, err := commonrepo.NewStrategyColl().GetByTarget(commonmodels.DependencyCacheQuota)
+	if err != nil || strategy.Quota == nil {
+		strategy = commonmodels.DefaultDependencyCacheQuota
+	}
+	s.cacheSpec.MaxSizeInMiB = strategy.Quota.MaxSizeInMiB
+	s.step.Spec = s.cacheSpec
+	return nil
+}
+
+func (s *cacheCtl) AfterRun(ctx context.Context) error {
+	return nil
+}
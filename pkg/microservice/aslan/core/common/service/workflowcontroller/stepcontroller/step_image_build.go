@@ -0,0 +1,71 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stepcontroller
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+type imageBuildCtl struct {
+	step           *commonmodels.StepTask
+	imageBuildSpec *step.StepImageBuildSpec
+	log            *zap.SugaredLogger
+}
+
+func NewImageBuildCtl(stepTask *commonmodels.StepTask, log *zap.SugaredLogger) (*imageBuildCtl, error) {
+	yamlString, err := yaml.Marshal(stepTask.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal image build spec error: %v", err)
+	}
+	imageBuildSpec := &step.StepImageBuildSpec{}
+	if err := yaml.Unmarshal(yamlString, &imageBuildSpec); err != nil {
+		return nil, fmt.Errorf("unmarshal image build spec error: %v", err)
+	}
+	if imageBuildSpec.Proxy == nil {
+		imageBuildSpec.Proxy = &step.Proxy{}
+	}
+	stepTask.Spec = imageBuildSpec
+	return &imageBuildCtl{imageBuildSpec: imageBuildSpec, log: log, step: stepTask}, nil
+}
+
+func (s *imageBuildCtl) PreRun(ctx context.Context) error {
+	proxies, _ := mongodb.NewProxyColl().List(&mongodb.ProxyArgs{})
+	if len(proxies) != 0 {
+		s.imageBuildSpec.Proxy.Address = proxies[0].Address
+		s.imageBuildSpec.Proxy.EnableApplicationProxy = proxies[0].EnableApplicationProxy
+		s.imageBuildSpec.Proxy.EnableRepoProxy = proxies[0].EnableRepoProxy
+		s.imageBuildSpec.Proxy.NeedPassword = proxies[0].NeedPassword
+		s.imageBuildSpec.Proxy.Password = proxies[0].Password
+		s.imageBuildSpec.Proxy.Port = proxies[0].Port
+		s.imageBuildSpec.Proxy.Type = proxies[0].Type
+		s.imageBuildSpec.Proxy.Username = proxies[0].Username
+	}
+	s.step.Spec = s.imageBuildSpec
+	return nil
+}
+
+func (s *imageBuildCtl) AfterRun(ctx context.Context) error {
+	return nil
+}
@@ -18,6 +18,7 @@ package stepcontroller
 
 import (
 	"context"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
@@ -34,6 +35,7 @@ import (
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/log"
 	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/types/step"
 	"github.com/koderover/zadig/pkg/util"
 )
@@ -41,10 +43,12 @@ import (
 type junitReportCtl struct {
 	step            *commonmodels.StepTask
 	junitReportSpec *step.StepJunitReportSpec
+	workflowCtx     *commonmodels.WorkflowTaskCtx
+	jobName         string
 	log             *zap.SugaredLogger
 }
 
-func NewJunitReportCtl(stepTask *commonmodels.StepTask, log *zap.SugaredLogger) (*junitReportCtl, error) {
+func NewJunitReportCtl(stepTask *commonmodels.StepTask, workflowCtx *commonmodels.WorkflowTaskCtx, jobName string, log *zap.SugaredLogger) (*junitReportCtl, error) {
 	yamlString, err := yaml.Marshal(stepTask.Spec)
 	if err != nil {
 		return nil, fmt.Errorf("marshal junit report spec error: %v", err)
@@ -54,7 +58,7 @@ func NewJunitReportCtl(stepTask *commonmodels.StepTask, log *zap.SugaredLogger)
 		return nil, fmt.Errorf("unmarshal junit report spec error: %v", err)
 	}
 	stepTask.Spec = junitReportSpec
-	return &junitReportCtl{junitReportSpec: junitReportSpec, log: log, step: stepTask}, nil
+	return &junitReportCtl{junitReportSpec: junitReportSpec, workflowCtx: workflowCtx, jobName: jobName, log: log, step: stepTask}, nil
 }
 
 func (s *junitReportCtl) PreRun(ctx context.Context) error {
@@ -73,21 +77,6 @@ func (s *junitReportCtl) AfterRun(ctx context.Context) error {
 	if s.junitReportSpec.TestName == "" {
 		return nil
 	}
-	var testTaskStat *commonmodels.TestTaskStat
-	var isNew bool
-	testTaskStat, _ = commonrepo.NewTestTaskStatColl().FindTestTaskStat(&commonrepo.TestTaskStatOption{Name: s.junitReportSpec.TestName})
-	if testTaskStat == nil {
-		isNew = true
-		testTaskStat = new(commonmodels.TestTaskStat)
-		testTaskStat.Name = s.junitReportSpec.TestName
-		testTaskStat.CreateTime = time.Now().Unix()
-		testTaskStat.UpdateTime = time.Now().Unix()
-	}
-	filename, err := util.GenerateTmpFile()
-	if err != nil {
-		log.Errorf("GenerateTmpFile err:%v", err)
-		return err
-	}
 	storage, err := s3.FindDefaultS3()
 	if err != nil {
 		log.Errorf("find defalt s3 error: %v", err)
@@ -102,23 +91,74 @@ func (s *junitReportCtl) AfterRun(ctx context.Context) error {
 		log.Errorf("NewClient err:%v", err)
 		return err
 	}
+
+	jobStat := &commonmodels.TestJobStat{
+		TestName:     s.junitReportSpec.TestName,
+		ProjectName:  s.workflowCtx.ProjectName,
+		WorkflowName: s.workflowCtx.WorkflowName,
+		TaskID:       s.workflowCtx.TaskID,
+		JobName:      s.jobName,
+		CreateTime:   time.Now().Unix(),
+	}
+	if s.junitReportSpec.ReportDir != "" {
+		testReport, err := s.updateTestTaskStat(storage.Bucket, client)
+		if err != nil {
+			return err
+		}
+		jobStat.Tests = testReport.Tests
+		jobStat.Failures = testReport.Failures
+		jobStat.Errors = testReport.Errors
+		jobStat.Skips = testReport.Skips
+	}
+	if s.junitReportSpec.CoverageFormat != "" {
+		coverage := new(types.TestCoverage)
+		objectKey := storage.GetObjectPath(filepath.Join(s.junitReportSpec.S3DestDir, s.junitReportSpec.CoverageFileName))
+		if err := downloadJSON(client, storage.Bucket, objectKey, coverage); err != nil {
+			log.Errorf("Download coverage report err:%v", err)
+			return err
+		}
+		jobStat.Coverage = coverage
+	}
+	if err := commonrepo.NewTestJobStatColl().Create(jobStat); err != nil {
+		log.Errorf("create test job stat error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *junitReportCtl) updateTestTaskStat(bucket string, client *s3tool.Client) (*commonmodels.TestSuite, error) {
+	var testTaskStat *commonmodels.TestTaskStat
+	var isNew bool
+	testTaskStat, _ = commonrepo.NewTestTaskStatColl().FindTestTaskStat(&commonrepo.TestTaskStatOption{Name: s.junitReportSpec.TestName})
+	if testTaskStat == nil {
+		isNew = true
+		testTaskStat = new(commonmodels.TestTaskStat)
+		testTaskStat.Name = s.junitReportSpec.TestName
+		testTaskStat.CreateTime = time.Now().Unix()
+		testTaskStat.UpdateTime = time.Now().Unix()
+	}
+	filename, err := util.GenerateTmpFile()
+	if err != nil {
+		log.Errorf("GenerateTmpFile err:%v", err)
+		return nil, err
+	}
 	objectKey := filepath.Join(s.junitReportSpec.S3DestDir, s.junitReportSpec.FileName)
-	err = client.Download(storage.Bucket, objectKey, filename)
+	err = client.Download(bucket, objectKey, filename)
 	if err != nil {
 		log.Errorf("Download junit report err:%v", err)
-		return err
+		return nil, err
 	}
 	defer os.Remove(filename)
 
 	b, err := ioutil.ReadFile(filename)
 	if err != nil {
 		log.Error("get local test result file error: %v", err)
-		return err
+		return nil, err
 	}
 	testReport := new(commonmodels.TestSuite)
 	if err := xml.Unmarshal(b, &testReport); err != nil {
 		log.Error("uploadTaskData testSuite unmarshal it report xml error: %v", err)
-		return err
+		return nil, err
 	}
 	totalCaseNum := testReport.Tests
 	if totalCaseNum != 0 {
@@ -131,5 +171,21 @@ func (s *junitReportCtl) AfterRun(ctx context.Context) error {
 		testTaskStat.UpdateTime = time.Now().Unix()
 		_ = commonrepo.NewTestTaskStatColl().Update(testTaskStat)
 	}
-	return nil
+	return testReport, nil
+}
+
+func downloadJSON(client *s3tool.Client, bucket, objectKey string, obj interface{}) error {
+	filename, err := util.GenerateTmpFile()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(filename)
+	if err := client.Download(bucket, objectKey, filename); err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, obj)
 }
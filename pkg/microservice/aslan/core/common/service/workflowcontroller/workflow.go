@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	uuid "github.com/satori/go.uuid"
@@ -39,6 +40,7 @@ import (
 	kubeclient "github.com/koderover/zadig/pkg/shared/kube/client"
 	"github.com/koderover/zadig/pkg/tool/kube/updater"
 	"github.com/koderover/zadig/pkg/tool/log"
+	jobtypes "github.com/koderover/zadig/pkg/types/job"
 )
 
 var cancelChannelMap sync.Map
@@ -49,6 +51,15 @@ type workflowCtl struct {
 	clusterIDMutex     sync.RWMutex
 	logger             *zap.SugaredLogger
 	ack                func()
+	pause              *pauseSwitch
+	// leaseLost is set when the task lease is taken by another replica, the task must not be updated by this replica anymore.
+	leaseLost atomic.Bool
+	// largeOutputs caches the output values downloaded from the object storage, they are not saved in the task.
+	largeOutputs      map[string]string
+	largeOutputsMutex sync.Mutex
+	// waiting counts the jobs and stages waiting for approval or resumption, they may wait in parallel.
+	waiting      map[config.Status]int
+	waitingMutex sync.Mutex
 }
 
 func NewWorkflowController(workflowTask *commonmodels.WorkflowTask, logger *zap.SugaredLogger) *workflowCtl {
@@ -96,16 +107,33 @@ func CancelWorkflowTask(userName, workflowName string, taskID int64, logger *zap
 		log.Warnf("Failed to update github check status for custom workflow %s, taskID: %d the error is: %s", t.WorkflowName, t.TaskID, err)
 	}
 
-	value, ok := cancelChannelMap.Load(fmt.Sprintf("%s-%d", workflowName, taskID))
+	found, err := cancelLocalTask(workflowName, taskID)
+	if err != nil || found {
+		return err
+	}
+	// the task may be running on another aslan replica.
+	logger.Infof("task %s:%d not running on this replica, send cancel message", workflowName, taskID)
+	return publishCancelMessage(&CancelMessage{
+		Revoker:      userName,
+		PipelineName: workflowName,
+		TaskID:       taskID,
+	})
+}
+
+func cancelLocalTask(workflowName string, taskID int64) (bool, error) {
+	value, ok := cancelChannelMap.Load(getTaskKey(workflowName, taskID))
 	if !ok {
-		logger.Errorf("no mactched task found, id: %d, workflow name: %s", taskID, workflowName)
-		return nil
+		return false, nil
 	}
 	if f, ok := value.(context.CancelFunc); ok {
 		f()
-		return nil
+		return true, nil
 	}
-	return fmt.Errorf("cancel func type mismatched, id: %d, workflow name: %s", taskID, workflowName)
+	return true, fmt.Errorf("cancel func type mismatched, id: %d, workflow name: %s", taskID, workflowName)
+}
+
+func (c *workflowCtl) loseLease() {
+	c.leaseLost.Store(true)
 }
 
 func (c *workflowCtl) setWorkflowStatus(status config.Status) {
@@ -113,6 +141,30 @@ func (c *workflowCtl) setWorkflowStatus(status config.Status) {
 	c.ack()
 }
 
+// setWaiting updates the waiting ones and recomputes the task status from them, waiting for approval is shown before
+// being paused, the task is running if nothing is waiting.
+func (c *workflowCtl) setWaiting(status config.Status, waiting bool) {
+	c.waitingMutex.Lock()
+	defer c.waitingMutex.Unlock()
+	if c.waiting == nil {
+		c.waiting = make(map[config.Status]int)
+	}
+	if waiting {
+		c.waiting[status]++
+	} else if c.waiting[status] > 0 {
+		c.waiting[status]--
+	}
+
+	taskStatus := config.StatusRunning
+	switch {
+	case c.waiting[config.StatusWaitingApprove] > 0:
+		taskStatus = config.StatusWaitingApprove
+	case c.waiting[config.StatusPaused] > 0:
+		taskStatus = config.StatusPaused
+	}
+	c.setWorkflowStatus(taskStatus)
+}
+
 func (c *workflowCtl) Run(ctx context.Context, concurrency int) {
 	if c.workflowTask.GlobalContext == nil {
 		c.workflowTask.GlobalContext = make(map[string]string)
@@ -121,21 +173,32 @@ func (c *workflowCtl) Run(ctx context.Context, concurrency int) {
 		c.workflowTask.ClusterIDMap = make(map[string]bool)
 	}
 	c.workflowTask.Status = config.StatusRunning
-	c.workflowTask.StartTime = time.Now().Unix()
+	// a resumed task keeps its original start time.
+	if c.workflowTask.StartTime == 0 {
+		c.workflowTask.StartTime = time.Now().Unix()
+	}
 	c.ack()
 	c.logger.Infof("start workflow: %s,status: %s", c.workflowTask.WorkflowName, c.workflowTask.Status)
 	defer func() {
 		c.workflowTask.EndTime = time.Now().Unix()
 		c.logger.Infof("finish workflow: %s,status: %s", c.workflowTask.WorkflowName, c.workflowTask.Status)
 		c.ack()
+		// the share storage is still used by the replica which took over the task.
+		if c.leaseLost.Load() {
+			return
+		}
 		// clean share storage after workflow finished
 		go c.CleanShareStorage()
 	}()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	cancelKey := fmt.Sprintf("%s-%d", c.workflowTask.WorkflowName, c.workflowTask.TaskID)
+	cancelKey := getTaskKey(c.workflowTask.WorkflowName, c.workflowTask.TaskID)
 	cancelChannelMap.Store(cancelKey, cancel)
 	defer cancelChannelMap.Delete(cancelKey)
+	// a task paused before aslan restarted stays paused.
+	c.pause = newPauseSwitch(c.workflowTask.Paused)
+	pauseMap.Store(cancelKey, c)
+	defer pauseMap.Delete(cancelKey)
 
 	workflowCtx := &commonmodels.WorkflowTaskCtx{
 		WorkflowName:              c.workflowTask.WorkflowName,
@@ -149,13 +212,21 @@ func (c *workflowCtl) Run(ctx context.Context, concurrency int) {
 		DockerMountDir:            fmt.Sprintf("/tmp/%s/docker/%d", uuid.NewV4(), time.Now().Unix()),
 		ConfigMapMountDir:         fmt.Sprintf("/tmp/%s/cm/%d", uuid.NewV4(), time.Now().Unix()),
 		WorkflowKeyVals:           c.workflowTask.KeyVals,
+		WorkflowParams:            c.workflowTask.Params,
+		HookPayload:               getHookPayload(c.workflowTask),
 		GlobalContextGet:          c.getGlobalContext,
 		GlobalContextSet:          c.setGlobalContext,
 		GlobalContextEach:         c.globalContextEach,
 		ClusterIDAdd:              c.addCluterID,
-		SetStatus:                 c.setWorkflowStatus,
+		SetWaiting:                c.setWaiting,
+		WaitIfPaused:              c.waitIfPaused,
 	}
-	defer jobcontroller.CleanWorkflowJobs(ctx, c.workflowTask, workflowCtx, c.logger, c.ack)
+	defer func() {
+		if c.leaseLost.Load() {
+			return
+		}
+		jobcontroller.CleanWorkflowJobs(ctx, c.workflowTask, workflowCtx, c.logger, c.ack)
+	}()
 	if err := scmnotify.NewService().UpdateWebhookCommentForWorkflowV4(c.workflowTask, c.logger); err != nil {
 		log.Warnf("Failed to update comment for custom workflow %s, taskID: %d the error is: %s", c.workflowTask.WorkflowName, c.workflowTask.TaskID, err)
 	}
@@ -166,6 +237,13 @@ func (c *workflowCtl) Run(ctx context.Context, concurrency int) {
 	updateworkflowStatus(c.workflowTask)
 }
 
+func getHookPayload(workflowTask *commonmodels.WorkflowTask) *commonmodels.HookPayload {
+	if workflowTask.WorkflowArgs == nil {
+		return nil
+	}
+	return workflowTask.WorkflowArgs.HookPayload
+}
+
 func updateworkflowStatus(workflow *commonmodels.WorkflowTask) {
 	statusMap := map[config.Status]int{
 		config.StatusReject:    5,
@@ -205,6 +283,10 @@ func updateworkflowStatus(workflow *commonmodels.WorkflowTask) {
 }
 
 func (c *workflowCtl) updateWorkflowTask() {
+	if c.leaseLost.Load() {
+		c.logger.Infof("%s:%d lease lost, ACK dropped", c.workflowTask.WorkflowName, c.workflowTask.TaskID)
+		return
+	}
 	taskInColl, err := commonrepo.NewworkflowTaskv4Coll().Find(c.workflowTask.WorkflowName, c.workflowTask.TaskID)
 	if err != nil {
 		c.logger.Errorf("find workflow task v4 %s failed,error: %v", c.workflowTask.WorkflowName, err)
@@ -310,11 +392,13 @@ const (
 	split = "@?"
 )
 
-func (c *workflowCtl) getGlobalContext(key string) (string, bool) {
+func (c *workflowCtl) getGlobalContext(key string) (string, bool, error) {
 	c.globalContextMutex.RLock()
-	defer c.globalContextMutex.RUnlock()
 	v, existed := c.workflowTask.GlobalContext[GetContextKey(key)]
-	return v, existed
+	c.globalContextMutex.RUnlock()
+	// large outputs are downloaded out of the lock, so the other jobs are not blocked
+	value, err := c.resolveOutputRef(v)
+	return value, existed, err
 }
 
 func (c *workflowCtl) setGlobalContext(key, value string) {
@@ -323,15 +407,51 @@ func (c *workflowCtl) setGlobalContext(key, value string) {
 	c.workflowTask.GlobalContext[GetContextKey(key)] = value
 }
 
-func (c *workflowCtl) globalContextEach(f func(k, v string) bool) {
+// globalContextEach calls f on a copy of the global context, it stops at the first large output failed to download.
+func (c *workflowCtl) globalContextEach(f func(k, v string) bool) error {
 	c.globalContextMutex.RLock()
-	defer c.globalContextMutex.RUnlock()
+	globalContext := make(map[string]string, len(c.workflowTask.GlobalContext))
 	for k, v := range c.workflowTask.GlobalContext {
-		k = strings.Join(strings.Split(k, split), ".")
-		if !f(k, v) {
-			return
+		globalContext[k] = v
+	}
+	c.globalContextMutex.RUnlock()
+
+	for k, v := range globalContext {
+		value, err := c.resolveOutputRef(v)
+		if err != nil {
+			return err
 		}
+		if !f(strings.Join(strings.Split(k, split), "."), value) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// resolveOutputRef returns the value of a large output saved in the object storage, other values are returned as is.
+func (c *workflowCtl) resolveOutputRef(value string) (string, error) {
+	storageID, objectKey, ok := jobtypes.ParseOutputRef(value)
+	if !ok {
+		return value, nil
+	}
+	c.largeOutputsMutex.Lock()
+	v, ok := c.largeOutputs[value]
+	c.largeOutputsMutex.Unlock()
+	if ok {
+		return v, nil
+	}
+
+	v, err := jobcontroller.DownloadOutput(storageID, objectKey)
+	if err != nil {
+		return "", fmt.Errorf("download output %s error: %v", objectKey, err)
+	}
+	c.largeOutputsMutex.Lock()
+	defer c.largeOutputsMutex.Unlock()
+	if c.largeOutputs == nil {
+		c.largeOutputs = map[string]string{}
 	}
+	c.largeOutputs[value] = v
+	return v, nil
 }
 
 func GetContextKey(key string) string {
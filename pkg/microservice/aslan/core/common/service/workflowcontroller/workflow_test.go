@@ -0,0 +1,78 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflowcontroller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func TestSetWaiting(t *testing.T) {
+	type action struct {
+		status  config.Status
+		waiting bool
+	}
+	tests := []struct {
+		name    string
+		actions []action
+		want    config.Status
+	}{
+		{
+			name:    "gate waiting",
+			actions: []action{{config.StatusWaitingApprove, true}},
+			want:    config.StatusWaitingApprove,
+		},
+		{
+			name:    "gate approved",
+			actions: []action{{config.StatusWaitingApprove, true}, {config.StatusWaitingApprove, false}},
+			want:    config.StatusRunning,
+		},
+		{
+			name:    "gate approved while the task is paused",
+			actions: []action{{config.StatusPaused, true}, {config.StatusWaitingApprove, true}, {config.StatusWaitingApprove, false}},
+			want:    config.StatusPaused,
+		},
+		{
+			name:    "one of two gates approved",
+			actions: []action{{config.StatusWaitingApprove, true}, {config.StatusWaitingApprove, true}, {config.StatusWaitingApprove, false}},
+			want:    config.StatusWaitingApprove,
+		},
+		{
+			name:    "task resumed while a gate is waiting",
+			actions: []action{{config.StatusWaitingApprove, true}, {config.StatusPaused, true}, {config.StatusPaused, false}},
+			want:    config.StatusWaitingApprove,
+		},
+		{
+			name:    "all done",
+			actions: []action{{config.StatusPaused, true}, {config.StatusWaitingApprove, true}, {config.StatusPaused, false}, {config.StatusWaitingApprove, false}},
+			want:    config.StatusRunning,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &workflowCtl{workflowTask: &commonmodels.WorkflowTask{}, ack: func() {}}
+			for _, a := range tt.actions {
+				c.setWaiting(a.status, a.waiting)
+			}
+			assert.Equal(t, tt.want, c.workflowTask.Status)
+		})
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"strings"
 
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/koderover/zadig/pkg/setting"
 )
@@ -38,6 +39,30 @@ func checkGpuResourceParam(gpuLimit string) error {
 	return err
 }
 
+// serviceContainerNamePrefix is prepended to the names of the job services to name their sidecar containers.
+const serviceContainerNamePrefix = "svc-"
+
+// CheckServiceNames checks the names of the services running next to a job, the names are used in the container names
+// and env variables of the job, so they must be unique DNS-1123 labels.
+func CheckServiceNames(names []string) error {
+	existed := make(map[string]bool)
+	for _, name := range names {
+		errs := validation.IsDNS1123Label(name)
+		if len(errs) == 0 {
+			// the container name must not be too long either
+			errs = validation.IsDNS1123Label(serviceContainerNamePrefix + name)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("invalid service name %q: %s", name, strings.Join(errs, ", "))
+		}
+		if existed[name] {
+			return fmt.Errorf("duplicate service name %q", name)
+		}
+		existed[name] = true
+	}
+	return nil
+}
+
 func CheckDefineResourceParam(req setting.Request, reqSpec setting.RequestSpec) error {
 	if req != setting.DefineRequest {
 		return nil
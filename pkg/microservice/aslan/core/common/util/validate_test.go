@@ -0,0 +1,45 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckServiceNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		wantErr bool
+	}{
+		{name: "valid", names: []string{"mysql", "redis-6"}},
+		{name: "empty name", names: []string{""}, wantErr: true},
+		{name: "upper case", names: []string{"MySQL"}, wantErr: true},
+		{name: "underscore", names: []string{"my_sql"}, wantErr: true},
+		{name: "too long for the container name", names: []string{strings.Repeat("a", 60)}, wantErr: true},
+		{name: "duplicate", names: []string{"mysql", "redis", "mysql"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckServiceNames(tt.names)
+			assert.Equal(t, tt.wantErr, err != nil, "err: %v", err)
+		})
+	}
+}
@@ -73,6 +73,7 @@ type cronjobResp struct {
 	WorkflowArgs   *commonmodels.WorkflowTaskArgs `json:"workflow_args,omitempty"`
 	WorkflowV4Args *commonmodels.WorkflowV4       `json:"workflow_v4_args,omitempty"`
 	TestArgs       *commonmodels.TestTaskArgs     `json:"test_args,omitempty"`
+	EnvArgs        *commonmodels.EnvArgs          `json:"env_args,omitempty"`
 	JobType        string                         `json:"job_type"`
 	Enabled        bool                           `json:"enabled"`
 }
@@ -98,6 +99,7 @@ func ListActiveCronjobFailsafe(c *gin.Context) {
 			WorkflowArgs:   cronjob.WorkflowArgs,
 			WorkflowV4Args: cronjob.WorkflowV4Args,
 			TestArgs:       cronjob.TestArgs,
+			EnvArgs:        cronjob.EnvArgs,
 			JobType:        cronjob.JobType,
 			Enabled:        cronjob.Enabled,
 		})
@@ -127,6 +129,7 @@ func ListActiveCronjob(c *gin.Context) {
 			WorkflowArgs:   cronjob.WorkflowArgs,
 			WorkflowV4Args: cronjob.WorkflowV4Args,
 			TestArgs:       cronjob.TestArgs,
+			EnvArgs:        cronjob.EnvArgs,
 			JobType:        cronjob.JobType,
 			Enabled:        cronjob.Enabled,
 		})
@@ -159,6 +162,7 @@ func ListCronjob(c *gin.Context) {
 			WorkflowArgs:   cronjob.WorkflowArgs,
 			WorkflowV4Args: cronjob.WorkflowV4Args,
 			TestArgs:       cronjob.TestArgs,
+			EnvArgs:        cronjob.EnvArgs,
 			JobType:        cronjob.JobType,
 			Enabled:        cronjob.Enabled,
 		})
@@ -940,6 +940,17 @@ func insertDeliveryDistributions(result *task.ServicePackageResult, chartVersion
 	return nil
 }
 
+// insertDeliveryAttestations records the attestations of the released images generated by the build jobs
+func insertDeliveryAttestations(deliveryVersion *commonmodels.DeliveryVersion, imagesMap *sync.Map, logger *zap.SugaredLogger) {
+	imagesMap.Range(func(key, value interface{}) bool {
+		imageDetail := value.(*ServiceImageDetails)
+		for _, image := range imageDetail.Images {
+			commonservice.InsertDeliveryAttestation(deliveryVersion.ID, imageDetail.ServiceName, image.ImageUrl, logger)
+		}
+		return true
+	})
+}
+
 func buildDeliveryCharts(chartDataMap map[string]*DeliveryChartData, deliveryVersion *commonmodels.DeliveryVersion, args *DeliveryVersionChartData, logger *zap.SugaredLogger) (err error) {
 	defer func() {
 		if err != nil {
@@ -1005,6 +1016,8 @@ func buildDeliveryCharts(chartDataMap map[string]*DeliveryChartData, deliveryVer
 		return
 	}
 
+	insertDeliveryAttestations(deliveryVersion, imagesDataMap, logger)
+
 	// create task to deal with images
 	// offline docker images are not supported
 	taskArgs := buildArtifactTaskArgs(deliveryVersion.ProductName, deliveryVersion.ProductEnvInfo.EnvName, imagesDataMap)
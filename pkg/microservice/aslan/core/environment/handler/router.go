@@ -169,6 +169,16 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		environments.PUT("/:name/services/:serviceName", UpdateService)
 		environments.POST("/:name/services/:serviceName/restart", RestartService)
 		environments.POST("/:name/services/:serviceName/restartNew", RestartWorkload)
+		environments.GET("/:name/drifts", ListEnvDrifts)
+		environments.POST("/:name/services/:serviceName/reconcile", ReconcileServiceDrift)
+		environments.POST("/:name/services/:serviceName/adopt", AdoptServiceDrift)
+		environments.GET("/:name/snapshots", ListEnvSnapshots)
+		environments.GET("/:name/snapshots/:revision", GetEnvSnapshot)
+		environments.POST("/:name/snapshots/:revision/rollback", RollbackEnvToSnapshot)
+		environments.GET("/:name/sleepSchedule", GetEnvSleepSchedule)
+		environments.PUT("/:name/sleepSchedule", UpdateEnvSleepSchedule)
+		environments.POST("/:name/sleep", SleepEnv)
+		environments.POST("/:name/wake", WakeEnv)
 		environments.POST("/:name/services/:serviceName/scaleNew", ScaleNewService)
 		environments.GET("/:name/services/:serviceName/containers/:container", GetServiceContainer)
 
@@ -132,8 +132,11 @@ func DeleteCommonEnvCfg(envName, productName, objectName string, commonEnvCfgTyp
 	}
 
 	if resourceData != nil {
-		return commonrepo.NewEnvResourceColl().Delete(resourceData.ID)
+		if err := commonrepo.NewEnvResourceColl().Delete(resourceData.ID); err != nil {
+			return err
+		}
 	}
+	snapshotEnv(productName, envName, "", fmt.Sprintf("delete %s %s", commonEnvCfgType, objectName), log)
 	return nil
 }
 
@@ -356,7 +359,7 @@ func CreateCommonEnvCfg(args *models.CreateUpdateCommonEnvCfgArgs, userName stri
 	if err = commonrepo.NewEnvResourceColl().Create(envResource); err != nil {
 		return e.ErrUpdateResource.AddDesc(err.Error())
 	}
-
+	snapshotEnv(args.ProductName, args.EnvName, userName, fmt.Sprintf("create %s %s", args.CommonEnvCfgType, envResource.Name), log)
 	return nil
 }
 
@@ -418,6 +421,7 @@ func UpdateCommonEnvCfg(args *models.CreateUpdateCommonEnvCfgArgs, userName stri
 		log.Error(err)
 		return err
 	}
+	snapshotEnv(args.ProductName, args.EnvName, userName, fmt.Sprintf("update %s %s", args.CommonEnvCfgType, args.Name), log)
 	return nil
 }
 
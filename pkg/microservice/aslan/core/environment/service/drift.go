@@ -0,0 +1,623 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	versionedclient "istio.io/client-go/pkg/clientset/versioned"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/informers"
+	"sigs.k8s.io/yaml"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	templatemodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/template"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/kube"
+	"github.com/koderover/zadig/pkg/setting"
+	kubeclient "github.com/koderover/zadig/pkg/shared/kube/client"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	"github.com/koderover/zadig/pkg/tool/kube/serializer"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/util/converter"
+)
+
+const (
+	driftDetectInterval = 10 * time.Minute
+	// the informer cache needs a while to catch up with the re-applied objects
+	driftRecheckDelay = 30 * time.Second
+
+	driftDetectorLease = "drift-detector"
+)
+
+var driftImagePathRegexp = regexp.MustCompile(`^containers\[(.+)\]\.image$`)
+
+type DriftAdoptResult struct {
+	Adopted []*commonmodels.DriftField `json:"adopted"`
+	Skipped []*commonmodels.DriftField `json:"skipped"`
+	Drift   *commonmodels.ServiceDrift `json:"drift"`
+}
+
+// StartDriftDetector compares the services in the k8s yaml environments with the live workloads periodically,
+// someone may have edited the workloads with kubectl, which is invisible to the environment otherwise.
+func StartDriftDetector() {
+	for {
+		time.Sleep(driftDetectInterval)
+		if !holdLease(driftDetectorLease, driftDetectInterval) {
+			continue
+		}
+
+		envs, err := commonrepo.NewProductColl().List(&commonrepo.ProductListOptions{})
+		if err != nil {
+			log.Errorf("StartDriftDetector list envs error: %s", err)
+			continue
+		}
+		for _, env := range envs {
+			if !driftDetectable(env) {
+				continue
+			}
+			clusterID := env.ClusterID
+			if clusterID == "" {
+				clusterID = setting.LocalClusterID
+			}
+			// only the clusters watched by the cluster informer are checked
+			inf, ok := ClusterInformersMap.Load(clusterID)
+			if !ok {
+				continue
+			}
+			if err := detectEnvDrift(env, inf.(informers.SharedInformerFactory), log.SugaredLogger()); err != nil {
+				log.Warnf("failed to detect drift of env %s/%s: %s", env.ProductName, env.EnvName, err)
+			}
+		}
+	}
+}
+
+// holdLease makes sure a background loop only runs in one of the aslan replicas, the lease outlives the interval
+// of the loop so that it's kept by the same replica until the replica is gone.
+func holdLease(name string, interval time.Duration) bool {
+	held, err := commonrepo.NewLeaseColl().Acquire(name, config.PodName(), 2*interval)
+	if err != nil {
+		log.Errorf("failed to acquire lease %s: %s", name, err)
+		return false
+	}
+	return held
+}
+
+// ListEnvDrifts returns the drift reports of the services in the environment, they are detected again if refresh is set.
+func ListEnvDrifts(productName, envName string, refresh bool, log *zap.SugaredLogger) ([]*commonmodels.ServiceDrift, error) {
+	if refresh {
+		env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+		if err != nil {
+			return nil, e.ErrGetEnv.AddErr(err)
+		}
+		if !driftDetectable(env) {
+			return nil, e.ErrGetEnv.AddDesc("drift detection is only supported by k8s yaml environments")
+		}
+		inf, err := getClusterInformer(env.ClusterID)
+		if err != nil {
+			return nil, e.ErrGetEnv.AddErr(err)
+		}
+		if err := detectEnvDrift(env, inf, log); err != nil {
+			return nil, e.ErrGetEnv.AddErr(err)
+		}
+	}
+	drifts, err := commonrepo.NewServiceDriftColl().List(productName, envName)
+	if err != nil {
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+	return drifts, nil
+}
+
+// ReconcileServiceDrift re-applies the desired state of the service, the drifted fields are overwritten.
+func ReconcileServiceDrift(productName, envName, serviceName string, log *zap.SugaredLogger) error {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	if !driftDetectable(env) {
+		return e.ErrUpdateEnv.AddDesc("drift reconciliation is only supported by k8s yaml environments")
+	}
+	service, ok := env.GetServiceMap()[serviceName]
+	if !ok || service.Type != setting.K8SDeployType {
+		return e.ErrUpdateEnv.AddDesc(fmt.Sprintf("k8s service %s not found in env %s", serviceName, envName))
+	}
+	if err := reapplyService(env, service, log); err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+
+	go func() {
+		time.Sleep(driftRecheckDelay)
+		if err := redetectServiceDrift(productName, envName, serviceName, log); err != nil {
+			log.Warnf("failed to detect drift of service %s in env %s/%s: %s", serviceName, productName, envName, err)
+		}
+	}()
+	return nil
+}
+
+// AdoptServiceDrift takes the live state of the drifted fields back into the environment: the images are
+// written to the env service, and the other values are written to the service variables of the renderset
+// if they are rendered from a single service variable. The fields can't be traced back are skipped.
+func AdoptServiceDrift(productName, envName, serviceName, userName string, log *zap.SugaredLogger) (*DriftAdoptResult, error) {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return nil, e.ErrUpdateEnv.AddErr(err)
+	}
+	if !driftDetectable(env) {
+		return nil, e.ErrUpdateEnv.AddDesc("drift adoption is only supported by k8s yaml environments")
+	}
+	inf, err := getClusterInformer(env.ClusterID)
+	if err != nil {
+		return nil, e.ErrUpdateEnv.AddErr(err)
+	}
+	renderSet, err := getEnvRenderSet(env)
+	if err != nil {
+		return nil, e.ErrUpdateEnv.AddErr(err)
+	}
+
+	var (
+		groupIndex = -1
+		service    *commonmodels.ProductService
+	)
+	for i, group := range env.Services {
+		for _, svc := range group {
+			if svc.ServiceName == serviceName {
+				groupIndex, service = i, svc
+			}
+		}
+	}
+	if service == nil || service.Type != setting.K8SDeployType {
+		return nil, e.ErrUpdateEnv.AddDesc(fmt.Sprintf("k8s service %s not found in env %s", serviceName, envName))
+	}
+
+	drift := detectServiceDrift(env, renderSet, service, inf)
+	if drift.Error != "" {
+		return nil, e.ErrUpdateEnv.AddDesc(drift.Error)
+	}
+
+	svcTmpl, err := commonrepo.NewServiceColl().Find(&commonrepo.ServiceFindOption{
+		ServiceName: service.ServiceName,
+		ProductName: service.ProductName,
+		Type:        service.Type,
+		Revision:    service.Revision,
+	})
+	if err != nil {
+		return nil, e.ErrUpdateEnv.AddErr(err)
+	}
+	variables, err := newDriftVariables(serviceName, renderSet, svcTmpl)
+	if err != nil {
+		return nil, e.ErrUpdateEnv.AddErr(err)
+	}
+
+	result := &DriftAdoptResult{Adopted: make([]*commonmodels.DriftField, 0), Skipped: make([]*commonmodels.DriftField, 0)}
+	containersUpdated := false
+	for _, resource := range drift.Resources {
+		for _, field := range resource.Fields {
+			if match := driftImagePathRegexp.FindStringSubmatch(field.Path); match != nil && field.Live != "" {
+				adopted := false
+				for _, container := range service.Containers {
+					if container.Name == match[1] {
+						container.Image = field.Live
+						adopted = true
+					}
+				}
+				if adopted {
+					containersUpdated = true
+					result.Adopted = append(result.Adopted, field)
+					continue
+				}
+			}
+			if variables.adopt(field) {
+				result.Adopted = append(result.Adopted, field)
+				continue
+			}
+			result.Skipped = append(result.Skipped, field)
+		}
+	}
+
+	if containersUpdated {
+		if err := commonrepo.NewProductColl().UpdateGroup(envName, productName, groupIndex, env.Services[groupIndex]); err != nil {
+			log.Errorf("failed to update service %s in env %s/%s: %s", serviceName, productName, envName, err)
+			return nil, e.ErrUpdateEnv.AddErr(err)
+		}
+	}
+	if variables.updated {
+		renderSet, err = variables.save(env, renderSet, userName, log)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the live state is unchanged, so the adopted fields are no longer drifted right away
+	result.Drift = detectServiceDrift(env, renderSet, service, inf)
+	if err := commonrepo.NewServiceDriftColl().Upsert(result.Drift); err != nil {
+		log.Errorf("failed to save drift of service %s in env %s/%s: %s", serviceName, productName, envName, err)
+	}
+	return result, nil
+}
+
+// reapplyService applies the desired state of the service without restarting the pods whose templates are unchanged.
+func reapplyService(env *commonmodels.Product, service *commonmodels.ProductService, log *zap.SugaredLogger) error {
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return err
+	}
+	restConfig, err := kubeclient.GetRESTConfig(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return err
+	}
+	istioClient, err := versionedclient.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	inf, err := getClusterInformer(env.ClusterID)
+	if err != nil {
+		return err
+	}
+	renderSet, err := getEnvRenderSet(env)
+	if err != nil {
+		return err
+	}
+	_, err = applyService(env, service, service, renderSet, env.Render, false, inf, kubeClient, istioClient, log)
+	return err
+}
+
+func driftDetectable(env *commonmodels.Product) bool {
+	if env.Source == setting.SourceFromHelm || env.Source == setting.SourceFromExternal {
+		return false
+	}
+	// the environments being changed by zadig are skipped
+	return env.Status == setting.ProductStatusSuccess || env.Status == setting.ProductStatusUnstable || env.Status == setting.ProductStatusFailed
+}
+
+func getClusterInformer(clusterID string) (informers.SharedInformerFactory, error) {
+	if clusterID == "" {
+		clusterID = setting.LocalClusterID
+	}
+	if inf, ok := ClusterInformersMap.Load(clusterID); ok {
+		return inf.(informers.SharedInformerFactory), nil
+	}
+	cls, err := kubeclient.GetKubeClientSet(config.HubServerAddress(), clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return NewClusterInformerFactory(clusterID, cls)
+}
+
+func getEnvRenderSet(env *commonmodels.Product) (*commonmodels.RenderSet, error) {
+	env.EnsureRenderInfo()
+	return commonrepo.NewRenderSetColl().Find(&commonrepo.RenderSetFindOption{
+		Name:        env.Render.Name,
+		Revision:    env.Render.Revision,
+		ProductTmpl: env.ProductName,
+		EnvName:     env.EnvName,
+	})
+}
+
+func redetectServiceDrift(productName, envName, serviceName string, log *zap.SugaredLogger) error {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return err
+	}
+	service, ok := env.GetServiceMap()[serviceName]
+	if !ok {
+		return nil
+	}
+	inf, err := getClusterInformer(env.ClusterID)
+	if err != nil {
+		return err
+	}
+	renderSet, err := getEnvRenderSet(env)
+	if err != nil {
+		return err
+	}
+	return commonrepo.NewServiceDriftColl().Upsert(detectServiceDrift(env, renderSet, service, inf))
+}
+
+func detectEnvDrift(env *commonmodels.Product, inf informers.SharedInformerFactory, log *zap.SugaredLogger) error {
+	renderSet, err := getEnvRenderSet(env)
+	if err != nil {
+		return fmt.Errorf("failed to find renderset: %s", err)
+	}
+	serviceNames := make([]string, 0)
+	for _, group := range env.Services {
+		for _, service := range group {
+			if service.Type != setting.K8SDeployType {
+				continue
+			}
+			serviceNames = append(serviceNames, service.ServiceName)
+			if err := commonrepo.NewServiceDriftColl().Upsert(detectServiceDrift(env, renderSet, service, inf)); err != nil {
+				log.Errorf("failed to save drift of service %s in env %s/%s: %s", service.ServiceName, env.ProductName, env.EnvName, err)
+			}
+		}
+	}
+	return commonrepo.NewServiceDriftColl().DeleteStale(env.ProductName, env.EnvName, serviceNames)
+}
+
+// detectServiceDrift renders the service the way it is applied to the environment, and compares the pod templates of
+// the workloads with the live ones. The replicas are not compared since they are changed by scaling and autoscalers.
+func detectServiceDrift(env *commonmodels.Product, renderSet *commonmodels.RenderSet, service *commonmodels.ProductService, inf informers.SharedInformerFactory) *commonmodels.ServiceDrift {
+	drift := &commonmodels.ServiceDrift{
+		ProductName: env.ProductName,
+		EnvName:     env.EnvName,
+		ServiceName: service.ServiceName,
+		Resources:   make([]*commonmodels.DriftResource, 0),
+		CheckTime:   time.Now().Unix(),
+	}
+	parsedYaml, err := kube.RenderEnvService(env, renderSet, service)
+	if err != nil {
+		drift.Error = fmt.Sprintf("failed to render service: %s", err)
+		return drift
+	}
+
+	for _, item := range releaseutil.SplitManifests(parsedYaml) {
+		u, err := serializer.NewDecoder().YamlToUnstructured([]byte(item))
+		if err != nil {
+			continue
+		}
+		resource := &commonmodels.DriftResource{Kind: u.GetKind(), Name: u.GetName()}
+		switch u.GetKind() {
+		case setting.Deployment:
+			desired := &appsv1.Deployment{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, desired); err != nil {
+				continue
+			}
+			live, err := getter.GetDeploymentByNameWithCache(u.GetName(), env.Namespace, inf)
+			if err != nil {
+				resource.Missing = apierrors.IsNotFound(err)
+			} else {
+				resource.Fields = compareContainers(desired.Spec.Template.Spec.Containers, live.Spec.Template.Spec.Containers)
+			}
+		case setting.StatefulSet:
+			desired := &appsv1.StatefulSet{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, desired); err != nil {
+				continue
+			}
+			live, err := getter.GetStatefulSetByNameWWithCache(u.GetName(), env.Namespace, inf)
+			if err != nil {
+				resource.Missing = apierrors.IsNotFound(err)
+			} else {
+				resource.Fields = compareContainers(desired.Spec.Template.Spec.Containers, live.Spec.Template.Spec.Containers)
+			}
+		default:
+			continue
+		}
+		if resource.Missing || len(resource.Fields) > 0 {
+			drift.Drifted = true
+			drift.Resources = append(drift.Resources, resource)
+		}
+	}
+	return drift
+}
+
+func compareContainers(desired, live []corev1.Container) []*commonmodels.DriftField {
+	fields := make([]*commonmodels.DriftField, 0)
+	liveContainers := make(map[string]corev1.Container)
+	for _, container := range live {
+		liveContainers[container.Name] = container
+	}
+
+	for _, d := range desired {
+		prefix := fmt.Sprintf("containers[%s]", d.Name)
+		l, ok := liveContainers[d.Name]
+		if !ok {
+			fields = append(fields, &commonmodels.DriftField{Path: prefix, Desired: d.Image})
+			continue
+		}
+		if d.Image != l.Image {
+			fields = append(fields, &commonmodels.DriftField{Path: prefix + ".image", Desired: d.Image, Live: l.Image})
+		}
+		if desiredCmd, liveCmd := jsonString(d.Command), jsonString(l.Command); desiredCmd != liveCmd {
+			fields = append(fields, &commonmodels.DriftField{Path: prefix + ".command", Desired: desiredCmd, Live: liveCmd})
+		}
+		if desiredArgs, liveArgs := jsonString(d.Args), jsonString(l.Args); desiredArgs != liveArgs {
+			fields = append(fields, &commonmodels.DriftField{Path: prefix + ".args", Desired: desiredArgs, Live: liveArgs})
+		}
+		fields = append(fields, compareStringMaps(prefix+".env", envValues(d.Env), envValues(l.Env))...)
+		// the resources not set in the yaml are defaulted by the apiserver and the limit ranges, they are not drifts
+		desiredRequests, desiredLimits := quantityValues(d.Resources.Requests), quantityValues(d.Resources.Limits)
+		fields = append(fields, compareStringMaps(prefix+".resources.requests", desiredRequests, filterKeys(quantityValues(l.Resources.Requests), desiredRequests))...)
+		fields = append(fields, compareStringMaps(prefix+".resources.limits", desiredLimits, filterKeys(quantityValues(l.Resources.Limits), desiredLimits))...)
+	}
+	return fields
+}
+
+func compareStringMaps(prefix string, desired, live map[string]string) []*commonmodels.DriftField {
+	fields := make([]*commonmodels.DriftField, 0)
+	keys := sets.StringKeySet(desired).Union(sets.StringKeySet(live)).List()
+	for _, key := range keys {
+		if desired[key] != live[key] {
+			fields = append(fields, &commonmodels.DriftField{Path: fmt.Sprintf("%s[%s]", prefix, key), Desired: desired[key], Live: live[key]})
+		}
+	}
+	return fields
+}
+
+// filterKeys returns the values whose keys are in the keys map.
+func filterKeys(values, keys map[string]string) map[string]string {
+	ret := make(map[string]string)
+	for key, value := range values {
+		if _, ok := keys[key]; ok {
+			ret[key] = value
+		}
+	}
+	return ret
+}
+
+func envValues(envs []corev1.EnvVar) map[string]string {
+	ret := make(map[string]string)
+	for _, env := range envs {
+		if env.ValueFrom == nil {
+			ret[env.Name] = env.Value
+			continue
+		}
+		// the api version of field refs is defaulted by the apiserver
+		if env.ValueFrom.FieldRef != nil && env.ValueFrom.FieldRef.APIVersion == "" {
+			env.ValueFrom.FieldRef.APIVersion = "v1"
+		}
+		ret[env.Name] = jsonString(env.ValueFrom)
+	}
+	return ret
+}
+
+func quantityValues(resources corev1.ResourceList) map[string]string {
+	ret := make(map[string]string)
+	for name, quantity := range resources {
+		// canonicalize the quantity so that 0.5 and 500m are equal
+		q := quantity.DeepCopy()
+		ret[string(name)] = q.String()
+	}
+	return ret
+}
+
+func jsonString(v interface{}) string {
+	bs, _ := json.Marshal(v)
+	return string(bs)
+}
+
+// driftVariables traces the drifted values back to the service variables of the environment.
+type driftVariables struct {
+	serviceName string
+	overrides   map[string]interface{}
+	values      map[string]string
+	updated     bool
+}
+
+func newDriftVariables(serviceName string, renderSet *commonmodels.RenderSet, svcTmpl *commonmodels.Service) (*driftVariables, error) {
+	overrideYaml := ""
+	for _, sv := range renderSet.ServiceVariables {
+		if sv.ServiceName == serviceName && sv.OverrideYaml != nil {
+			overrideYaml = sv.OverrideYaml.YamlContent
+		}
+	}
+	overrides, err := converter.YamlToFlatMap([]byte(overrideYaml))
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := converter.YamlToFlatMap([]byte(svcTmpl.VariableYaml))
+	if err != nil {
+		return nil, err
+	}
+	globals, err := converter.YamlToFlatMap([]byte(renderSet.DefaultValues))
+	if err != nil {
+		return nil, err
+	}
+
+	// only the service vars can be overridden in the environment, the global variables are shared by the services
+	values := make(map[string]string)
+	for _, key := range svcTmpl.ServiceVars {
+		if _, ok := globals[key]; ok {
+			continue
+		}
+		if v, ok := overrides[key]; ok {
+			values[key] = fmt.Sprint(v)
+		} else if v, ok := defaults[key]; ok {
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return &driftVariables{serviceName: serviceName, overrides: overrides, values: values}, nil
+}
+
+// adopt overrides the variable whose value is the desired value of the field, the field is skipped
+// if no variable or more than one variables have the value.
+func (v *driftVariables) adopt(field *commonmodels.DriftField) bool {
+	if field.Desired == "" || field.Live == "" {
+		return false
+	}
+	keys := make([]string, 0)
+	for key, value := range v.values {
+		if value == field.Desired {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) != 1 {
+		return false
+	}
+	v.overrides[keys[0]] = field.Live
+	v.values[keys[0]] = field.Live
+	v.updated = true
+	return true
+}
+
+// save creates a new revision of the renderset with the adopted variables and points the environment to it.
+func (v *driftVariables) save(env *commonmodels.Product, renderSet *commonmodels.RenderSet, userName string, log *zap.SugaredLogger) (*commonmodels.RenderSet, error) {
+	expanded, err := converter.Expand(v.overrides)
+	if err != nil {
+		return nil, e.ErrUpdateEnv.AddErr(err)
+	}
+	bs, err := yaml.Marshal(expanded)
+	if err != nil {
+		return nil, e.ErrUpdateEnv.AddErr(err)
+	}
+
+	found := false
+	for _, sv := range renderSet.ServiceVariables {
+		if sv.ServiceName != v.serviceName {
+			continue
+		}
+		found = true
+		if sv.OverrideYaml == nil {
+			sv.OverrideYaml = &templatemodels.CustomYaml{}
+		}
+		sv.OverrideYaml.YamlContent = string(bs)
+	}
+	if !found {
+		renderSet.ServiceVariables = append(renderSet.ServiceVariables, &templatemodels.ServiceRender{
+			ServiceName:  v.serviceName,
+			OverrideYaml: &templatemodels.CustomYaml{YamlContent: string(bs)},
+		})
+	}
+	sort.SliceStable(renderSet.ServiceVariables, func(i, j int) bool {
+		return renderSet.ServiceVariables[i].ServiceName < renderSet.ServiceVariables[j].ServiceName
+	})
+
+	if err := commonservice.CreateK8sHelmRenderSet(&commonmodels.RenderSet{
+		Name:             renderSet.Name,
+		EnvName:          env.EnvName,
+		ProductTmpl:      env.ProductName,
+		UpdateBy:         userName,
+		DefaultValues:    renderSet.DefaultValues,
+		YamlData:         renderSet.YamlData,
+		ChartInfos:       renderSet.ChartInfos,
+		ServiceVariables: renderSet.ServiceVariables,
+	}, log); err != nil {
+		log.Errorf("[%s][P:%s] create renderset error: %v", env.EnvName, env.ProductName, err)
+		return nil, e.ErrUpdateEnv.AddErr(err)
+	}
+	newRenderSet, err := FindProductRenderSet(env.ProductName, renderSet.Name, env.EnvName, log)
+	if err != nil {
+		return nil, e.ErrUpdateEnv.AddErr(err)
+	}
+	env.Render.Name, env.Render.Revision = newRenderSet.Name, newRenderSet.Revision
+	if err := commonrepo.NewProductColl().UpdateRender(env.EnvName, env.ProductName, env.Render); err != nil {
+		log.Errorf("[%s][P:%s] failed to update product renderset: %s", env.EnvName, env.ProductName, err)
+		return nil, e.ErrUpdateEnv.AddErr(err)
+	}
+	return newRenderSet, nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+var _ = Describe("Testing drift", func() {
+
+	Describe("test compareContainers", func() {
+
+		desired := func() corev1.Container {
+			return corev1.Container{
+				Name:  "web",
+				Image: "nginx:1.23",
+				Args:  []string{"--port", "80"},
+				Env:   []corev1.EnvVar{{Name: "MODE", Value: "prod"}},
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+				},
+			}
+		}
+
+		It("should report nothing when the containers are the same", func() {
+			live := desired()
+			live.Resources.Limits = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1000m")}
+			Expect(compareContainers([]corev1.Container{desired()}, []corev1.Container{live})).To(BeEmpty())
+		})
+
+		It("should ignore the resources defaulted in the cluster", func() {
+			live := desired()
+			live.Resources.Limits[corev1.ResourceMemory] = resource.MustParse("512Mi")
+			live.Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}
+			Expect(compareContainers([]corev1.Container{desired()}, []corev1.Container{live})).To(BeEmpty())
+		})
+
+		It("should report the changed fields", func() {
+			live := desired()
+			live.Image = "nginx:1.24"
+			live.Args = []string{"--port", "8080"}
+			live.Env = append(live.Env, corev1.EnvVar{Name: "DEBUG", Value: "true"})
+			live.Resources.Limits = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}
+
+			fields := compareContainers([]corev1.Container{desired()}, []corev1.Container{live})
+			Expect(fields).To(Equal([]*commonmodels.DriftField{
+				{Path: "containers[web].image", Desired: "nginx:1.23", Live: "nginx:1.24"},
+				{Path: "containers[web].args", Desired: `["--port","80"]`, Live: `["--port","8080"]`},
+				{Path: "containers[web].env[DEBUG]", Desired: "", Live: "true"},
+				{Path: "containers[web].resources.limits[cpu]", Desired: "1", Live: "2"},
+			}))
+		})
+
+		It("should report the missing containers", func() {
+			fields := compareContainers([]corev1.Container{desired()}, nil)
+			Expect(fields).To(Equal([]*commonmodels.DriftField{{Path: "containers[web]", Desired: "nginx:1.23"}}))
+		})
+	})
+
+	Describe("test compareStringMaps", func() {
+
+		It("should report the changed, added and removed keys in order", func() {
+			fields := compareStringMaps("env", map[string]string{"a": "1", "b": "2", "c": "3"}, map[string]string{"a": "1", "b": "4", "d": "5"})
+			Expect(fields).To(Equal([]*commonmodels.DriftField{
+				{Path: "env[b]", Desired: "2", Live: "4"},
+				{Path: "env[c]", Desired: "3", Live: ""},
+				{Path: "env[d]", Desired: "", Live: "5"},
+			}))
+		})
+	})
+
+	Describe("test envValues", func() {
+
+		It("should default the api version of the field refs", func() {
+			values := envValues([]corev1.EnvVar{
+				{Name: "MODE", Value: "prod"},
+				{Name: "POD_IP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"}}},
+			})
+			Expect(values).To(Equal(map[string]string{
+				"MODE":   "prod",
+				"POD_IP": `{"fieldRef":{"apiVersion":"v1","fieldPath":"status.podIP"}}`,
+			}))
+		})
+	})
+
+	Describe("test driftVariables.adopt", func() {
+
+		newVariables := func() *driftVariables {
+			return &driftVariables{
+				serviceName: "web",
+				overrides:   map[string]interface{}{},
+				values:      map[string]string{"image": "nginx:1.23", "replicas": "2", "port": "80", "targetPort": "80"},
+			}
+		}
+
+		It("should override the variable holding the desired value", func() {
+			v := newVariables()
+			Expect(v.adopt(&commonmodels.DriftField{Path: "containers[web].image", Desired: "nginx:1.23", Live: "nginx:1.24"})).To(BeTrue())
+			Expect(v.updated).To(BeTrue())
+			Expect(v.overrides).To(Equal(map[string]interface{}{"image": "nginx:1.24"}))
+			Expect(v.values["image"]).To(Equal("nginx:1.24"))
+		})
+
+		It("should skip the ambiguous and untraceable fields", func() {
+			v := newVariables()
+			Expect(v.adopt(&commonmodels.DriftField{Path: "containers[web].env[PORT]", Desired: "80", Live: "8080"})).To(BeFalse())
+			Expect(v.adopt(&commonmodels.DriftField{Path: "containers[web].env[MODE]", Desired: "prod", Live: "dev"})).To(BeFalse())
+			Expect(v.adopt(&commonmodels.DriftField{Path: "containers[web].env[DEBUG]", Desired: "", Live: "true"})).To(BeFalse())
+			Expect(v.updated).To(BeFalse())
+			Expect(v.overrides).To(BeEmpty())
+		})
+	})
+})
@@ -17,6 +17,8 @@ limitations under the License.
 package service
 
 import (
+	"fmt"
+
 	"go.uber.org/zap"
 	"k8s.io/client-go/informers"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -49,5 +51,9 @@ func envHandleFunc(projectType string, log *zap.SugaredLogger) envHandle {
 
 func UpdateService(args *SvcOptArgs, log *zap.SugaredLogger) error {
 	projectType := getProjectType(args.ProductName)
-	return envHandleFunc(projectType, log).updateService(args)
+	if err := envHandleFunc(projectType, log).updateService(args); err != nil {
+		return err
+	}
+	snapshotEnv(args.ProductName, args.EnvName, args.UpdateBy, fmt.Sprintf("update service %s", args.ServiceName), log)
+	return nil
 }
@@ -485,12 +485,13 @@ func updateProductImpl(updateRevisionSvcs []string, deployStrategy map[string]st
 	existedServices := existedProd.GetServiceMap()
 
 	// 按照产品模板的顺序来创建或者更新服务
-	for groupIndex, prodServiceGroup := range updateProd.Services {
+	updatedGroups := make([][]*commonmodels.ProductService, 0, len(updateProd.Services))
+	deployGroups := make([][]*commonmodels.ProductService, 0, len(updateProd.Services))
+	for _, prodServiceGroup := range updateProd.Services {
 		//Mark if there is k8s type service in this group
 		//groupServices := make([]*commonmodels.ProductService, 0)
-		var wg sync.WaitGroup
-
 		groupSvcs := make([]*commonmodels.ProductService, 0)
+		deploySvcs := make([]*commonmodels.ProductService, 0)
 		for _, prodService := range prodServiceGroup {
 			// no need to update service
 			if filter != nil && !filter(prodService) {
@@ -518,25 +519,8 @@ func updateProductImpl(updateRevisionSvcs []string, deployStrategy map[string]st
 			}
 			groupSvcs = append(groupSvcs, service)
 
-			if prodService.Type == setting.K8SDeployType {
-				log.Infof("[Namespace:%s][Product:%s][Service:%s] upsert service", envName, productName, prodService.ServiceName)
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					if !commonutil.ServiceDeployed(prodService.ServiceName, deployStrategy) {
-						return
-					}
-					_, errUpsertService := upsertService(
-						updateProd,
-						service,
-						existedServices[service.ServiceName],
-						renderSet, oldProductRender, inf, kubeClient, istioClient, log)
-					if errUpsertService != nil {
-						service.Error = errUpsertService.Error()
-					} else {
-						service.Error = ""
-					}
-				}()
+			if prodService.Type == setting.K8SDeployType && commonutil.ServiceDeployed(service.ServiceName, deployStrategy) {
+				deploySvcs = append(deploySvcs, service)
 			}
 
 			//if svcRev.Updatable || commonutil.DeployStrategyChanged(svcRev.ServiceName, existedProd.ServiceDeployStrategy, deployStrategy) {
@@ -575,7 +559,8 @@ func updateProductImpl(updateRevisionSvcs []string, deployStrategy map[string]st
 			//	groupServices = append(groupServices, prodService)
 			//}
 		}
-		wg.Wait()
+		updatedGroups = append(updatedGroups, groupSvcs)
+		deployGroups = append(deployGroups, deploySvcs)
 
 		////merge new and old services
 		//var updateGroup []*commonmodels.ProductService
@@ -604,7 +589,41 @@ func updateProductImpl(updateRevisionSvcs []string, deployStrategy map[string]st
 		//		updateGroup = append(updateGroup, newService)
 		//	}
 		//}
+	}
 
+	rollout, err := newServiceRollout(updateProd, deployGroups, renderSet, log)
+	if err != nil {
+		log.Errorf("[%s][P:%s] failed to plan service rollout, err: %s", envName, productName, err)
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	// services blocked by dependencies have been marked with errors, the others keep updating
+	errRollout := rollout.run(func(layer []*commonmodels.ProductService) error {
+		var wg sync.WaitGroup
+		for _, service := range layer {
+			log.Infof("[Namespace:%s][Product:%s][Service:%s] upsert service", envName, productName, service.ServiceName)
+			wg.Add(1)
+			go func(service *commonmodels.ProductService) {
+				defer wg.Done()
+				_, errUpsertService := upsertService(
+					updateProd,
+					service,
+					existedServices[service.ServiceName],
+					renderSet, oldProductRender, inf, kubeClient, istioClient, log)
+				if errUpsertService != nil {
+					service.Error = errUpsertService.Error()
+				} else {
+					service.Error = ""
+				}
+			}(service)
+		}
+		wg.Wait()
+		return nil
+	})
+	if errRollout != nil {
+		log.Errorf("[%s][P:%s] some services are not updated, err: %s", envName, productName, errRollout)
+	}
+
+	for groupIndex, groupSvcs := range updatedGroups {
 		err = commonrepo.NewProductColl().UpdateGroup(envName, productName, groupIndex, groupSvcs)
 		if err != nil {
 			log.Errorf("Failed to update collection - service group %d. Error: %v", groupIndex, err)
@@ -837,6 +856,7 @@ func updateHelmProduct(productName, envName, username, requestID string, overrid
 			log.Errorf("[%s][%s] Product.Update error: %v", envName, productName, err)
 			return
 		}
+		snapshotEnv(productName, envName, username, "update environment", log)
 	}()
 	return nil
 }
@@ -1479,6 +1499,7 @@ func updateHelmProductVariable(productResp *commonmodels.Product, renderset *com
 			log.Errorf("[%s][%s] Product.Update error: %v", envName, productName, err)
 			return
 		}
+		snapshotEnv(productName, envName, userName, "update variables", log)
 	}()
 	return nil
 }
@@ -1722,6 +1743,12 @@ func DeleteProduct(username, envName, productName, requestID string, isDelete bo
 	log.Infof("[%s] delete product %s", username, productInfo.Namespace)
 	commonservice.LogProductStats(username, setting.DeleteProductEvent, productName, requestID, eventStart, log)
 
+	if productInfo.SleepSchedule != nil {
+		if err := upsertEnvSleepCronjobs(productName, envName, nil); err != nil {
+			log.Errorf("failed to delete sleep cronjobs of env %s/%s: %s", productName, envName, err)
+		}
+	}
+
 	ctx := context.TODO()
 	switch productInfo.Source {
 	case setting.SourceFromHelm:
@@ -1734,6 +1761,8 @@ func DeleteProduct(username, envName, productName, requestID string, isDelete bo
 		err = commonrepo.NewProductColl().Delete(envName, productName)
 		if err != nil {
 			log.Errorf("Product.Delete error: %v", err)
+		} else {
+			deleteEnvRecords(productName, envName, log)
 		}
 
 		go func() {
@@ -1778,6 +1807,8 @@ func DeleteProduct(username, envName, productName, requestID string, isDelete bo
 		err = commonrepo.NewProductColl().Delete(envName, productName)
 		if err != nil {
 			log.Errorf("Product.Delete error: %v", err)
+		} else {
+			deleteEnvRecords(productName, envName, log)
 		}
 
 		tempProduct, err := mongotemplate.NewProductColl().Find(productName)
@@ -1837,6 +1868,8 @@ func DeleteProduct(username, envName, productName, requestID string, isDelete bo
 		err = commonrepo.NewProductColl().Delete(envName, productName)
 		if err != nil {
 			log.Errorf("Product.Delete error: %v", err)
+		} else {
+			deleteEnvRecords(productName, envName, log)
 		}
 	default:
 		go func() {
@@ -1844,6 +1877,8 @@ func DeleteProduct(username, envName, productName, requestID string, isDelete bo
 			err = commonrepo.NewProductColl().Delete(envName, productName)
 			if err != nil {
 				log.Errorf("Product.Delete error: %v", err)
+			} else {
+				deleteEnvRecords(productName, envName, log)
 			}
 			defer func() {
 				if err != nil {
@@ -1891,6 +1926,16 @@ func DeleteProduct(username, envName, productName, requestID string, isDelete bo
 	return nil
 }
 
+// deleteEnvRecords removes the snapshots and the drift reports of the environment once the environment is deleted.
+func deleteEnvRecords(productName, envName string, log *zap.SugaredLogger) {
+	if err := commonrepo.NewEnvSnapshotColl().Delete(productName, envName); err != nil {
+		log.Errorf("failed to delete snapshots of env %s/%s: %s", productName, envName, err)
+	}
+	if err := commonrepo.NewServiceDriftColl().Delete(productName, envName); err != nil {
+		log.Errorf("failed to delete drift reports of env %s/%s: %s", productName, envName, err)
+	}
+}
+
 func DeleteProductServices(userName, requestID, envName, productName string, serviceNames []string, log *zap.SugaredLogger) (err error) {
 	productInfo, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
 	if err != nil {
@@ -2215,6 +2260,7 @@ func createGroups(user, requestID string, args *commonmodels.Product, eventStart
 			log.Errorf("[%s][%s] Product.Update set product status error: %v", envName, args.ProductName, err)
 			return
 		}
+		snapshotEnv(args.ProductName, envName, user, "create environment", log)
 	}()
 
 	err = initEnvConfigSetAction(args.EnvName, args.Namespace, args.ProductName, user, args.EnvConfigs, false, kubeClient)
@@ -2224,13 +2270,29 @@ func createGroups(user, requestID string, args *commonmodels.Product, eventStart
 		return
 	}
 
+	deployGroups := make([][]*commonmodels.ProductService, 0, len(args.Services))
 	for _, group := range args.Services {
-		err = envHandleFunc(getProjectType(args.ProductName), log).createGroup(user, args, group, renderSet, informer, kubeClient)
-		if err != nil {
-			args.Status = setting.ProductStatusFailed
-			log.Errorf("createGroup error :%+v", err)
-			return
+		deployGroup := make([]*commonmodels.ProductService, 0, len(group))
+		for _, svc := range group {
+			if commonutil.ServiceDeployed(svc.ServiceName, args.ServiceDeployStrategy) {
+				deployGroup = append(deployGroup, svc)
+			}
 		}
+		deployGroups = append(deployGroups, deployGroup)
+	}
+	rollout, err := newServiceRollout(args, deployGroups, renderSet, log)
+	if err != nil {
+		args.Status = setting.ProductStatusFailed
+		log.Errorf("failed to plan service rollout, err: %s", err)
+		return
+	}
+	err = rollout.run(func(layer []*commonmodels.ProductService) error {
+		return envHandleFunc(getProjectType(args.ProductName), log).createGroup(user, args, layer, renderSet, informer, kubeClient)
+	})
+	if err != nil {
+		args.Status = setting.ProductStatusFailed
+		log.Errorf("createGroup error :%+v", err)
+		return
 	}
 
 	// If the user does not enable environment sharing, end. Otherwise, continue to perform environment sharing operations.
@@ -2271,6 +2333,14 @@ func getProjectType(productName string) string {
 // upsertService
 func upsertService(env *commonmodels.Product, service *commonmodels.ProductService, prevSvc *commonmodels.ProductService,
 	renderSet *commonmodels.RenderSet, preRenderInfo *commonmodels.RenderInfo, informer informers.SharedInformerFactory, kubeClient client.Client, istioClient versionedclient.Interface, log *zap.SugaredLogger,
+) ([]*unstructured.Unstructured, error) {
+	return applyService(env, service, prevSvc, renderSet, preRenderInfo, true, informer, kubeClient, istioClient, log)
+}
+
+// applyService applies the rendered resources of the service, the pods are restarted if restart is set,
+// otherwise they are only recreated when the pod templates are changed.
+func applyService(env *commonmodels.Product, service *commonmodels.ProductService, prevSvc *commonmodels.ProductService,
+	renderSet *commonmodels.RenderSet, preRenderInfo *commonmodels.RenderInfo, restart bool, informer informers.SharedInformerFactory, kubeClient client.Client, istioClient versionedclient.Interface, log *zap.SugaredLogger,
 ) ([]*unstructured.Unstructured, error) {
 	isUpdate := prevSvc == nil
 	errList := &multierror.Error{
@@ -2408,10 +2478,15 @@ func upsertService(env *commonmodels.Product, service *commonmodels.ProductServi
 			if err != nil {
 				podAnnotations = nil
 			}
-			err = unstructured.SetNestedStringMap(u.Object, applyUpdatedAnnotations(podAnnotations), "spec", "template", "metadata", "annotations")
+			if restart {
+				podAnnotations = applyUpdatedAnnotations(podAnnotations)
+			} else {
+				podAnnotations = keepLiveUpdatedAnnotations(u.GetKind(), u.GetName(), namespace, podAnnotations, informer)
+			}
+			err = unstructured.SetNestedStringMap(u.Object, podAnnotations, "spec", "template", "metadata", "annotations")
 			if err != nil {
 				log.Errorf("merge annotation failed err:%s", err)
-				u.Object = setFieldValueIsNotExist(u.Object, applyUpdatedAnnotations(podAnnotations), "spec", "template", "metadata", "annotations")
+				u.Object = setFieldValueIsNotExist(u.Object, podAnnotations, "spec", "template", "metadata", "annotations")
 			}
 
 			if needSelectorLabel {
@@ -2792,6 +2867,29 @@ func applyUpdatedAnnotations(annotations map[string]string) map[string]string {
 	return annotations
 }
 
+// keepLiveUpdatedAnnotations keeps the update time of the live workload in the pod template, so that the pods are not
+// restarted unless the pod template is changed.
+func keepLiveUpdatedAnnotations(kind, name, namespace string, annotations map[string]string, informer informers.SharedInformerFactory) map[string]string {
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	var liveAnnotations map[string]string
+	switch kind {
+	case setting.Deployment:
+		if deploy, err := getter.GetDeploymentByNameWithCache(name, namespace, informer); err == nil {
+			liveAnnotations = deploy.Spec.Template.Annotations
+		}
+	case setting.StatefulSet:
+		if sts, err := getter.GetStatefulSetByNameWWithCache(name, namespace, informer); err == nil {
+			liveAnnotations = sts.Spec.Template.Annotations
+		}
+	}
+	if updatedAt, ok := liveAnnotations[setting.UpdatedByLabel]; ok {
+		annotations[setting.UpdatedByLabel] = updatedAt
+	}
+	return annotations
+}
+
 func applySystemImagePullSecrets(podSpec *corev1.PodSpec) {
 	for _, secret := range podSpec.ImagePullSecrets {
 		if secret.Name == setting.DefaultImagePullSecret {
@@ -2942,6 +3040,7 @@ func installProductHelmCharts(user, requestID string, args *commonmodels.Product
 			log.Errorf("[%s][P:%s] Product.UpdateStatusAndError error: %v", envName, args.ProductName, err)
 			return
 		}
+		snapshotEnv(args.ProductName, envName, user, "create environment", log)
 	}()
 
 	chartInfoMap := make(map[string]*templatemodels.ServiceRender)
@@ -3404,8 +3503,10 @@ func proceedHelmRelease(productResp *commonmodels.Product, renderset *commonmode
 	}
 
 	errList := new(multierror.Error)
-	for groupIndex, groupServices := range productResp.Services {
-		serviceList := make([]*commonmodels.Service, 0)
+	serviceObjMap := make(map[string]*commonmodels.Service)
+	deployGroups := make([][]*commonmodels.ProductService, 0, len(productResp.Services))
+	for _, groupServices := range productResp.Services {
+		deploySvcs := make([]*commonmodels.ProductService, 0)
 		for _, service := range groupServices {
 			if _, ok := renderChartMap[service.ServiceName]; !ok {
 				continue
@@ -3427,12 +3528,32 @@ func proceedHelmRelease(productResp *commonmodels.Product, renderset *commonmode
 				log.Errorf("failed to find service %s, err %s", service.ServiceName, err.Error())
 				return err
 			}
-			serviceList = append(serviceList, serviceObj)
+			serviceObjMap[service.ServiceName] = serviceObj
+			deploySvcs = append(deploySvcs, service)
+		}
+		deployGroups = append(deployGroups, deploySvcs)
+	}
+
+	rollout, err := newServiceRollout(productResp, deployGroups, renderset, log)
+	if err != nil {
+		return err
+	}
+	errRollout := rollout.run(func(layer []*commonmodels.ProductService) error {
+		serviceList := make([]*commonmodels.Service, 0, len(layer))
+		for _, service := range layer {
+			serviceList = append(serviceList, serviceObjMap[service.ServiceName])
 		}
-		groupServiceErr := batchExecutorWithRetry(3, time.Millisecond*500, serviceList, handler, log)
-		if groupServiceErr != nil {
-			errList = multierror.Append(errList, groupServiceErr...)
+		layerServiceErr := batchExecutorWithRetry(3, time.Millisecond*500, serviceList, handler, log)
+		if layerServiceErr != nil {
+			errList = multierror.Append(errList, layerServiceErr...)
 		}
+		return nil
+	})
+	if errRollout != nil {
+		errList = multierror.Append(errList, errRollout)
+	}
+
+	for groupIndex, groupServices := range productResp.Services {
 		err := commonrepo.NewProductColl().UpdateGroup(envName, productName, groupIndex, groupServices)
 		if err != nil {
 			log.Errorf("Failed to update service group %d. Error: %v", groupIndex, err)
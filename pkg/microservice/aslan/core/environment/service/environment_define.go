@@ -91,6 +91,8 @@ type ProductResp struct {
 	ShareEnvEnable  bool   `json:"share_env_enable"`
 	ShareEnvIsBase  bool   `json:"share_env_is_base"`
 	ShareEnvBaseEnv string `json:"share_env_base_env"`
+
+	Drifts []*commonmodels.ServiceDrift `json:"drifts,omitempty"`
 }
 
 type ProductParams struct {
@@ -228,6 +230,8 @@ type ResourceDeployStatus struct {
 	Type   string       `json:"type"`
 	Name   string       `json:"name"`
 	Status DeployStatus `json:"status"`
+	// Ready indicates whether the workloads have finished rolling out, resources without pods are ready once deployed
+	Ready bool `json:"ready"`
 }
 
 type ServiceDeployStatus struct {
@@ -466,6 +466,7 @@ func updateK8sProduct(exitedProd *commonmodels.Product, user, requestID string,
 			log.Errorf("[%s][%s] Product.Update set product status error: %v", envName, productName, err)
 			return
 		}
+		snapshotEnv(productName, envName, user, "update environment", log)
 	}()
 
 	return nil
@@ -284,5 +284,6 @@ func UpdateContainerImage(requestID string, args *UpdateContainerImageArgs, log
 			return e.ErrUpdateConainterImage.AddDesc("更新环境信息失败")
 		}
 	}
+	snapshotEnv(args.ProductName, args.EnvName, setting.SystemUser, fmt.Sprintf("update image of %s/%s", args.Name, args.ContainerName), log)
 	return nil
 }
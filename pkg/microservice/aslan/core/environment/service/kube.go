@@ -30,12 +30,15 @@ import (
 	"go.uber.org/zap"
 	"helm.sh/helm/v3/pkg/releaseutil"
 	"helm.sh/helm/v3/pkg/storage/driver"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
@@ -1053,12 +1056,39 @@ func setResourceDeployStatus(namespace string, resourceMap map[string]map[string
 		for _, item := range u.Items {
 			if deployStatus, ok := resources[item.GetName()]; ok && deployStatus.Status == StatusUnDeployed {
 				deployStatus.Status = StatusDeployed
+				deployStatus.Ready = unstructuredWorkloadReady(&item)
 			}
 		}
 	}
 	return nil
 }
 
+// unstructuredWorkloadReady checks if the workload has finished rolling out, resources without pods are always ready
+func unstructuredWorkloadReady(u *unstructured.Unstructured) bool {
+	switch u.GetKind() {
+	case setting.Deployment:
+		d := &appsv1.Deployment{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, d); err != nil {
+			return false
+		}
+		return wrapper.Deployment(d).Ready()
+	case setting.StatefulSet:
+		s := &appsv1.StatefulSet{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, s); err != nil {
+			return false
+		}
+		return wrapper.StatefulSet(s).Ready()
+	case setting.Job:
+		j := &batchv1.Job{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, j); err != nil {
+			return false
+		}
+		return wrapper.Job(j).Complete()
+	default:
+		return true
+	}
+}
+
 func GetReleaseDeployStatus(productName string, request *HelmDeployStatusCheckRequest) ([]*ServiceDeployStatus, error) {
 	clusterID, namespace, envName := request.ClusterID, request.Namespace, request.EnvName
 	productServices, err := commonrepo.NewServiceColl().ListMaxRevisionsByProduct(productName)
@@ -1114,7 +1144,30 @@ func setReleaseDeployStatus(namespace string, resourceMap map[string]*ResourceDe
 		}
 		if release != nil {
 			deployStatus.Status = StatusDeployed
+			deployStatus.Ready = releaseWorkloadsReady(namespace, release.Manifest, kubeClient)
 		}
 	}
 	return nil
 }
+
+// releaseWorkloadsReady checks if all the workloads rendered in the release manifest have finished rolling out
+func releaseWorkloadsReady(namespace, manifest string, kubeClient client.Client) bool {
+	for _, item := range releaseutil.SplitManifests(manifest) {
+		u, err := serializer.NewDecoder().YamlToUnstructured([]byte(item))
+		if err != nil {
+			continue
+		}
+		switch u.GetKind() {
+		case setting.Deployment, setting.StatefulSet, setting.Job:
+		default:
+			continue
+		}
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(u.GroupVersionKind())
+		found, err := getter.GetResourceInCache(namespace, u.GetName(), current, kubeClient)
+		if err != nil || !found || !unstructuredWorkloadReady(current) {
+			return false
+		}
+	}
+	return true
+}
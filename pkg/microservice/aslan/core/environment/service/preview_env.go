@@ -0,0 +1,444 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	templaterepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb/template"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+const (
+	previewEnvCleanInterval = 10 * time.Minute
+	previewEnvCleanerLease  = "preview-env-cleaner"
+	// previewEnvLockTTL keeps the lock of a preview environment from being held forever if aslan exits while holding it.
+	previewEnvLockTTL = 5 * time.Minute
+	// defaultPreviewEnvIdleTTL is in hours
+	defaultPreviewEnvIdleTTL = 72
+	previewEnvNameMaxLength  = 32
+	previewEnvNameHashLength = 6
+
+	// the statuses are shown in the comment of the pull request
+	previewEnvStatusCreating = "Creating"
+	previewEnvStatusRunning  = "Running"
+	previewEnvStatusDeleting = "Deleting"
+	previewEnvStatusDeleted  = "Completed"
+)
+
+var previewEnvNameInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+type EnsurePreviewEnvArgs struct {
+	ProductName  string
+	WorkflowName string
+	HookName     string
+	Setting      *commonmodels.PreviewEnvSetting
+	MainRepo     *commonmodels.MainHookRepo
+	PR           int
+	// Services are the services built from the pull request
+	Services []string
+	BaseURI  string
+}
+
+// PreviewEnvName returns the name of the preview environment of the pull request,
+// the workflows of the same project triggered by the pull request share the environment.
+// The repos with the same name in different namespaces or code hosts are told apart by the hash of the full path.
+func PreviewEnvName(codehostID int, repoOwner, repoName string, pr int) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d/%s/%s", codehostID, repoOwner, repoName)))
+	suffix := fmt.Sprintf("-%s-%d", hex.EncodeToString(hash[:])[:previewEnvNameHashLength], pr)
+	name := previewEnvNameInvalidChars.ReplaceAllString(strings.ToLower(repoName), "-")
+	if len(name) > previewEnvNameMaxLength-len("pr-")-len(suffix) {
+		name = name[:previewEnvNameMaxLength-len("pr-")-len(suffix)]
+	}
+	return "pr-" + strings.Trim(name, "-") + suffix
+}
+
+// EnsurePreviewEnv creates the preview environment of the pull request from the base environment
+// with only the given services, or adds the missing services to the existing one.
+// It waits until the environment is ready and comments the preview environment in the pull request.
+func EnsurePreviewEnv(args *EnsurePreviewEnvArgs, requestID string, log *zap.SugaredLogger) (*commonmodels.PreviewEnv, error) {
+	if len(args.Services) == 0 {
+		return nil, fmt.Errorf("no service is built from the pull request")
+	}
+
+	envName := PreviewEnvName(args.MainRepo.CodehostID, args.MainRepo.GetRepoNamespace(), args.MainRepo.RepoName, args.PR)
+	unlock, err := lockPreviewEnv(args.ProductName, envName)
+	if err != nil {
+		return nil, err
+	}
+	preview, err := preparePreviewEnv(args, envName, requestID, log)
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := waitPreviewEnvReady(args.ProductName, envName); err != nil {
+		return nil, err
+	}
+
+	preview.LastActiveTime = time.Now().Unix()
+	commentPreviewEnv(args.MainRepo, args.BaseURI, preview, previewEnvStatusRunning, log)
+	if err := commonrepo.NewPreviewEnvColl().Update(preview); err != nil {
+		return nil, fmt.Errorf("failed to update preview env %s: %s", envName, err)
+	}
+	return preview, nil
+}
+
+// lockPreviewEnv serializes the preparation of the preview environment, the workflows triggered by the same
+// pull request run concurrently and must not create the environment twice. The others wait until it is unlocked.
+func lockPreviewEnv(productName, envName string) (func(), error) {
+	name := fmt.Sprintf("preview-env-%s-%s", productName, envName)
+	holder := fmt.Sprintf("%s-%s", config.PodName(), uuid.NewV4())
+	timeout := time.After(time.Duration(config.ServiceStartTimeout()) * time.Second)
+	for {
+		acquired, err := commonrepo.NewLeaseColl().Acquire(name, holder, previewEnvLockTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock preview env %s: %s", envName, err)
+		}
+		if acquired {
+			return func() {
+				if err := commonrepo.NewLeaseColl().Release(name, holder); err != nil {
+					log.Warnf("failed to unlock preview env %s: %s", envName, err)
+				}
+			}, nil
+		}
+
+		select {
+		case <-timeout:
+			return nil, fmt.Errorf("preview env %s is not prepared by other workflows in %d seconds", envName, config.ServiceStartTimeout())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// preparePreviewEnv creates the preview environment or adds the missing services to it, and saves the preview environment.
+func preparePreviewEnv(args *EnsurePreviewEnvArgs, envName, requestID string, log *zap.SugaredLogger) (*commonmodels.PreviewEnv, error) {
+	coll := commonrepo.NewPreviewEnvColl()
+	preview, err := coll.Find(args.ProductName, envName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find preview env %s: %s", envName, err)
+	}
+
+	notificationID := ""
+	if preview != nil {
+		// the environment may be deleted manually
+		if _, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: args.ProductName, EnvName: envName}); err == mongo.ErrNoDocuments {
+			notificationID = preview.NotificationID
+			if err := coll.Delete(args.ProductName, envName); err != nil {
+				return nil, err
+			}
+			preview = nil
+		}
+	}
+
+	if preview == nil {
+		if err := createPreviewEnv(args, envName, requestID, log); err != nil {
+			return nil, err
+		}
+		preview = &commonmodels.PreviewEnv{
+			ProductName:    args.ProductName,
+			EnvName:        envName,
+			BaseEnv:        args.Setting.BaseEnv,
+			WorkflowName:   args.WorkflowName,
+			HookName:       args.HookName,
+			CodehostID:     args.MainRepo.CodehostID,
+			RepoOwner:      args.MainRepo.GetRepoNamespace(),
+			RepoName:       args.MainRepo.RepoName,
+			PR:             args.PR,
+			Services:       args.Services,
+			NotificationID: notificationID,
+			IdleTTL:        args.Setting.IdleTTL,
+			LastActiveTime: time.Now().Unix(),
+			CreatedBy:      setting.WebhookTaskCreator,
+			CreateTime:     time.Now().Unix(),
+		}
+		preview.URL = renderPreviewEnvURL(args.Setting.URLTemplate, preview)
+		// save it before the environment is ready, so that the events of the pull request do not create it again
+		if err := coll.Create(preview); err != nil {
+			return nil, fmt.Errorf("failed to save preview env %s: %s", envName, err)
+		}
+		commentPreviewEnv(args.MainRepo, args.BaseURI, preview, previewEnvStatusCreating, log)
+		return preview, nil
+	}
+
+	addedServices := sets.NewString(args.Services...).Difference(sets.NewString(preview.Services...)).List()
+	if len(addedServices) > 0 {
+		if err := addPreviewEnvServices(preview, addedServices, requestID, log); err != nil {
+			return nil, err
+		}
+		preview.Services = append(preview.Services, addedServices...)
+	}
+	preview.IdleTTL = args.Setting.IdleTTL
+	preview.URL = renderPreviewEnvURL(args.Setting.URLTemplate, preview)
+	if err := coll.Update(preview); err != nil {
+		return nil, fmt.Errorf("failed to update preview env %s: %s", envName, err)
+	}
+	return preview, nil
+}
+
+func createPreviewEnv(args *EnsurePreviewEnvArgs, envName, requestID string, log *zap.SugaredLogger) error {
+	templateProduct, err := templaterepo.NewProductColl().Find(args.ProductName)
+	if err != nil {
+		return fmt.Errorf("failed to find project %s: %s", args.ProductName, err)
+	}
+	if !templateProduct.IsK8sYamlProduct() {
+		return fmt.Errorf("preview environment is only supported in k8s yaml projects")
+	}
+
+	baseEnv, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: args.ProductName, EnvName: args.Setting.BaseEnv})
+	if err != nil {
+		return fmt.Errorf("failed to find base env %s: %s", args.Setting.BaseEnv, err)
+	}
+	if !baseEnv.ShareEnv.Enable || !baseEnv.ShareEnv.IsBase {
+		return fmt.Errorf("env %s is not a base environment", baseEnv.EnvName)
+	}
+
+	baseEnv.EnsureRenderInfo()
+	renderSet, err := commonrepo.NewRenderSetColl().Find(&commonrepo.RenderSetFindOption{Name: baseEnv.Render.Name, Revision: baseEnv.Render.Revision, EnvName: baseEnv.EnvName})
+	if err != nil {
+		return fmt.Errorf("failed to find renderset of base env %s: %s", baseEnv.EnvName, err)
+	}
+	variableYamls := make(map[string]string)
+	for _, sv := range renderSet.ServiceVariables {
+		if sv.OverrideYaml != nil {
+			variableYamls[sv.ServiceName] = sv.OverrideYaml.YamlContent
+		}
+	}
+
+	// keep the deploy order of the base environment
+	serviceSet := sets.NewString(args.Services...)
+	services := make([][]*ProductK8sServiceCreationInfo, 0)
+	for _, group := range baseEnv.Services {
+		svcGroup := make([]*ProductK8sServiceCreationInfo, 0)
+		for _, svc := range group {
+			if !serviceSet.Has(svc.ServiceName) {
+				continue
+			}
+			serviceSet.Delete(svc.ServiceName)
+			svcGroup = append(svcGroup, &ProductK8sServiceCreationInfo{
+				ProductService: &commonmodels.ProductService{
+					ServiceName:  svc.ServiceName,
+					ProductName:  svc.ProductName,
+					Type:         svc.Type,
+					Revision:     svc.Revision,
+					Containers:   svc.Containers,
+					VariableYaml: variableYamls[svc.ServiceName],
+				},
+				DeployStrategy: setting.ServiceDeployStrategyDeploy,
+			})
+		}
+		if len(svcGroup) > 0 {
+			services = append(services, svcGroup)
+		}
+	}
+	if serviceSet.Len() > 0 {
+		return fmt.Errorf("services %v are not deployed in base env %s", serviceSet.List(), baseEnv.EnvName)
+	}
+
+	return CopyYamlProduct(setting.WebhookTaskCreator, requestID, args.ProductName, []*CreateSingleProductArg{{
+		ProductName:   args.ProductName,
+		EnvName:       envName,
+		ClusterID:     baseEnv.ClusterID,
+		RegistryID:    baseEnv.RegistryID,
+		BaseEnvName:   baseEnv.EnvName,
+		DefaultValues: renderSet.DefaultValues,
+		Services:      services,
+		ShareEnv: commonmodels.ProductShareEnv{
+			Enable:  true,
+			IsBase:  false,
+			BaseEnv: baseEnv.EnvName,
+		},
+	}}, log)
+}
+
+func addPreviewEnvServices(preview *commonmodels.PreviewEnv, serviceNames []string, requestID string, log *zap.SugaredLogger) error {
+	services := make([]*UpdateServiceArg, 0)
+	for _, serviceName := range serviceNames {
+		services = append(services, &UpdateServiceArg{
+			ServiceName:    serviceName,
+			DeployStrategy: setting.ServiceDeployStrategyDeploy,
+		})
+	}
+	_, err := UpdateMultipleK8sEnv([]*UpdateEnv{{EnvName: preview.EnvName, Services: services}}, []string{preview.EnvName}, preview.ProductName, requestID, false, log)
+	if err != nil {
+		return fmt.Errorf("failed to add services %v to preview env %s: %s", serviceNames, preview.EnvName, err)
+	}
+	return nil
+}
+
+func waitPreviewEnvReady(productName, envName string) error {
+	timeout := time.After(time.Duration(config.ServiceStartTimeout()) * time.Second)
+	for {
+		env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+		if err != nil {
+			return fmt.Errorf("failed to find preview env %s: %s", envName, err)
+		}
+		if env.Status != setting.ProductStatusCreating && env.Status != setting.ProductStatusUpdating {
+			return nil
+		}
+
+		select {
+		case <-timeout:
+			return fmt.Errorf("preview env %s is not ready in %d seconds", envName, config.ServiceStartTimeout())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func renderPreviewEnvURL(urlTemplate string, preview *commonmodels.PreviewEnv) string {
+	if urlTemplate == "" {
+		return ""
+	}
+	tmpl, err := template.New("url").Parse(urlTemplate)
+	if err != nil {
+		log.Warnf("invalid url template of preview env %s: %s", preview.EnvName, err)
+		return ""
+	}
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, map[string]interface{}{
+		"EnvName":     preview.EnvName,
+		"BaseEnv":     preview.BaseEnv,
+		"PR":          preview.PR,
+		"ProjectName": preview.ProductName,
+	}); err != nil {
+		log.Warnf("failed to render url of preview env %s: %s", preview.EnvName, err)
+		return ""
+	}
+	return buf.String()
+}
+
+// commentPreviewEnv updates the comment of the preview environment in the pull request, failures are only logged.
+func commentPreviewEnv(mainRepo *commonmodels.MainHookRepo, baseURI string, preview *commonmodels.PreviewEnv, status string, log *zap.SugaredLogger) {
+	if mainRepo == nil && preview.NotificationID == "" {
+		return
+	}
+	notification, err := scmnotify.NewService().UpdatePreviewEnvWebhookComment(mainRepo, preview.NotificationID, preview.PR, baseURI, &commonmodels.PrTaskInfo{
+		ProductName:     preview.ProductName,
+		EnvStatus:       status,
+		PreviewEnvName:  preview.EnvName,
+		PreviewURL:      preview.URL,
+		PreviewServices: strings.Join(preview.Services, ", "),
+	}, log)
+	if err != nil {
+		log.Warnf("failed to comment preview env %s/%s in pull request %d: %s", preview.ProductName, preview.EnvName, preview.PR, err)
+		return
+	}
+	preview.NotificationID = notification.ID.Hex()
+}
+
+// DeletePreviewEnvsOfPR deletes the preview environments when the pull request is merged or closed.
+func DeletePreviewEnvsOfPR(repoNamespace, repoName string, pr int, log *zap.SugaredLogger) error {
+	previews, err := commonrepo.NewPreviewEnvColl().List(&commonrepo.PreviewEnvListOption{
+		RepoOwner: repoNamespace,
+		RepoName:  repoName,
+		PR:        pr,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list preview envs of %s/%s#%d: %s", repoNamespace, repoName, pr, err)
+	}
+	for _, preview := range previews {
+		preview := preview
+		go func() {
+			if err := deletePreviewEnv(preview, log); err != nil {
+				log.Errorf("failed to delete preview env %s/%s: %s", preview.ProductName, preview.EnvName, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// StartPreviewEnvCleaner deletes the preview environments which have been idle longer than their TTL.
+func StartPreviewEnvCleaner() {
+	for {
+		time.Sleep(previewEnvCleanInterval)
+		if !holdLease(previewEnvCleanerLease, previewEnvCleanInterval) {
+			continue
+		}
+
+		previews, err := commonrepo.NewPreviewEnvColl().List(&commonrepo.PreviewEnvListOption{})
+		if err != nil {
+			log.Errorf("StartPreviewEnvCleaner list preview envs error: %s", err)
+			continue
+		}
+		for _, preview := range previews {
+			idleTTL := preview.IdleTTL
+			if idleTTL <= 0 {
+				idleTTL = defaultPreviewEnvIdleTTL
+			}
+			if time.Unix(preview.LastActiveTime, 0).Add(time.Duration(idleTTL) * time.Hour).After(time.Now()) {
+				continue
+			}
+			log.Infof("preview env %s/%s has been idle for %d hours, delete it", preview.ProductName, preview.EnvName, idleTTL)
+			if err := deletePreviewEnv(preview, log.SugaredLogger()); err != nil {
+				log.Errorf("failed to delete idle preview env %s/%s: %s", preview.ProductName, preview.EnvName, err)
+			}
+		}
+	}
+}
+
+func deletePreviewEnv(preview *commonmodels.PreviewEnv, log *zap.SugaredLogger) error {
+	_, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: preview.ProductName, EnvName: preview.EnvName})
+	switch {
+	case err == mongo.ErrNoDocuments:
+		// the environment has been deleted manually
+	case err != nil:
+		return err
+	default:
+		commentPreviewEnv(nil, "", preview, previewEnvStatusDeleting, log)
+		if err := DeleteProduct(setting.WebhookTaskCreator, preview.EnvName, preview.ProductName, "", true, log); err != nil {
+			return err
+		}
+		if err := waitPreviewEnvDeleted(preview.ProductName, preview.EnvName); err != nil {
+			log.Warnf("%s", err)
+		}
+	}
+
+	commentPreviewEnv(nil, "", preview, previewEnvStatusDeleted, log)
+	return commonrepo.NewPreviewEnvColl().Delete(preview.ProductName, preview.EnvName)
+}
+
+func waitPreviewEnvDeleted(productName, envName string) error {
+	timeout := time.After(time.Duration(config.ServiceStartTimeout()) * time.Second)
+	for {
+		if _, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName}); err == mongo.ErrNoDocuments {
+			return nil
+		}
+
+		select {
+		case <-timeout:
+			return fmt.Errorf("preview env %s is not deleted in %d seconds", envName, config.ServiceStartTimeout())
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+var _ = Describe("Testing preview env", func() {
+
+	Describe("test PreviewEnvName", func() {
+
+		It("names the env after the repo and the pull request", func() {
+			name := PreviewEnvName(1, "koderover", "Zadig_Portal", 12)
+			Expect(strings.HasPrefix(name, "pr-zadig-portal-")).To(BeTrue())
+			Expect(strings.HasSuffix(name, "-12")).To(BeTrue())
+			Expect(name).To(Equal(PreviewEnvName(1, "koderover", "Zadig_Portal", 12)))
+		})
+
+		It("tells apart the repos with the same name", func() {
+			name := PreviewEnvName(1, "koderover", "zadig", 1)
+			Expect(PreviewEnvName(1, "fork", "zadig", 1)).NotTo(Equal(name))
+			Expect(PreviewEnvName(2, "koderover", "zadig", 1)).NotTo(Equal(name))
+		})
+
+		It("fits the long repo name into the length limit", func() {
+			name := PreviewEnvName(1, "koderover", "a-very-long-repository-name-for-testing", 123456)
+			Expect(len(name)).To(BeNumerically("<=", previewEnvNameMaxLength))
+			Expect(strings.HasSuffix(name, "-123456")).To(BeTrue())
+			Expect(previewEnvNameInvalidChars.MatchString(name)).To(BeFalse())
+		})
+	})
+
+	Describe("test renderPreviewEnvURL", func() {
+
+		preview := &commonmodels.PreviewEnv{
+			ProductName: "demo",
+			EnvName:     "pr-zadig-1a2b3c-12",
+			BaseEnv:     "dev",
+			PR:          12,
+		}
+
+		It("renders the keys of the preview env", func() {
+			url := renderPreviewEnvURL("https://{{.EnvName}}.{{.ProjectName}}.example.com/?base={{.BaseEnv}}&pr={{.PR}}", preview)
+			Expect(url).To(Equal("https://pr-zadig-1a2b3c-12.demo.example.com/?base=dev&pr=12"))
+		})
+
+		It("returns empty url for the empty or invalid template", func() {
+			Expect(renderPreviewEnvURL("", preview)).To(BeEmpty())
+			Expect(renderPreviewEnvURL("https://{{.EnvName", preview)).To(BeEmpty())
+		})
+	})
+})
@@ -224,6 +224,16 @@ func GetProduct(username, envName, productName string, log *zap.SugaredLogger) (
 		prod.RegistryID = reg.ID.Hex()
 	}
 	resp := buildProductResp(prod.EnvName, prod, log)
+
+	drifts, err := commonrepo.NewServiceDriftColl().List(productName, envName)
+	if err != nil {
+		log.Warnf("[EnvName:%s][Product:%s] failed to list service drifts: %s", envName, productName, err)
+	}
+	for _, drift := range drifts {
+		if drift.Drifted {
+			resp.Drifts = append(resp.Drifts, drift)
+		}
+	}
 	return resp, nil
 }
 
@@ -297,6 +307,10 @@ func buildProductResp(envName string, prod *commonmodels.Product, log *zap.Sugar
 		prodResp.Status = setting.ClusterUnknown
 		return prodResp
 	}
+	if prod.Status == setting.ProductStatusSleeping {
+		prodResp.Status = setting.ProductStatusSleeping
+		return prodResp
+	}
 
 	var (
 		servicesResp = make([]*commonservice.ServiceResp, 0)
@@ -0,0 +1,347 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/setting"
+)
+
+// serviceRollout deploys the services of an environment layer by layer according to the dependencies defined
+// in service templates, a layer is deployed only after the services it depends on are ready
+type serviceRollout struct {
+	env         *commonmodels.Product
+	deployType  string
+	layers      [][]*commonmodels.ProductService
+	dependsOn   map[string][]string
+	variableMap map[string]string
+	log         *zap.SugaredLogger
+}
+
+// newServiceRollout plans the rollout of the given service groups by the dependencies of the revisions to be deployed,
+// the groups are returned unchanged if there is no dependency among the services
+func newServiceRollout(env *commonmodels.Product, groups [][]*commonmodels.ProductService, renderSet *commonmodels.RenderSet, log *zap.SugaredLogger) (*serviceRollout, error) {
+	r := &serviceRollout{
+		env:         env,
+		deployType:  getProjectType(env.ProductName),
+		layers:      groups,
+		dependsOn:   make(map[string][]string),
+		variableMap: make(map[string]string),
+		log:         log,
+	}
+	// dependencies are only supported for services running in kubernetes
+	if r.deployType == setting.PMDeployType {
+		return r, nil
+	}
+
+	revisions := make([]*commonrepo.ServiceRevision, 0)
+	for _, group := range groups {
+		for _, svc := range group {
+			revisions = append(revisions, &commonrepo.ServiceRevision{ServiceName: svc.ServiceName, Revision: svc.Revision})
+		}
+	}
+	if len(revisions) == 0 {
+		return r, nil
+	}
+	services, err := commonrepo.NewServiceColl().ListServicesWithSRevision(&commonrepo.SvcRevisionListOption{
+		ProductName:      env.ProductName,
+		ServiceRevisions: revisions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services of project %s, err: %s", env.ProductName, err)
+	}
+	dependsOn := make(map[string][]string)
+	for _, svc := range services {
+		if len(svc.DependsOn) > 0 {
+			dependsOn[svc.ServiceName] = svc.DependsOn
+		}
+	}
+
+	r.layers, r.dependsOn, err = buildRolloutLayers(groups, dependsOn)
+	if err != nil {
+		return nil, err
+	}
+
+	if renderSet != nil {
+		for _, sv := range renderSet.ServiceVariables {
+			if sv.OverrideYaml != nil {
+				r.variableMap[sv.ServiceName] = sv.OverrideYaml.YamlContent
+			}
+		}
+	}
+	return r, nil
+}
+
+// run deploys the layers in order, services whose dependencies are not ready in time are not deployed and marked
+// with an error naming the blocking services, the returned error aggregates these blocked services
+// deploy is expected to record the failure of a service in its Error field, returning an error stops the rollout
+func (r *serviceRollout) run(deploy func(layer []*commonmodels.ProductService) error) error {
+	blockedErrs := &multierror.Error{}
+	ready, failed := sets.NewString(), sets.NewString()
+
+	for _, layer := range r.layers {
+		pending := sets.NewString()
+		for _, svc := range layer {
+			for _, dependency := range r.dependsOn[svc.ServiceName] {
+				if !ready.Has(dependency) && !failed.Has(dependency) {
+					pending.Insert(dependency)
+				}
+			}
+		}
+		if pending.Len() > 0 {
+			ready.Insert(r.waitServicesReady(pending.List())...)
+		}
+
+		deployable := make([]*commonmodels.ProductService, 0, len(layer))
+		for _, svc := range layer {
+			blockers := make([]string, 0)
+			for _, dependency := range r.dependsOn[svc.ServiceName] {
+				if !ready.Has(dependency) {
+					blockers = append(blockers, dependency)
+				}
+			}
+			if len(blockers) > 0 {
+				r.log.Errorf("[%s][%s] service %s is blocked by unready dependencies: %v", r.env.EnvName, r.env.ProductName, svc.ServiceName, blockers)
+				svc.Error = fmt.Sprintf("依赖的服务 %s 未就绪", strings.Join(blockers, ","))
+				blockedErrs = multierror.Append(blockedErrs, fmt.Errorf("服务 %s 依赖的服务 %s 未就绪", svc.ServiceName, strings.Join(blockers, ",")))
+				failed.Insert(svc.ServiceName)
+				continue
+			}
+			deployable = append(deployable, svc)
+		}
+		if len(deployable) == 0 {
+			continue
+		}
+
+		if err := deploy(deployable); err != nil {
+			return err
+		}
+		for _, svc := range deployable {
+			if svc.Error != "" {
+				failed.Insert(svc.ServiceName)
+			}
+		}
+	}
+	return blockedErrs.ErrorOrNil()
+}
+
+// waitServicesReady waits for the services to be ready until timeout, and returns the services which are ready
+func (r *serviceRollout) waitServicesReady(serviceNames []string) []string {
+	r.log.Infof("[%s][%s] wait dependencies %v to be ready in %d seconds", r.env.EnvName, r.env.ProductName, serviceNames, config.ServiceStartTimeout())
+
+	ready := sets.NewString()
+	_ = wait.PollImmediate(3*time.Second, time.Duration(config.ServiceStartTimeout())*time.Second, func() (bool, error) {
+		statuses, err := r.deployStatus(serviceNames)
+		if err != nil {
+			r.log.Warnf("failed to get deploy status of services %v, err: %s", serviceNames, err)
+			return false, nil
+		}
+		for _, status := range statuses {
+			if serviceReady(status) {
+				ready.Insert(status.ServiceName)
+			}
+		}
+		return ready.HasAll(serviceNames...), nil
+	})
+	return ready.List()
+}
+
+func (r *serviceRollout) deployStatus(serviceNames []string) ([]*ServiceDeployStatus, error) {
+	if r.deployType == setting.HelmDeployType {
+		return GetReleaseDeployStatus(r.env.ProductName, &HelmDeployStatusCheckRequest{
+			EnvName:   r.env.EnvName,
+			Services:  serviceNames,
+			ClusterID: r.env.ClusterID,
+			Namespace: r.env.Namespace,
+		})
+	}
+
+	args := make([]*commonservice.K8sSvcRenderArg, 0, len(serviceNames))
+	for _, serviceName := range serviceNames {
+		args = append(args, &commonservice.K8sSvcRenderArg{
+			EnvName:      r.env.EnvName,
+			ServiceName:  serviceName,
+			VariableYaml: r.variableMap[serviceName],
+		})
+	}
+	return GetResourceDeployStatus(r.env.ProductName, &K8sDeployStatusCheckRequest{
+		EnvName:   r.env.EnvName,
+		Services:  args,
+		ClusterID: r.env.ClusterID,
+		Namespace: r.env.Namespace,
+	}, r.log)
+}
+
+func serviceReady(status *ServiceDeployStatus) bool {
+	// the workloads of the service may not be created yet
+	if len(status.Resources) == 0 {
+		return false
+	}
+	for _, resource := range status.Resources {
+		if resource.Status != StatusDeployed || !resource.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// buildRolloutLayers splits the service groups into layers, the groups are deployed in order and each group is split
+// by the dependencies among its services. A service depending on a service of a later group is deferred to that group,
+// dependencies on services outside the groups are ignored
+func buildRolloutLayers(groups [][]*commonmodels.ProductService, dependsOn map[string][]string) ([][]*commonmodels.ProductService, map[string][]string, error) {
+	groupIndexMap := make(map[string]int)
+	for i, group := range groups {
+		for _, svc := range group {
+			groupIndexMap[svc.ServiceName] = i
+		}
+	}
+
+	relatedDependencies := make(map[string][]string)
+	for serviceName := range groupIndexMap {
+		for _, dependency := range dependsOn[serviceName] {
+			if _, ok := groupIndexMap[dependency]; ok && dependency != serviceName {
+				relatedDependencies[serviceName] = append(relatedDependencies[serviceName], dependency)
+			}
+		}
+	}
+	if len(relatedDependencies) == 0 {
+		return groups, relatedDependencies, nil
+	}
+	if err := ValidateServiceDependencies(relatedDependencies); err != nil {
+		return nil, nil, err
+	}
+
+	// a service is deployed in the latest group among its own and the ones of its dependencies
+	deployGroups := make(map[string]int)
+	var deployGroup func(serviceName string) int
+	deployGroup = func(serviceName string) int {
+		if i, ok := deployGroups[serviceName]; ok {
+			return i
+		}
+		i := groupIndexMap[serviceName]
+		for _, dependency := range relatedDependencies[serviceName] {
+			if di := deployGroup(dependency); di > i {
+				i = di
+			}
+		}
+		deployGroups[serviceName] = i
+		return i
+	}
+
+	// the level of a service in its group, the dependencies in the earlier groups are deployed before the group
+	levels := make(map[string]int)
+	var level func(serviceName string) int
+	level = func(serviceName string) int {
+		if l, ok := levels[serviceName]; ok {
+			return l
+		}
+		l := 0
+		for _, dependency := range relatedDependencies[serviceName] {
+			if deployGroup(dependency) != deployGroup(serviceName) {
+				continue
+			}
+			if dl := level(dependency) + 1; dl > l {
+				l = dl
+			}
+		}
+		levels[serviceName] = l
+		return l
+	}
+
+	type layerKey struct {
+		groupIndex int
+		level      int
+	}
+	layerMap := make(map[layerKey][]*commonmodels.ProductService)
+	keys := make([]layerKey, 0)
+	for _, group := range groups {
+		for _, svc := range group {
+			key := layerKey{groupIndex: deployGroup(svc.ServiceName), level: level(svc.ServiceName)}
+			if _, ok := layerMap[key]; !ok {
+				keys = append(keys, key)
+			}
+			layerMap[key] = append(layerMap[key], svc)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].groupIndex != keys[j].groupIndex {
+			return keys[i].groupIndex < keys[j].groupIndex
+		}
+		return keys[i].level < keys[j].level
+	})
+
+	layers := make([][]*commonmodels.ProductService, 0, len(keys))
+	for _, key := range keys {
+		layers = append(layers, layerMap[key])
+	}
+	return layers, relatedDependencies, nil
+}
+
+// ValidateServiceDependencies checks if there is circular dependency among the services
+func ValidateServiceDependencies(dependsOn map[string][]string) error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := make(map[string]int)
+	path := make([]string, 0)
+
+	var visit func(serviceName string) error
+	visit = func(serviceName string) error {
+		switch states[serviceName] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("circular dependency found: %s -> %s", strings.Join(path, " -> "), serviceName)
+		}
+		states[serviceName] = visiting
+		path = append(path, serviceName)
+		for _, dependency := range dependsOn[serviceName] {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		states[serviceName] = visited
+		return nil
+	}
+
+	serviceNames := make([]string, 0, len(dependsOn))
+	for serviceName := range dependsOn {
+		serviceNames = append(serviceNames, serviceName)
+	}
+	sort.Strings(serviceNames)
+	for _, serviceName := range serviceNames {
+		if err := visit(serviceName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+var _ = Describe("Testing service rollout", func() {
+
+	newGroups := func(groups ...[]string) [][]*commonmodels.ProductService {
+		res := make([][]*commonmodels.ProductService, 0, len(groups))
+		for _, group := range groups {
+			services := make([]*commonmodels.ProductService, 0, len(group))
+			for _, serviceName := range group {
+				services = append(services, &commonmodels.ProductService{ServiceName: serviceName})
+			}
+			res = append(res, services)
+		}
+		return res
+	}
+
+	layerNames := func(layers [][]*commonmodels.ProductService) [][]string {
+		res := make([][]string, 0, len(layers))
+		for _, layer := range layers {
+			names := make([]string, 0, len(layer))
+			for _, svc := range layer {
+				names = append(names, svc.ServiceName)
+			}
+			res = append(res, names)
+		}
+		return res
+	}
+
+	Describe("test buildRolloutLayers", func() {
+
+		It("keeps the groups without dependencies", func() {
+			layers, dependsOn, err := buildRolloutLayers(newGroups([]string{"a", "b"}, []string{"c"}), map[string][]string{"a": {"x"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dependsOn).To(BeEmpty())
+			Expect(layerNames(layers)).To(Equal([][]string{{"a", "b"}, {"c"}}))
+		})
+
+		It("splits a group by the dependencies in it and keeps the group order", func() {
+			layers, dependsOn, err := buildRolloutLayers(newGroups([]string{"a", "b", "c"}, []string{"d", "e"}), map[string][]string{
+				"b": {"a"},
+				"c": {"b"},
+				"e": {"a", "x"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dependsOn).To(Equal(map[string][]string{"b": {"a"}, "c": {"b"}, "e": {"a"}}))
+			Expect(layerNames(layers)).To(Equal([][]string{{"a"}, {"b"}, {"c"}, {"d", "e"}}))
+		})
+
+		It("defers the service depending on a later group", func() {
+			layers, _, err := buildRolloutLayers(newGroups([]string{"a", "b"}, []string{"c", "d"}), map[string][]string{
+				"a": {"c"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(layerNames(layers)).To(Equal([][]string{{"b"}, {"c", "d"}, {"a"}}))
+		})
+
+		It("returns error for circular dependencies", func() {
+			_, _, err := buildRolloutLayers(newGroups([]string{"a", "b"}), map[string][]string{
+				"a": {"b"},
+				"b": {"a"},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("test ValidateServiceDependencies", func() {
+
+		It("accepts the dependencies without circle", func() {
+			Expect(ValidateServiceDependencies(map[string][]string{
+				"a": {"b", "c"},
+				"b": {"c"},
+				"d": {"x"},
+			})).To(Succeed())
+		})
+
+		It("rejects the circular dependencies", func() {
+			err := ValidateServiceDependencies(map[string][]string{
+				"a": {"b"},
+				"b": {"c"},
+				"c": {"a"},
+			})
+			Expect(err).To(MatchError("circular dependency found: a -> b -> c -> a"))
+		})
+
+		It("rejects the service depending on itself", func() {
+			Expect(ValidateServiceDependencies(map[string][]string{"a": {"a"}})).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,488 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rfyiamcool/cronlib"
+	"go.uber.org/zap"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	templaterepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb/template"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/nsq"
+	"github.com/koderover/zadig/pkg/setting"
+	kubeclient "github.com/koderover/zadig/pkg/shared/kube/client"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	helmtool "github.com/koderover/zadig/pkg/tool/helmclient"
+	"github.com/koderover/zadig/pkg/tool/kube/getter"
+	"github.com/koderover/zadig/pkg/tool/kube/serializer"
+	"github.com/koderover/zadig/pkg/tool/kube/updater"
+	"github.com/koderover/zadig/pkg/tool/kube/util"
+)
+
+func GetEnvSleepSchedule(productName, envName string, log *zap.SugaredLogger) (*commonmodels.EnvSleepSchedule, error) {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		log.Errorf("failed to find env %s/%s: %s", productName, envName, err)
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+	if env.SleepSchedule == nil {
+		return &commonmodels.EnvSleepSchedule{}, nil
+	}
+	return env.SleepSchedule, nil
+}
+
+// UpdateEnvSleepSchedule saves the sleep schedule of an environment and re-registers its cronjobs in the cron service
+func UpdateEnvSleepSchedule(productName, envName string, args *commonmodels.EnvSleepSchedule, log *zap.SugaredLogger) error {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		log.Errorf("failed to find env %s/%s: %s", productName, envName, err)
+		return e.ErrGetEnv.AddErr(err)
+	}
+	if args.Enabled {
+		if err := envSleepable(env); err != nil {
+			return e.ErrUpdateEnv.AddErr(err)
+		}
+		// the cron service registers the crontab cronjobs with a leading second field
+		for _, expr := range []string{args.SleepCron, args.WakeCron} {
+			if _, err := cronlib.NewJobModel("0 "+expr, func() {}); err != nil {
+				return e.ErrUpdateEnv.AddDesc(fmt.Sprintf("invalid cron expression %q: %s", expr, err))
+			}
+		}
+	}
+
+	if err := commonrepo.NewProductColl().UpdateSleepSchedule(envName, productName, args); err != nil {
+		log.Errorf("failed to update sleep schedule of env %s/%s: %s", productName, envName, err)
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	if err := upsertEnvSleepCronjobs(productName, envName, args); err != nil {
+		log.Errorf("failed to update sleep cronjobs of env %s/%s: %s", productName, envName, err)
+		return e.ErrUpsertCronjob.AddErr(err)
+	}
+	return nil
+}
+
+// SleepEnv scales the Deployments and StatefulSets of an environment to zero and suspends its CronJobs, only the
+// workloads labeled with the project, or rendered in the releases of helm envs, are touched. The original replicas are recorded so that WakeEnv can restore them.
+func SleepEnv(productName, envName string, log *zap.SugaredLogger) error {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		log.Errorf("failed to find env %s/%s: %s", productName, envName, err)
+		return e.ErrGetEnv.AddErr(err)
+	}
+	if env.Status == setting.ProductStatusSleeping {
+		return nil
+	}
+	if err := envSleepable(env); err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	if env.Status != setting.ProductStatusSuccess && env.Status != setting.ProductStatusUnstable && env.Status != setting.ProductStatusFailed {
+		return e.ErrUpdateEnv.AddDesc(fmt.Sprintf("environment is %s, can't sleep now", env.Status))
+	}
+
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	versionLessThan121, err := clusterVersionLessThan121(env.ClusterID)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+
+	state := &commonmodels.EnvSleepState{
+		SleepTime: time.Now().Unix(),
+		Workloads: make([]*commonmodels.EnvSleepWorkload, 0),
+		CronJobs:  make([]string, 0),
+	}
+	errList := new(multierror.Error)
+
+	workloads, err := selectSleepWorkloads(env, kubeClient)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	deployments, err := getter.ListDeployments(env.Namespace, workloads.selector, kubeClient)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	selected := 0
+	for _, deployment := range deployments {
+		if !workloads.has(setting.Deployment, deployment.Name) {
+			continue
+		}
+		selected++
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		if replicas == 0 {
+			continue
+		}
+		if err := updater.ScaleDeployment(env.Namespace, deployment.Name, 0, kubeClient); err != nil {
+			errList = multierror.Append(errList, fmt.Errorf("failed to scale deployment %s: %s", deployment.Name, err))
+			continue
+		}
+		state.Workloads = append(state.Workloads, &commonmodels.EnvSleepWorkload{Type: setting.Deployment, Name: deployment.Name, Replicas: replicas})
+	}
+
+	statefulSets, err := getter.ListStatefulSets(env.Namespace, workloads.selector, kubeClient)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	for _, sts := range statefulSets {
+		if !workloads.has(setting.StatefulSet, sts.Name) {
+			continue
+		}
+		selected++
+		replicas := int32(1)
+		if sts.Spec.Replicas != nil {
+			replicas = *sts.Spec.Replicas
+		}
+		if replicas == 0 {
+			continue
+		}
+		if err := updater.ScaleStatefulSet(env.Namespace, sts.Name, 0, kubeClient); err != nil {
+			errList = multierror.Append(errList, fmt.Errorf("failed to scale statefulset %s: %s", sts.Name, err))
+			continue
+		}
+		state.Workloads = append(state.Workloads, &commonmodels.EnvSleepWorkload{Type: setting.StatefulSet, Name: sts.Name, Replicas: replicas})
+	}
+
+	cronJobs, err := listActiveCronJobNames(env.Namespace, workloads.selector, kubeClient, versionLessThan121)
+	if err != nil {
+		errList = multierror.Append(errList, err)
+	}
+	for _, name := range cronJobs {
+		if !workloads.has(setting.CronJob, name) {
+			continue
+		}
+		selected++
+		if err := updater.SuspendCronJob(env.Namespace, name, true, kubeClient, versionLessThan121); err != nil {
+			errList = multierror.Append(errList, fmt.Errorf("failed to suspend cronjob %s: %s", name, err))
+			continue
+		}
+		state.CronJobs = append(state.CronJobs, name)
+	}
+
+	// an env without any workload selected is most likely selected wrongly, it must not be marked as sleeping
+	if selected == 0 && errList.ErrorOrNil() == nil {
+		return e.ErrUpdateEnv.AddDesc("no workload of the environment is found to sleep")
+	}
+
+	mergeUnrestoredSleepState(state, env.SleepState)
+
+	// the state is saved even if some workloads failed, so the ones already scaled down can be restored on wake
+	if err := commonrepo.NewProductColl().UpdateSleepState(envName, productName, setting.ProductStatusSleeping, state); err != nil {
+		log.Errorf("failed to save sleep state of env %s/%s: %s", productName, envName, err)
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	log.Infof("env %s/%s is sleeping, %d workloads scaled down, %d cronjobs suspended", productName, envName, len(state.Workloads), len(state.CronJobs))
+
+	if errList.ErrorOrNil() != nil {
+		return e.ErrUpdateEnv.AddErr(errList.ErrorOrNil())
+	}
+	return nil
+}
+
+// WakeEnv restores the replicas and CronJobs recorded by SleepEnv. Workloads failed to restore are kept in
+// the sleep state and the environment stays sleeping, so that waking it again retries them.
+func WakeEnv(productName, envName string, log *zap.SugaredLogger) error {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		log.Errorf("failed to find env %s/%s: %s", productName, envName, err)
+		return e.ErrGetEnv.AddErr(err)
+	}
+	if env.Status != setting.ProductStatusSleeping && env.SleepState == nil {
+		return nil
+	}
+
+	state := env.SleepState
+	if state == nil {
+		state = &commonmodels.EnvSleepState{}
+	}
+
+	kubeClient, err := kubeclient.GetKubeClient(config.HubServerAddress(), env.ClusterID)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	versionLessThan121, err := clusterVersionLessThan121(env.ClusterID)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+
+	remaining := &commonmodels.EnvSleepState{
+		SleepTime: state.SleepTime,
+		Workloads: make([]*commonmodels.EnvSleepWorkload, 0),
+		CronJobs:  make([]string, 0),
+	}
+	errList := new(multierror.Error)
+	for _, workload := range state.Workloads {
+		var err error
+		switch workload.Type {
+		case setting.Deployment:
+			err = updater.ScaleDeployment(env.Namespace, workload.Name, int(workload.Replicas), kubeClient)
+		case setting.StatefulSet:
+			err = updater.ScaleStatefulSet(env.Namespace, workload.Name, int(workload.Replicas), kubeClient)
+		}
+		// workloads deleted during the sleep have nothing to restore
+		if err = util.IgnoreNotFoundError(err); err != nil {
+			errList = multierror.Append(errList, fmt.Errorf("failed to scale %s %s: %s", workload.Type, workload.Name, err))
+			remaining.Workloads = append(remaining.Workloads, workload)
+		}
+	}
+	for _, name := range state.CronJobs {
+		if err := util.IgnoreNotFoundError(updater.SuspendCronJob(env.Namespace, name, false, kubeClient, versionLessThan121)); err != nil {
+			errList = multierror.Append(errList, fmt.Errorf("failed to resume cronjob %s: %s", name, err))
+			remaining.CronJobs = append(remaining.CronJobs, name)
+		}
+	}
+
+	if errList.ErrorOrNil() != nil {
+		if err := commonrepo.NewProductColl().UpdateSleepState(envName, productName, setting.ProductStatusSleeping, remaining); err != nil {
+			log.Errorf("failed to save sleep state of env %s/%s: %s", productName, envName, err)
+		}
+		return e.ErrUpdateEnv.AddErr(errList.ErrorOrNil())
+	}
+
+	status := env.Status
+	if status == setting.ProductStatusSleeping {
+		status = setting.ProductStatusSuccess
+	}
+	if err := commonrepo.NewProductColl().UpdateSleepState(envName, productName, status, nil); err != nil {
+		log.Errorf("failed to clear sleep state of env %s/%s: %s", productName, envName, err)
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	log.Infof("env %s/%s is woken up, %d workloads restored, %d cronjobs resumed", productName, envName, len(state.Workloads), len(state.CronJobs))
+	return nil
+}
+
+// sleepWorkloads selects the workloads of an environment to sleep, the namespace may be shared with the workloads
+// not managed by the env.
+type sleepWorkloads struct {
+	selector labels.Selector
+	// names of the workloads by kind, nil if all the workloads matching the selector are selected
+	names map[string]sets.String
+}
+
+func (w *sleepWorkloads) has(kind, name string) bool {
+	return w.names == nil || w.names[kind].Has(name)
+}
+
+// selectSleepWorkloads selects the workloads labeled with the project, the workloads of helm envs are not labeled
+// so they are selected from the manifests of the releases instead.
+func selectSleepWorkloads(env *commonmodels.Product, kubeClient client.Client) (*sleepWorkloads, error) {
+	if env.Source != setting.SourceFromHelm {
+		return &sleepWorkloads{selector: labels.Set{setting.ProductLabel: env.ProductName}.AsSelector()}, nil
+	}
+
+	releaseNames, err := commonservice.GetReleaseNameToServiceNameMap(env)
+	if err != nil {
+		return nil, err
+	}
+	helmClient, err := helmtool.NewClientFromNamespace(env.ClusterID, env.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	manifests := make([]string, 0, len(releaseNames))
+	for releaseName := range releaseNames {
+		release, err := helmClient.GetRelease(releaseName)
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get release %s: %s", releaseName, err)
+		}
+		manifests = append(manifests, release.Manifest)
+	}
+	return &sleepWorkloads{selector: labels.Everything(), names: releaseSleepWorkloadNames(manifests)}, nil
+}
+
+// releaseSleepWorkloadNames finds the names of the Deployments, StatefulSets and CronJobs rendered in the release manifests
+func releaseSleepWorkloadNames(manifests []string) map[string]sets.String {
+	names := map[string]sets.String{
+		setting.Deployment:  sets.NewString(),
+		setting.StatefulSet: sets.NewString(),
+		setting.CronJob:     sets.NewString(),
+	}
+	for _, manifest := range manifests {
+		for _, item := range releaseutil.SplitManifests(manifest) {
+			u, err := serializer.NewDecoder().YamlToUnstructured([]byte(item))
+			if err != nil {
+				continue
+			}
+			if kindNames, ok := names[u.GetKind()]; ok {
+				kindNames.Insert(u.GetName())
+			}
+		}
+	}
+	return names
+}
+
+// mergeUnrestoredSleepState keeps the workloads left unrestored from the last sleep, which happens when
+// the env is updated while sleeping. They are already scaled to zero and won't be recorded again.
+func mergeUnrestoredSleepState(state, last *commonmodels.EnvSleepState) {
+	if last == nil {
+		return
+	}
+	recorded := sets.NewString()
+	for _, workload := range state.Workloads {
+		recorded.Insert(workload.Type + "/" + workload.Name)
+	}
+	for _, workload := range last.Workloads {
+		if !recorded.Has(workload.Type + "/" + workload.Name) {
+			state.Workloads = append(state.Workloads, workload)
+		}
+	}
+	cronJobs := sets.NewString(state.CronJobs...)
+	for _, name := range last.CronJobs {
+		if !cronJobs.Has(name) {
+			state.CronJobs = append(state.CronJobs, name)
+		}
+	}
+}
+
+func envSleepable(env *commonmodels.Product) error {
+	if env.Production {
+		return fmt.Errorf("production environment can't sleep")
+	}
+	project, err := templaterepo.NewProductColl().Find(env.ProductName)
+	if err != nil {
+		return err
+	}
+	if project.IsCVMProduct() {
+		return fmt.Errorf("environments of host project can't sleep")
+	}
+	return nil
+}
+
+func clusterVersionLessThan121(clusterID string) (bool, error) {
+	cls, err := kubeclient.GetKubeClientSet(config.HubServerAddress(), clusterID)
+	if err != nil {
+		return false, err
+	}
+	version, err := cls.Discovery().ServerVersion()
+	if err != nil {
+		return false, err
+	}
+	return VersionLessThan121(version), nil
+}
+
+func listActiveCronJobNames(namespace string, selector labels.Selector, kubeClient client.Client, versionLessThan121 bool) ([]string, error) {
+	names := make([]string, 0)
+	if versionLessThan121 {
+		cronJobs, err := getter.ListCronJobsV1Beta(namespace, selector, kubeClient)
+		if err != nil {
+			return nil, err
+		}
+		for _, cronJob := range cronJobs {
+			if cronJob.Spec.Suspend == nil || !*cronJob.Spec.Suspend {
+				names = append(names, cronJob.Name)
+			}
+		}
+		return names, nil
+	}
+
+	cronJobs, err := getter.ListCronJobs(namespace, selector, kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	for _, cronJob := range cronJobs {
+		if cronJob.Spec.Suspend == nil || !*cronJob.Spec.Suspend {
+			names = append(names, cronJob.Name)
+		}
+	}
+	return names, nil
+}
+
+// upsertEnvSleepCronjobs replaces the sleep and wake cronjobs of an environment, the cronjobs are
+// removed if the schedule is disabled
+func upsertEnvSleepCronjobs(productName, envName string, schedule *commonmodels.EnvSleepSchedule) error {
+	payload := &commonservice.CronjobPayload{
+		Name:        envName,
+		ProductName: productName,
+		JobType:     config.EnvSleepCronjob,
+		Action:      setting.TypeEnableCronjob,
+	}
+
+	// cronjobs are named after the env, so envs with the same name in other projects have to be filtered out
+	jobs, err := commonrepo.NewCronjobColl().List(&commonrepo.ListCronjobParam{
+		ParentName: envName,
+		ParentType: config.EnvSleepCronjob,
+	})
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.ProductName == productName {
+			payload.DeleteList = append(payload.DeleteList, job.ID.Hex())
+		}
+	}
+	if len(payload.DeleteList) > 0 {
+		if err := commonrepo.NewCronjobColl().Delete(&commonrepo.CronjobDeleteOption{IDList: payload.DeleteList}); err != nil {
+			return err
+		}
+	}
+
+	if schedule != nil && schedule.Enabled {
+		for action, expr := range map[string]string{
+			setting.EnvSleepActionSleep: schedule.SleepCron,
+			setting.EnvSleepActionWake:  schedule.WakeCron,
+		} {
+			job := &commonmodels.Cronjob{
+				Name:        envName,
+				Type:        config.EnvSleepCronjob,
+				ProductName: productName,
+				Cron:        expr,
+				JobType:     setting.CrontabCronjob,
+				Enabled:     true,
+				EnvArgs: &commonmodels.EnvArgs{
+					ProductName: productName,
+					EnvName:     envName,
+					Action:      action,
+				},
+			}
+			if err := commonrepo.NewCronjobColl().Create(job); err != nil {
+				return err
+			}
+			payload.JobList = append(payload.JobList, &commonmodels.Schedule{
+				ID:      job.ID,
+				Type:    config.ScheduleType(job.JobType),
+				Cron:    job.Cron,
+				EnvArgs: job.EnvArgs,
+				Enabled: job.Enabled,
+			})
+		}
+	}
+
+	if len(payload.DeleteList) == 0 && len(payload.JobList) == 0 {
+		return nil
+	}
+	pl, _ := json.Marshal(payload)
+	return nsq.Publish(setting.TopicCronjob, pl)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/setting"
+)
+
+var _ = Describe("Testing env sleep", func() {
+
+	Describe("test mergeUnrestoredSleepState", func() {
+
+		It("keeps the state unchanged without last state", func() {
+			state := &commonmodels.EnvSleepState{
+				Workloads: []*commonmodels.EnvSleepWorkload{{Type: setting.Deployment, Name: "web", Replicas: 2}},
+				CronJobs:  []string{"backup"},
+			}
+			mergeUnrestoredSleepState(state, nil)
+			Expect(state.Workloads).To(HaveLen(1))
+			Expect(state.CronJobs).To(Equal([]string{"backup"}))
+		})
+
+		It("keeps the unrestored workloads and cronjobs of the last state", func() {
+			state := &commonmodels.EnvSleepState{
+				Workloads: []*commonmodels.EnvSleepWorkload{{Type: setting.Deployment, Name: "web", Replicas: 3}},
+				CronJobs:  []string{"backup"},
+			}
+			last := &commonmodels.EnvSleepState{
+				Workloads: []*commonmodels.EnvSleepWorkload{
+					{Type: setting.Deployment, Name: "web", Replicas: 2},
+					{Type: setting.Deployment, Name: "api", Replicas: 2},
+					{Type: setting.StatefulSet, Name: "web", Replicas: 1},
+				},
+				CronJobs: []string{"backup", "report"},
+			}
+			mergeUnrestoredSleepState(state, last)
+			Expect(state.Workloads).To(Equal([]*commonmodels.EnvSleepWorkload{
+				{Type: setting.Deployment, Name: "web", Replicas: 3},
+				{Type: setting.Deployment, Name: "api", Replicas: 2},
+				{Type: setting.StatefulSet, Name: "web", Replicas: 1},
+			}))
+			Expect(state.CronJobs).To(Equal([]string{"backup", "report"}))
+		})
+	})
+
+	Describe("test releaseSleepWorkloadNames", func() {
+
+		It("selects the workloads rendered in the release manifests", func() {
+			manifests := []string{`---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+# Source: web/templates/cronjob.yaml
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+`, `---
+# Source: db/templates/statefulset.yaml
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+`}
+			names := releaseSleepWorkloadNames(manifests)
+			Expect(names[setting.Deployment].List()).To(Equal([]string{"web"}))
+			Expect(names[setting.StatefulSet].List()).To(Equal([]string{"db"}))
+			Expect(names[setting.CronJob].List()).To(Equal([]string{"backup"}))
+
+			workloads := &sleepWorkloads{names: names}
+			Expect(workloads.has(setting.Deployment, "web")).To(BeTrue())
+			Expect(workloads.has(setting.Deployment, "db")).To(BeFalse())
+		})
+
+		It("selects nothing without releases", func() {
+			workloads := &sleepWorkloads{names: releaseSleepWorkloadNames(nil)}
+			Expect(workloads.has(setting.Deployment, "web")).To(BeFalse())
+			Expect(workloads.has(setting.CronJob, "backup")).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,412 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/go-multierror"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/envsnapshot"
+	"github.com/koderover/zadig/pkg/setting"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+	helmtool "github.com/koderover/zadig/pkg/tool/helmclient"
+)
+
+type EnvSnapshotBrief struct {
+	Revision    int64            `json:"revision"`
+	EnvRevision int64            `json:"env_revision"`
+	Description string           `json:"description"`
+	CreatedBy   string           `json:"created_by"`
+	CreateTime  int64            `json:"create_time"`
+	Diff        *EnvSnapshotDiff `json:"diff"`
+}
+
+// EnvSnapshotDiff is the changes of a snapshot compared with the previous one.
+type EnvSnapshotDiff struct {
+	AddedServices    []string               `json:"added_services"`
+	DeletedServices  []string               `json:"deleted_services"`
+	UpdatedServices  []*ServiceSnapshotDiff `json:"updated_services"`
+	VariablesChanged bool                   `json:"variables_changed"`
+	// resources are in format of type/name, such as ConfigMap/nginx-conf
+	AddedResources   []string `json:"added_resources"`
+	DeletedResources []string `json:"deleted_resources"`
+	UpdatedResources []string `json:"updated_resources"`
+}
+
+type ServiceSnapshotDiff struct {
+	ServiceName  string            `json:"service_name"`
+	RevisionFrom int64             `json:"revision_from"`
+	RevisionTo   int64             `json:"revision_to"`
+	Images       []*ImageDiffEntry `json:"images"`
+}
+
+type ImageDiffEntry struct {
+	Container string `json:"container"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+type ListEnvSnapshotsResp struct {
+	Total     int64               `json:"total"`
+	Snapshots []*EnvSnapshotBrief `json:"snapshots"`
+}
+
+func ListEnvSnapshots(productName, envName string, page, pageSize int, log *zap.SugaredLogger) (*ListEnvSnapshotsResp, error) {
+	snapshots, total, err := commonrepo.NewEnvSnapshotColl().List(productName, envName, page, pageSize)
+	if err != nil {
+		log.Errorf("failed to list snapshots of env %s/%s: %s", productName, envName, err)
+		return nil, e.ErrGetEnv.AddErr(err)
+	}
+
+	resp := &ListEnvSnapshotsResp{Total: total, Snapshots: make([]*EnvSnapshotBrief, 0)}
+	for i, snapshot := range snapshots {
+		// the snapshots are sorted by revision desc, so the previous one is the next in the list
+		var previous *commonmodels.EnvSnapshot
+		if i+1 < len(snapshots) {
+			previous = snapshots[i+1]
+		} else if snapshot.Revision > 1 {
+			previous, _ = commonrepo.NewEnvSnapshotColl().Find(productName, envName, snapshot.Revision-1)
+		}
+		resp.Snapshots = append(resp.Snapshots, &EnvSnapshotBrief{
+			Revision:    snapshot.Revision,
+			EnvRevision: snapshot.EnvRevision,
+			Description: snapshot.Description,
+			CreatedBy:   snapshot.CreatedBy,
+			CreateTime:  snapshot.CreateTime,
+			Diff:        diffEnvSnapshot(previous, snapshot),
+		})
+	}
+	return resp, nil
+}
+
+func GetEnvSnapshot(productName, envName string, revision int64, log *zap.SugaredLogger) (*commonmodels.EnvSnapshot, error) {
+	snapshot, err := commonrepo.NewEnvSnapshotColl().Find(productName, envName, revision)
+	if err != nil {
+		log.Errorf("failed to find snapshot %d of env %s/%s: %s", revision, productName, envName, err)
+		return nil, e.ErrGetEnv.AddDesc(fmt.Sprintf("snapshot %d not found", revision))
+	}
+	return snapshot, nil
+}
+
+func diffEnvSnapshot(previous, current *commonmodels.EnvSnapshot) *EnvSnapshotDiff {
+	diff := &EnvSnapshotDiff{
+		AddedServices:    make([]string, 0),
+		DeletedServices:  make([]string, 0),
+		UpdatedServices:  make([]*ServiceSnapshotDiff, 0),
+		AddedResources:   make([]string, 0),
+		DeletedResources: make([]string, 0),
+		UpdatedResources: make([]string, 0),
+	}
+	if previous == nil {
+		previous = &commonmodels.EnvSnapshot{}
+	}
+
+	previousServices := snapshotServiceMap(previous)
+	currentServices := snapshotServiceMap(current)
+	for _, name := range sets.StringKeySet(currentServices).List() {
+		cur := currentServices[name]
+		prev, ok := previousServices[name]
+		if !ok {
+			diff.AddedServices = append(diff.AddedServices, name)
+			continue
+		}
+		svcDiff := &ServiceSnapshotDiff{ServiceName: name, RevisionFrom: prev.Revision, RevisionTo: cur.Revision, Images: make([]*ImageDiffEntry, 0)}
+		prevImages := make(map[string]string)
+		for _, container := range prev.Containers {
+			prevImages[container.Name] = container.Image
+		}
+		for _, container := range cur.Containers {
+			if prevImages[container.Name] != container.Image {
+				svcDiff.Images = append(svcDiff.Images, &ImageDiffEntry{Container: container.Name, From: prevImages[container.Name], To: container.Image})
+			}
+		}
+		if svcDiff.RevisionFrom != svcDiff.RevisionTo || len(svcDiff.Images) > 0 {
+			diff.UpdatedServices = append(diff.UpdatedServices, svcDiff)
+		}
+	}
+	for _, name := range sets.StringKeySet(previousServices).List() {
+		if _, ok := currentServices[name]; !ok {
+			diff.DeletedServices = append(diff.DeletedServices, name)
+		}
+	}
+
+	diff.VariablesChanged = !reflect.DeepEqual(snapshotVariables(previous.RenderSet), snapshotVariables(current.RenderSet))
+
+	previousResources := snapshotResourceMap(previous)
+	currentResources := snapshotResourceMap(current)
+	for _, key := range sets.StringKeySet(currentResources).List() {
+		prev, ok := previousResources[key]
+		if !ok {
+			diff.AddedResources = append(diff.AddedResources, key)
+		} else if prev.YamlData != currentResources[key].YamlData {
+			diff.UpdatedResources = append(diff.UpdatedResources, key)
+		}
+	}
+	for _, key := range sets.StringKeySet(previousResources).List() {
+		if _, ok := currentResources[key]; !ok {
+			diff.DeletedResources = append(diff.DeletedResources, key)
+		}
+	}
+	return diff
+}
+
+func snapshotServiceMap(snapshot *commonmodels.EnvSnapshot) map[string]*commonmodels.ProductService {
+	ret := make(map[string]*commonmodels.ProductService)
+	for _, group := range snapshot.Services {
+		for _, svc := range group {
+			ret[svc.ServiceName] = svc
+		}
+	}
+	return ret
+}
+
+func snapshotResourceMap(snapshot *commonmodels.EnvSnapshot) map[string]*commonmodels.EnvSnapshotResource {
+	ret := make(map[string]*commonmodels.EnvSnapshotResource)
+	for _, resource := range snapshot.EnvResources {
+		ret[fmt.Sprintf("%s/%s", resource.Type, resource.Name)] = resource
+	}
+	return ret
+}
+
+// snapshotVariables drops the renderset revision which changes on every update.
+func snapshotVariables(renderSet *commonmodels.EnvSnapshotRenderSet) *commonmodels.EnvSnapshotRenderSet {
+	if renderSet == nil {
+		return &commonmodels.EnvSnapshotRenderSet{}
+	}
+	ret := *renderSet
+	ret.Name, ret.Revision = "", 0
+	return &ret
+}
+
+// RollbackEnvToSnapshot re-applies the services, variables and env configs in the snapshot to the environment,
+// the services not in the snapshot are removed.
+func RollbackEnvToSnapshot(productName, envName string, revision int64, userName, requestID string, log *zap.SugaredLogger) error {
+	env, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	switch env.Status {
+	case setting.ProductStatusCreating, setting.ProductStatusUpdating, setting.ProductStatusDeleting:
+		return e.ErrUpdateEnv.AddDesc(e.EnvCantUpdatedMsg)
+	}
+
+	snapshot, err := commonrepo.NewEnvSnapshotColl().Find(productName, envName, revision)
+	if err != nil {
+		return e.ErrUpdateEnv.AddDesc(fmt.Sprintf("snapshot %d not found", revision))
+	}
+	if snapshot.RenderSet == nil {
+		return e.ErrUpdateEnv.AddDesc(fmt.Sprintf("snapshot %d has no variables to roll back", revision))
+	}
+
+	isHelm := env.Source == setting.SourceFromHelm
+	if !isHelm && getProjectType(productName) != setting.K8SDeployType {
+		return e.ErrUpdateEnv.AddDesc("rollback is only supported by k8s yaml and helm environments")
+	}
+
+	env.EnsureRenderInfo()
+	if err := commonservice.CreateK8sHelmRenderSet(&commonmodels.RenderSet{
+		Name:             env.Render.Name,
+		EnvName:          envName,
+		ProductTmpl:      productName,
+		UpdateBy:         userName,
+		DefaultValues:    snapshot.RenderSet.DefaultValues,
+		YamlData:         snapshot.RenderSet.YamlData,
+		ServiceVariables: snapshot.RenderSet.ServiceVariables,
+		ChartInfos:       snapshot.RenderSet.ChartInfos,
+	}, log); err != nil {
+		log.Errorf("[%s][P:%s] create renderset error: %v", envName, productName, err)
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	renderSet, err := FindProductRenderSet(productName, env.Render.Name, envName, log)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+
+	if err := commonrepo.NewProductColl().UpdateStatus(envName, productName, setting.ProductStatusUpdating); err != nil {
+		log.Errorf("[%s][P:%s] Product.UpdateStatus error: %v", envName, productName, err)
+		return e.ErrUpdateEnv.AddDesc(e.UpdateEnvStatusErrMsg)
+	}
+
+	go func() {
+		var err error
+		if isHelm {
+			err = rollbackHelmEnv(env, snapshot, renderSet, log)
+		} else {
+			err = rollbackK8sEnv(env, snapshot, renderSet, log)
+		}
+		if errResources := rollbackEnvResources(env, snapshot, userName, log); errResources != nil {
+			err = multierror.Append(err, errResources).ErrorOrNil()
+		}
+
+		status, errMsg := setting.ProductStatusSuccess, ""
+		if err != nil {
+			log.Errorf("[%s][P:%s] failed to rollback to snapshot %d: %s", envName, productName, revision, err)
+			title := fmt.Sprintf("回滚 [%s] 的 [%s] 环境失败", productName, envName)
+			commonservice.SendErrorMessage(userName, title, requestID, err, log)
+			status, errMsg = setting.ProductStatusFailed, err.Error()
+		}
+		if err := commonrepo.NewProductColl().UpdateStatusAndError(envName, productName, status, errMsg); err != nil {
+			log.Errorf("[%s][%s] Product.Update set product status error: %v", envName, productName, err)
+			return
+		}
+		snapshotEnv(productName, envName, userName, fmt.Sprintf("rollback to snapshot %d", revision), log)
+	}()
+	return nil
+}
+
+// snapshotEnv saves the snapshot after the environment is changed, the error is only logged
+// since the change itself has been done.
+func snapshotEnv(productName, envName, userName, description string, log *zap.SugaredLogger) {
+	if err := envsnapshot.CreateEnvSnapshot(productName, envName, userName, description, log); err != nil {
+		log.Warnf("failed to create snapshot of env %s/%s: %s", productName, envName, err)
+	}
+}
+
+func rollbackK8sEnv(env *commonmodels.Product, snapshot *commonmodels.EnvSnapshot, renderSet *commonmodels.RenderSet, log *zap.SugaredLogger) error {
+	productName, envName := env.ProductName, env.EnvName
+	snapshotServices := snapshotServiceMap(snapshot)
+	for _, svc := range env.GetServiceMap() {
+		if _, ok := snapshotServices[svc.ServiceName]; ok {
+			continue
+		}
+		log.Infof("[%s][P:%s][S:%s] delete service not in snapshot", envName, productName, svc.ServiceName)
+		selector := labels.Set{setting.ProductLabel: productName, setting.ServiceLabel: svc.ServiceName}.AsSelector()
+		if err := commonservice.DeleteNamespacedResource(env.Namespace, selector, env.ClusterID, log); err != nil {
+			log.Errorf("delete resource of service %s error:%v", svc.ServiceName, err)
+		}
+		clusterSelector := labels.Set{setting.ProductLabel: productName, setting.ServiceLabel: svc.ServiceName, setting.EnvNameLabel: envName}.AsSelector()
+		if err := commonservice.DeleteClusterResource(clusterSelector, env.ClusterID, log); err != nil {
+			log.Errorf("delete cluster resource of service %s error:%v", svc.ServiceName, err)
+		}
+	}
+
+	updateProd := &commonmodels.Product{
+		ProductName: productName,
+		Revision:    snapshot.EnvRevision,
+		Services:    snapshot.Services,
+		Source:      env.Source,
+		ClusterID:   env.ClusterID,
+		RegistryID:  env.RegistryID,
+		Production:  env.Production,
+	}
+	if err := updateProductImpl(nil, snapshot.DeployStrategy, env, updateProd, renderSet, nil, log); err != nil {
+		return err
+	}
+
+	// updateProductImpl only updates the groups in the snapshot, the rest groups and the revision are updated here
+	prod, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: productName, EnvName: envName})
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+	if len(prod.Services) > len(snapshot.Services) {
+		prod.Services = prod.Services[:len(snapshot.Services)]
+	}
+	prod.Revision = snapshot.EnvRevision
+	return commonrepo.NewProductColl().Update(prod)
+}
+
+func rollbackHelmEnv(env *commonmodels.Product, snapshot *commonmodels.EnvSnapshot, renderSet *commonmodels.RenderSet, log *zap.SugaredLogger) error {
+	helmClient, err := helmtool.NewClientFromNamespace(env.ClusterID, env.Namespace)
+	if err != nil {
+		return e.ErrUpdateEnv.AddErr(err)
+	}
+
+	snapshotServices := snapshotServiceMap(snapshot)
+	for _, svc := range env.GetServiceMap() {
+		if _, ok := snapshotServices[svc.ServiceName]; ok {
+			continue
+		}
+		if err := UninstallServiceByName(helmClient, svc.ServiceName, env, svc.Revision, true); err != nil {
+			log.Errorf("UninstallRelease err:%v", err)
+			return e.ErrUpdateEnv.AddErr(err)
+		}
+	}
+
+	env.Services = snapshot.Services
+	env.Revision = snapshot.EnvRevision
+	env.ServiceRenders = renderSet.ChartInfos
+	env.Render.Revision = renderSet.Revision
+	if len(snapshot.DeployStrategy) > 0 {
+		env.ServiceDeployStrategy = snapshot.DeployStrategy
+	}
+	env.Status = setting.ProductStatusUpdating
+	if err := commonrepo.NewProductColl().Update(env); err != nil {
+		log.Errorf("Failed to update env, err: %s", err)
+		return err
+	}
+	return proceedHelmRelease(env, renderSet, helmClient, nil, log)
+}
+
+// rollbackEnvResources creates, updates and deletes the env configs to make them the same as the snapshot.
+func rollbackEnvResources(env *commonmodels.Product, snapshot *commonmodels.EnvSnapshot, userName string, log *zap.SugaredLogger) error {
+	resources, err := commonrepo.NewEnvResourceColl().ListLatestResource(&commonrepo.QueryEnvResourceOption{ProductName: env.ProductName, EnvName: env.EnvName})
+	if err != nil {
+		return err
+	}
+	currentResources := sets.NewString()
+	for _, resource := range resources {
+		currentResources.Insert(fmt.Sprintf("%s/%s", resource.ID.Type, resource.ID.Name))
+	}
+
+	errList := new(multierror.Error)
+	snapshotResources := snapshotResourceMap(snapshot)
+	for key, resource := range snapshotResources {
+		args := &commonmodels.CreateUpdateCommonEnvCfgArgs{
+			EnvName:          env.EnvName,
+			ProductName:      env.ProductName,
+			Name:             resource.Name,
+			YamlData:         resource.YamlData,
+			CommonEnvCfgType: config.CommonEnvCfgType(resource.Type),
+		}
+		if !currentResources.Has(key) {
+			if err := CreateCommonEnvCfg(args, userName, log); err != nil {
+				errList = multierror.Append(errList, fmt.Errorf("failed to create %s: %s", key, err))
+			}
+			continue
+		}
+		latest, err := getLatestEnvResource(resource.Name, resource.Type, env.EnvName, env.ProductName)
+		if err != nil {
+			errList = multierror.Append(errList, fmt.Errorf("failed to find %s: %s", key, err))
+			continue
+		}
+		if latest.YamlData == resource.YamlData {
+			continue
+		}
+		args.LatestEnvResource = latest
+		if err := UpdateCommonEnvCfg(args, userName, true, log); err != nil {
+			errList = multierror.Append(errList, fmt.Errorf("failed to update %s: %s", key, err))
+		}
+	}
+	for _, resource := range resources {
+		if _, ok := snapshotResources[fmt.Sprintf("%s/%s", resource.ID.Type, resource.ID.Name)]; ok {
+			continue
+		}
+		if err := DeleteCommonEnvCfg(env.EnvName, env.ProductName, resource.ID.Name, config.CommonEnvCfgType(resource.ID.Type), log); err != nil {
+			errList = multierror.Append(errList, fmt.Errorf("failed to delete %s/%s: %s", resource.ID.Type, resource.ID.Name, err))
+		}
+	}
+	return errList.ErrorOrNil()
+}
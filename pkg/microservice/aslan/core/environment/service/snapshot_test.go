@@ -0,0 +1,103 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	templatemodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/template"
+)
+
+var _ = Describe("Testing env snapshot", func() {
+
+	Describe("test diffEnvSnapshot", func() {
+
+		previous := &commonmodels.EnvSnapshot{
+			Revision: 1,
+			Services: [][]*commonmodels.ProductService{
+				{
+					{ServiceName: "web", Revision: 1, Containers: []*commonmodels.Container{{Name: "web", Image: "web:v1"}}},
+					{ServiceName: "api", Revision: 2, Containers: []*commonmodels.Container{{Name: "api", Image: "api:v1"}}},
+				},
+				{
+					{ServiceName: "cache", Revision: 1},
+				},
+			},
+			RenderSet: &commonmodels.EnvSnapshotRenderSet{Name: "dev", Revision: 3, DefaultValues: "replicas: 1"},
+			EnvResources: []*commonmodels.EnvSnapshotResource{
+				{Type: "ConfigMap", Name: "web-conf", YamlData: "a: 1"},
+				{Type: "Secret", Name: "web-secret", YamlData: "b: 1"},
+			},
+		}
+
+		It("should report everything as added without the previous snapshot", func() {
+			diff := diffEnvSnapshot(nil, previous)
+			Expect(diff.AddedServices).To(Equal([]string{"api", "cache", "web"}))
+			Expect(diff.UpdatedServices).To(BeEmpty())
+			Expect(diff.AddedResources).To(Equal([]string{"ConfigMap/web-conf", "Secret/web-secret"}))
+			Expect(diff.VariablesChanged).To(BeTrue())
+		})
+
+		It("should ignore the renderset revision", func() {
+			current := *previous
+			current.Revision = 2
+			current.RenderSet = &commonmodels.EnvSnapshotRenderSet{Name: "dev", Revision: 4, DefaultValues: "replicas: 1"}
+			diff := diffEnvSnapshot(previous, &current)
+			Expect(diff.AddedServices).To(BeEmpty())
+			Expect(diff.DeletedServices).To(BeEmpty())
+			Expect(diff.UpdatedServices).To(BeEmpty())
+			Expect(diff.UpdatedResources).To(BeEmpty())
+			Expect(diff.VariablesChanged).To(BeFalse())
+		})
+
+		It("should report the changed services, variables and resources", func() {
+			current := &commonmodels.EnvSnapshot{
+				Revision: 2,
+				Services: [][]*commonmodels.ProductService{
+					{
+						{ServiceName: "web", Revision: 1, Containers: []*commonmodels.Container{{Name: "web", Image: "web:v2"}}},
+						{ServiceName: "api", Revision: 3, Containers: []*commonmodels.Container{{Name: "api", Image: "api:v1"}}},
+						{ServiceName: "worker", Revision: 1},
+					},
+				},
+				RenderSet: &commonmodels.EnvSnapshotRenderSet{
+					Name:             "dev",
+					Revision:         4,
+					DefaultValues:    "replicas: 1",
+					ServiceVariables: []*templatemodels.ServiceRender{{ServiceName: "web", OverrideYaml: &templatemodels.CustomYaml{YamlContent: "port: 80"}}},
+				},
+				EnvResources: []*commonmodels.EnvSnapshotResource{
+					{Type: "ConfigMap", Name: "web-conf", YamlData: "a: 2"},
+					{Type: "Ingress", Name: "web", YamlData: "c: 1"},
+				},
+			}
+			diff := diffEnvSnapshot(previous, current)
+			Expect(diff.AddedServices).To(Equal([]string{"worker"}))
+			Expect(diff.DeletedServices).To(Equal([]string{"cache"}))
+			Expect(diff.UpdatedServices).To(Equal([]*ServiceSnapshotDiff{
+				{ServiceName: "api", RevisionFrom: 2, RevisionTo: 3, Images: []*ImageDiffEntry{}},
+				{ServiceName: "web", RevisionFrom: 1, RevisionTo: 1, Images: []*ImageDiffEntry{{Container: "web", From: "web:v1", To: "web:v2"}}},
+			}))
+			Expect(diff.VariablesChanged).To(BeTrue())
+			Expect(diff.AddedResources).To(Equal([]string{"Ingress/web"}))
+			Expect(diff.DeletedResources).To(Equal([]string{"Secret/web-secret"}))
+			Expect(diff.UpdatedResources).To(Equal([]string{"ConfigMap/web-conf"}))
+		})
+	})
+})
@@ -69,8 +69,16 @@ func GetWorkflowV4JobContainerLogs(c *gin.Context) {
 		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
 		return
 	}
+	// attempt is set to get the log of a failed attempt of a retried job.
+	attempt := 0
+	if c.Query("attempt") != "" {
+		if attempt, err = strconv.Atoi(c.Query("attempt")); err != nil {
+			ctx.Err = e.ErrInvalidParam.AddDesc("invalid attempt")
+			return
+		}
+	}
 	// Use all lowercase job names to avoid subdomain errors
-	ctx.Resp, ctx.Err = logservice.GetWorkflowV4JobContainerLogs(strings.ToLower(c.Param("workflowName")), c.Param("jobName"), taskID, ctx.Logger)
+	ctx.Resp, ctx.Err = logservice.GetWorkflowV4JobContainerLogs(strings.ToLower(c.Param("workflowName")), c.Param("jobName"), taskID, attempt, ctx.Logger)
 }
 
 func GetTestJobContainerLogs(c *gin.Context) {
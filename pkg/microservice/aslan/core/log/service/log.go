@@ -32,6 +32,7 @@ import (
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/kube/containerlog"
 	s3tool "github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/job"
 	"github.com/koderover/zadig/pkg/util"
 )
 
@@ -55,8 +56,11 @@ func GetWorkflowBuildJobContainerLogs(pipelineName, serviceName, buildType strin
 	return buildLog, nil
 }
 
-func GetWorkflowV4JobContainerLogs(workflowName, jobName string, taskID int64, log *zap.SugaredLogger) (string, error) {
+func GetWorkflowV4JobContainerLogs(workflowName, jobName string, taskID int64, attempt int, log *zap.SugaredLogger) (string, error) {
 	buildJobNamePrefix := jobName
+	if attempt > 0 {
+		buildJobNamePrefix = job.GetJobAttemptLogName(jobName, attempt)
+	}
 	buildLog, err := getContainerLogFromS3(workflowName, buildJobNamePrefix, taskID, log)
 	if err != nil {
 		return "", err
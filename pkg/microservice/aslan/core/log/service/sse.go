@@ -38,6 +38,7 @@ import (
 	"github.com/koderover/zadig/pkg/tool/kube/getter"
 	"github.com/koderover/zadig/pkg/tool/kube/label"
 	"github.com/koderover/zadig/pkg/tool/kube/watcher"
+	"github.com/koderover/zadig/pkg/util/mask"
 )
 
 const (
@@ -59,6 +60,8 @@ type GetContainerOptions struct {
 	EnvName       string
 	ProductName   string
 	ClusterID     string
+	// Secrets are masked in the streamed log
+	Secrets []string
 }
 
 func ContainerLogStream(ctx context.Context, streamChan chan interface{}, envName, productName, podName, containerName string, follow bool, tailLines int64, log *zap.SugaredLogger) {
@@ -72,10 +75,10 @@ func ContainerLogStream(ctx context.Context, streamChan chan interface{}, envNam
 		log.Errorf("failed to find ns and kubeClient: %v", err)
 		return
 	}
-	containerLogStream(ctx, streamChan, productInfo.Namespace, podName, containerName, follow, tailLines, clientset, log)
+	containerLogStream(ctx, streamChan, productInfo.Namespace, podName, containerName, follow, tailLines, clientset, nil, log)
 }
 
-func containerLogStream(ctx context.Context, streamChan chan interface{}, namespace, podName, containerName string, follow bool, tailLines int64, client kubernetes.Interface, log *zap.SugaredLogger) {
+func containerLogStream(ctx context.Context, streamChan chan interface{}, namespace, podName, containerName string, follow bool, tailLines int64, client kubernetes.Interface, masker *mask.Masker, log *zap.SugaredLogger) {
 	log.Infof("[GetContainerLogsSSE] Get container log of pod %s", podName)
 
 	out, err := containerlog.GetContainerLogStream(ctx, namespace, podName, containerName, follow, tailLines, client)
@@ -100,11 +103,11 @@ func containerLogStream(ctx context.Context, streamChan chan interface{}, namesp
 		default:
 			line, err := buf.ReadString('\n')
 			if err == nil {
-				line = strings.TrimSpace(line)
+				line = strings.TrimSpace(masker.Mask(line))
 				streamChan <- line
 			}
 			if err == io.EOF {
-				line = strings.TrimSpace(line)
+				line = strings.TrimSpace(masker.Mask(line))
 				if len(line) > 0 {
 					streamChan <- line
 				}
@@ -273,6 +276,7 @@ func WorkflowTaskV4ContainerLogStream(ctx context.Context, streamChan chan inter
 					return
 				}
 				options.ClusterID = jobSpec.Properties.ClusterID
+				options.Secrets = commonmodels.CredentialValues(jobSpec.Properties.Envs, nil)
 			case string(config.JobPlugin):
 				jobSpec := &commonmodels.JobTaskPluginSpec{}
 				if err := commonmodels.IToi(job.Spec, jobSpec); err != nil {
@@ -280,6 +284,7 @@ func WorkflowTaskV4ContainerLogStream(ctx context.Context, streamChan chan inter
 					return
 				}
 				options.ClusterID = jobSpec.Properties.ClusterID
+				options.Secrets = commonmodels.CredentialValues(jobSpec.Properties.Envs, nil)
 			default:
 				log.Errorf("get real-time log error, unsupported job type %s", job.JobType)
 				return
@@ -297,6 +302,8 @@ func WorkflowTaskV4ContainerLogStream(ctx context.Context, streamChan chan inter
 		}
 	}
 
+	options.Secrets = append(options.Secrets, commonmodels.CredentialValues(task.KeyVals, task.Params)...)
+
 	selector := getWorkflowSelector(options)
 	waitAndGetLog(ctx, streamChan, selector, options, log)
 }
@@ -371,6 +378,7 @@ func waitAndGetLog(ctx context.Context, streamChan chan interface{}, selector la
 			true,
 			options.TailLines,
 			clientSet,
+			mask.NewMasker(options.Secrets),
 			log,
 		)
 	}
@@ -157,6 +157,8 @@ func Start(ctx context.Context) {
 
 	//Parse the workload dependencies configMap, PVC, ingress, secret
 	go environmentservice.StartClusterInformer()
+	go environmentservice.StartDriftDetector()
+	go environmentservice.StartPreviewEnvCleaner()
 
 	go StartControllers(ctx.Done())
 
@@ -241,6 +243,7 @@ func initDatabase() {
 		commonrepo.NewCallbackRequestColl(),
 		commonrepo.NewConfigurationManagementColl(),
 		commonrepo.NewCounterColl(),
+		commonrepo.NewLeaseColl(),
 		commonrepo.NewCronjobColl(),
 		commonrepo.NewDeliveryActivityColl(),
 		commonrepo.NewDeliveryArtifactColl(),
@@ -277,6 +280,7 @@ func initDatabase() {
 		commonrepo.NewSystemSettingColl(),
 		commonrepo.NewTaskColl(),
 		commonrepo.NewTestTaskStatColl(),
+		commonrepo.NewTestJobStatColl(),
 		commonrepo.NewTestingColl(),
 		commonrepo.NewWebHookColl(),
 		commonrepo.NewWebHookUserColl(),
@@ -298,6 +302,11 @@ func initDatabase() {
 		commonrepo.NewPluginRepoColl(),
 		commonrepo.NewWorkflowViewColl(),
 		commonrepo.NewWorkflowV4TemplateColl(),
+		commonrepo.NewStepTemplateColl(),
+		commonrepo.NewImageAttestationColl(),
+		commonrepo.NewServiceDriftColl(),
+		commonrepo.NewEnvSnapshotColl(),
+		commonrepo.NewPreviewEnvColl(),
 		commonrepo.NewVariableSetColl(),
 
 		systemrepo.NewAnnouncementColl(),
@@ -135,6 +135,29 @@ func UpdateServiceVariable(c *gin.Context) {
 	ctx.Err = svcservice.UpdateServiceVariables(args)
 }
 
+type updateServiceDependenciesReq struct {
+	DependsOn []string `json:"depends_on"`
+}
+
+func UpdateServiceDependencies(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	args := new(updateServiceDependenciesReq)
+	if err := c.ShouldBindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddErr(err)
+		return
+	}
+	projectName := c.Query("projectName")
+	if projectName == "" {
+		ctx.Err = e.ErrInvalidParam.AddDesc("projectName can not be empty")
+		return
+	}
+	serviceName := c.Param("name")
+	internalhandler.InsertOperationLog(c, ctx.UserName, projectName, "更新", "项目管理-服务依赖", fmt.Sprintf("服务名称:%s", serviceName), "", ctx.Logger)
+	ctx.Err = svcservice.UpdateServiceDependencies(projectName, serviceName, args.DependsOn, ctx.Logger)
+}
+
 func UpdateServiceHealthCheckStatus(c *gin.Context) {
 	ctx := internalhandler.NewContext(c)
 	defer func() { internalhandler.JSONResponse(c, ctx) }()
@@ -1536,6 +1536,8 @@ func createOrUpdateHelmService(fsTree fs.FS, args *helmServiceCreationArgs, forc
 			return nil, err
 		}
 		serviceObj.ReleaseNaming = currentSvcTmpl.GetReleaseNaming()
+		// dependencies are maintained by UpdateServiceDependencies, the new revision keeps them
+		serviceObj.DependsOn = currentSvcTmpl.DependsOn
 	}
 
 	// create new service template
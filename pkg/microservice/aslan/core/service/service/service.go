@@ -771,6 +771,10 @@ func CreateServiceTemplate(userName string, args *commonmodels.Service, force bo
 
 	// fill serviceVars and variableYaml
 	fillServiceVariable(args)
+	// dependencies are maintained by UpdateServiceDependencies, the new revision keeps them
+	if notFoundErr == nil {
+		args.DependsOn = serviceTmpl.DependsOn
+	}
 
 	// 校验args
 	if err := ensureServiceTmpl(userName, args, log); err != nil {
@@ -968,6 +972,45 @@ func UpdateServiceVariables(args *commonservice.ServiceTmplObject) error {
 	return nil
 }
 
+// UpdateServiceDependencies sets the services to be ready before the service is deployed, the dependencies apply to
+// all the revisions of the service and are kept by the new revisions
+func UpdateServiceDependencies(productName, serviceName string, dependsOn []string, log *zap.SugaredLogger) error {
+	services, err := commonrepo.NewServiceColl().ListMaxRevisionsByProduct(productName)
+	if err != nil {
+		return e.ErrUpdateService.AddErr(fmt.Errorf("failed to list services, err: %s", err))
+	}
+
+	var currentService *commonmodels.Service
+	dependencies := make(map[string][]string)
+	for _, svc := range services {
+		dependencies[svc.ServiceName] = svc.DependsOn
+		if svc.ServiceName == serviceName {
+			currentService = svc
+		}
+	}
+	if currentService == nil {
+		return e.ErrUpdateService.AddDesc(fmt.Sprintf("service %s not found in project %s", serviceName, productName))
+	}
+
+	dependsOn = sets.NewString(dependsOn...).List()
+	for _, dependency := range dependsOn {
+		if _, ok := dependencies[dependency]; !ok {
+			return e.ErrUpdateService.AddDesc(fmt.Sprintf("dependent service %s not found in project %s", dependency, productName))
+		}
+	}
+	dependencies[serviceName] = dependsOn
+	if err := service.ValidateServiceDependencies(dependencies); err != nil {
+		return e.ErrUpdateService.AddErr(err)
+	}
+
+	currentService.DependsOn = dependsOn
+	if err := commonrepo.NewServiceColl().UpdateServiceDependencies(currentService); err != nil {
+		log.Errorf("failed to update dependencies of service %s, err: %s", serviceName, err)
+		return e.ErrUpdateService.AddErr(err)
+	}
+	return nil
+}
+
 func UpdateServiceHealthCheckStatus(args *commonservice.ServiceTmplObject) error {
 	currentService, err := commonrepo.NewServiceColl().Find(&commonrepo.ServiceFindOption{
 		ProductName: args.ProductName,
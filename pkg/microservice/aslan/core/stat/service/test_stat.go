@@ -457,7 +457,8 @@ func GetTestHealthMeasure(startDate, endDate int64, productNames []string, log *
 
 type testTrend struct {
 	*CurrentDay
-	Sum []*sumData `json:"sum"`
+	Sum      []*sumData      `json:"sum"`
+	Coverage []*coverageData `json:"coverage"`
 }
 
 func GetTestTrendMeasure(startDate, endDate int64, productNames []string, log *zap.SugaredLogger) (*testTrend, error) {
@@ -574,10 +575,147 @@ func GetTestTrendMeasure(startDate, endDate int64, productNames []string, log *z
 		}
 	}
 
+	coverageDatas, err := getCoverageTrend(startDate, endDate, productNames)
+	if err != nil {
+		log.Errorf("get coverage trend err:%v", err)
+		return nil, fmt.Errorf("get coverage trend err:%v", err)
+	}
+
 	testTrend := &testTrend{
 		CurrentDay: currDay,
 		Sum:        sumDatas,
+		Coverage:   coverageDatas,
 	}
 
 	return testTrend, nil
 }
+
+type coverageData struct {
+	Day      int64   `json:"day"`
+	LineRate float64 `json:"line_rate"`
+}
+
+type testCoverageDelta struct {
+	TestName     string                  `json:"test_name"`
+	WorkflowName string                  `json:"workflow_name"`
+	TaskID       int64                   `json:"task_id"`
+	LineRate     float64                 `json:"line_rate"`
+	Delta        float64                 `json:"delta"`
+	Packages     []*packageCoverageDelta `json:"packages"`
+}
+
+type packageCoverageDelta struct {
+	Name     string  `json:"name"`
+	LineRate float64 `json:"line_rate"`
+	Delta    float64 `json:"delta"`
+}
+
+// getCoverageTrend returns the daily line coverage, which is calculated from the last run of every test in the day.
+func getCoverageTrend(startDate, endDate int64, productNames []string) ([]*coverageData, error) {
+	jobStats, err := commonmongodb.NewTestJobStatColl().List(&commonmongodb.TestJobStatOption{
+		ProjectNames: productNames,
+		StartTime:    startDate,
+		EndTime:      endOfDay(endDate),
+		WithCoverage: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// date -> test name -> the last stat of the test in the day
+	dailyStats := make(map[string]map[string]*commonmodels.TestJobStat)
+	for _, jobStat := range jobStats {
+		date := time.Unix(jobStat.CreateTime, 0).Format(config.Date)
+		if _, ok := dailyStats[date]; !ok {
+			dailyStats[date] = make(map[string]*commonmodels.TestJobStat)
+		}
+		dailyStats[date][jobStat.TestName] = jobStat
+	}
+
+	resp := make([]*coverageData, 0, len(dailyStats))
+	for date, testStats := range dailyStats {
+		day, err := time.ParseInLocation(config.Date, date, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		var covered, valid int
+		for _, testStat := range testStats {
+			covered += testStat.Coverage.LinesCovered
+			valid += testStat.Coverage.LinesValid
+		}
+		lineRate := 0.0
+		if valid > 0 {
+			lineRate = float64(covered) * 100 / float64(valid)
+		}
+		resp = append(resp, &coverageData{Day: day.Unix(), LineRate: lineRate})
+	}
+	sort.Slice(resp, func(i, j int) bool {
+		return resp[i].Day < resp[j].Day
+	})
+	return resp, nil
+}
+
+// GetTestCoverageDelta compares the coverage of the last run of every test with its previous run in the time range.
+func GetTestCoverageDelta(startDate, endDate int64, productNames []string, log *zap.SugaredLogger) ([]*testCoverageDelta, error) {
+	jobStats, err := commonmongodb.NewTestJobStatColl().List(&commonmongodb.TestJobStatOption{
+		ProjectNames: productNames,
+		StartTime:    startDate,
+		EndTime:      endOfDay(endDate),
+		WithCoverage: true,
+	})
+	if err != nil {
+		log.Errorf("list test job stat err: %v", err)
+		return nil, fmt.Errorf("list test job stat err: %v", err)
+	}
+
+	testNames := make([]string, 0)
+	testStats := make(map[string][]*commonmodels.TestJobStat)
+	for _, jobStat := range jobStats {
+		if _, ok := testStats[jobStat.TestName]; !ok {
+			testNames = append(testNames, jobStat.TestName)
+		}
+		testStats[jobStat.TestName] = append(testStats[jobStat.TestName], jobStat)
+	}
+	sort.Strings(testNames)
+
+	resp := make([]*testCoverageDelta, 0, len(testNames))
+	for _, testName := range testNames {
+		stats := testStats[testName]
+		latest := stats[len(stats)-1]
+		delta := &testCoverageDelta{
+			TestName:     testName,
+			WorkflowName: latest.WorkflowName,
+			TaskID:       latest.TaskID,
+			LineRate:     latest.Coverage.LineRate(),
+			Packages:     make([]*packageCoverageDelta, 0, len(latest.Coverage.Packages)),
+		}
+		previousRates := make(map[string]float64)
+		if len(stats) > 1 {
+			previous := stats[len(stats)-2]
+			delta.Delta = delta.LineRate - previous.Coverage.LineRate()
+			for _, pkg := range previous.Coverage.Packages {
+				previousRates[pkg.Name] = pkg.LineRate()
+			}
+		}
+		for _, pkg := range latest.Coverage.Packages {
+			pkgDelta := &packageCoverageDelta{
+				Name:     pkg.Name,
+				LineRate: pkg.LineRate(),
+			}
+			if previousRate, ok := previousRates[pkg.Name]; ok {
+				pkgDelta.Delta = pkgDelta.LineRate - previousRate
+			}
+			delta.Packages = append(delta.Packages, pkgDelta)
+		}
+		resp = append(resp, delta)
+	}
+	return resp, nil
+}
+
+// endOfDay returns the last second of the day, the daily stats are recorded with the beginning of the day.
+func endOfDay(date int64) int64 {
+	if date <= 0 {
+		return date
+	}
+	return now.With(time.Unix(date, 0)).EndOfDay().Unix()
+}
@@ -19,6 +19,8 @@ package service
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
@@ -26,6 +28,7 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models/task"
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/s3"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/workflowcontroller/jobcontroller"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/log"
 	s3tool "github.com/koderover/zadig/pkg/tool/s3"
@@ -43,7 +46,11 @@ func UpdateSysCapStrategy(strategy *commonmodels.CapacityStrategy) error {
 	}
 
 	// 更新成功后，立即按照新的配置清理数据
-	go handleWorkflowTaskRetentionCenter(strategy, false)
+	if strategy.Target == commonmodels.DependencyCacheQuota {
+		go handleDependencyCacheQuota(strategy, false)
+	} else {
+		go handleWorkflowTaskRetentionCenter(strategy, false)
+	}
 
 	return nil
 }
@@ -53,6 +60,9 @@ func GetCapacityStrategy(target commonmodels.CapacityTarget) (*commonmodels.Capa
 	if err != nil && target == commonmodels.WorkflowTaskRetention {
 		return commonmodels.DefaultWorkflowTaskRetention, nil // Return default setup
 	}
+	if err != nil && target == commonmodels.DependencyCacheQuota {
+		return commonmodels.DefaultDependencyCacheQuota, nil
+	}
 	return result, err
 }
 
@@ -65,7 +75,17 @@ func HandleSystemGC(dryRun bool) error {
 		return err
 	}
 
-	return handleWorkflowTaskRetentionCenter(strategy, dryRun)
+	if err := handleWorkflowTaskRetentionCenter(strategy, dryRun); err != nil {
+		return err
+	}
+
+	cacheStrategy, err := commonrepo.NewStrategyColl().GetByTarget(commonmodels.DependencyCacheQuota)
+	if err != nil {
+		cacheStrategy = commonmodels.DefaultDependencyCacheQuota
+	} else if err = validateStrategy(cacheStrategy); err != nil {
+		return err
+	}
+	return handleDependencyCacheQuota(cacheStrategy, dryRun)
 }
 
 func CleanCache() error {
@@ -382,6 +402,16 @@ func cleanStaleTaskV4s(tasks []*commonmodels.WorkflowTask, s3Server *s3.S3, dryR
 	if err == nil {
 		go s3client.RemoveFiles(s3Server.Bucket, paths)
 	}
+	// the large outputs of jobs may be saved in other storages
+	if !dryRun {
+		go func() {
+			for _, task := range tasks {
+				if err := jobcontroller.DeleteOutputs(task.WorkflowName, task.TaskID, task.GlobalContext); err != nil {
+					log.Warnf("failed to delete outputs of workflow task %s/%d: %s", task.WorkflowName, task.TaskID, err)
+				}
+			}
+		}()
+	}
 	return ids
 }
 
@@ -397,9 +427,97 @@ func validateStrategy(strategy *commonmodels.CapacityStrategy) error {
 				"can only set one positive value at a time. days: %v, items: %v",
 				retention.MaxDays, retention.MaxItems)
 		}
+	} else if strategy.Target == commonmodels.DependencyCacheQuota {
+		if strategy.Quota == nil || strategy.Quota.MaxSizeInMiB <= 0 {
+			return errors.New("SysCap strategy: max size of DependencyCacheQuota must be positive")
+		}
 	} else {
 		// Note: currently doesn't support other strategies yet.
 		return fmt.Errorf("SysCap strategy target is invalid - passed in value: %v", strategy.Target)
 	}
 	return nil
 }
+
+type dependencyCacheObject struct {
+	key      string
+	size     int64
+	lastUsed time.Time
+}
+
+// handleDependencyCacheQuota evicts the least recently used dependency caches of each project in the default object storage
+// until they fit in the quota. Caches kept in NFS are not reachable here, they are evicted by the cache_save step.
+func handleDependencyCacheQuota(strategy *commonmodels.CapacityStrategy, dryRun bool) error {
+	s3Server, err := s3.FindDefaultS3()
+	if err != nil {
+		log.Errorf("Failed to find default s3, error: %s", err)
+		return err
+	}
+	forcedPathStyle := true
+	if s3Server.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3tool.NewClient(s3Server.Endpoint, s3Server.Ak, s3Server.Sk, s3Server.Region, s3Server.Insecure, forcedPathStyle)
+	if err != nil {
+		log.Errorf("Failed to create s3 client, error: %s", err)
+		return err
+	}
+
+	prefix := s3Server.GetObjectPath(setting.DependencyCachePrefix) + "/"
+	objects, err := client.ListObjectsWithInfo(s3Server.Bucket, prefix)
+	if err != nil {
+		return err
+	}
+	projectCaches := make(map[string][]*dependencyCacheObject)
+	for _, object := range objects {
+		if object.Key == nil || object.Size == nil || object.LastModified == nil {
+			continue
+		}
+		project := strings.SplitN(strings.TrimPrefix(*object.Key, prefix), "/", 2)[0]
+		projectCaches[project] = append(projectCaches[project], &dependencyCacheObject{
+			key:      *object.Key,
+			size:     *object.Size,
+			lastUsed: *object.LastModified,
+		})
+	}
+
+	quota := strategy.Quota.MaxSizeInMiB * 1024 * 1024
+	evicted := make([]string, 0)
+	for project, caches := range projectCaches {
+		var total int64
+		for _, cache := range caches {
+			total += cache.size
+		}
+		if total <= quota {
+			continue
+		}
+		sort.Slice(caches, func(i, j int) bool {
+			return caches[i].lastUsed.Before(caches[j].lastUsed)
+		})
+		count := 0
+		for _, cache := range caches {
+			if total <= quota {
+				break
+			}
+			evicted = append(evicted, cache.key)
+			total -= cache.size
+			count++
+		}
+		log.Infof("%d dependency caches of project %s will be evicted", count, project)
+	}
+	if dryRun || len(evicted) == 0 {
+		return nil
+	}
+
+	// at most 1000 objects can be deleted in a single request
+	for start := 0; start < len(evicted); start += 1000 {
+		end := start + 1000
+		if end > len(evicted) {
+			end = len(evicted)
+		}
+		if err := client.DeleteObjects(s3Server.Bucket, evicted[start:end]); err != nil {
+			log.Errorf("Failed to evict dependency caches, error: %s", err)
+			return err
+		}
+	}
+	return nil
+}
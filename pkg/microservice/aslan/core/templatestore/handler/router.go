@@ -84,4 +84,15 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		workflow.GET("/:id", GetWorkflowTemplateByID)
 		workflow.DELETE("/:id", DeleteWorkflowTemplateByID)
 	}
+
+	step := router.Group("step")
+	{
+		step.POST("", CreateStepTemplate)
+		step.PUT("/:name", UpdateStepTemplate)
+		step.GET("", ListStepTemplates)
+		step.GET("/:name", GetStepTemplate)
+		step.GET("/:name/versions", ListStepTemplateVersions)
+		step.GET("/:name/reference", GetStepTemplateReference)
+		step.DELETE("/:name", RemoveStepTemplate)
+	}
 }
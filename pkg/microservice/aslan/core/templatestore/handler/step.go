@@ -0,0 +1,108 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	templateservice "github.com/koderover/zadig/pkg/microservice/aslan/core/templatestore/service"
+	internalhandler "github.com/koderover/zadig/pkg/shared/handler"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+func CreateStepTemplate(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	args := new(commonmodels.StepTemplate)
+	if err := c.BindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid step template args")
+		return
+	}
+
+	bs, _ := json.Marshal(args)
+	internalhandler.InsertOperationLog(c, ctx.UserName, "", "新增", "模板-步骤", args.Name, string(bs), ctx.Logger)
+
+	ctx.Err = templateservice.CreateStepTemplate(ctx.UserName, args, ctx.Logger)
+}
+
+func UpdateStepTemplate(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	args := new(commonmodels.StepTemplate)
+	if err := c.BindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid step template args")
+		return
+	}
+
+	bs, _ := json.Marshal(args)
+	internalhandler.InsertOperationLog(c, ctx.UserName, "", "更新", "模板-步骤", c.Param("name"), string(bs), ctx.Logger)
+
+	ctx.Err = templateservice.UpdateStepTemplate(ctx.UserName, c.Param("name"), args, ctx.Logger)
+}
+
+func ListStepTemplates(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	ctx.Resp, ctx.Err = templateservice.ListStepTemplates(ctx.Logger)
+}
+
+func GetStepTemplate(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	var version int64
+	if v := c.Query("version"); v != "" {
+		var err error
+		version, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			ctx.Err = e.ErrInvalidParam.AddDesc("invalid version")
+			return
+		}
+	}
+
+	ctx.Resp, ctx.Err = templateservice.GetStepTemplate(c.Param("name"), version, ctx.Logger)
+}
+
+func ListStepTemplateVersions(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	ctx.Resp, ctx.Err = templateservice.ListStepTemplateVersions(c.Param("name"), ctx.Logger)
+}
+
+func RemoveStepTemplate(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	internalhandler.InsertOperationLog(c, ctx.UserName, "", "删除", "模板-步骤", c.Param("name"), "", ctx.Logger)
+
+	ctx.Err = templateservice.RemoveStepTemplate(c.Param("name"), ctx.Logger)
+}
+
+func GetStepTemplateReference(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	ctx.Resp, ctx.Err = templateservice.GetStepTemplateReference(c.Param("name"), ctx.Logger)
+}
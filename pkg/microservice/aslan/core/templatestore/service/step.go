@@ -0,0 +1,249 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/template"
+	"github.com/koderover/zadig/pkg/setting"
+	e "github.com/koderover/zadig/pkg/tool/errors"
+)
+
+var stepTemplateInputRegexp = regexp.MustCompile(`\{\{\.inputs\.([^}]+)\}\}`)
+
+type StepTemplateBrief struct {
+	Name        string `json:"name"`
+	Version     int64  `json:"version"`
+	Description string `json:"description"`
+	UpdateBy    string `json:"update_by"`
+	UpdateTime  int64  `json:"update_time"`
+}
+
+func CreateStepTemplate(userName string, stepTemplate *commonmodels.StepTemplate, logger *zap.SugaredLogger) error {
+	if _, err := commonrepo.NewStepTemplateColl().Find(stepTemplate.Name, 0); err == nil {
+		errMsg := fmt.Sprintf("步骤模板名称: %s 已存在", stepTemplate.Name)
+		logger.Error(errMsg)
+		return e.ErrCreateStepTemplate.AddDesc(errMsg)
+	}
+	if err := lintStepTemplate(stepTemplate); err != nil {
+		return e.ErrLintStepTemplate.AddErr(err)
+	}
+	stepTemplate.ID = primitive.NilObjectID
+	stepTemplate.Version = 1
+	stepTemplate.UpdateBy = userName
+	stepTemplate.CreateTime = time.Now().Unix()
+	if err := commonrepo.NewStepTemplateColl().Create(stepTemplate); err != nil {
+		logger.Errorf("Failed to create step template %s, err: %s", stepTemplate.Name, err)
+		return e.ErrCreateStepTemplate.AddErr(err)
+	}
+	return nil
+}
+
+// UpdateStepTemplate saves the template as a new version, the former versions are kept for the steps pinned to them.
+func UpdateStepTemplate(userName, name string, stepTemplate *commonmodels.StepTemplate, logger *zap.SugaredLogger) error {
+	latest, err := commonrepo.NewStepTemplateColl().Find(name, 0)
+	if err != nil {
+		errMsg := fmt.Sprintf("step template %s not found: %v", name, err)
+		logger.Error(errMsg)
+		return e.ErrUpdateStepTemplate.AddDesc(errMsg)
+	}
+	stepTemplate.Name = name
+	if err := lintStepTemplate(stepTemplate); err != nil {
+		return e.ErrLintStepTemplate.AddErr(err)
+	}
+	stepTemplate.ID = primitive.NilObjectID
+	stepTemplate.Version = latest.Version + 1
+	stepTemplate.UpdateBy = userName
+	stepTemplate.CreateTime = time.Now().Unix()
+	if err := commonrepo.NewStepTemplateColl().Create(stepTemplate); err != nil {
+		logger.Errorf("Failed to update step template %s, err: %s", name, err)
+		return e.ErrUpdateStepTemplate.AddErr(err)
+	}
+	return nil
+}
+
+// ListStepTemplates lists the latest version of every step template.
+func ListStepTemplates(logger *zap.SugaredLogger) ([]*StepTemplateBrief, error) {
+	stepTemplates, err := commonrepo.NewStepTemplateColl().List("")
+	if err != nil {
+		logger.Errorf("Failed to list step templates, err: %s", err)
+		return nil, e.ErrListStepTemplate.AddErr(err)
+	}
+	resp := make([]*StepTemplateBrief, 0)
+	names := sets.NewString()
+	for _, stepTemplate := range stepTemplates {
+		// versions of a template are sorted in descending order
+		if names.Has(stepTemplate.Name) {
+			continue
+		}
+		names.Insert(stepTemplate.Name)
+		resp = append(resp, toStepTemplateBrief(stepTemplate))
+	}
+	return resp, nil
+}
+
+func ListStepTemplateVersions(name string, logger *zap.SugaredLogger) ([]*StepTemplateBrief, error) {
+	stepTemplates, err := commonrepo.NewStepTemplateColl().List(name)
+	if err != nil {
+		logger.Errorf("Failed to list versions of step template %s, err: %s", name, err)
+		return nil, e.ErrListStepTemplate.AddErr(err)
+	}
+	resp := make([]*StepTemplateBrief, 0, len(stepTemplates))
+	for _, stepTemplate := range stepTemplates {
+		resp = append(resp, toStepTemplateBrief(stepTemplate))
+	}
+	return resp, nil
+}
+
+// GetStepTemplate returns the given version of the template, or the latest version if the version is not positive.
+func GetStepTemplate(name string, version int64, logger *zap.SugaredLogger) (*commonmodels.StepTemplate, error) {
+	stepTemplate, err := commonrepo.NewStepTemplateColl().Find(name, version)
+	if err != nil {
+		logger.Errorf("Failed to get step template %s of version %d, err: %s", name, version, err)
+		return nil, e.ErrGetStepTemplate.AddErr(err)
+	}
+	return stepTemplate, nil
+}
+
+func RemoveStepTemplate(name string, logger *zap.SugaredLogger) error {
+	references, err := GetStepTemplateReference(name, logger)
+	if err != nil {
+		return e.ErrDeleteStepTemplate.AddErr(err)
+	}
+	// when the step template is used by workflows, it can't be deleted
+	if len(references) > 0 {
+		return e.ErrDeleteStepTemplate.AddDesc(fmt.Sprintf("step template %s is used by workflow %s", name, references[0].WorkflowName))
+	}
+	if err := commonrepo.NewStepTemplateColl().DeleteByName(name); err != nil {
+		logger.Errorf("Failed to delete step template %s, err: %s", name, err)
+		return e.ErrDeleteStepTemplate.AddErr(err)
+	}
+	return nil
+}
+
+func GetStepTemplateReference(name string, logger *zap.SugaredLogger) ([]*template.StepTemplateReference, error) {
+	ret := make([]*template.StepTemplateReference, 0)
+	workflows, err := commonrepo.NewWorkflowV4Coll().ListByStepTemplate(name)
+	if err != nil {
+		logger.Errorf("Failed to get step template reference for template: %s, the error is: %s", name, err)
+		return ret, err
+	}
+	for _, workflow := range workflows {
+		for _, stage := range workflow.Stages {
+			for _, job := range stage.Jobs {
+				if job.JobType != config.JobFreestyle {
+					continue
+				}
+				spec := &commonmodels.FreestyleJobSpec{}
+				if err := commonmodels.IToi(job.Spec, spec); err != nil {
+					logger.Errorf("Failed to parse spec of job %s in workflow %s, err: %s", job.Name, workflow.Name, err)
+					continue
+				}
+				for _, step := range spec.Steps {
+					if step.StepType != config.StepTemplate {
+						continue
+					}
+					stepSpec := &commonmodels.StepTemplateSpec{}
+					if err := commonmodels.IToi(step.Spec, stepSpec); err != nil || stepSpec.TemplateName != name {
+						continue
+					}
+					ret = append(ret, &template.StepTemplateReference{
+						WorkflowName:        workflow.Name,
+						WorkflowDisplayName: workflow.DisplayName,
+						ProjectName:         workflow.Project,
+						JobName:             job.Name,
+						StepName:            step.Name,
+						Version:             stepSpec.Version,
+					})
+				}
+			}
+		}
+	}
+	return ret, nil
+}
+
+func toStepTemplateBrief(stepTemplate *commonmodels.StepTemplate) *StepTemplateBrief {
+	return &StepTemplateBrief{
+		Name:        stepTemplate.Name,
+		Version:     stepTemplate.Version,
+		Description: stepTemplate.Description,
+		UpdateBy:    stepTemplate.UpdateBy,
+		UpdateTime:  stepTemplate.CreateTime,
+	}
+}
+
+func lintStepTemplate(stepTemplate *commonmodels.StepTemplate) error {
+	reg, err := regexp.Compile(setting.JobNameRegx)
+	if err != nil {
+		return err
+	}
+	if !reg.MatchString(stepTemplate.Name) {
+		return fmt.Errorf("step template name [%s] did not match %s", stepTemplate.Name, setting.JobNameRegx)
+	}
+	if len(stepTemplate.Steps) == 0 {
+		return fmt.Errorf("step template %s has no step", stepTemplate.Name)
+	}
+
+	inputNames := sets.NewString()
+	for _, input := range stepTemplate.Inputs {
+		if inputNames.Has(input.Name) {
+			return fmt.Errorf("duplicated input name: %s", input.Name)
+		}
+		inputNames.Insert(input.Name)
+		switch input.Type {
+		case "":
+			input.Type = commonmodels.StepTemplateInputString
+		case commonmodels.StepTemplateInputString, commonmodels.StepTemplateInputBool, commonmodels.StepTemplateInputNumber:
+		default:
+			return fmt.Errorf("input %s has invalid type: %s", input.Name, input.Type)
+		}
+	}
+
+	stepNames := sets.NewString()
+	for _, step := range stepTemplate.Steps {
+		if stepNames.Has(step.Name) {
+			return fmt.Errorf("duplicated step name: %s", step.Name)
+		}
+		stepNames.Insert(step.Name)
+		if step.StepType == config.StepTemplate {
+			return fmt.Errorf("step %s: step templates can not be nested", step.Name)
+		}
+	}
+
+	// every input referenced by the steps must be declared
+	bs, err := json.Marshal(stepTemplate.Steps)
+	if err != nil {
+		return err
+	}
+	for _, match := range stepTemplateInputRegexp.FindAllStringSubmatch(string(bs), -1) {
+		if !inputNames.Has(match[1]) {
+			return fmt.Errorf("input %s is referenced but not declared", match[1])
+		}
+	}
+	return nil
+}
@@ -217,9 +217,15 @@ func (*Router) Inject(router *gin.RouterGroup) {
 		taskV4.GET("", ListWorkflowTaskV4)
 		taskV4.GET("/workflow/:workflowName/task/:taskID", GetWorkflowTaskV4)
 		taskV4.DELETE("/workflow/:workflowName/task/:taskID", CancelWorkflowTaskV4)
+		taskV4.POST("/retry/workflow/:workflowName/task/:taskID", RetryWorkflowTaskV4)
 		taskV4.GET("/clone/workflow/:workflowName/task/:taskID", CloneWorkflowTaskV4)
 		taskV4.POST("/approve", ApproveStage)
+		taskV4.POST("/manualgate", SubmitManualGate)
+		taskV4.POST("/pause/workflow/:workflowName/task/:taskID", PauseWorkflowTaskV4)
+		taskV4.POST("/resume/workflow/:workflowName/task/:taskID", ResumeWorkflowTaskV4)
 		taskV4.GET("/workflow/:workflowName/taskId/:taskId/job/:jobName", GetWorkflowV4ArtifactFileContent)
+		taskV4.GET("/workflow/:workflowName/task/:taskID/artifact", ListWorkflowV4TaskArtifacts)
+		taskV4.GET("/workflow/:workflowName/task/:taskID/artifact/:name", DownloadWorkflowV4TaskArtifact)
 		taskV4.POST("/trigger", CreateWorkflowTaskV4ByBuildInTrigger)
 	}
 
@@ -19,6 +19,7 @@ package handler
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"strconv"
 
@@ -51,6 +52,13 @@ type ApproveRequest struct {
 	Comment      string `json:"comment"`
 }
 
+type ManualGateRequest struct {
+	WorkflowName string                `json:"workflow_name"`
+	JobName      string                `json:"job_name"`
+	TaskID       int64                 `json:"task_id"`
+	Params       []*commonmodels.Param `json:"params"`
+}
+
 func CreateWorkflowTaskV4(c *gin.Context) {
 	ctx := internalhandler.NewContext(c)
 	defer func() { internalhandler.JSONResponse(c, ctx) }()
@@ -130,6 +138,19 @@ func CancelWorkflowTaskV4(c *gin.Context) {
 	ctx.Err = workflow.CancelWorkflowTaskV4(ctx.UserName, c.Param("workflowName"), taskID, ctx.Logger)
 }
 
+func RetryWorkflowTaskV4(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	taskID, err := strconv.ParseInt(c.Param("taskID"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+	internalhandler.InsertOperationLog(c, ctx.UserName, c.Query("projectName"), "重试", "自定义工作流任务", c.Param("workflowName"), "", ctx.Logger)
+	ctx.Resp, ctx.Err = workflow.RetryWorkflowTaskV4(ctx.UserName, ctx.UserID, c.Param("workflowName"), taskID, ctx.Logger)
+}
+
 func CloneWorkflowTaskV4(c *gin.Context) {
 	ctx := internalhandler.NewContext(c)
 	defer func() { internalhandler.JSONResponse(c, ctx) }()
@@ -166,6 +187,44 @@ func ApproveStage(c *gin.Context) {
 	ctx.Err = workflow.ApproveStage(args.WorkflowName, args.StageName, ctx.UserName, ctx.UserID, args.Comment, args.TaskID, args.Approve, ctx.Logger)
 }
 
+func PauseWorkflowTaskV4(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	taskID, err := strconv.ParseInt(c.Param("taskID"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+	internalhandler.InsertOperationLog(c, ctx.UserName, c.Query("projectName"), "暂停", "自定义工作流任务", c.Param("workflowName"), "", ctx.Logger)
+	ctx.Err = workflow.PauseWorkflowTaskV4(ctx.UserName, c.Param("workflowName"), taskID, ctx.Logger)
+}
+
+func ResumeWorkflowTaskV4(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	taskID, err := strconv.ParseInt(c.Param("taskID"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+	internalhandler.InsertOperationLog(c, ctx.UserName, c.Query("projectName"), "恢复", "自定义工作流任务", c.Param("workflowName"), "", ctx.Logger)
+	ctx.Err = workflow.ResumeWorkflowTaskV4(ctx.UserName, c.Param("workflowName"), taskID, ctx.Logger)
+}
+
+func SubmitManualGate(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	args := &ManualGateRequest{}
+	if err := c.ShouldBindJSON(args); err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc(err.Error())
+		return
+	}
+	ctx.Err = workflow.SubmitManualGate(args.WorkflowName, args.JobName, ctx.UserName, ctx.UserID, args.TaskID, args.Params, ctx.Logger)
+}
+
 func GetWorkflowV4ArtifactFileContent(c *gin.Context) {
 	ctx := internalhandler.NewContext(c)
 	defer func() { internalhandler.JSONResponse(c, ctx) }()
@@ -185,3 +244,37 @@ func GetWorkflowV4ArtifactFileContent(c *gin.Context) {
 
 	c.Data(200, "application/octet-stream", resp)
 }
+
+func ListWorkflowV4TaskArtifacts(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	taskID, err := strconv.ParseInt(c.Param("taskID"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+	ctx.Resp, ctx.Err = workflow.ListWorkflowV4TaskArtifacts(c.Param("workflowName"), taskID, ctx.Logger)
+}
+
+func DownloadWorkflowV4TaskArtifact(c *gin.Context) {
+	ctx := internalhandler.NewContext(c)
+	defer func() { internalhandler.JSONResponse(c, ctx) }()
+
+	taskID, err := strconv.ParseInt(c.Param("taskID"), 10, 64)
+	if err != nil {
+		ctx.Err = e.ErrInvalidParam.AddDesc("invalid task id")
+		return
+	}
+
+	reader, size, err := workflow.DownloadWorkflowV4TaskArtifact(c.Param("workflowName"), c.Param("name"), taskID, ctx.Logger)
+	if err != nil {
+		ctx.Err = err
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(200, size, "application/octet-stream", reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="%s.tar.gz"`, c.Param("name")),
+	})
+}
@@ -110,6 +110,9 @@ func ProcessGiteeHook(payload []byte, req *http.Request, requestID string, log *
 			}
 		}()
 	case *gitee.PullRequestEvent:
+		if event.Action == "close" || event.Action == "merge" {
+			deletePreviewEnvsOfPR(event.PullRequest.Base.Repo.FullName, event.PullRequest.Number, log)
+		}
 		if event.Action != "open" && event.Action != "update" {
 			return fmt.Errorf("action %s is skipped", event.Action)
 		}
@@ -72,6 +72,7 @@ func (gpem *giteePushEventMatcherForWorkflowV4) Match(hookRepo *commonmodels.Mai
 			changedFiles = append(changedFiles, commit.Removed...)
 			changedFiles = append(changedFiles, commit.Modified...)
 		}
+		hookRepo.ChangedFiles = changedFiles
 		return MatchChanges(hookRepo, changedFiles), nil
 	}
 
@@ -124,6 +125,7 @@ func (gmem *giteeMergeEventMatcherForWorkflowV4) Match(hookRepo *commonmodels.Ma
 			}
 			gmem.log.Debugf("succeed to get %d changes in merge event", len(changedFiles))
 
+			hookRepo.ChangedFiles = changedFiles
 			return MatchChanges(hookRepo, changedFiles), nil
 		}
 	}
@@ -282,6 +284,10 @@ func TriggerWorkflowV4ByGiteeEvent(event interface{}, baseURI, requestID string,
 					MergeRequestID: mergeRequestID,
 					CommitID:       commitID,
 					EventType:      eventType,
+					ChangedFiles:   item.MainRepo.ChangedFiles,
+				}
+				for _, label := range ev.PullRequest.Labels {
+					hookPayload.Labels = append(hookPayload.Labels, label.Name)
 				}
 			case *gitee.PushEvent:
 				eventType = EventTypePush
@@ -291,14 +297,15 @@ func TriggerWorkflowV4ByGiteeEvent(event interface{}, baseURI, requestID string,
 				autoCancelOpt.CommitID = commitID
 				autoCancelOpt.Ref = ref
 				hookPayload = &commonmodels.HookPayload{
-					Owner:      eventRepo.RepoOwner,
-					Repo:       eventRepo.RepoName,
-					CodehostID: item.MainRepo.CodehostID,
-					Branch:     eventRepo.Branch,
-					Ref:        ref,
-					IsPr:       false,
-					CommitID:   commitID,
-					EventType:  eventType,
+					Owner:        eventRepo.RepoOwner,
+					Repo:         eventRepo.RepoName,
+					CodehostID:   item.MainRepo.CodehostID,
+					Branch:       eventRepo.Branch,
+					Ref:          ref,
+					IsPr:         false,
+					CommitID:     commitID,
+					EventType:    eventType,
+					ChangedFiles: item.MainRepo.ChangedFiles,
 				}
 			case *gitee.TagPushEvent:
 				eventType = EventTypeTag
@@ -336,6 +343,14 @@ func TriggerWorkflowV4ByGiteeEvent(event interface{}, baseURI, requestID string,
 				workflow.NotificationID = notification.ID.Hex()
 			}
 			workflow.HookPayload = hookPayload
+			if item.PreviewEnv != nil && item.PreviewEnv.Enabled && eventRepo.PR > 0 {
+				if err := deployToPreviewEnv(workflow, item, eventRepo, baseURI, requestID, false, log); err != nil {
+					errMsg := fmt.Sprintf("failed to prepare preview env for workflow %s: %v", workflow.Name, err)
+					log.Error(errMsg)
+					mErr = multierror.Append(mErr, fmt.Errorf(errMsg))
+				}
+				continue
+			}
 			if resp, err := workflowservice.CreateWorkflowTaskV4(&workflowservice.CreateWorkflowTaskV4Args{
 				Name: setting.WebhookTaskCreator,
 			}, workflow, log); err != nil {
@@ -537,6 +537,10 @@ func ProcessGithubWebHookForWorkflowV4(payload []byte, req *http.Request, reques
 
 	switch et := event.(type) {
 	case *github.PullRequestEvent:
+		if *et.Action == "closed" {
+			deletePreviewEnvsOfPR(et.GetPullRequest().GetBase().GetRepo().GetFullName(), et.GetPullRequest().GetNumber(), log)
+			return nil
+		}
 		if *et.Action != "opened" && *et.Action != "synchronize" {
 			return nil
 		}
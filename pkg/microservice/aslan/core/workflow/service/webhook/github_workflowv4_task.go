@@ -75,6 +75,7 @@ func (gpem *githubPushEventMatcheForWorkflowV4) Match(hookRepo *commonmodels.Mai
 		changedFiles = append(changedFiles, commit.Removed...)
 		changedFiles = append(changedFiles, commit.Modified...)
 	}
+	hookRepo.ChangedFiles = changedFiles
 	return MatchChanges(hookRepo, changedFiles), nil
 }
 
@@ -129,6 +130,7 @@ func (gmem *githubMergeEventMatcherForWorkflowV4) Match(hookRepo *commonmodels.M
 		}
 		gmem.log.Debugf("succeed to get %d changes in merge event", len(changedFiles))
 
+		hookRepo.ChangedFiles = changedFiles
 		return MatchChanges(hookRepo, changedFiles), nil
 	}
 
@@ -287,6 +289,10 @@ func TriggerWorkflowV4ByGithubEvent(event interface{}, baseURI, deliveryID, requ
 					MergeRequestID: mergeRequestID,
 					CommitID:       commitID,
 					EventType:      eventType,
+					ChangedFiles:   item.MainRepo.ChangedFiles,
+				}
+				for _, label := range ev.PullRequest.Labels {
+					hookPayload.Labels = append(hookPayload.Labels, label.GetName())
 				}
 			case *github.PushEvent:
 				if ev.GetRef() != "" && ev.GetHeadCommit().GetID() != "" {
@@ -297,20 +303,22 @@ func TriggerWorkflowV4ByGithubEvent(event interface{}, baseURI, deliveryID, requ
 					autoCancelOpt.Ref = ref
 					autoCancelOpt.CommitID = commitID
 					hookPayload = &commonmodels.HookPayload{
-						Owner:      *ev.Repo.Owner.Login,
-						Repo:       *ev.Repo.Name,
-						Ref:        ref,
-						IsPr:       false,
-						CodehostID: item.MainRepo.CodehostID,
-						DeliveryID: deliveryID,
-						CommitID:   commitID,
-						EventType:  eventType,
+						Owner:        *ev.Repo.Owner.Login,
+						Repo:         *ev.Repo.Name,
+						Ref:          ref,
+						IsPr:         false,
+						CodehostID:   item.MainRepo.CodehostID,
+						DeliveryID:   deliveryID,
+						CommitID:     commitID,
+						EventType:    eventType,
+						ChangedFiles: item.MainRepo.ChangedFiles,
 					}
 				}
 			case *github.CreateEvent:
 				eventType = EventTypeTag
 				hookPayload = &commonmodels.HookPayload{
 					EventType: eventType,
+					Tag:       item.MainRepo.Tag,
 				}
 			}
 			if autoCancelOpt.Type != "" {
@@ -336,6 +344,14 @@ func TriggerWorkflowV4ByGithubEvent(event interface{}, baseURI, deliveryID, requ
 				continue
 			}
 			workflow.HookPayload = hookPayload
+			if item.PreviewEnv != nil && item.PreviewEnv.Enabled && eventRepo.PR > 0 {
+				if err := deployToPreviewEnv(workflow, item, eventRepo, baseURI, requestID, true, log); err != nil {
+					errMsg := fmt.Sprintf("failed to prepare preview env for workflow %s: %v", workflow.Name, err)
+					log.Error(errMsg)
+					mErr = multierror.Append(mErr, fmt.Errorf(errMsg))
+				}
+				continue
+			}
 			if resp, err := workflowservice.CreateWorkflowTaskV4(&workflowservice.CreateWorkflowTaskV4Args{
 				Name: setting.WebhookTaskCreator,
 			}, workflow, log); err != nil {
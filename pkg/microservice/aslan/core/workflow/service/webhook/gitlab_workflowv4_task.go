@@ -88,6 +88,7 @@ func (gmem *gitlabMergeEventMatcherForWorkflowV4) Match(hookRepo *commonmodels.M
 			return false, err
 		}
 		gmem.log.Debugf("succeed to get %d changes in merge event", len(changedFiles))
+		hookRepo.ChangedFiles = changedFiles
 		if gmem.isYaml {
 			serviceChangeds := ServicesMatchChangesFiles(gmem.trigger.Rules.MatchFolders, changedFiles)
 			gmem.yamlServiceChanged = serviceChangeds
@@ -203,6 +204,7 @@ func (gpem *gitlabPushEventMatcherForWorkflowV4) Match(hookRepo *commonmodels.Ma
 		changedFiles = append(changedFiles, diff.NewPath)
 		changedFiles = append(changedFiles, diff.OldPath)
 	}
+	hookRepo.ChangedFiles = changedFiles
 	if gpem.isYaml {
 		serviceChangeds := ServicesMatchChangesFiles(gpem.trigger.Rules.MatchFolders, changedFiles)
 		gpem.yamlServiceChanged = serviceChangeds
@@ -292,6 +294,10 @@ func TriggerWorkflowV4ByGitlabEvent(event interface{}, baseURI, requestID string
 		return fmt.Errorf(errMsg)
 	}
 
+	if ev, ok := event.(*gitlab.MergeEvent); ok && (ev.ObjectAttributes.State == "closed" || ev.ObjectAttributes.State == "merged") {
+		deletePreviewEnvsOfPR(ev.ObjectAttributes.Target.PathWithNamespace, ev.ObjectAttributes.IID, log)
+	}
+
 	mErr := &multierror.Error{}
 	diffSrv := func(mergeEvent *gitlab.MergeEvent, codehostId int) ([]string, error) {
 		return findChangedFilesOfMergeRequest(mergeEvent, codehostId)
@@ -373,6 +379,10 @@ func TriggerWorkflowV4ByGitlabEvent(event interface{}, baseURI, requestID string
 					CommitID:       commitID,
 					CodehostID:     eventRepo.CodehostID,
 					EventType:      eventType,
+					ChangedFiles:   item.MainRepo.ChangedFiles,
+				}
+				for _, label := range ev.Labels {
+					hookPayload.Labels = append(hookPayload.Labels, label.Name)
 				}
 			case *gitlab.PushEvent:
 				eventType = EventTypePush
@@ -382,19 +392,21 @@ func TriggerWorkflowV4ByGitlabEvent(event interface{}, baseURI, requestID string
 				autoCancelOpt.Ref = ref
 				autoCancelOpt.CommitID = commitID
 				hookPayload = &commonmodels.HookPayload{
-					Owner:      eventRepo.RepoOwner,
-					Repo:       eventRepo.RepoName,
-					Branch:     eventRepo.Branch,
-					Ref:        ref,
-					IsPr:       false,
-					CommitID:   commitID,
-					CodehostID: eventRepo.CodehostID,
-					EventType:  eventType,
+					Owner:        eventRepo.RepoOwner,
+					Repo:         eventRepo.RepoName,
+					Branch:       eventRepo.Branch,
+					Ref:          ref,
+					IsPr:         false,
+					CommitID:     commitID,
+					CodehostID:   eventRepo.CodehostID,
+					EventType:    eventType,
+					ChangedFiles: item.MainRepo.ChangedFiles,
 				}
 			case *gitlab.TagEvent:
 				eventType = EventTypeTag
 				hookPayload = &commonmodels.HookPayload{
 					EventType: eventType,
+					Tag:       item.MainRepo.Tag,
 				}
 			}
 			if autoCancelOpt.Type != "" {
@@ -426,6 +438,14 @@ func TriggerWorkflowV4ByGitlabEvent(event interface{}, baseURI, requestID string
 				workflow.NotificationID = notification.ID.Hex()
 			}
 			workflow.HookPayload = hookPayload
+			if item.PreviewEnv != nil && item.PreviewEnv.Enabled && eventRepo.PR > 0 {
+				if err := deployToPreviewEnv(workflow, item, eventRepo, baseURI, requestID, false, log); err != nil {
+					errMsg := fmt.Sprintf("failed to prepare preview env for workflow %s: %v", workflow.Name, err)
+					log.Error(errMsg)
+					mErr = multierror.Append(mErr, fmt.Errorf(errMsg))
+				}
+				continue
+			}
 			if resp, err := workflowservice.CreateWorkflowTaskV4(&workflowservice.CreateWorkflowTaskV4Args{
 				Name: setting.WebhookTaskCreator,
 			}, workflow, log); err != nil {
@@ -0,0 +1,156 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/scmnotify"
+	environmentservice "github.com/koderover/zadig/pkg/microservice/aslan/core/environment/service"
+	workflowservice "github.com/koderover/zadig/pkg/microservice/aslan/core/workflow/service/workflow"
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+// deployToPreviewEnv makes the workflow triggered by the pull request only build the services from the repo of the event,
+// and deploy them into the preview environment of the pull request instead of the configured one.
+// The environment may take minutes to be ready, so it is created in the background and the task is created once it is ready.
+func deployToPreviewEnv(workflow *commonmodels.WorkflowV4, hook *commonmodels.WorkflowV4Hook, eventRepo *types.Repository, baseURI, requestID string, gitCheck bool, log *zap.SugaredLogger) error {
+	// the workflow is shared by the other hooks of the event, work on a copy of it
+	previewWorkflow := &commonmodels.WorkflowV4{}
+	if err := commonmodels.IToi(workflow, previewWorkflow); err != nil {
+		return err
+	}
+	services, err := filterPreviewBuilds(previewWorkflow, eventRepo)
+	if err != nil {
+		return err
+	}
+
+	args := &environmentservice.EnsurePreviewEnvArgs{
+		ProductName:  previewWorkflow.Project,
+		WorkflowName: previewWorkflow.Name,
+		HookName:     hook.Name,
+		Setting:      hook.PreviewEnv,
+		MainRepo:     hook.MainRepo,
+		PR:           eventRepo.PR,
+		Services:     services,
+		BaseURI:      baseURI,
+	}
+	go func() {
+		preview, err := environmentservice.EnsurePreviewEnv(args, requestID, log)
+		if err != nil {
+			log.Errorf("failed to prepare preview env for workflow %s: %s", previewWorkflow.Name, err)
+			return
+		}
+		if err := setPreviewDeployEnv(previewWorkflow, preview.EnvName, services); err != nil {
+			log.Errorf("failed to deploy workflow %s to preview env %s: %s", previewWorkflow.Name, preview.EnvName, err)
+			return
+		}
+		resp, err := workflowservice.CreateWorkflowTaskV4(&workflowservice.CreateWorkflowTaskV4Args{
+			Name: setting.WebhookTaskCreator,
+		}, previewWorkflow, log)
+		if err != nil {
+			log.Errorf("failed to create workflow task for preview env %s: %s", preview.EnvName, err)
+			return
+		}
+		if gitCheck {
+			if err := scmnotify.NewService().CreateGitCheckForWorkflowV4(previewWorkflow, resp.TaskID, log); err != nil {
+				log.Warnf("Failed to create github check status for custom workflow %s, taskID: %d the error is: %s", previewWorkflow.Name, resp.TaskID, err)
+			}
+		}
+		log.Infof("succeed to create task %v", resp)
+	}()
+	return nil
+}
+
+// setPreviewDeployEnv makes the deploy jobs of the workflow deploy the services into the preview environment.
+func setPreviewDeployEnv(workflow *commonmodels.WorkflowV4, envName string, services []string) error {
+	serviceSet := sets.NewString(services...)
+	for _, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			if job.JobType != config.JobZadigDeploy {
+				continue
+			}
+			spec := &commonmodels.ZadigDeployJobSpec{}
+			if err := commonmodels.IToi(job.Spec, spec); err != nil {
+				return err
+			}
+			spec.Env = envName
+			if spec.Source == config.SourceRuntime {
+				serviceAndImages := make([]*commonmodels.ServiceAndImage, 0)
+				for _, svc := range spec.ServiceAndImages {
+					if serviceSet.Has(svc.ServiceName) {
+						serviceAndImages = append(serviceAndImages, svc)
+					}
+				}
+				spec.ServiceAndImages = serviceAndImages
+			}
+			job.Spec = spec
+		}
+	}
+	return nil
+}
+
+// filterPreviewBuilds keeps the builds using the repo of the event and returns their services.
+func filterPreviewBuilds(workflow *commonmodels.WorkflowV4, eventRepo *types.Repository) ([]string, error) {
+	services := sets.NewString()
+	for _, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			if job.JobType != config.JobZadigBuild {
+				continue
+			}
+			spec := &commonmodels.ZadigBuildJobSpec{}
+			if err := commonmodels.IToi(job.Spec, spec); err != nil {
+				return nil, err
+			}
+			builds := make([]*commonmodels.ServiceAndBuild, 0)
+			for _, build := range spec.ServiceAndBuilds {
+				for _, repo := range build.Repos {
+					if repo.Source == eventRepo.Source && repo.GetRepoNamespace() == eventRepo.GetRepoNamespace() && repo.RepoName == eventRepo.RepoName {
+						builds = append(builds, build)
+						services.Insert(build.ServiceName)
+						break
+					}
+				}
+			}
+			spec.ServiceAndBuilds = builds
+			job.Spec = spec
+		}
+	}
+	if services.Len() == 0 {
+		return nil, fmt.Errorf("no service in workflow %s is built from %s/%s", workflow.Name, eventRepo.GetRepoNamespace(), eventRepo.RepoName)
+	}
+	return services.List(), nil
+}
+
+// deletePreviewEnvsOfPR deletes the preview environments of the closed or merged pull request,
+// fullName is the path of the repo with namespace.
+func deletePreviewEnvsOfPR(fullName string, pr int, log *zap.SugaredLogger) {
+	idx := strings.LastIndex(fullName, "/")
+	if idx < 0 {
+		return
+	}
+	if err := environmentservice.DeletePreviewEnvsOfPR(fullName[:idx], fullName[idx+1:], pr, log); err != nil {
+		log.Errorf("failed to delete preview envs of %s#%d: %s", fullName, pr, err)
+	}
+}
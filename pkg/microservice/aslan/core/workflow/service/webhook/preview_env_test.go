@@ -0,0 +1,76 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types"
+)
+
+var _ = Describe("Testing preview env", func() {
+
+	Describe("test filterPreviewBuilds", func() {
+
+		newWorkflow := func() *commonmodels.WorkflowV4 {
+			return &commonmodels.WorkflowV4{
+				Name: "demo-workflow",
+				Stages: []*commonmodels.WorkflowStage{
+					{
+						Jobs: []*commonmodels.Job{
+							{
+								Name:    "build",
+								JobType: config.JobZadigBuild,
+								Spec: &commonmodels.ZadigBuildJobSpec{
+									ServiceAndBuilds: []*commonmodels.ServiceAndBuild{
+										{ServiceName: "web", ServiceModule: "web", Repos: []*types.Repository{{Source: "github", RepoOwner: "koderover", RepoName: "web"}}},
+										{ServiceName: "api", ServiceModule: "api", Repos: []*types.Repository{{Source: "github", RepoOwner: "koderover", RepoName: "api"}}},
+										{ServiceName: "worker", ServiceModule: "worker", Repos: []*types.Repository{
+											{Source: "github", RepoOwner: "koderover", RepoName: "lib"},
+											{Source: "github", RepoOwner: "koderover", RepoName: "web"},
+										}},
+										{ServiceName: "fork", ServiceModule: "fork", Repos: []*types.Repository{{Source: "github", RepoOwner: "someone", RepoName: "web"}}},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+		}
+
+		It("keeps the builds using the repo of the event", func() {
+			workflow := newWorkflow()
+			services, err := filterPreviewBuilds(workflow, &types.Repository{Source: "github", RepoOwner: "koderover", RepoName: "web", PR: 1})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(services).To(Equal([]string{"web", "worker"}))
+
+			spec := workflow.Stages[0].Jobs[0].Spec.(*commonmodels.ZadigBuildJobSpec)
+			Expect(spec.ServiceAndBuilds).To(HaveLen(2))
+			Expect(spec.ServiceAndBuilds[0].ServiceName).To(Equal("web"))
+			Expect(spec.ServiceAndBuilds[1].ServiceName).To(Equal("worker"))
+		})
+
+		It("returns error if no build uses the repo of the event", func() {
+			_, err := filterPreviewBuilds(newWorkflow(), &types.Repository{Source: "gitlab", RepoOwner: "koderover", RepoName: "web", PR: 1})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -22,25 +22,32 @@ import (
 	"fmt"
 	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/util/expression"
 )
 
 const (
-	OutputNameRegexString = "^[a-zA-Z0-9_]{1,64}$"
+	OutputNameRegexString     = "^[a-zA-Z0-9_]{1,64}$"
+	MatrixAxisNameRegexString = "^[a-zA-Z_][a-zA-Z0-9_]{0,63}$"
+	MaxMatrixCells            = 64
 )
 
 var (
-	OutputNameRegex = regexp.MustCompile(OutputNameRegexString)
+	OutputNameRegex     = regexp.MustCompile(OutputNameRegexString)
+	MatrixAxisNameRegex = regexp.MustCompile(MatrixAxisNameRegexString)
+	matrixSuffixRegex   = regexp.MustCompile(`[^a-z0-9.-]`)
 )
 
 type JobCtl interface {
@@ -96,6 +103,8 @@ func InitJobCtl(job *commonmodels.Job, workflow *commonmodels.WorkflowV4) (JobCt
 		resp = &ApolloJob{job: job, workflow: workflow}
 	case config.JobMeegoTransition:
 		resp = &MeegoTransitionJob{job: job, workflow: workflow}
+	case config.JobManualGate:
+		resp = &ManualGateJob{job: job, workflow: workflow}
 	default:
 		return resp, fmt.Errorf("job type not found %s", job.JobType)
 	}
@@ -136,7 +145,14 @@ func ToJobs(job *commonmodels.Job, workflow *commonmodels.WorkflowV4, taskID int
 	if err != nil {
 		return []*commonmodels.JobTask{}, warpJobError(job.Name, err)
 	}
-	return jobCtl.ToJobs(taskID)
+	jobTasks, err := jobCtl.ToJobs(taskID)
+	if err != nil {
+		return jobTasks, err
+	}
+	for _, jobTask := range jobTasks {
+		jobTask.When = job.When
+	}
+	return jobTasks, nil
 }
 
 func LintJob(job *commonmodels.Job, workflow *commonmodels.WorkflowV4) error {
@@ -144,6 +160,11 @@ func LintJob(job *commonmodels.Job, workflow *commonmodels.WorkflowV4) error {
 	if err != nil {
 		return warpJobError(job.Name, err)
 	}
+	if job.When != "" {
+		if _, err := expression.Parse(job.When); err != nil {
+			return warpJobError(job.Name, err)
+		}
+	}
 	return jobCtl.LintJob()
 }
 
@@ -212,6 +233,10 @@ func GetWorkflowOutputs(workflow *commonmodels.WorkflowV4, currentJobName string
 				jobCtl := &PluginJob{job: job, workflow: workflow}
 				resp = append(resp, jobCtl.GetOutPuts(log)...)
 			}
+			if job.JobType == config.JobManualGate {
+				jobCtl := &ManualGateJob{job: job, workflow: workflow}
+				resp = append(resp, jobCtl.GetOutPuts(log)...)
+			}
 		}
 	}
 	return resp
@@ -425,6 +450,275 @@ func getJobRankMap(stages []*commonmodels.WorkflowStage) map[string]int {
 	return resp
 }
 
+// UseJobDependency returns true if any job in the workflow declares depends_on,
+// the jobs of such workflow are scheduled as a DAG instead of stage by stage.
+func UseJobDependency(stages []*commonmodels.WorkflowStage) bool {
+	for _, stage := range stages {
+		for _, job := range stage.Jobs {
+			if len(job.DependsOn) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetJobDependencies returns the names of the jobs each job waits for.
+// a job without depends_on waits for the previous job in a serial stage, or all the jobs in the previous stage.
+func GetJobDependencies(stages []*commonmodels.WorkflowStage) map[string][]string {
+	resp := make(map[string][]string)
+	prevStageJobs := []string{}
+	for _, stage := range stages {
+		stageJobs := []string{}
+		for i, job := range stage.Jobs {
+			switch {
+			case len(job.DependsOn) > 0:
+				resp[job.Name] = job.DependsOn
+			case !stage.Parallel && i > 0:
+				resp[job.Name] = []string{stage.Jobs[i-1].Name}
+			default:
+				resp[job.Name] = prevStageJobs
+			}
+			stageJobs = append(stageJobs, job.Name)
+		}
+		if len(stageJobs) > 0 {
+			prevStageJobs = stageJobs
+		}
+	}
+	return resp
+}
+
+// LintJobDependencies checks that depends_on refers to existing jobs and the dependencies have no cycle.
+// a job can not depend on jobs after an approval stage, since they will not start until the approval passed.
+func LintJobDependencies(stages []*commonmodels.WorkflowStage) error {
+	jobSegmentMap := make(map[string]int)
+	segment := 0
+	for i, stage := range stages {
+		if i > 0 && stage.Approval != nil && stage.Approval.Enabled {
+			segment++
+		}
+		for _, job := range stage.Jobs {
+			jobSegmentMap[job.Name] = segment
+		}
+	}
+	for _, stage := range stages {
+		for _, job := range stage.Jobs {
+			for _, dependency := range job.DependsOn {
+				if dependency == job.Name {
+					return fmt.Errorf("job %s can not depend on itself", job.Name)
+				}
+				dependencySegment, ok := jobSegmentMap[dependency]
+				if !ok {
+					return fmt.Errorf("job %s depends on job %s which does not exist", job.Name, dependency)
+				}
+				if dependencySegment > jobSegmentMap[job.Name] {
+					return fmt.Errorf("job %s can not depend on job %s which waits for a later approval", job.Name, dependency)
+				}
+			}
+		}
+	}
+	return checkDependencyCycle(GetJobDependencies(stages))
+}
+
+func checkDependencyCycle(dependencies map[string][]string) error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := make(map[string]int, len(dependencies))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch states[name] {
+		case visiting:
+			for i, jobName := range path {
+				if jobName == name {
+					path = path[i:]
+					break
+				}
+			}
+			return fmt.Errorf("circular dependency found: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		states[name] = visiting
+		for _, dependency := range dependencies[name] {
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+		states[name] = visited
+		return nil
+	}
+
+	names := make([]string, 0, len(dependencies))
+	for name := range dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name, []string{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetJobTaskDependencies converts the job dependencies to the keys of the job tasks generated from them.
+// a dependency that is skipped or generates no job task is replaced by its own dependencies.
+func SetJobTaskDependencies(stages []*commonmodels.WorkflowStage, jobTaskMap map[string][]*commonmodels.JobTask) {
+	dependencies := GetJobDependencies(stages)
+	var resolve func(name string, resolved map[string]bool) []string
+	resolve = func(name string, resolved map[string]bool) []string {
+		resp := []string{}
+		if resolved[name] {
+			return resp
+		}
+		resolved[name] = true
+		if jobTasks := jobTaskMap[name]; len(jobTasks) > 0 {
+			for _, jobTask := range jobTasks {
+				resp = append(resp, jobTask.Key)
+			}
+			return resp
+		}
+		for _, dependency := range dependencies[name] {
+			resp = append(resp, resolve(dependency, resolved)...)
+		}
+		return resp
+	}
+
+	for _, stage := range stages {
+		for _, job := range stage.Jobs {
+			jobTasks := jobTaskMap[job.Name]
+			if len(jobTasks) == 0 {
+				continue
+			}
+			keys := []string{}
+			resolved := make(map[string]bool)
+			for _, dependency := range dependencies[job.Name] {
+				keys = append(keys, resolve(dependency, resolved)...)
+			}
+			for i, jobTask := range jobTasks {
+				// job tasks generated from the same job in a serial stage still run one by one.
+				if i > 0 && !stage.Parallel {
+					jobTask.DependsOn = []string{jobTasks[i-1].Key}
+					continue
+				}
+				jobTask.DependsOn = keys
+			}
+		}
+	}
+}
+
+// matrixCell is one combination of the matrix axis values, set as env vars of the job.
+type matrixCell []*commonmodels.KeyVal
+
+// getMatrixCells returns every combination of the matrix axis values,
+// a job without matrix has one empty cell.
+func getMatrixCells(matrix *commonmodels.JobMatrix) []matrixCell {
+	cells := []matrixCell{{}}
+	if matrix == nil {
+		return cells
+	}
+	for _, axis := range matrix.Axes {
+		next := []matrixCell{}
+		for _, cell := range cells {
+			for _, value := range axis.Values {
+				newCell := append(matrixCell{}, cell...)
+				next = append(next, append(newCell, &commonmodels.KeyVal{Key: axis.Name, Value: value}))
+			}
+		}
+		cells = next
+	}
+	return cells
+}
+
+func (c matrixCell) values() []string {
+	resp := []string{}
+	for _, kv := range c {
+		resp = append(resp, kv.Value)
+	}
+	return resp
+}
+
+// nameSuffix is appended to the job task name and image tag to tell the cells apart.
+func (c matrixCell) nameSuffix() string {
+	if len(c) == 0 {
+		return ""
+	}
+	return "-" + matrixSuffixRegex.ReplaceAllString(strings.ToLower(strings.Join(c.values(), "-")), "-")
+}
+
+// getMatrixKey returns the key of the job task running the cell, the values are escaped
+// so that the key never contains a dot other than the separators and different cells never share a key.
+func getMatrixKey(key string, cell matrixCell) string {
+	resp := []string{key}
+	for _, value := range cell.values() {
+		resp = append(resp, escapeMatrixValue(value))
+	}
+	return strings.Join(resp, ".")
+}
+
+// escapeMatrixValue keeps letters, digits and "-", "_" is escaped as "__" and any other byte as "_xHH".
+func escapeMatrixValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+			b.WriteByte(c)
+		case c == '_':
+			b.WriteString("__")
+		default:
+			fmt.Fprintf(&b, "_x%02x", c)
+		}
+	}
+	return b.String()
+}
+
+func setMatrixInfo(jobTask *commonmodels.JobTask, key string, matrix *commonmodels.JobMatrix) {
+	if matrix == nil {
+		return
+	}
+	jobTask.MatrixKey = key
+	jobTask.FailFast = matrix.FailFast
+}
+
+func lintMatrix(matrix *commonmodels.JobMatrix) error {
+	if matrix == nil {
+		return nil
+	}
+	if len(matrix.Axes) == 0 {
+		return fmt.Errorf("matrix should have at least one axis")
+	}
+	cellCount := 1
+	axisNames := sets.NewString()
+	for _, axis := range matrix.Axes {
+		if !MatrixAxisNameRegex.MatchString(axis.Name) {
+			return fmt.Errorf("matrix axis name %s must match %s", axis.Name, MatrixAxisNameRegexString)
+		}
+		if axisNames.Has(axis.Name) {
+			return fmt.Errorf("duplicated matrix axis: %s", axis.Name)
+		}
+		axisNames.Insert(axis.Name)
+		if len(axis.Values) == 0 {
+			return fmt.Errorf("matrix axis %s has no value", axis.Name)
+		}
+		cellCount *= len(axis.Values)
+	}
+	if cellCount > MaxMatrixCells {
+		return fmt.Errorf("matrix expands to %d jobs, at most %d are allowed", cellCount, MaxMatrixCells)
+	}
+	// the name suffix is used in the job name and image tag, cells sharing it would overwrite each other.
+	suffixes := map[string]matrixCell{}
+	for _, cell := range getMatrixCells(matrix) {
+		if other, ok := suffixes[cell.nameSuffix()]; ok {
+			return fmt.Errorf("matrix values %v and %v produce the same job name, please use values different in lowercase letters, digits, '.' or '-'", other.values(), cell.values())
+		}
+		suffixes[cell.nameSuffix()] = cell
+	}
+	return nil
+}
+
 func getOutputKey(jobKey string, outputs []*commonmodels.Output) []string {
 	resp := []string{}
 	for _, output := range outputs {
@@ -447,6 +741,11 @@ func checkOutputNames(outputs []*commonmodels.Output) error {
 		if match := OutputNameRegex.MatchString(output.Name); !match {
 			return fmt.Errorf("output name must match %s", OutputNameRegexString)
 		}
+		switch output.Type {
+		case "", job.OutputTypeString, job.OutputTypeJSON, job.OutputTypeFile:
+		default:
+			return fmt.Errorf("output %s has invalid type: %s", output.Name, output.Type)
+		}
 	}
 	return nil
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package job
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path"
@@ -28,7 +29,9 @@ import (
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
 	templ "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service/template"
+	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/rsa"
 	"github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/types/job"
 	"github.com/koderover/zadig/pkg/types/step"
@@ -36,8 +39,9 @@ import (
 )
 
 const (
-	IMAGEKEY   = "IMAGE"
-	PKGFILEKEY = "PKG_FILE"
+	IMAGEKEY       = "IMAGE"
+	PKGFILEKEY     = "PKG_FILE"
+	IMAGEDIGESTKEY = "IMAGE_DIGEST"
 )
 
 type BuildJob struct {
@@ -174,8 +178,11 @@ func (j *BuildJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 		return resp, fmt.Errorf("find default s3 storage error: %v", err)
 	}
 
-	for _, build := range j.spec.ServiceAndBuilds {
-		imageTag := commonservice.ReleaseCandidate(build.Repos, taskID, j.workflow.Project, build.ServiceModule, "", build.ServiceModule, "image")
+	// every service module is built once for each combination of the matrix.
+	cells := getMatrixCells(j.spec.Matrix)
+	for index := 0; index < len(j.spec.ServiceAndBuilds)*len(cells); index++ {
+		build, cell := j.spec.ServiceAndBuilds[index/len(cells)], cells[index%len(cells)]
+		imageTag := commonservice.ReleaseCandidate(build.Repos, taskID, j.workflow.Project, build.ServiceModule, "", build.ServiceModule, "image") + cell.nameSuffix()
 
 		image := fmt.Sprintf("%s/%s", registry.RegAddr, imageTag)
 		if len(registry.Namespace) > 0 {
@@ -185,7 +192,7 @@ func (j *BuildJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 		image = strings.TrimPrefix(image, "http://")
 		image = strings.TrimPrefix(image, "https://")
 
-		build.Package = fmt.Sprintf("%s.tar.gz", commonservice.ReleaseCandidate(build.Repos, taskID, j.workflow.Project, build.ServiceModule, "", build.ServiceModule, "tar"))
+		build.Package = fmt.Sprintf("%s%s.tar.gz", commonservice.ReleaseCandidate(build.Repos, taskID, j.workflow.Project, build.ServiceModule, "", build.ServiceModule, "tar"), cell.nameSuffix())
 
 		buildInfo, err := commonrepo.NewBuildColl().Find(&commonrepo.BuildFindOption{Name: build.BuildName, ProductName: j.workflow.Project})
 		if err != nil {
@@ -203,20 +210,28 @@ func (j *BuildJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 			return resp, err
 		}
 		outputs := ensureBuildInOutputs(buildInfo.Outputs)
+		daemonless := useBuildKit(buildInfo.PostBuild)
+		if daemonless {
+			outputs = ensureImageDigestOutput(outputs)
+		}
 		jobTaskSpec := &commonmodels.JobTaskFreestyleSpec{}
+		buildKey := strings.Join([]string{j.job.Name, build.ServiceName, build.ServiceModule}, ".")
 		jobTask := &commonmodels.JobTask{
-			Name:    jobNameFormat(build.ServiceName + "-" + build.ServiceModule + "-" + j.job.Name),
-			Key:     strings.Join([]string{j.job.Name, build.ServiceName, build.ServiceModule}, "."),
+			Name:    jobNameFormat(build.ServiceName + "-" + build.ServiceModule + "-" + j.job.Name + cell.nameSuffix()),
+			Key:     getMatrixKey(buildKey, cell),
 			JobType: string(config.JobZadigBuild),
 			Spec:    jobTaskSpec,
 			Timeout: int64(buildInfo.Timeout),
 			Outputs: outputs,
 		}
+		setMatrixInfo(jobTask, buildKey, j.spec.Matrix)
 		jobTaskSpec.Properties = commonmodels.JobProperties{
 			Timeout:             int64(buildInfo.Timeout),
+			Retry:               j.spec.Retry,
+			RetryPolicy:         j.spec.RetryPolicy,
 			ResourceRequest:     buildInfo.PreBuild.ResReq,
 			ResReqSpec:          buildInfo.PreBuild.ResReqSpec,
-			CustomEnvs:          renderKeyVals(build.KeyVals, buildInfo.PreBuild.Envs),
+			CustomEnvs:          append(renderKeyVals(build.KeyVals, buildInfo.PreBuild.Envs), cell...),
 			ClusterID:           buildInfo.PreBuild.ClusterID,
 			BuildOS:             basicImage.Value,
 			ImageFrom:           buildInfo.PreBuild.ImageFrom,
@@ -244,8 +259,21 @@ func (j *BuildJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 			jobTaskSpec.Properties.Cache.NFSProperties.Subpath = renderEnv(jobTaskSpec.Properties.Cache.NFSProperties.Subpath, jobTaskSpec.Properties.Envs)
 		}
 
-		// for other job refer current latest image.
-		build.Image = job.GetJobOutputKey(jobTask.Key, "IMAGE")
+		// for other job refer current latest image, the first cell of the matrix is referred by Image.
+		// the digest of images built by buildkit is a separate IMAGE_DIGEST output, IMAGE is always repo:tag,
+		// the deploy jobs pin the image to ImageDigest when it is set.
+		cellImage := job.GetJobOutputKey(jobTask.Key, IMAGEKEY)
+		if index%len(cells) == 0 {
+			build.Image = cellImage
+			build.ImageDigest = ""
+			if daemonless {
+				build.ImageDigest = job.GetJobOutputKey(jobTask.Key, IMAGEDIGESTKEY)
+			}
+			build.MatrixImages = nil
+		}
+		if j.spec.Matrix != nil {
+			build.MatrixImages = append(build.MatrixImages, &commonmodels.KeyVal{Key: jobTask.Key, Value: cellImage})
+		}
 
 		// init tools install step
 		tools := []*step.Tool{}
@@ -293,6 +321,13 @@ func (j *BuildJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 					dockefileContent = dockerfileDetail.Content
 				}
 			}
+			dockerRegistry := &step.DockerRegistry{
+				DockerRegistryID: j.spec.DockerRegistryID,
+				Host:             registry.RegAddr,
+				UserName:         registry.AccessKey,
+				Password:         registry.SecretKey,
+				Namespace:        registry.Namespace,
+			}
 
 			dockerBuildStep := &commonmodels.StepTask{
 				Name:     build.ServiceName + "-docker-build",
@@ -306,16 +341,48 @@ func (j *BuildJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 					ImageReleaseTag:       imageTag,
 					BuildArgs:             buildInfo.PostBuild.DockerBuild.BuildArgs,
 					DockerTemplateContent: dockefileContent,
-					DockerRegistry: &step.DockerRegistry{
-						DockerRegistryID: j.spec.DockerRegistryID,
-						Host:             registry.RegAddr,
-						UserName:         registry.AccessKey,
-						Password:         registry.SecretKey,
-						Namespace:        registry.Namespace,
-					},
+					DockerRegistry:        dockerRegistry,
 				},
 			}
+			// buildkit builds and pushes the image without a docker daemon
+			if daemonless {
+				dockerBuildStep.Name = build.ServiceName + "-image-build"
+				dockerBuildStep.StepType = config.StepImageBuild
+				dockerBuildStep.Spec = step.StepImageBuildSpec{
+					Source:                buildInfo.PostBuild.DockerBuild.Source,
+					WorkDir:               buildInfo.PostBuild.DockerBuild.WorkDir,
+					DockerFile:            buildInfo.PostBuild.DockerBuild.DockerFile,
+					ImageName:             "$IMAGE",
+					BuildArgs:             buildInfo.PostBuild.DockerBuild.BuildArgs,
+					DockerTemplateContent: dockefileContent,
+					Platforms:             buildInfo.PostBuild.DockerBuild.Platforms,
+					EnableRegistryCache:   buildInfo.PostBuild.DockerBuild.EnableRegistryCache,
+					DigestOutput:          IMAGEDIGESTKEY,
+					DockerRegistry:        dockerRegistry,
+				}
+			}
 			jobTaskSpec.Steps = append(jobTaskSpec.Steps, dockerBuildStep)
+
+			// init attestation step
+			if attestation := j.spec.Attestation; attestation != nil && attestation.Enabled {
+				// the attestation is pushed with docker, which is not available with buildkit
+				if daemonless {
+					return resp, fmt.Errorf("build %s: attestation is not supported by the buildkit builder", build.BuildName)
+				}
+				attestationStep := &commonmodels.StepTask{
+					Name:     build.ServiceName + "-attestation",
+					JobName:  jobTask.Name,
+					StepType: config.StepAttestation,
+					Spec: step.StepAttestationSpec{
+						ImageName:      "$IMAGE",
+						SBOMFormat:     attestation.SBOMFormat,
+						SBOMSource:     attestation.SBOMSource,
+						SigningKeyID:   attestation.SigningKeyID,
+						DockerRegistry: dockerRegistry,
+					},
+				}
+				jobTaskSpec.Steps = append(jobTaskSpec.Steps, attestationStep)
+			}
 		}
 
 		// init archive step
@@ -537,6 +604,46 @@ func mergeRepos(templateRepos []*types.Repository, customRepos []*types.Reposito
 }
 
 func (j *BuildJob) LintJob() error {
+	j.spec = &commonmodels.ZadigBuildJobSpec{}
+	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	if err := lintAttestation(j.spec.Attestation); err != nil {
+		return err
+	}
+	return lintMatrix(j.spec.Matrix)
+}
+
+func lintAttestation(attestation *commonmodels.BuildAttestation) error {
+	if attestation == nil || !attestation.Enabled {
+		return nil
+	}
+	// the SBOM is generated from the image in spdx format by default
+	switch attestation.SBOMFormat {
+	case "", step.SBOMFormatSPDX, step.SBOMFormatCycloneDX:
+	default:
+		return fmt.Errorf("attestation: unsupported sbom format: %s", attestation.SBOMFormat)
+	}
+	switch attestation.SBOMSource {
+	case "", step.SBOMSourceImage, step.SBOMSourceWorkspace:
+	default:
+		return fmt.Errorf("attestation: unsupported sbom source: %s", attestation.SBOMSource)
+	}
+	if attestation.SigningKeyID == "" {
+		return fmt.Errorf("attestation: signing key is not set")
+	}
+	privateKey, err := commonrepo.NewPrivateKeyColl().Find(commonrepo.FindPrivateKeyOption{ID: attestation.SigningKeyID})
+	if err != nil {
+		return fmt.Errorf("attestation: signing key %s not found: %v", attestation.SigningKeyID, err)
+	}
+	// the private key is stored base64 encoded, make sure it can be used to sign before the workflow runs
+	key, err := base64.StdEncoding.DecodeString(privateKey.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("attestation: signing key %s is not base64 encoded: %v", attestation.SigningKeyID, err)
+	}
+	if _, err := rsa.PublicKeyOfPrivateKey(key); err != nil {
+		return fmt.Errorf("attestation: signing key %s is not a valid RSA private key: %v", attestation.SigningKeyID, err)
+	}
 	return nil
 }
 
@@ -546,6 +653,7 @@ func (j *BuildJob) GetOutPuts(log *zap.SugaredLogger) []string {
 	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
 		return resp
 	}
+	cells := getMatrixCells(j.spec.Matrix)
 	for _, build := range j.spec.ServiceAndBuilds {
 		jobKey := strings.Join([]string{j.job.Name, build.ServiceName, build.ServiceModule}, ".")
 		buildInfo, err := commonrepo.NewBuildColl().Find(&commonrepo.BuildFindOption{Name: build.BuildName})
@@ -553,16 +661,24 @@ func (j *BuildJob) GetOutPuts(log *zap.SugaredLogger) []string {
 			log.Errorf("found build %s failed, err: %s", build.BuildName, err)
 			continue
 		}
-		if buildInfo.TemplateID == "" {
-			resp = append(resp, getOutputKey(jobKey, ensureBuildInOutputs(buildInfo.Outputs))...)
-			continue
+		outputs := buildInfo.Outputs
+		postBuild := buildInfo.PostBuild
+		if buildInfo.TemplateID != "" {
+			buildTemplate, err := commonrepo.NewBuildTemplateColl().Find(&commonrepo.BuildTemplateQueryOption{ID: buildInfo.TemplateID})
+			if err != nil {
+				log.Errorf("found build template %s failed, err: %s", buildInfo.TemplateID, err)
+				continue
+			}
+			outputs = buildTemplate.Outputs
+			postBuild = buildTemplate.PostBuild
 		}
-		buildTemplate, err := commonrepo.NewBuildTemplateColl().Find(&commonrepo.BuildTemplateQueryOption{ID: buildInfo.TemplateID})
-		if err != nil {
-			log.Errorf("found build template %s failed, err: %s", buildInfo.TemplateID, err)
-			continue
+		outputs = ensureBuildInOutputs(outputs)
+		if useBuildKit(postBuild) {
+			outputs = ensureImageDigestOutput(outputs)
+		}
+		for _, cell := range cells {
+			resp = append(resp, getOutputKey(getMatrixKey(jobKey, cell), outputs)...)
 		}
-		resp = append(resp, getOutputKey(jobKey, ensureBuildInOutputs(buildTemplate.Outputs))...)
 	}
 	return resp
 }
@@ -584,3 +700,18 @@ func ensureBuildInOutputs(outputs []*commonmodels.Output) []*commonmodels.Output
 	}
 	return outputs
 }
+
+func ensureImageDigestOutput(outputs []*commonmodels.Output) []*commonmodels.Output {
+	for _, output := range outputs {
+		if output.Name == IMAGEDIGESTKEY {
+			return outputs
+		}
+	}
+	return append(outputs, &commonmodels.Output{
+		Name: IMAGEDIGESTKEY,
+	})
+}
+
+func useBuildKit(postBuild *commonmodels.PostBuild) bool {
+	return postBuild != nil && postBuild.DockerBuild != nil && postBuild.DockerBuild.Builder == setting.ImageBuilderBuildKit
+}
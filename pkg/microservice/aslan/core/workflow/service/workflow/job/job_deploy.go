@@ -146,6 +146,7 @@ func (j *DeployJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 							ServiceName:   build.ServiceName,
 							ServiceModule: build.ServiceModule,
 							Image:         build.Image,
+							ImageDigest:   build.ImageDigest,
 						})
 					}
 				}
@@ -179,6 +180,7 @@ func (j *DeployJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 				ServiceModule:      deploy.ServiceModule,
 				ClusterID:          product.ClusterID,
 				Image:              deploy.Image,
+				ImageDigest:        deploy.ImageDigest,
 			}
 			jobTask := &commonmodels.JobTask{
 				Name:    jobNameFormat(deploy.ServiceName + "-" + deploy.ServiceModule + "-" + j.job.Name),
@@ -225,6 +227,7 @@ func (j *DeployJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 				jobTaskSpec.ImageAndModules = append(jobTaskSpec.ImageAndModules, &commonmodels.ImageAndServiceModule{
 					ServiceModule: deploy.ServiceModule,
 					Image:         deploy.Image,
+					ImageDigest:   deploy.ImageDigest,
 				})
 			}
 			jobTask := &commonmodels.JobTask{
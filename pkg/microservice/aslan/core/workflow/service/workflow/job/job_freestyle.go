@@ -17,7 +17,10 @@ limitations under the License.
 package job
 
 import (
+	"encoding/json"
 	"fmt"
+	"path"
+	"strconv"
 	"strings"
 
 	configbase "github.com/koderover/zadig/pkg/config"
@@ -26,10 +29,13 @@ import (
 	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	commonservice "github.com/koderover/zadig/pkg/microservice/aslan/core/common/service"
 	"github.com/koderover/zadig/pkg/microservice/aslan/core/common/util"
+	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/types"
 	steptypes "github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util/expression"
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 type FreeStyleJob struct {
@@ -181,34 +187,213 @@ func (j *FreeStyleJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 		return resp, err
 	}
 	j.job.Spec = j.spec
-	jobTaskSpec := &commonmodels.JobTaskFreestyleSpec{
-		Properties: *j.spec.Properties,
-		Steps:      stepsToStepTasks(j.spec.Steps, j.spec.Outputs),
-	}
-	jobTask := &commonmodels.JobTask{
-		Name:    j.job.Name,
-		Key:     j.job.Name,
-		JobType: string(config.JobFreestyle),
-		Spec:    jobTaskSpec,
-		Timeout: j.spec.Properties.Timeout,
-		Outputs: j.spec.Outputs,
-	}
 	registries, err := commonservice.ListRegistryNamespaces("", true, logger)
 	if err != nil {
 		return resp, err
 	}
-	jobTaskSpec.Properties.Registries = registries
-	jobTaskSpec.Properties.ShareStorageDetails = getShareStorageDetail(j.workflow.ShareStorages, j.spec.Properties.ShareStorageInfo, j.workflow.Name, taskID)
+	basicImage, err := commonrepo.NewBasicImageColl().Find(j.spec.Properties.ImageID)
+	if err != nil {
+		return resp, fmt.Errorf("failed to find base image: %s,error :%v", j.spec.Properties.ImageID, err)
+	}
+
+	steps, outputs, err := expandStepTemplates(j.spec.Steps, j.spec.Outputs)
+	if err != nil {
+		return resp, err
+	}
+
+	for _, cell := range getMatrixCells(j.spec.Matrix) {
+		jobTaskSpec := &commonmodels.JobTaskFreestyleSpec{
+			Properties: *j.spec.Properties,
+			Steps:      stepsToStepTasks(steps, outputs),
+		}
+		jobTask := &commonmodels.JobTask{
+			Name:    jobNameFormat(j.job.Name + cell.nameSuffix()),
+			Key:     getMatrixKey(j.job.Name, cell),
+			JobType: string(config.JobFreestyle),
+			Spec:    jobTaskSpec,
+			Timeout: j.spec.Properties.Timeout,
+			Outputs: outputs,
+		}
+		setMatrixInfo(jobTask, j.job.Name, j.spec.Matrix)
+		jobTaskSpec.Properties.Registries = registries
+		jobTaskSpec.Properties.ShareStorageDetails = getShareStorageDetail(j.workflow.ShareStorages, j.spec.Properties.ShareStorageInfo, j.workflow.Name, taskID)
+		jobTaskSpec.Properties.BuildOS = basicImage.Value
+		// save user defined variables, matrix values are exposed as variables too.
+		jobTaskSpec.Properties.CustomEnvs = append(append([]*commonmodels.KeyVal{}, j.spec.Properties.Envs...), cell...)
+		jobTaskSpec.Properties.Envs = append(append([]*commonmodels.KeyVal{}, jobTaskSpec.Properties.CustomEnvs...), getfreestyleJobVariables(jobTaskSpec.Steps, taskID, j.workflow.Project, j.workflow.Name)...)
+		if err := setDependencyCacheStorage(jobTaskSpec); err != nil {
+			return resp, err
+		}
+		resp = append(resp, jobTask)
+	}
+	return resp, nil
+}
 
-	basicImage, err := commonrepo.NewBasicImageColl().Find(jobTaskSpec.Properties.ImageID)
+// setDependencyCacheStorage attaches the NFS cache of the cluster to the job when any cache step keeps its caches in NFS,
+// the caches are kept in the project dir of the volume, which is mounted separately from the cache of the job.
+func setDependencyCacheStorage(jobTaskSpec *commonmodels.JobTaskFreestyleSpec) error {
+	if !jobTaskSpec.UseNFSDependencyCache() {
+		return nil
+	}
+	clusterID := jobTaskSpec.Properties.ClusterID
+	if clusterID == "" {
+		clusterID = setting.LocalClusterID
+	}
+	clusterInfo, err := commonrepo.NewK8SClusterColl().Get(clusterID)
 	if err != nil {
-		return resp, fmt.Errorf("failed to find base image: %s,error :%v", jobTaskSpec.Properties.ImageID, err)
+		return fmt.Errorf("find cluster: %s error: %v", clusterID, err)
+	}
+	if clusterInfo.Cache.MediumType != types.NFSMedium {
+		return fmt.Errorf("cluster %s has no nfs cache configured, which is required by the nfs cache steps", clusterInfo.Name)
+	}
+	cache := clusterInfo.Cache
+	jobTaskSpec.Properties.DependencyCache = &cache
+	return nil
+}
+
+// expandStepTemplates replaces the template steps with the steps of their step templates, the inputs are rendered into
+// the specs of the expanded steps, and the outputs of the templates are added to the job outputs.
+func expandStepTemplates(steps []*commonmodels.Step, outputs []*commonmodels.Output) ([]*commonmodels.Step, []*commonmodels.Output, error) {
+	return expandStepTemplatesWith(steps, outputs, commonrepo.NewStepTemplateColl().Find)
+}
+
+// expandStepTemplatesWith expands the template steps with the templates returned by findTemplate,
+// an output of a template must not have the same name as any other output of the job.
+func expandStepTemplatesWith(steps []*commonmodels.Step, outputs []*commonmodels.Output, findTemplate func(name string, version int64) (*commonmodels.StepTemplate, error)) ([]*commonmodels.Step, []*commonmodels.Output, error) {
+	respSteps := make([]*commonmodels.Step, 0, len(steps))
+	respOutputs := append([]*commonmodels.Output{}, outputs...)
+	outputNames := sets.NewString()
+	for _, output := range outputs {
+		outputNames.Insert(output.Name)
+	}
+
+	for _, step := range steps {
+		if step.StepType != config.StepTemplate {
+			respSteps = append(respSteps, step)
+			continue
+		}
+		spec := &commonmodels.StepTemplateSpec{}
+		if err := commonmodels.IToi(step.Spec, spec); err != nil {
+			return nil, nil, fmt.Errorf("step %s: parse template step spec error: %v", step.Name, err)
+		}
+		template, err := findTemplate(spec.TemplateName, spec.Version)
+		if err != nil {
+			return nil, nil, fmt.Errorf("step %s: find step template %s of version %d error: %v", step.Name, spec.TemplateName, spec.Version, err)
+		}
+		inputs, err := getStepTemplateInputs(template.Inputs, spec.Inputs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("step %s: %v", step.Name, err)
+		}
+
+		for _, templateStep := range template.Steps {
+			expanded, err := renderTemplateStep(templateStep, inputs)
+			if err != nil {
+				return nil, nil, fmt.Errorf("step %s: render step %s of template %s error: %v", step.Name, templateStep.Name, template.Name, err)
+			}
+			expanded.Name = fmt.Sprintf("%s-%s", step.Name, templateStep.Name)
+			switch {
+			case step.When != "" && expanded.When != "":
+				expanded.When = fmt.Sprintf("(%s) && (%s)", step.When, expanded.When)
+			case step.When != "":
+				expanded.When = step.When
+			}
+			expanded.ContinueOnError = expanded.ContinueOnError || step.ContinueOnError
+			respSteps = append(respSteps, expanded)
+		}
+		for _, output := range template.Outputs {
+			if outputNames.Has(output.Name) {
+				return nil, nil, fmt.Errorf("step %s: output %s of template %s conflicts with another output of the job", step.Name, output.Name, template.Name)
+			}
+			outputNames.Insert(output.Name)
+			respOutputs = append(respOutputs, output)
+		}
+	}
+	return respSteps, respOutputs, nil
+}
+
+// getStepTemplateInputs checks the input values of a template step against the inputs declared by the template,
+// inputs not given use the default values.
+func getStepTemplateInputs(declared []*commonmodels.StepTemplateInput, given []*commonmodels.KeyVal) (map[string]string, error) {
+	declaredNames := sets.NewString()
+	for _, input := range declared {
+		declaredNames.Insert(input.Name)
 	}
-	jobTaskSpec.Properties.BuildOS = basicImage.Value
-	// save user defined variables.
-	jobTaskSpec.Properties.CustomEnvs = jobTaskSpec.Properties.Envs
-	jobTaskSpec.Properties.Envs = append(jobTaskSpec.Properties.Envs, getfreestyleJobVariables(jobTaskSpec.Steps, taskID, j.workflow.Project, j.workflow.Name)...)
-	return []*commonmodels.JobTask{jobTask}, nil
+	givenValues := make(map[string]string, len(given))
+	for _, kv := range given {
+		if !declaredNames.Has(kv.Key) {
+			return nil, fmt.Errorf("input %s is not declared by the template", kv.Key)
+		}
+		givenValues[kv.Key] = kv.Value
+	}
+
+	inputs := make(map[string]string, len(declared))
+	for _, input := range declared {
+		value := givenValues[input.Name]
+		if value == "" {
+			value = input.Default
+		}
+		if value == "" {
+			if input.Required {
+				return nil, fmt.Errorf("input %s is required", input.Name)
+			}
+			inputs[input.Name] = value
+			continue
+		}
+		switch input.Type {
+		case commonmodels.StepTemplateInputBool:
+			if _, err := strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("input %s must be a bool, got %q", input.Name, value)
+			}
+		case commonmodels.StepTemplateInputNumber:
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("input %s must be a number, got %q", input.Name, value)
+			}
+		}
+		inputs[input.Name] = value
+	}
+	return inputs, nil
+}
+
+// renderTemplateStep returns a copy of the template step whose strings have the {{.inputs.<name>}} replaced.
+func renderTemplateStep(step *commonmodels.Step, inputs map[string]string) (*commonmodels.Step, error) {
+	oldnew := make([]string, 0, len(inputs)*2)
+	for name, value := range inputs {
+		oldnew = append(oldnew, fmt.Sprintf(setting.RenderValueTemplate, "inputs."+name), value)
+	}
+	replacer := strings.NewReplacer(oldnew...)
+
+	bs, err := json.Marshal(step)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if err := json.Unmarshal(bs, &data); err != nil {
+		return nil, err
+	}
+	if bs, err = json.Marshal(renderTemplateValue(data, replacer)); err != nil {
+		return nil, err
+	}
+	resp := &commonmodels.Step{}
+	if err := json.Unmarshal(bs, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func renderTemplateValue(data interface{}, replacer *strings.Replacer) interface{} {
+	switch v := data.(type) {
+	case string:
+		return replacer.Replace(v)
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = renderTemplateValue(value, replacer)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = renderTemplateValue(value, replacer)
+		}
+	}
+	return data
 }
 
 func stepsToStepTasks(step []*commonmodels.Step, outputs []*commonmodels.Output) []*commonmodels.StepTask {
@@ -216,9 +401,14 @@ func stepsToStepTasks(step []*commonmodels.Step, outputs []*commonmodels.Output)
 	resp := []*commonmodels.StepTask{}
 	for _, step := range step {
 		stepTask := &commonmodels.StepTask{
-			Name:     step.Name,
-			StepType: step.StepType,
-			Spec:     step.Spec,
+			Name:            step.Name,
+			StepType:        step.StepType,
+			Spec:            step.Spec,
+			When:            step.When,
+			Timeout:         step.Timeout,
+			Retry:           step.Retry,
+			ContinueOnError: step.ContinueOnError,
+			WorkingDir:      step.WorkingDir,
 		}
 		if stepTask.StepType == config.StepDockerBuild {
 			stepTaskSpec := &steptypes.StepDockerBuildSpec{}
@@ -242,6 +432,28 @@ func stepsToStepTasks(step []*commonmodels.Step, outputs []*commonmodels.Output)
 			}
 			stepTask.Spec = stepTaskSpec
 		}
+		if stepTask.StepType == config.StepImageBuild {
+			stepTaskSpec := &steptypes.StepImageBuildSpec{}
+			if err := commonmodels.IToi(stepTask.Spec, stepTaskSpec); err != nil {
+				continue
+			}
+			registryID := ""
+			if stepTaskSpec.DockerRegistry != nil {
+				registryID = stepTaskSpec.DockerRegistry.DockerRegistryID
+			}
+			registry, _, err := commonservice.FindRegistryById(registryID, true, logger)
+			if err != nil {
+				logger.Errorf("FindRegistryById error: %v", err)
+			}
+			stepTaskSpec.DockerRegistry = &steptypes.DockerRegistry{
+				DockerRegistryID: registryID,
+				Host:             registry.RegAddr,
+				UserName:         registry.AccessKey,
+				Password:         registry.SecretKey,
+				Namespace:        registry.Namespace,
+			}
+			stepTask.Spec = stepTaskSpec
+		}
 		if stepTask.StepType == config.StepShell {
 			stepTaskSpec := &steptypes.StepShellSpec{}
 			if err := commonmodels.IToi(stepTask.Spec, stepTaskSpec); err != nil {
@@ -284,7 +496,54 @@ func (j *FreeStyleJob) LintJob() error {
 	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
 		return err
 	}
-	return checkOutputNames(j.spec.Outputs)
+	// the steps expanded from the step templates are linted as well
+	steps, outputs, err := expandStepTemplates(j.spec.Steps, j.spec.Outputs)
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if err := lintStep(step); err != nil {
+			return err
+		}
+	}
+	if err := lintMatrix(j.spec.Matrix); err != nil {
+		return err
+	}
+	if j.spec.Properties != nil {
+		if err := util.CheckServiceNames(commonmodels.ServiceContainerNames(j.spec.Properties.Services)); err != nil {
+			return err
+		}
+	}
+	return checkOutputNames(outputs)
+}
+
+// timeoutStepTypes are the step types which are stopped by the job executor once their timeout is reached.
+var timeoutStepTypes = sets.NewString(
+	string(config.StepTools),
+	string(config.StepShell),
+	string(config.StepGit),
+	string(config.StepDockerBuild),
+	string(config.StepImageBuild),
+	string(config.StepAttestation),
+)
+
+func lintStep(step *commonmodels.Step) error {
+	if step.Timeout < 0 || step.Retry < 0 {
+		return fmt.Errorf("step %s: timeout and retry can not be negative", step.Name)
+	}
+	if step.Timeout > 0 && !timeoutStepTypes.Has(string(step.StepType)) {
+		return fmt.Errorf("step %s: timeout is not supported by %s steps", step.Name, step.StepType)
+	}
+	if cleaned := path.Clean(step.WorkingDir); path.IsAbs(step.WorkingDir) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return fmt.Errorf("step %s: working dir must be relative to the workspace", step.Name)
+	}
+	if step.When == "" {
+		return nil
+	}
+	if _, err := expression.Parse(step.When); err != nil {
+		return fmt.Errorf("step %s: %v", step.Name, err)
+	}
+	return nil
 }
 
 func (j *FreeStyleJob) GetOutPuts(log *zap.SugaredLogger) []string {
@@ -294,7 +553,8 @@ func (j *FreeStyleJob) GetOutPuts(log *zap.SugaredLogger) []string {
 		return resp
 	}
 
-	jobKey := j.job.Name
-	resp = append(resp, getOutputKey(jobKey, j.spec.Outputs)...)
+	for _, cell := range getMatrixCells(j.spec.Matrix) {
+		resp = append(resp, getOutputKey(getMatrixKey(j.job.Name, cell), j.spec.Outputs)...)
+	}
 	return resp
 }
@@ -0,0 +1,228 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func TestGetStepTemplateInputs(t *testing.T) {
+	declared := []*commonmodels.StepTemplateInput{
+		{Name: "version", Type: commonmodels.StepTemplateInputString, Required: true},
+		{Name: "debug", Type: commonmodels.StepTemplateInputBool, Default: "false"},
+		{Name: "retries", Type: commonmodels.StepTemplateInputNumber},
+	}
+
+	tests := []struct {
+		name    string
+		given   []*commonmodels.KeyVal
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "defaults are used for the inputs not given",
+			given: []*commonmodels.KeyVal{{Key: "version", Value: "1.20"}},
+			want:  map[string]string{"version": "1.20", "debug": "false", "retries": ""},
+		},
+		{
+			name:  "given values override the defaults",
+			given: []*commonmodels.KeyVal{{Key: "version", Value: "1.20"}, {Key: "debug", Value: "true"}, {Key: "retries", Value: "3"}},
+			want:  map[string]string{"version": "1.20", "debug": "true", "retries": "3"},
+		},
+		{
+			name:    "required input is missing",
+			given:   []*commonmodels.KeyVal{{Key: "debug", Value: "true"}},
+			wantErr: "input version is required",
+		},
+		{
+			name:    "undeclared input",
+			given:   []*commonmodels.KeyVal{{Key: "version", Value: "1.20"}, {Key: "arch", Value: "amd64"}},
+			wantErr: "input arch is not declared by the template",
+		},
+		{
+			name:    "invalid bool",
+			given:   []*commonmodels.KeyVal{{Key: "version", Value: "1.20"}, {Key: "debug", Value: "yes"}},
+			wantErr: `input debug must be a bool, got "yes"`,
+		},
+		{
+			name:    "invalid number",
+			given:   []*commonmodels.KeyVal{{Key: "version", Value: "1.20"}, {Key: "retries", Value: "many"}},
+			wantErr: `input retries must be a number, got "many"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getStepTemplateInputs(declared, tt.given)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRenderTemplateStep(t *testing.T) {
+	step := &commonmodels.Step{
+		Name:     "install",
+		StepType: config.StepShell,
+		When:     "{{.inputs.enabled}}",
+		Spec: map[string]interface{}{
+			"script": "install go {{.inputs.version}} {{.inputs.unknown}}",
+			"args":   []interface{}{"--version={{.inputs.version}}"},
+		},
+	}
+
+	got, err := renderTemplateStep(step, map[string]string{"version": "1.20", "enabled": "true"})
+	assert.NoError(t, err)
+	assert.Equal(t, "install", got.Name)
+	assert.Equal(t, config.StepShell, got.StepType)
+	assert.Equal(t, "true", got.When)
+	assert.Equal(t, map[string]interface{}{
+		"script": "install go 1.20 {{.inputs.unknown}}",
+		"args":   []interface{}{"--version=1.20"},
+	}, got.Spec)
+	// the template step itself is left untouched
+	assert.Equal(t, "install go {{.inputs.version}} {{.inputs.unknown}}", step.Spec.(map[string]interface{})["script"])
+}
+
+func TestExpandStepTemplates(t *testing.T) {
+	templates := map[string]*commonmodels.StepTemplate{
+		"setup-go": {
+			Name:    "setup-go",
+			Inputs:  []*commonmodels.StepTemplateInput{{Name: "version", Required: true}},
+			Outputs: []*commonmodels.Output{{Name: "GOROOT"}},
+			Steps: []*commonmodels.Step{
+				{Name: "download", StepType: config.StepShell, Spec: map[string]interface{}{"script": "download {{.inputs.version}}"}},
+				{Name: "verify", StepType: config.StepShell, When: "{{.inputs.version}} != ''", Spec: map[string]interface{}{"script": "go version"}},
+			},
+		},
+	}
+	findTemplate := func(name string, version int64) (*commonmodels.StepTemplate, error) {
+		if template, ok := templates[name]; ok {
+			return template, nil
+		}
+		return nil, fmt.Errorf("not found")
+	}
+	templateStep := func(name, when string) *commonmodels.Step {
+		return &commonmodels.Step{
+			Name:     name,
+			StepType: config.StepTemplate,
+			When:     when,
+			Spec: &commonmodels.StepTemplateSpec{
+				TemplateName: "setup-go",
+				Inputs:       []*commonmodels.KeyVal{{Key: "version", Value: "1.20"}},
+			},
+		}
+	}
+	shellStep := &commonmodels.Step{Name: "build", StepType: config.StepShell, Spec: map[string]interface{}{"script": "make"}}
+
+	t.Run("template steps are expanded in place", func(t *testing.T) {
+		steps, outputs, err := expandStepTemplatesWith([]*commonmodels.Step{templateStep("go", "a == b"), shellStep}, []*commonmodels.Output{{Name: "IMAGE"}}, findTemplate)
+		assert.NoError(t, err)
+		if !assert.Len(t, steps, 3) {
+			return
+		}
+		assert.Equal(t, "go-download", steps[0].Name)
+		assert.Equal(t, map[string]interface{}{"script": "download 1.20"}, steps[0].Spec)
+		assert.Equal(t, "a == b", steps[0].When)
+		assert.Equal(t, "go-verify", steps[1].Name)
+		assert.Equal(t, "(a == b) && (1.20 != '')", steps[1].When)
+		assert.Equal(t, shellStep, steps[2])
+		assert.Equal(t, []*commonmodels.Output{{Name: "IMAGE"}, {Name: "GOROOT"}}, outputs)
+	})
+
+	t.Run("template output conflicts with a job output", func(t *testing.T) {
+		_, _, err := expandStepTemplatesWith([]*commonmodels.Step{templateStep("go", "")}, []*commonmodels.Output{{Name: "GOROOT"}}, findTemplate)
+		assert.EqualError(t, err, "step go: output GOROOT of template setup-go conflicts with another output of the job")
+	})
+
+	t.Run("template used twice has conflicting outputs", func(t *testing.T) {
+		_, _, err := expandStepTemplatesWith([]*commonmodels.Step{templateStep("go1", ""), templateStep("go2", "")}, nil, findTemplate)
+		assert.EqualError(t, err, "step go2: output GOROOT of template setup-go conflicts with another output of the job")
+	})
+
+	t.Run("template not found", func(t *testing.T) {
+		step := templateStep("go", "")
+		step.Spec.(*commonmodels.StepTemplateSpec).TemplateName = "setup-node"
+		_, _, err := expandStepTemplatesWith([]*commonmodels.Step{step}, nil, findTemplate)
+		assert.EqualError(t, err, "step go: find step template setup-node of version 0 error: not found")
+	})
+}
+
+func TestLintStep(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    *commonmodels.Step
+		wantErr string
+	}{
+		{
+			name: "valid shell step",
+			step: &commonmodels.Step{Name: "test", StepType: config.StepShell, Timeout: 10, Retry: 2, WorkingDir: "src/app", When: "a == b"},
+		},
+		{
+			name:    "negative retry",
+			step:    &commonmodels.Step{Name: "test", StepType: config.StepShell, Retry: -1},
+			wantErr: "step test: timeout and retry can not be negative",
+		},
+		{
+			name:    "timeout of a step which can not be stopped",
+			step:    &commonmodels.Step{Name: "upload", StepType: config.StepArchive, Timeout: 10},
+			wantErr: "step upload: timeout is not supported by archive steps",
+		},
+		{
+			name: "retry of a step which can not be stopped",
+			step: &commonmodels.Step{Name: "upload", StepType: config.StepArchive, Retry: 2},
+		},
+		{
+			name: "working dir starts with dots",
+			step: &commonmodels.Step{Name: "test", StepType: config.StepShell, WorkingDir: "..cache"},
+		},
+		{
+			name:    "working dir is the parent dir",
+			step:    &commonmodels.Step{Name: "test", StepType: config.StepShell, WorkingDir: ".."},
+			wantErr: "step test: working dir must be relative to the workspace",
+		},
+		{
+			name:    "working dir escapes the workspace",
+			step:    &commonmodels.Step{Name: "test", StepType: config.StepShell, WorkingDir: "src/../../etc"},
+			wantErr: "step test: working dir must be relative to the workspace",
+		},
+		{
+			name:    "absolute working dir",
+			step:    &commonmodels.Step{Name: "test", StepType: config.StepShell, WorkingDir: "/etc"},
+			wantErr: "step test: working dir must be relative to the workspace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := lintStep(tt.step)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/koderover/zadig/pkg/microservice/aslan/config"
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	"github.com/koderover/zadig/pkg/types/job"
+)
+
+type ManualGateJob struct {
+	job      *commonmodels.Job
+	workflow *commonmodels.WorkflowV4
+	spec     *commonmodels.ManualGateJobSpec
+}
+
+func (j *ManualGateJob) Instantiate() error {
+	j.spec = &commonmodels.ManualGateJobSpec{}
+	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	j.job.Spec = j.spec
+	return nil
+}
+
+func (j *ManualGateJob) SetPreset() error {
+	j.spec = &commonmodels.ManualGateJobSpec{}
+	if err := commonmodels.IToi(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	j.job.Spec = j.spec
+	return nil
+}
+
+// MergeArgs only keeps the settings of the gate, the params are submitted when the job runs.
+func (j *ManualGateJob) MergeArgs(args *commonmodels.Job) error {
+	return nil
+}
+
+func (j *ManualGateJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
+	resp := []*commonmodels.JobTask{}
+	j.spec = &commonmodels.ManualGateJobSpec{}
+	if err := commonmodels.IToi(j.job.Spec, j.spec); err != nil {
+		return resp, err
+	}
+	j.job.Spec = j.spec
+	jobTask := &commonmodels.JobTask{
+		Name:    j.job.Name,
+		Key:     j.job.Name,
+		JobType: string(config.JobManualGate),
+		Spec: &commonmodels.JobTaskManualGateSpec{
+			Description: j.spec.Description,
+			Timeout:     j.spec.Timeout,
+			Users:       j.spec.Users,
+			Params:      j.spec.Params,
+		},
+	}
+	resp = append(resp, jobTask)
+	return resp, nil
+}
+
+func (j *ManualGateJob) LintJob() error {
+	j.spec = &commonmodels.ManualGateJobSpec{}
+	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
+		return err
+	}
+	if j.spec.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if len(j.spec.Users) == 0 {
+		return fmt.Errorf("manual gate job %s has no user to submit it", j.job.Name)
+	}
+	for _, user := range j.spec.Users {
+		if user.UserID == "" {
+			return fmt.Errorf("manual gate job %s has a user without id", j.job.Name)
+		}
+	}
+	for _, param := range j.spec.Params {
+		if match := OutputNameRegex.MatchString(param.Name); !match {
+			return fmt.Errorf("param name must match %s", OutputNameRegexString)
+		}
+		if param.ParamsType == "choice" && len(param.ChoiceOption) == 0 {
+			return fmt.Errorf("choice param %s has no options", param.Name)
+		}
+	}
+	return nil
+}
+
+// GetOutPuts returns the params of the gate, they can be used by the jobs after the gate.
+func (j *ManualGateJob) GetOutPuts(log *zap.SugaredLogger) []string {
+	resp := []string{}
+	j.spec = &commonmodels.ManualGateJobSpec{}
+	if err := commonmodels.IToiYaml(j.job.Spec, j.spec); err != nil {
+		return resp
+	}
+	for _, param := range j.spec.Params {
+		resp = append(resp, job.GetJobOutputKey(j.job.Name, param.Name))
+	}
+	return resp
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+)
+
+func TestLintJobDependencies(t *testing.T) {
+	approval := &commonmodels.Approval{Enabled: true}
+	tests := []struct {
+		name    string
+		stages  []*commonmodels.WorkflowStage
+		wantErr string
+	}{
+		{
+			name: "no dependencies",
+			stages: []*commonmodels.WorkflowStage{
+				{Name: "build", Jobs: []*commonmodels.Job{{Name: "a"}, {Name: "b"}}},
+				{Name: "deploy", Jobs: []*commonmodels.Job{{Name: "c"}}},
+			},
+		},
+		{
+			name: "depends on job in a later stage",
+			stages: []*commonmodels.WorkflowStage{
+				{Name: "build", Parallel: true, Jobs: []*commonmodels.Job{{Name: "a", DependsOn: []string{"c"}}, {Name: "b"}}},
+				{Name: "deploy", Jobs: []*commonmodels.Job{{Name: "c", DependsOn: []string{"b"}}}},
+			},
+		},
+		{
+			name: "depends on itself",
+			stages: []*commonmodels.WorkflowStage{
+				{Name: "build", Jobs: []*commonmodels.Job{{Name: "a", DependsOn: []string{"a"}}}},
+			},
+			wantErr: "job a can not depend on itself",
+		},
+		{
+			name: "depends on missing job",
+			stages: []*commonmodels.WorkflowStage{
+				{Name: "build", Jobs: []*commonmodels.Job{{Name: "a", DependsOn: []string{"x"}}}},
+			},
+			wantErr: "job a depends on job x which does not exist",
+		},
+		{
+			name: "depends on job after approval",
+			stages: []*commonmodels.WorkflowStage{
+				{Name: "build", Jobs: []*commonmodels.Job{{Name: "a", DependsOn: []string{"b"}}}},
+				{Name: "deploy", Approval: approval, Jobs: []*commonmodels.Job{{Name: "b"}}},
+			},
+			wantErr: "job a can not depend on job b which waits for a later approval",
+		},
+		{
+			name: "cycle",
+			stages: []*commonmodels.WorkflowStage{
+				{Name: "build", Parallel: true, Jobs: []*commonmodels.Job{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"c"}}}},
+				{Name: "deploy", Jobs: []*commonmodels.Job{{Name: "c"}}},
+			},
+			wantErr: "circular dependency found: a -> b -> c -> a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := LintJobDependencies(tt.stages)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestCheckDependencyCycle(t *testing.T) {
+	tests := []struct {
+		name         string
+		dependencies map[string][]string
+		wantErr      string
+	}{
+		{
+			name:         "empty",
+			dependencies: map[string][]string{},
+		},
+		{
+			name: "diamond",
+			dependencies: map[string][]string{
+				"a": {},
+				"b": {"a"},
+				"c": {"a"},
+				"d": {"b", "c"},
+			},
+		},
+		{
+			name: "self loop",
+			dependencies: map[string][]string{
+				"a": {"a"},
+			},
+			wantErr: "circular dependency found: a -> a",
+		},
+		{
+			name: "cycle not starting from the first job",
+			dependencies: map[string][]string{
+				"a": {"b"},
+				"b": {"c"},
+				"c": {"d"},
+				"d": {"b"},
+			},
+			wantErr: "circular dependency found: b -> c -> d -> b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDependencyCycle(tt.dependencies)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestGetMatrixKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{
+			name: "no matrix",
+			want: "build",
+		},
+		{
+			name:   "plain values",
+			values: []string{"linux", "amd64"},
+			want:   "build.linux.amd64",
+		},
+		{
+			name:   "value with dot",
+			values: []string{"1.19"},
+			want:   "build.1_x2e19",
+		},
+		{
+			name:   "dot separated values",
+			values: []string{"1", "19"},
+			want:   "build.1.19",
+		},
+		{
+			name:   "value with underscore",
+			values: []string{"a_x2e"},
+			want:   "build.a__x2e",
+		},
+		{
+			name:   "empty value",
+			values: []string{""},
+			want:   "build.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cell := matrixCell{}
+			for i, value := range tt.values {
+				cell = append(cell, &commonmodels.KeyVal{Key: fmt.Sprintf("axis%d", i), Value: value})
+			}
+			assert.Equal(t, tt.want, getMatrixKey("build", cell))
+		})
+	}
+}
+
+func TestLintMatrix(t *testing.T) {
+	tests := []struct {
+		name    string
+		matrix  *commonmodels.JobMatrix
+		wantErr string
+	}{
+		{
+			name: "no matrix",
+		},
+		{
+			name: "valid matrix",
+			matrix: &commonmodels.JobMatrix{Axes: []*commonmodels.MatrixAxis{
+				{Name: "GOOS", Values: []string{"linux", "darwin"}},
+				{Name: "GOVERSION", Values: []string{"1.19", "1.20"}},
+			}},
+		},
+		{
+			name:    "no axis",
+			matrix:  &commonmodels.JobMatrix{},
+			wantErr: "matrix should have at least one axis",
+		},
+		{
+			name: "invalid axis name",
+			matrix: &commonmodels.JobMatrix{Axes: []*commonmodels.MatrixAxis{
+				{Name: "GO-OS", Values: []string{"linux"}},
+			}},
+			wantErr: "matrix axis name GO-OS must match " + MatrixAxisNameRegexString,
+		},
+		{
+			name: "duplicated axis",
+			matrix: &commonmodels.JobMatrix{Axes: []*commonmodels.MatrixAxis{
+				{Name: "GOOS", Values: []string{"linux"}},
+				{Name: "GOOS", Values: []string{"darwin"}},
+			}},
+			wantErr: "duplicated matrix axis: GOOS",
+		},
+		{
+			name: "axis without value",
+			matrix: &commonmodels.JobMatrix{Axes: []*commonmodels.MatrixAxis{
+				{Name: "GOOS"},
+			}},
+			wantErr: "matrix axis GOOS has no value",
+		},
+		{
+			name: "values sharing the job name",
+			matrix: &commonmodels.JobMatrix{Axes: []*commonmodels.MatrixAxis{
+				{Name: "VERSION", Values: []string{"1_0", "1-0"}},
+			}},
+			wantErr: "matrix values [1_0] and [1-0] produce the same job name, please use values different in lowercase letters, digits, '.' or '-'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := lintMatrix(tt.matrix)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
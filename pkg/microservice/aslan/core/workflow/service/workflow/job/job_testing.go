@@ -164,6 +164,8 @@ func (j *TestingJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 		}
 		jobTaskSpec.Properties = commonmodels.JobProperties{
 			Timeout:             int64(testingInfo.Timeout),
+			Retry:               j.spec.Retry,
+			RetryPolicy:         j.spec.RetryPolicy,
 			ResourceRequest:     testingInfo.PreTest.ResReq,
 			ResReqSpec:          testingInfo.PreTest.ResReqSpec,
 			CustomEnvs:          renderKeyVals(testing.KeyVals, testingInfo.PreTest.Envs),
@@ -172,6 +174,7 @@ func (j *TestingJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 			ImageFrom:           testingInfo.PreTest.ImageFrom,
 			Registries:          registries,
 			ShareStorageDetails: getShareStorageDetail(j.workflow.ShareStorages, testing.ShareStorageInfo, j.workflow.Name, taskID),
+			Services:            testingInfo.PreTest.Services,
 		}
 		clusterInfo, err := commonrepo.NewK8SClusterColl().Get(testingInfo.PreTest.ClusterID)
 		if err != nil {
@@ -228,25 +231,26 @@ func (j *TestingJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 		}
 		jobTaskSpec.Steps = append(jobTaskSpec.Steps, shellStep)
 
-		// init archive html step
+		// init html report step
 		if len(testingInfo.TestReportPath) > 0 {
-			uploads := []*step.Upload{
-				{
-					FilePath:        testingInfo.TestReportPath,
-					DestinationPath: path.Join(j.workflow.Name, fmt.Sprint(taskID), jobTask.Name, "html"),
-				},
+			htmlReport := &commonmodels.HtmlReport{
+				S3DestDir:  path.Join(j.workflow.Name, fmt.Sprint(taskID), jobTask.Name, "html"),
+				ReportFile: path.Base(testingInfo.TestReportPath),
 			}
-			archiveStep := &commonmodels.StepTask{
+			htmlReportStep := &commonmodels.StepTask{
 				Name:      config.TestJobHTMLReportStepName,
 				JobName:   jobTask.Name,
-				StepType:  config.StepArchive,
+				StepType:  config.StepHtmlReport,
 				Onfailure: true,
-				Spec: step.StepArchiveSpec{
-					UploadDetail: uploads,
-					S3:           modelS3toS3(defaultS3),
+				Spec: step.StepHtmlReportSpec{
+					ReportDir:  path.Dir(testingInfo.TestReportPath),
+					ReportFile: htmlReport.ReportFile,
+					S3DestDir:  htmlReport.S3DestDir,
+					S3Storage:  modelS3toS3(defaultS3),
 				},
 			}
-			jobTaskSpec.Steps = append(jobTaskSpec.Steps, archiveStep)
+			jobTask.HtmlReport = htmlReport
+			jobTaskSpec.Steps = append(jobTaskSpec.Steps, htmlReportStep)
 		}
 
 		// init test result storage step
@@ -269,18 +273,22 @@ func (j *TestingJob) ToJobs(taskID int64) ([]*commonmodels.JobTask, error) {
 		}
 
 		// init junit report step
-		if len(testingInfo.TestResultPath) > 0 {
+		if len(testingInfo.TestResultPath) > 0 || len(testingInfo.CoveragePath) > 0 {
 			junitStep := &commonmodels.StepTask{
 				Name:      config.TestJobJunitReportStepName,
 				JobName:   jobTask.Name,
 				StepType:  config.StepJunitReport,
 				Onfailure: true,
 				Spec: &step.StepJunitReportSpec{
-					ReportDir: testingInfo.TestResultPath,
-					S3DestDir: path.Join(j.workflow.Name, fmt.Sprint(taskID), jobTask.Name, "junit"),
-					TestName:  testing.Name,
-					DestDir:   "/tmp",
-					FileName:  "merged.xml",
+					ReportDir:        testingInfo.TestResultPath,
+					ReportFormat:     testingInfo.TestResultFormat,
+					S3DestDir:        path.Join(j.workflow.Name, fmt.Sprint(taskID), jobTask.Name, "junit"),
+					TestName:         testing.Name,
+					DestDir:          "/tmp",
+					FileName:         "merged.xml",
+					CoverageFormat:   testingInfo.CoverageFormat,
+					CoveragePath:     testingInfo.CoveragePath,
+					CoverageFileName: setting.TestCoverageOut,
 				},
 			}
 			jobTaskSpec.Steps = append(jobTaskSpec.Steps, junitStep)
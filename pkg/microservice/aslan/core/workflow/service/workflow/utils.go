@@ -18,9 +18,12 @@ package workflow
 
 import (
 	"fmt"
+	"text/template"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 
+	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
+	commonrepo "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/mongodb"
 	"github.com/koderover/zadig/pkg/setting"
 )
 
@@ -41,3 +44,24 @@ func validateHookNames(hookNames []string) error {
 
 	return nil
 }
+
+// validatePreviewEnv checks the base environment of the preview environments created by the webhook.
+func validatePreviewEnv(projectName string, hook *commonmodels.WorkflowV4Hook) error {
+	if hook.PreviewEnv == nil || !hook.PreviewEnv.Enabled {
+		return nil
+	}
+	baseEnv, err := commonrepo.NewProductColl().Find(&commonrepo.ProductFindOptions{Name: projectName, EnvName: hook.PreviewEnv.BaseEnv})
+	if err != nil {
+		return fmt.Errorf("failed to find base env %s of preview env: %v", hook.PreviewEnv.BaseEnv, err)
+	}
+	if !baseEnv.ShareEnv.Enable || !baseEnv.ShareEnv.IsBase {
+		return fmt.Errorf("env %s is not a base environment with environment sharing enabled", baseEnv.EnvName)
+	}
+	if hook.PreviewEnv.IdleTTL < 0 {
+		return fmt.Errorf("idle ttl of preview env can not be negative")
+	}
+	if _, err := template.New("url").Parse(hook.PreviewEnv.URLTemplate); err != nil {
+		return fmt.Errorf("invalid url template of preview env: %v", err)
+	}
+	return nil
+}
@@ -18,13 +18,17 @@ package workflow
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/koderover/zadig/pkg/microservice/aslan/config"
 	commonmodels "github.com/koderover/zadig/pkg/microservice/aslan/core/common/repository/models"
@@ -71,6 +75,9 @@ type WorkflowTaskPreview struct {
 	ProjectName         string                `bson:"project_name"              json:"project_name"`
 	Error               string                `bson:"error,omitempty"           json:"error,omitempty"`
 	IsRestart           bool                  `bson:"is_restart"                json:"is_restart"`
+	OriginTaskID        int64                 `bson:"origin_task_id"            json:"origin_task_id,omitempty"`
+	RetryTaskIDs        []int64               `bson:"retry_task_ids"            json:"retry_task_ids,omitempty"`
+	Paused              bool                  `bson:"paused"                    json:"paused"`
 }
 
 type StageTaskPreview struct {
@@ -91,6 +98,13 @@ type JobTaskPreview struct {
 	EndTime   int64         `bson:"end_time"       json:"end_time,omitempty"`
 	Error     string        `bson:"error"          json:"error"`
 	Spec      interface{}   `bson:"spec"           json:"spec"`
+	// Key and DependsOn are used to show the jobs as a DAG.
+	Key       string   `bson:"key"            json:"key"`
+	DependsOn []string `bson:"depends_on"     json:"depends_on,omitempty"`
+	// Attempts is the failed runs of a retried job.
+	Attempts []*commonmodels.JobAttempt `bson:"attempts"       json:"attempts,omitempty"`
+	// FromTaskID is the task the result of the job comes from when it is not run again in a retry task.
+	FromTaskID int64 `bson:"from_task_id"   json:"from_task_id,omitempty"`
 }
 
 type ZadigBuildJobSpec struct {
@@ -307,7 +321,10 @@ func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels
 	workflowTask.KeyVals = workflow.KeyVals
 	workflowTask.MultiRun = workflow.MultiRun
 	workflowTask.ShareStorages = workflow.ShareStorages
+	workflowTask.Priority = workflow.Priority
+	workflowTask.Concurrency = workflow.Concurrency
 
+	jobTaskMap := make(map[string][]*commonmodels.JobTask)
 	for _, stage := range workflow.Stages {
 		stageTask := &commonmodels.StageTask{
 			Name:     stage.Name,
@@ -318,31 +335,9 @@ func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels
 			if jobctl.JobSkiped(job) {
 				continue
 			}
-			// TODO: move this logic to job controller
-			if job.JobType == config.JobZadigBuild {
-				if err := setZadigBuildRepos(job, log); err != nil {
-					log.Errorf("zadig build job set build info error: %v", err)
-					return resp, e.ErrCreateTask.AddDesc(err.Error())
-				}
-			}
-			if job.JobType == config.JobFreestyle {
-				if err := setFreeStyleRepos(job, log); err != nil {
-					log.Errorf("freestyle job set build info error: %v", err)
-					return resp, e.ErrCreateTask.AddDesc(err.Error())
-				}
-			}
-			if job.JobType == config.JobZadigTesting {
-				if err := setZadigTestingRepos(job, log); err != nil {
-					log.Errorf("testing job set build info error: %v", err)
-					return resp, e.ErrCreateTask.AddDesc(err.Error())
-				}
-			}
-
-			if job.JobType == config.JobZadigScanning {
-				if err := setZadigScanningRepos(job, log); err != nil {
-					log.Errorf("scanning job set build info error: %v", err)
-					return resp, e.ErrCreateTask.AddDesc(err.Error())
-				}
+			if err := setJobRepos(job, log); err != nil {
+				log.Error(err)
+				return resp, e.ErrCreateTask.AddDesc(err.Error())
 			}
 
 			jobs, err := jobctl.ToJobs(job, workflow, nextTaskID)
@@ -351,11 +346,15 @@ func CreateWorkflowTaskV4(args *CreateWorkflowTaskV4Args, workflow *commonmodels
 				return resp, e.ErrCreateTask.AddDesc(err.Error())
 			}
 			stageTask.Jobs = append(stageTask.Jobs, jobs...)
+			jobTaskMap[job.Name] = jobs
 		}
 		if len(stageTask.Jobs) > 0 {
 			workflowTask.Stages = append(workflowTask.Stages, stageTask)
 		}
 	}
+	if jobctl.UseJobDependency(workflow.Stages) {
+		jobctl.SetJobTaskDependencies(workflow.Stages, jobTaskMap)
+	}
 
 	if err := workflowTaskLint(workflowTask, log); err != nil {
 		return resp, err
@@ -442,6 +441,311 @@ func CancelWorkflowTaskV4(userName, workflowName string, taskID int64, logger *z
 	return nil
 }
 
+// RetryWorkflowTaskV4 creates a new task from a finished task, the passed jobs keep their results,
+// the failed and cancelled jobs and the jobs after them run again.
+func RetryWorkflowTaskV4(userName, userID, workflowName string, taskID int64, logger *zap.SugaredLogger) (*CreateTaskV4Resp, error) {
+	task, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+	if err != nil {
+		logger.Errorf("find workflowTaskV4 error: %s", err)
+		return nil, e.ErrGetTask.AddErr(err)
+	}
+	resp := &CreateTaskV4Resp{
+		ProjectName:  task.ProjectName,
+		WorkflowName: task.WorkflowName,
+	}
+	switch task.Status {
+	case config.StatusFailed, config.StatusCancelled, config.StatusTimeout, config.StatusReject:
+	default:
+		errMsg := fmt.Sprintf("task %d of workflow %s is %s, only failed task can be retried", taskID, workflowName, task.Status)
+		logger.Error(errMsg)
+		return resp, e.ErrRestartTask.AddDesc(errMsg)
+	}
+	retryJobs := setRetryJobs(task)
+	if retryJobs.Len() == 0 {
+		errMsg := fmt.Sprintf("task %d of workflow %s has no failed job to retry", taskID, workflowName)
+		logger.Error(errMsg)
+		return resp, e.ErrRestartTask.AddDesc(errMsg)
+	}
+
+	task.TaskCreatorEmail = ""
+	task.TaskCreatorPhone = ""
+	if userID != "" {
+		userInfo, err := orm.GetUserByUid(userID, core.DB)
+		if err != nil || userInfo == nil {
+			return resp, errors.New("failed to get user info by uid")
+		}
+		task.TaskCreatorEmail = userInfo.Email
+		task.TaskCreatorPhone = userInfo.Phone
+	}
+
+	nextTaskID, err := commonrepo.NewCounterColl().GetNextSeq(fmt.Sprintf(setting.WorkflowTaskV4Fmt, workflowName))
+	if err != nil {
+		logger.Errorf("Counter.GetNextSeq error: %v", err)
+		return resp, e.ErrGetCounter.AddDesc(err.Error())
+	}
+	resp.TaskID = nextTaskID
+
+	if err := renderRetryJobs(task, retryJobs, nextTaskID, userName, logger); err != nil {
+		logger.Errorf("render retry jobs error: %v", err)
+		return resp, e.ErrRestartTask.AddDesc(err.Error())
+	}
+
+	task.ID = primitive.NilObjectID
+	task.TaskID = nextTaskID
+	task.OriginTaskID = taskID
+	task.RetryTaskIDs = nil
+	task.TaskCreator = userName
+	task.TaskRevoker = userName
+	task.CreateTime = time.Now().Unix()
+	task.StartTime = 0
+	task.EndTime = 0
+	task.Error = ""
+	task.IsRestart = false
+	task.IsArchived = false
+	task.Paused = false
+	task.Status = config.StatusCreated
+
+	if err := workflowcontroller.CreateTask(task); err != nil {
+		logger.Errorf("create workflow task error: %v", err)
+		return resp, e.ErrRestartTask.AddDesc(err.Error())
+	}
+	if err := commonrepo.NewworkflowTaskv4Coll().AddRetryTask(workflowName, taskID, nextTaskID); err != nil {
+		logger.Warnf("failed to add retry task %d to task %d of workflow %s: %v", nextTaskID, taskID, workflowName, err)
+	}
+	return resp, nil
+}
+
+// setRetryJobs resets the jobs to run again in the retry task and the stages they belong to,
+// it returns the keys of the jobs to run again.
+func setRetryJobs(task *commonmodels.WorkflowTask) sets.String {
+	retryJobs := sets.NewString()
+	needRetry := func(job *commonmodels.JobTask) bool {
+		if job.Status != config.StatusPassed && job.Status != config.StatusSkipped {
+			return true
+		}
+		for _, key := range job.DependsOn {
+			if retryJobs.Has(key) {
+				return true
+			}
+		}
+		return false
+	}
+
+	useDependency := false
+	for _, stage := range task.Stages {
+		for _, job := range stage.Jobs {
+			if len(job.DependsOn) > 0 {
+				useDependency = true
+			}
+		}
+	}
+	if useDependency {
+		// jobs may depend on the jobs in later stages, so mark until nothing changes.
+		for changed := true; changed; {
+			changed = false
+			for _, stage := range task.Stages {
+				for _, job := range stage.Jobs {
+					if !retryJobs.Has(job.Key) && needRetry(job) {
+						retryJobs.Insert(job.Key)
+						changed = true
+					}
+				}
+			}
+		}
+	} else {
+		// without dependencies, all the jobs after a failed stage run again.
+		stageFailed := false
+		for _, stage := range task.Stages {
+			for _, job := range stage.Jobs {
+				if stageFailed || needRetry(job) {
+					retryJobs.Insert(job.Key)
+				}
+			}
+			for _, job := range stage.Jobs {
+				if retryJobs.Has(job.Key) {
+					stageFailed = true
+				}
+			}
+		}
+	}
+	if retryJobs.Len() == 0 {
+		return retryJobs
+	}
+
+	for _, stage := range task.Stages {
+		stageRetry := false
+		for _, job := range stage.Jobs {
+			if !retryJobs.Has(job.Key) {
+				if job.FromTaskID == 0 {
+					job.FromTaskID = task.TaskID
+				}
+				continue
+			}
+			stageRetry = true
+			job.Status = ""
+			job.StartTime = 0
+			job.EndTime = 0
+			job.Error = ""
+			job.K8sJobName = ""
+			job.Attempts = nil
+			job.FromTaskID = 0
+			outputPrefix := workflowcontroller.GetContextKey(fmt.Sprintf("{{.job.%s.output.", job.Key))
+			for key := range task.GlobalContext {
+				if strings.HasPrefix(key, outputPrefix) {
+					delete(task.GlobalContext, key)
+				}
+			}
+		}
+		if !stageRetry {
+			continue
+		}
+		stage.Status = ""
+		stage.StartTime = 0
+		stage.EndTime = 0
+		stage.Error = ""
+		resetStageApproval(stage.Approval)
+	}
+	return retryJobs
+}
+
+// renderRetryJobs creates the jobs to run again from the original workflow args with the new task id,
+// since the task id and the variables rendered from it are written in the job specs.
+func renderRetryJobs(task *commonmodels.WorkflowTask, retryJobs sets.String, taskID int64, creator string, logger *zap.SugaredLogger) error {
+	// tasks created before the original args were saved keep their job specs.
+	if task.OriginWorkflowArgs == nil {
+		return nil
+	}
+	workflow := &commonmodels.WorkflowV4{}
+	if err := commonmodels.IToi(task.OriginWorkflowArgs, workflow); err != nil {
+		return err
+	}
+	if err := jobctl.RemoveFixedValueMarks(workflow); err != nil {
+		return err
+	}
+	if err := jobctl.RenderGlobalVariables(workflow, taskID, creator); err != nil {
+		return err
+	}
+
+	jobTaskMap := map[string]*commonmodels.JobTask{}
+	for _, stage := range workflow.Stages {
+		for _, job := range stage.Jobs {
+			if jobctl.JobSkiped(job) {
+				continue
+			}
+			if err := setJobRepos(job, logger); err != nil {
+				return err
+			}
+			jobs, err := jobctl.ToJobs(job, workflow, taskID)
+			if err != nil {
+				return err
+			}
+			for _, jobTask := range jobs {
+				jobTaskMap[jobTask.Key] = jobTask
+			}
+		}
+	}
+
+	for _, stage := range task.Stages {
+		for i, job := range stage.Jobs {
+			if !retryJobs.Has(job.Key) {
+				continue
+			}
+			jobTask, ok := jobTaskMap[job.Key]
+			if !ok {
+				return fmt.Errorf("job %s not found in the workflow args", job.Key)
+			}
+			jobTask.DependsOn = job.DependsOn
+			stage.Jobs[i] = jobTask
+		}
+	}
+	task.WorkflowArgs = workflow
+	return nil
+}
+
+// setJobRepos sets the commits of the repos the job builds from.
+// TODO: move this logic to job controller
+func setJobRepos(job *commonmodels.Job, logger *zap.SugaredLogger) error {
+	switch job.JobType {
+	case config.JobZadigBuild:
+		if err := setZadigBuildRepos(job, logger); err != nil {
+			return fmt.Errorf("zadig build job set build info error: %v", err)
+		}
+	case config.JobFreestyle:
+		if err := setFreeStyleRepos(job, logger); err != nil {
+			return fmt.Errorf("freestyle job set build info error: %v", err)
+		}
+	case config.JobZadigTesting:
+		if err := setZadigTestingRepos(job, logger); err != nil {
+			return fmt.Errorf("testing job set build info error: %v", err)
+		}
+	case config.JobZadigScanning:
+		if err := setZadigScanningRepos(job, logger); err != nil {
+			return fmt.Errorf("scanning job set build info error: %v", err)
+		}
+	}
+	return nil
+}
+
+// resetStageApproval clears a rejected approval so that the stage asks for approval again.
+func resetStageApproval(approval *commonmodels.Approval) {
+	if approval == nil || !approval.Enabled {
+		return
+	}
+	if approval.NativeApproval != nil && approval.NativeApproval.RejectOrApprove == config.Reject {
+		approval.NativeApproval.RejectOrApprove = ""
+		for _, user := range approval.NativeApproval.ApproveUsers {
+			user.RejectOrApprove = ""
+			user.Comment = ""
+			user.OperationTime = 0
+		}
+	}
+	if approval.LarkApproval != nil {
+		rejected := false
+		for _, user := range approval.LarkApproval.ApproveUsers {
+			if user.RejectOrApprove == config.Reject {
+				rejected = true
+			}
+		}
+		if !rejected {
+			return
+		}
+		for _, user := range approval.LarkApproval.ApproveUsers {
+			user.RejectOrApprove = ""
+			user.Comment = ""
+			user.OperationTime = 0
+		}
+	}
+}
+
+func PauseWorkflowTaskV4(userName, workflowName string, taskID int64, logger *zap.SugaredLogger) error {
+	if err := workflowcontroller.PauseWorkflowTask(userName, workflowName, taskID, true, logger); err != nil {
+		logger.Errorf("pause workflowTaskV4 error: %s", err)
+		return e.ErrPauseTask.AddErr(err)
+	}
+	return nil
+}
+
+func ResumeWorkflowTaskV4(userName, workflowName string, taskID int64, logger *zap.SugaredLogger) error {
+	if err := workflowcontroller.PauseWorkflowTask(userName, workflowName, taskID, false, logger); err != nil {
+		logger.Errorf("resume workflowTaskV4 error: %s", err)
+		return e.ErrResumeTask.AddErr(err)
+	}
+	return nil
+}
+
+func SubmitManualGate(workflowName, jobName, userName, userID string, taskID int64, params []*commonmodels.Param, logger *zap.SugaredLogger) error {
+	if workflowName == "" || jobName == "" || taskID == 0 {
+		errMsg := fmt.Sprintf("can not find manual gate job, workflow: %s, taskID: %d, job: %s", workflowName, taskID, jobName)
+		logger.Error(errMsg)
+		return e.ErrSubmitManualGate.AddDesc(errMsg)
+	}
+	if err := workflowcontroller.SubmitManualGate(workflowName, jobName, userName, userID, taskID, params); err != nil {
+		logger.Error(err)
+		return e.ErrSubmitManualGate.AddErr(err)
+	}
+	return nil
+}
+
 func GetWorkflowTaskV4(workflowName string, taskID int64, logger *zap.SugaredLogger) (*WorkflowTaskPreview, error) {
 	task, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID)
 	if err != nil {
@@ -462,6 +766,9 @@ func GetWorkflowTaskV4(workflowName string, taskID int64, logger *zap.SugaredLog
 		EndTime:             task.EndTime,
 		Error:               task.Error,
 		IsRestart:           task.IsRestart,
+		OriginTaskID:        task.OriginTaskID,
+		RetryTaskIDs:        task.RetryTaskIDs,
+		Paused:              task.Paused,
 	}
 	for _, stage := range task.Stages {
 		resp.Stages = append(resp.Stages, &StageTaskPreview{
@@ -494,12 +801,16 @@ func jobsToJobPreviews(jobs []*commonmodels.JobTask, context map[string]string)
 	resp := []*JobTaskPreview{}
 	for _, job := range jobs {
 		jobPreview := &JobTaskPreview{
-			Name:      job.Name,
-			Status:    job.Status,
-			StartTime: job.StartTime,
-			EndTime:   job.EndTime,
-			Error:     job.Error,
-			JobType:   job.JobType,
+			Name:       job.Name,
+			Status:     job.Status,
+			StartTime:  job.StartTime,
+			EndTime:    job.EndTime,
+			Error:      job.Error,
+			JobType:    job.JobType,
+			Key:        job.Key,
+			DependsOn:  job.DependsOn,
+			Attempts:   job.Attempts,
+			FromTaskID: job.FromTaskID,
 		}
 		switch job.JobType {
 		case string(config.JobFreestyle):
@@ -906,3 +1217,91 @@ func GetWorkflowV4ArtifactFileContent(workflowName, jobName string, taskID int64
 	}
 	return fileByts, nil
 }
+
+type WorkflowTaskArtifact struct {
+	Name       string `json:"name"`
+	Size       int64  `json:"size"`
+	Checksum   string `json:"checksum"`
+	UpdateTime int64  `json:"update_time"`
+}
+
+// ListWorkflowV4TaskArtifacts lists the artifacts uploaded by the artifact_upload steps of the workflow task.
+func ListWorkflowV4TaskArtifacts(workflowName string, taskID int64, log *zap.SugaredLogger) ([]*WorkflowTaskArtifact, error) {
+	if _, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID); err != nil {
+		return nil, fmt.Errorf("cannot find workflow task, workflow name: %s, task id: %d", workflowName, taskID)
+	}
+	storage, client, err := getDefaultS3Client()
+	if err != nil {
+		log.Errorf("ListWorkflowV4TaskArtifacts create s3 client err: %s", err)
+		return nil, err
+	}
+
+	objectDir := strings.TrimLeft(path.Join(storage.Subfolder, stepspec.GetArtifactObjectDir(workflowName, taskID)), "/")
+	objects, err := client.ListObjectsWithInfo(storage.Bucket, objectDir+"/")
+	if err != nil {
+		log.Errorf("ListWorkflowV4TaskArtifacts list objects err: %s", err)
+		return nil, fmt.Errorf("list artifacts err: %v", err)
+	}
+	resp := make([]*WorkflowTaskArtifact, 0)
+	for _, object := range objects {
+		key := *object.Key
+		if !strings.HasSuffix(key, stepspec.ArtifactArchiveSuffix) {
+			continue
+		}
+		artifact := &WorkflowTaskArtifact{
+			Name:       strings.TrimSuffix(path.Base(key), stepspec.ArtifactArchiveSuffix),
+			Size:       *object.Size,
+			UpdateTime: object.LastModified.Unix(),
+		}
+		if checksum, err := client.GetFile(storage.Bucket, key+stepspec.ArtifactChecksumSuffix, &s3tool.DownloadOption{RetryNum: 2}); err == nil {
+			content, _ := ioutil.ReadAll(checksum.Body)
+			checksum.Body.Close()
+			artifact.Checksum = strings.TrimSpace(string(content))
+		}
+		resp = append(resp, artifact)
+	}
+	return resp, nil
+}
+
+// DownloadWorkflowV4TaskArtifact returns the artifact archive along with its size, the caller must close the reader.
+func DownloadWorkflowV4TaskArtifact(workflowName, name string, taskID int64, log *zap.SugaredLogger) (io.ReadCloser, int64, error) {
+	if path.Base(name) != name {
+		return nil, 0, fmt.Errorf("invalid artifact name: %s", name)
+	}
+	if _, err := commonrepo.NewworkflowTaskv4Coll().Find(workflowName, taskID); err != nil {
+		return nil, 0, fmt.Errorf("cannot find workflow task, workflow name: %s, task id: %d", workflowName, taskID)
+	}
+	storage, client, err := getDefaultS3Client()
+	if err != nil {
+		log.Errorf("DownloadWorkflowV4TaskArtifact create s3 client err: %s", err)
+		return nil, 0, err
+	}
+
+	objectKey := stepspec.GetArtifactObjectKey(storage.Subfolder, stepspec.GetArtifactObjectDir(workflowName, taskID), name)
+	object, err := client.GetFile(storage.Bucket, objectKey, &s3tool.DownloadOption{RetryNum: 2})
+	if err != nil {
+		log.Errorf("DownloadWorkflowV4TaskArtifact GetFile err: %s", err)
+		return nil, 0, fmt.Errorf("get artifact %s err: %v", name, err)
+	}
+	var size int64 = -1
+	if object.ContentLength != nil {
+		size = *object.ContentLength
+	}
+	return object.Body, size, nil
+}
+
+func getDefaultS3Client() (*s3.S3, *s3tool.Client, error) {
+	storage, err := s3.FindDefaultS3()
+	if err != nil {
+		return nil, nil, fmt.Errorf("findDefaultS3 err: %v", err)
+	}
+	forcedPathStyle := true
+	if storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3tool.NewClient(storage.Endpoint, storage.Ak, storage.Sk, storage.Region, storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create S3 client err: %v", err)
+	}
+	return storage, client, nil
+}
@@ -474,6 +474,10 @@ func LintWorkflowV4(workflow *commonmodels.WorkflowV4, logger *zap.SugaredLogger
 			return e.ErrUpsertWorkflow.AddDesc("common workflow only support k8s and helm project")
 		}
 	}
+	if err := lintConcurrencyGroup(workflow.Concurrency); err != nil {
+		logger.Errorf("concurrency group error: %v", err)
+		return e.ErrUpsertWorkflow.AddDesc(fmt.Sprintf("concurrency group error: %v", err))
+	}
 	stageNameMap := make(map[string]bool)
 	jobNameMap := make(map[string]string)
 
@@ -510,6 +514,10 @@ func LintWorkflowV4(workflow *commonmodels.WorkflowV4, logger *zap.SugaredLogger
 			}
 		}
 	}
+	if err := jobctl.LintJobDependencies(workflow.Stages); err != nil {
+		logger.Errorf("lint job dependencies failed: %v", err)
+		return e.ErrUpsertWorkflow.AddErr(err)
+	}
 	return nil
 }
 
@@ -542,6 +550,19 @@ func lintApprovals(approval *commonmodels.Approval) error {
 	return nil
 }
 
+func lintConcurrencyGroup(group *commonmodels.ConcurrencyGroup) error {
+	if group == nil {
+		return nil
+	}
+	if group.Name == "" {
+		return errors.New("group name should not be empty")
+	}
+	if group.Limit < 0 {
+		return errors.New("limit should not be negative")
+	}
+	return nil
+}
+
 func CreateWebhookForWorkflowV4(workflowName string, input *commonmodels.WorkflowV4Hook, logger *zap.SugaredLogger) error {
 	workflow, err := commonrepo.NewWorkflowV4Coll().Find(workflowName)
 	if err != nil {
@@ -559,6 +580,10 @@ func CreateWebhookForWorkflowV4(workflowName string, input *commonmodels.Workflo
 		logger.Errorf(err.Error())
 		return e.ErrCreateWebhook.AddErr(err)
 	}
+	if err := validatePreviewEnv(workflow.Project, input); err != nil {
+		logger.Errorf(err.Error())
+		return e.ErrCreateWebhook.AddErr(err)
+	}
 	err = commonservice.ProcessWebhook([]*models.WorkflowV4Hook{input}, nil, webhook.WorkflowV4Prefix+workflowName, logger)
 	if err != nil {
 		errMsg := fmt.Sprintf("failed to create webhook for workflow %s, the error is: %v", workflowName, err)
@@ -602,6 +627,10 @@ func UpdateWebhookForWorkflowV4(workflowName string, input *commonmodels.Workflo
 		logger.Errorf(err.Error())
 		return e.ErrUpdateWebhook.AddErr(err)
 	}
+	if err := validatePreviewEnv(workflow.Project, input); err != nil {
+		logger.Errorf(err.Error())
+		return e.ErrUpdateWebhook.AddErr(err)
+	}
 	err = commonservice.ProcessWebhook([]*models.WorkflowV4Hook{input}, []*models.WorkflowV4Hook{existHook}, webhook.WorkflowV4Prefix+workflowName, logger)
 	if err != nil {
 		errMsg := fmt.Sprintf("failed to update webhook for workflow %s, the error is: %v", workflowName, err)
@@ -28,6 +28,7 @@ func (*Router) Inject(router *gin.RouterGroup) {
 	{
 		testReport.GET("", GetHTMLTestReport)
 		testReport.GET("workflowv4/:workflowName/id/:id/job/:jobName", GetWorkflowV4HTMLTestReport)
+		testReport.GET("workflowv4/:workflowName/id/:id/job/:jobName/html/*filepath", GetWorkflowV4HTMLTestReportAsset)
 	}
 
 	// ---------------------------------------------------------------------------------------
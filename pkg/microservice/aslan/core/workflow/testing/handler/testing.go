@@ -20,6 +20,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"mime"
+	"net/http"
+	"path"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -168,3 +171,23 @@ func GetWorkflowV4HTMLTestReport(c *gin.Context) {
 	c.Header("content-type", "text/html")
 	c.String(200, content)
 }
+
+func GetWorkflowV4HTMLTestReportAsset(c *gin.Context) {
+	taskID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(500, gin.H{"err": err})
+		return
+	}
+	filePath := c.Param("filepath")
+	content, err := service.GetWorkflowV4HTMLTestReportAsset(c.Param("workflowName"), c.Param("jobName"), taskID, filePath, ginzap.WithContext(c).Sugar())
+	if err != nil {
+		c.JSON(500, gin.H{"err": err})
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(filePath))
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	c.Data(200, contentType, content)
+}
@@ -19,9 +19,11 @@ package service
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -53,6 +55,9 @@ func CreateTesting(username string, testing *commonmodels.Testing, log *zap.Suga
 	if err := commonutil.CheckDefineResourceParam(testing.PreTest.ResReq, testing.PreTest.ResReqSpec); err != nil {
 		return e.ErrCreateTestModule.AddDesc(err.Error())
 	}
+	if err := commonutil.CheckServiceNames(commonmodels.ServiceContainerNames(testing.PreTest.Services)); err != nil {
+		return e.ErrCreateTestModule.AddDesc(err.Error())
+	}
 	err := HandleCronjob(testing, log)
 	if err != nil {
 		return e.ErrCreateTestModule.AddErr(err)
@@ -113,6 +118,9 @@ func UpdateTesting(username string, testing *commonmodels.Testing, log *zap.Suga
 	if err := commonutil.CheckDefineResourceParam(testing.PreTest.ResReq, testing.PreTest.ResReqSpec); err != nil {
 		return e.ErrUpdateTestModule.AddDesc(err.Error())
 	}
+	if err := commonutil.CheckServiceNames(commonmodels.ServiceContainerNames(testing.PreTest.Services)); err != nil {
+		return e.ErrUpdateTestModule.AddDesc(err.Error())
+	}
 	err := HandleCronjob(testing, log)
 	if err != nil {
 		return e.ErrUpdateTestModule.AddErr(err)
@@ -364,24 +372,22 @@ func GetHTMLTestReport(pipelineName, pipelineType, taskIDStr, testName string, l
 }
 
 func GetWorkflowV4HTMLTestReport(workflowName, jobName string, taskID int64, log *zap.SugaredLogger) (string, error) {
-	workflowTask, err := mongodb.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+	jobTask, err := findWorkflowV4JobTask(workflowName, jobName, taskID)
 	if err != nil {
-		return "", fmt.Errorf("cannot find workflow task, workflow name: %s, task id: %d", workflowName, taskID)
+		return "", err
 	}
-	var jobTask *commonmodels.JobTask
-	for _, stage := range workflowTask.Stages {
-		for _, job := range stage.Jobs {
-			if job.Name != jobName {
-				continue
-			}
-			if job.JobType != string(config.JobZadigTesting) {
-				return "", fmt.Errorf("job: %s was not a testing job", jobName)
-			}
-			jobTask = job
+	if jobTask.HtmlReport != nil {
+		content, err := downloadHTMLReportFile(path.Join(jobTask.HtmlReport.S3DestDir, jobTask.HtmlReport.ReportFile), log)
+		if err != nil {
+			return "", err
 		}
+		// the report is served at .../job/:jobName, relative assets are resolved against .../job/:jobName/html/
+		return setHTMLReportBase(string(content), fmt.Sprintf("%s/html/", url.PathEscape(jobName))), nil
 	}
-	if jobTask == nil {
-		return "", fmt.Errorf("cannot find job task, workflow name: %s, task id: %d, job name: %s", workflowName, taskID, jobName)
+
+	// tasks created before the html report step archived the report file only
+	if jobTask.JobType != string(config.JobZadigTesting) {
+		return "", fmt.Errorf("job: %s was not a testing job", jobName)
 	}
 	jobSpec := &commonmodels.JobTaskFreestyleSpec{}
 	if err := commonmodels.IToi(jobTask.Spec, jobSpec); err != nil {
@@ -411,16 +417,56 @@ func GetWorkflowV4HTMLTestReport(workflowName, jobName string, taskID int64, log
 		filePath = filepath.Join(artifact.DestinationPath, fileName)
 	}
 
+	content, err := downloadHTMLReportFile(filePath, log)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// GetWorkflowV4HTMLTestReportAsset returns the file referred by the html report, the filePath is relative to the report directory.
+func GetWorkflowV4HTMLTestReportAsset(workflowName, jobName string, taskID int64, filePath string, log *zap.SugaredLogger) ([]byte, error) {
+	jobTask, err := findWorkflowV4JobTask(workflowName, jobName, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if jobTask.HtmlReport == nil {
+		return nil, fmt.Errorf("job: %s has no html report", jobName)
+	}
+	// clean the path so that files out of the report directory can not be read
+	name := strings.TrimPrefix(path.Clean("/"+filePath), "/")
+	if name == "" {
+		name = jobTask.HtmlReport.ReportFile
+	}
+	return downloadHTMLReportFile(path.Join(jobTask.HtmlReport.S3DestDir, name), log)
+}
+
+func findWorkflowV4JobTask(workflowName, jobName string, taskID int64) (*commonmodels.JobTask, error) {
+	workflowTask, err := mongodb.NewworkflowTaskv4Coll().Find(workflowName, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find workflow task, workflow name: %s, task id: %d", workflowName, taskID)
+	}
+	for _, stage := range workflowTask.Stages {
+		for _, job := range stage.Jobs {
+			if job.Name == jobName {
+				return job, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("cannot find job task, workflow name: %s, task id: %d, job name: %s", workflowName, taskID, jobName)
+}
+
+func downloadHTMLReportFile(filePath string, log *zap.SugaredLogger) ([]byte, error) {
 	store, err := s3.FindDefaultS3()
 	if err != nil {
 		log.Errorf("parse storageURI failed, err: %s", err)
-		return "", e.ErrGetTestReport.AddErr(err)
+		return nil, e.ErrGetTestReport.AddErr(err)
 	}
 
 	tmpFilename, err := util.GenerateTmpFile()
 	if err != nil {
 		log.Errorf("generate temp file error: %s", err)
-		return "", e.ErrGetTestReport.AddErr(err)
+		return nil, e.ErrGetTestReport.AddErr(err)
 	}
 	defer func() {
 		_ = os.Remove(tmpFilename)
@@ -432,22 +478,35 @@ func GetWorkflowV4HTMLTestReport(workflowName, jobName string, taskID int64, log
 	client, err := s3tool.NewClient(store.Endpoint, store.Ak, store.Sk, store.Region, store.Insecure, forcedPathStyle)
 	if err != nil {
 		log.Errorf("download html test report error: %s", err)
-		return "", e.ErrGetTestReport.AddErr(err)
+		return nil, e.ErrGetTestReport.AddErr(err)
 	}
 	objectKey := store.GetObjectPath(filePath)
 	err = client.Download(store.Bucket, objectKey, tmpFilename)
 	if err != nil {
 		log.Errorf("download html test report error: %s", err)
-		return "", e.ErrGetTestReport.AddErr(err)
+		return nil, e.ErrGetTestReport.AddErr(err)
 	}
 
 	content, err := os.ReadFile(tmpFilename)
 	if err != nil {
 		log.Errorf("parse test report file error: %s", err)
-		return "", e.ErrGetTestReport.AddErr(err)
+		return nil, e.ErrGetTestReport.AddErr(err)
 	}
+	return content, nil
+}
 
-	return string(content), nil
+var htmlHeadRegexp = regexp.MustCompile(`(?i)<head[^>]*>`)
+
+// setHTMLReportBase adds a base element to the report, so the relative links of the report point to the archived assets.
+func setHTMLReportBase(content, baseHref string) string {
+	if strings.Contains(strings.ToLower(content), "<base ") {
+		return content
+	}
+	base := fmt.Sprintf(`<base href="%s">`, baseHref)
+	if loc := htmlHeadRegexp.FindStringIndex(content); loc != nil {
+		return content[:loc[1]] + base + content[loc[1]:]
+	}
+	return base + content
 }
 
 func validateTestReportParam(pipelineName, pipelineType, taskIDStr, testName string, log *zap.SugaredLogger) error {
@@ -215,6 +215,30 @@ func (c *Client) SyncEnvVariables(productName, envName string, log *zap.SugaredL
 	return nil
 }
 
+// SetEnvSleep makes the env sleep or wake up, action is sleep or wake
+func (c *Client) SetEnvSleep(productName, envName, action string, log *zap.SugaredLogger) error {
+	url := fmt.Sprintf("%s/environment/environments/%s/%s?projectName=%s", c.APIBase, envName, action, productName)
+	request, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		log.Errorf("SetEnvSleep new http request error: %v", err)
+		return err
+	}
+
+	ret, err := c.Conn.Do(request)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to %s env", action)
+	}
+	defer func() { _ = ret.Body.Close() }()
+	body, err := ioutil.ReadAll(ret.Body)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to %s env", action)
+	}
+	if ret.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to %s env, status: %d, response: %s", action, ret.StatusCode, string(body))
+	}
+	return nil
+}
+
 func (c *Client) SyncEnvResource(productName, envName, resType, resName string, log *zap.SugaredLogger) error {
 	url := fmt.Sprintf("%s/environment/envcfgs/%s/%s/%s/sync?projectName=%s", c.APIBase, envName, resType, resName, productName)
 	request, err := http.NewRequest("PUT", url, nil)
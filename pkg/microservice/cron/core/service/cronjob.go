@@ -39,6 +39,7 @@ type Cronjob struct {
 	WorkflowArgs   *WorkflowTaskArgs `json:"workflow_args,omitempty"`
 	TestArgs       *TestTaskArgs     `json:"test_args,omitempty"`
 	WorkflowV4Args *WorkflowV4       `json:"workflow_v4_args"`
+	EnvArgs        *EnvArgs          `json:"env_args,omitempty"`
 	JobType        string            `json:"job_type"`
 	Enabled        bool              `json:"enabled"`
 }
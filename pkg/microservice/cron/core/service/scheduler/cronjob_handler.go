@@ -189,6 +189,11 @@ func (h *CronjobHandler) updateCronjob(name, productName, jobType string, jobLis
 			if err != nil {
 				return err
 			}
+		case setting.EnvSleepCronjob:
+			err := h.registerEnvSleepJob(name, cron, job)
+			if err != nil {
+				return err
+			}
 		default:
 			log.Errorf("unrecognized cron job type for job id: %s", job.ID)
 		}
@@ -322,6 +327,29 @@ func (h *CronjobHandler) registerTestJob(name, productName, schedule string, job
 	return nil
 }
 
+func (h *CronjobHandler) registerEnvSleepJob(name, schedule string, job *service.Schedule) error {
+	if job.EnvArgs == nil {
+		return nil
+	}
+	scheduleJob, err := cronlib.NewJobModel(schedule, func() {
+		if err := h.aslanCli.SetEnvSleep(job.EnvArgs.ProductName, job.EnvArgs.EnvName, job.EnvArgs.Action, log.SugaredLogger()); err != nil {
+			log.Errorf("[%s]RunScheduledTask err: %v", name, err)
+		}
+	})
+	if err != nil {
+		log.Errorf("Failed to create job of ID: %s, the error is: %v", job.ID.Hex(), err)
+		return err
+	}
+
+	log.Infof("registering jobID: %s with cron: %s", job.ID.Hex(), schedule)
+	err = h.Scheduler.UpdateJobModel(job.ID.Hex(), scheduleJob)
+	if err != nil {
+		log.Errorf("Failed to register job of ID: %s to scheduler, the error is: %v", job.ID, err)
+		return err
+	}
+	return nil
+}
+
 // FIXME
 // UNDER CURRENT SERVICE STRUCTURE, STOPPING CRONJOB SERVICE AND UPDATING DB RECORD
 // ARE NOT ATOMIC, THIS WILL CAUSE SERIOUS PROBLEM IF UPDATE FAILED
@@ -449,6 +477,26 @@ func registerCronjob(job *service.Cronjob, client *client.Client, scheduler *cro
 			log.Errorf("Failed to register job of ID: %s to scheduler, the error is: %v", job.ID, err)
 			return err
 		}
+	case setting.EnvSleepCronjob:
+		if job.EnvArgs == nil {
+			return fmt.Errorf("env args is nil")
+		}
+		cron := fmt.Sprintf("%s%s", "0 ", job.Cron)
+		scheduleJob, err := cronlib.NewJobModel(cron, func() {
+			if err := client.SetEnvSleep(job.EnvArgs.ProductName, job.EnvArgs.EnvName, job.EnvArgs.Action, log.SugaredLogger()); err != nil {
+				log.Errorf("[%s]RunScheduledTask err: %v", job.Name, err)
+			}
+		})
+		if err != nil {
+			log.Errorf("Failed to generate job of ID: %s to scheduler, the error is: %v", job.ID, err)
+			return err
+		}
+		log.Infof("registering jobID: %s with cron: %s", job.ID, cron)
+		err = scheduler.UpdateJobModel(job.ID, scheduleJob)
+		if err != nil {
+			log.Errorf("Failed to register job of ID: %s to scheduler, the error is: %v", job.ID, err)
+			return err
+		}
 	default:
 		fmt.Printf("Not supported type of service: %s\n", job.Type)
 		return errors.New("not supported service type")
@@ -87,6 +87,7 @@ type Schedule struct {
 	WorkflowArgs   *WorkflowTaskArgs  `bson:"workflow_args,omitempty"       json:"workflow_args,omitempty"`
 	TestArgs       *TestTaskArgs      `bson:"test_args,omitempty"           json:"test_args,omitempty"`
 	WorkflowV4Args *WorkflowV4        `bson:"workflow_v4_args"              json:"workflow_v4_args"`
+	EnvArgs        *EnvArgs           `bson:"env_args,omitempty"            json:"env_args,omitempty"`
 	Type           ScheduleType       `bson:"type"                          json:"type"`
 	Cron           string             `bson:"cron"                          json:"cron"`
 	IsModified     bool               `bson:"-"                             json:"-"`
@@ -351,6 +352,13 @@ type VersionArgs struct {
 	Labels  []string `bson:"labels"  json:"labels"`
 }
 
+// EnvArgs is the args of env sleep cronjob, action is sleep or wake
+type EnvArgs struct {
+	ProductName string `bson:"product_name" json:"product_name"`
+	EnvName     string `bson:"env_name"     json:"env_name"`
+	Action      string `bson:"action"       json:"action"`
+}
+
 type TestTaskArgs struct {
 	ProductName     string `bson:"product_name"            json:"product_name"`
 	TestName        string `bson:"test_name"               json:"test_name"`
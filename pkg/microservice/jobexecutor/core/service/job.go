@@ -17,9 +17,11 @@ limitations under the License.
 package job
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -29,8 +31,11 @@ import (
 	"github.com/koderover/zadig/pkg/microservice/jobexecutor/config"
 	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/meta"
 	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/step"
+	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/s3"
 	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/util/mask"
 	"gopkg.in/yaml.v3"
 )
 
@@ -45,9 +50,20 @@ const (
 	// MaxContainerTerminationMessageLength is the upper bound any one container may write to
 	// its termination message path. Contents above this length will cause a failure.
 	MaxContainerTerminationMessageLength = 1024 * 4
+
+	serviceReadyTimeout = 5 * time.Minute
+
+	stepStatusPassed  = "passed"
+	stepStatusFailed  = "failed"
+	stepStatusTimeout = "timeout"
+	stepStatusSkipped = "skipped"
 )
 
-func NewJob() (*Job, error) {
+// stepTimeoutUnit is the unit of the step timeout, it is shortened in the tests.
+var stepTimeoutUnit = time.Minute
+
+// LoadJobContext reads the job context, it runs before the logger is initialized so nothing is logged here.
+func LoadJobContext() (*meta.JobContext, error) {
 	context, err := ioutil.ReadFile(config.JobConfigFile())
 	if err != nil {
 		return nil, fmt.Errorf("read job config file error: %v", err)
@@ -57,7 +73,41 @@ func NewJob() (*Job, error) {
 	if err := yaml.Unmarshal(context, &ctx); err != nil {
 		return nil, fmt.Errorf("cannot unmarshal job data: %v", err)
 	}
+	return ctx, nil
+}
+
+// MaskOutput redirects stdout and stderr, which the logger and all the steps write to, through a masker of the
+// secrets in the job context, so that no step can print them in the job log. The returned function restores
+// stdout and stderr after the buffered output is written.
+func MaskOutput(ctx *meta.JobContext) (func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipe for masking output: %v", err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+
+	writer := mask.NewWriter(stdout, mask.NewMasker(ctx.SecretValues()))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(writer, r)
+		_ = writer.Flush()
+	}()
+
+	return func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		_ = w.Close()
+		// processes left running in the background may still hold the pipe
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			_ = writer.Flush()
+		}
+	}, nil
+}
 
+func NewJob(ctx *meta.JobContext) (*Job, error) {
 	if ctx.Paths != "" {
 		ctx.Paths = fmt.Sprintf("%s:%s", config.Path(), ctx.Paths)
 	} else {
@@ -68,11 +118,15 @@ func NewJob() (*Job, error) {
 		Ctx: ctx,
 	}
 
-	err = job.EnsureActiveWorkspace(ctx.Workspace)
+	err := job.EnsureActiveWorkspace(ctx.Workspace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to ensure active workspace `%s`: %s", ctx.Workspace, err)
 	}
 
+	if err := job.writeSecretFiles(); err != nil {
+		return nil, err
+	}
+
 	userEnvs := job.getUserEnvs()
 	job.UserEnvs = make(map[string]string, len(userEnvs))
 	for _, env := range userEnvs {
@@ -106,6 +160,26 @@ func (j *Job) EnsureActiveWorkspace(workspace string) error {
 	return os.Chdir(j.ActiveWorkspace)
 }
 
+// writeSecretFiles writes the credentials provided as files, only the user running the job can read them.
+func (j *Job) writeSecretFiles() error {
+	if len(j.Ctx.SecretFiles) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(job.JobSecretFileDir, 0700); err != nil {
+		return fmt.Errorf("failed to create secret file dir: %v", err)
+	}
+	for _, secretFile := range j.Ctx.SecretFiles {
+		kv := strings.SplitN(secretFile, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(job.JobSecretFileDir, filepath.Base(kv[0])), []byte(kv[1]), 0400); err != nil {
+			return fmt.Errorf("failed to write secret file of %s: %v", kv[0], err)
+		}
+	}
+	return nil
+}
+
 func (j *Job) getUserEnvs() []string {
 	envs := []string{
 		"CI=true",
@@ -119,6 +193,10 @@ func (j *Job) getUserEnvs() []string {
 	envs = append(envs, fmt.Sprintf("DOCKER_HOST=%s", config.DockerHost()))
 	envs = append(envs, j.Ctx.Envs...)
 	envs = append(envs, j.Ctx.SecretEnvs...)
+	for _, secretFile := range j.Ctx.SecretFiles {
+		key := strings.SplitN(secretFile, "=", 2)[0]
+		envs = append(envs, fmt.Sprintf("%s=%s", key, filepath.Join(job.JobSecretFileDir, filepath.Base(key))))
+	}
 	// share output var between steps.
 	outputs, err := j.getJobOutputVars(context.Background())
 	if err != nil {
@@ -135,20 +213,128 @@ func (j *Job) Run(ctx context.Context) error {
 	if err := os.MkdirAll(job.JobOutputDir, os.ModePerm); err != nil {
 		return err
 	}
+	if j.Ctx.WaitServices {
+		if err := waitServicesReady(ctx, job.JobServicesReadyFile, serviceReadyTimeout); err != nil {
+			return err
+		}
+	}
+	return j.runSteps(ctx, writeStepResults)
+}
+
+// runSteps runs the steps in order, the steps after a failed one are skipped unless they run on failure.
+// The results are written after every step, so that they are still reported if the job is killed.
+func (j *Job) runSteps(ctx context.Context, writeResults func(results []*job.StepResult) error) error {
 	hasFailed := false
 	var respErr error
+	results := make([]*job.StepResult, 0, len(j.Ctx.Steps))
 	for _, stepInfo := range j.Ctx.Steps {
 		if hasFailed && !stepInfo.Onfailure {
+			results = append(results, &job.StepResult{Name: stepInfo.Name, Status: stepStatusSkipped})
 			continue
 		}
-		if err := step.RunStep(ctx, stepInfo, j.ActiveWorkspace, j.Ctx.Paths, j.getUserEnvs(), j.Ctx.SecretEnvs); err != nil {
-			hasFailed = true
-			respErr = err
+		result := j.runStep(ctx, stepInfo)
+		results = append(results, result)
+		if err := writeResults(results); err != nil {
+			log.Warnf("Failed to write step results: %s", err)
+		}
+		if result.Status == stepStatusPassed {
+			continue
 		}
+		if stepInfo.ContinueOnError {
+			log.Warnf("Step %s %s, continue since continue_on_error is set.", stepInfo.Name, result.Status)
+			continue
+		}
+		hasFailed = true
+		respErr = fmt.Errorf("step %s %s: %s", stepInfo.Name, result.Status, result.Error)
+	}
+	if err := writeResults(results); err != nil {
+		log.Warnf("Failed to write step results: %s", err)
 	}
 	return respErr
 }
 
+// runStep runs the step in its working directory, a step exceeding its timeout is cancelled,
+// and a failed or timed out step runs again until it passes or the retries are used up.
+func (j *Job) runStep(ctx context.Context, stepInfo *meta.Step) *job.StepResult {
+	result := &job.StepResult{Name: stepInfo.Name, StartTime: time.Now().Unix()}
+	defer func() {
+		result.EndTime = time.Now().Unix()
+	}()
+
+	workspace := j.ActiveWorkspace
+	if stepInfo.WorkingDir != "" {
+		workspace = filepath.Join(j.ActiveWorkspace, stepInfo.WorkingDir)
+		if err := os.MkdirAll(workspace, os.ModePerm); err != nil {
+			result.Status, result.Error = stepStatusFailed, fmt.Sprintf("create working dir %s error: %v", workspace, err)
+			return result
+		}
+	}
+
+	for attempt := 0; attempt <= stepInfo.Retry; attempt++ {
+		if attempt > 0 {
+			log.Infof("Retrying step %s, attempt %d of %d.", stepInfo.Name, attempt, stepInfo.Retry)
+		}
+		result.Attempts = attempt + 1
+
+		stepCtx, cancel := stepContext(ctx, stepInfo.Timeout)
+		err := step.RunStep(stepCtx, stepInfo, workspace, j.Ctx.Paths, j.getUserEnvs(), j.Ctx.SecretEnvs)
+		timeout := stepCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil
+		cancel()
+
+		switch {
+		case err == nil:
+			result.Status, result.Error = stepStatusPassed, ""
+			return result
+		case timeout:
+			log.Errorf("Step %s timed out after %d minutes.", stepInfo.Name, stepInfo.Timeout)
+			result.Status, result.Error = stepStatusTimeout, fmt.Sprintf("timed out after %d minutes", stepInfo.Timeout)
+		default:
+			result.Status, result.Error = stepStatusFailed, err.Error()
+		}
+		// the job itself is cancelled, no need to retry.
+		if ctx.Err() != nil {
+			return result
+		}
+	}
+	return result
+}
+
+func stepContext(ctx context.Context, timeoutInMinutes int64) (context.Context, context.CancelFunc) {
+	if timeoutInMinutes <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(timeoutInMinutes)*stepTimeoutUnit)
+}
+
+func writeStepResults(results []*job.StepResult) error {
+	content, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(job.JobStepResultFile, content, 0644)
+}
+
+// waitServicesReady waits until the ready file is created, aslan creates it once all the service containers,
+// which are started along with the job container, pass their readiness probes.
+func waitServicesReady(ctx context.Context, readyFile string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log.Infof("Waiting for services to be ready.")
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		if _, err := os.Stat(readyFile); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("services are not ready in %s", timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
 func (j *Job) AfterRun(ctx context.Context) error {
 	return j.collectJobResult(ctx)
 }
@@ -158,11 +344,36 @@ func (j *Job) collectJobResult(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("get job output vars error: %v", err)
 	}
+	for _, output := range outputs {
+		if err := j.resolveOutput(output); err != nil {
+			return err
+		}
+		if len(output.Value) > job.MaxInlineOutputLength {
+			if err := j.uploadOutput(output); err != nil {
+				return err
+			}
+		}
+	}
 	jsonOutput, err := json.Marshal(outputs)
 	if err != nil {
 		return err
 	}
 
+	// too many small outputs, upload all of them.
+	if len(jsonOutput) > MaxContainerTerminationMessageLength {
+		for _, output := range outputs {
+			if output.ObjectKey != "" {
+				continue
+			}
+			if err := j.uploadOutput(output); err != nil {
+				return err
+			}
+		}
+		if jsonOutput, err = json.Marshal(outputs); err != nil {
+			return err
+		}
+	}
+
 	if len(jsonOutput) > MaxContainerTerminationMessageLength {
 		return fmt.Errorf("termination message is above max allowed size 4096, caused by large task result")
 	}
@@ -179,6 +390,67 @@ func (j *Job) collectJobResult(ctx context.Context) error {
 	return f.Sync()
 }
 
+// resolveOutput sets the value of the output by its type.
+func (j *Job) resolveOutput(output *job.JobOutput) error {
+	switch j.Ctx.OutputTypes[output.Name] {
+	case job.OutputTypeFile:
+		filePath := output.Value
+		if !filepath.IsAbs(filePath) {
+			filePath = filepath.Join(j.ActiveWorkspace, filePath)
+		}
+		content, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("read file of output %s error: %v", output.Name, err)
+		}
+		output.Value = string(content)
+	case job.OutputTypeJSON:
+		buf := &bytes.Buffer{}
+		if err := json.Compact(buf, []byte(output.Value)); err != nil {
+			return fmt.Errorf("output %s is not valid json: %v", output.Name, err)
+		}
+		output.Value = buf.String()
+	}
+	return nil
+}
+
+// uploadOutput saves the value of the output in the object storage, aslan downloads it when the job finished.
+func (j *Job) uploadOutput(output *job.JobOutput) error {
+	storage := j.Ctx.OutputStorage
+	if storage == nil {
+		return fmt.Errorf("output %s is too large for the termination message and no object storage is configured", output.Name)
+	}
+	forcedPathStyle := true
+	if storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3.NewClient(storage.Endpoint, storage.Ak, storage.Sk, storage.Region, storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 client to upload output, err: %s", err)
+	}
+	tmpFile, err := ioutil.TempFile("", "output")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.WriteString(output.Value); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	objectKey := job.GetOutputObjectKey(storage.Subfolder, j.Ctx.WorkflowName, j.Ctx.TaskID, j.Ctx.Name, output.Name)
+	if err := client.Upload(storage.Bucket, tmpFile.Name(), objectKey); err != nil {
+		return fmt.Errorf("upload output %s error: %v", output.Name, err)
+	}
+	log.Infof("output %s is uploaded to %s since it is too large", output.Name, objectKey)
+	output.ObjectKey = objectKey
+	output.StorageID = j.Ctx.OutputStorageID
+	output.Value = ""
+	return nil
+}
+
 func (j *Job) getJobOutputVars(ctx context.Context) ([]*job.JobOutput, error) {
 	outputs := []*job.JobOutput{}
 	for _, outputName := range j.Ctx.Outputs {
@@ -0,0 +1,195 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/meta"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+func TestMain(m *testing.M) {
+	log.Init(&log.Config{Level: "info", NoCaller: true, NoLogLevel: true})
+	os.Exit(m.Run())
+}
+
+func TestWaitServicesReady(t *testing.T) {
+	readyFile := filepath.Join(t.TempDir(), "services_ready")
+
+	err := waitServicesReady(context.Background(), readyFile, 1500*time.Millisecond)
+	assert.Error(t, err)
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		_ = os.WriteFile(readyFile, nil, 0644)
+	}()
+	err = waitServicesReady(context.Background(), readyFile, 5*time.Second)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = waitServicesReady(ctx, filepath.Join(t.TempDir(), "missing"), 5*time.Second)
+	assert.Error(t, err)
+}
+
+func newShellStep(name, script string) *meta.Step {
+	return &meta.Step{
+		Name:     name,
+		StepType: "shell",
+		Spec:     &step.StepShellSpec{Scripts: []string{script}, SkipPrepare: true},
+	}
+}
+
+func newTestJob(t *testing.T, steps ...*meta.Step) *Job {
+	return &Job{
+		Ctx:             &meta.JobContext{Paths: os.Getenv("PATH"), Steps: steps},
+		ActiveWorkspace: t.TempDir(),
+	}
+}
+
+// processAlive tells whether the process is running, the killed processes may stay as zombies until they are reaped.
+func processAlive(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestRunStep(t *testing.T) {
+	stepTimeoutUnit = 500 * time.Millisecond
+	defer func() { stepTimeoutUnit = time.Minute }()
+
+	t.Run("passed", func(t *testing.T) {
+		j := newTestJob(t)
+		result := j.runStep(context.Background(), newShellStep("pass", "echo ok"))
+		assert.Equal(t, stepStatusPassed, result.Status)
+		assert.Equal(t, 1, result.Attempts)
+	})
+
+	t.Run("passed after retries", func(t *testing.T) {
+		j := newTestJob(t)
+		stepInfo := newShellStep("flaky", `echo run >> count; [ "$(wc -l < count)" -ge 3 ]`)
+		stepInfo.Retry = 3
+		result := j.runStep(context.Background(), stepInfo)
+		assert.Equal(t, stepStatusPassed, result.Status)
+		assert.Equal(t, 3, result.Attempts)
+	})
+
+	t.Run("failed after retries", func(t *testing.T) {
+		j := newTestJob(t)
+		stepInfo := newShellStep("fail", "exit 1")
+		stepInfo.Retry = 2
+		result := j.runStep(context.Background(), stepInfo)
+		assert.Equal(t, stepStatusFailed, result.Status)
+		assert.Equal(t, 3, result.Attempts)
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		j := newTestJob(t)
+		stepInfo := newShellStep("slow", "sleep 10")
+		stepInfo.Timeout = 1
+		stepInfo.Retry = 1
+		start := time.Now()
+		result := j.runStep(context.Background(), stepInfo)
+		assert.Equal(t, stepStatusTimeout, result.Status)
+		assert.Equal(t, 2, result.Attempts)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("the processes forked by a timed out step are killed", func(t *testing.T) {
+		j := newTestJob(t)
+		stepInfo := newShellStep("fork", "sleep 30 & echo $! > pid; wait")
+		stepInfo.Timeout = 1
+		start := time.Now()
+		result := j.runStep(context.Background(), stepInfo)
+		assert.Equal(t, stepStatusTimeout, result.Status)
+		// the step waits for the output of the forked process, it only returns in time if the process is killed.
+		assert.Less(t, time.Since(start), 5*time.Second)
+
+		content, err := os.ReadFile(filepath.Join(j.ActiveWorkspace, "pid"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Eventually(t, func() bool { return !processAlive(pid) }, 3*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("cancelled job is not retried", func(t *testing.T) {
+		j := newTestJob(t)
+		stepInfo := newShellStep("slow", "sleep 10")
+		stepInfo.Retry = 3
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+		result := j.runStep(ctx, stepInfo)
+		assert.Equal(t, stepStatusFailed, result.Status)
+		assert.Equal(t, 1, result.Attempts)
+	})
+}
+
+func TestRunSteps(t *testing.T) {
+	statuses := func(results []*job.StepResult) []string {
+		resp := make([]string, 0, len(results))
+		for _, result := range results {
+			resp = append(resp, result.Status)
+		}
+		return resp
+	}
+
+	t.Run("continue on error", func(t *testing.T) {
+		failed := newShellStep("lint", "exit 1")
+		failed.ContinueOnError = true
+		j := newTestJob(t, failed, newShellStep("test", "echo ok"))
+		var results []*job.StepResult
+		err := j.runSteps(context.Background(), func(r []*job.StepResult) error {
+			results = r
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{stepStatusFailed, stepStatusPassed}, statuses(results))
+	})
+
+	t.Run("steps after a failed step are skipped", func(t *testing.T) {
+		cleanup := newShellStep("cleanup", "echo cleanup")
+		cleanup.Onfailure = true
+		j := newTestJob(t, newShellStep("lint", "exit 1"), newShellStep("test", "echo ok"), cleanup)
+		var results []*job.StepResult
+		err := j.runSteps(context.Background(), func(r []*job.StepResult) error {
+			results = r
+			return nil
+		})
+		assert.Error(t, err)
+		assert.Equal(t, []string{stepStatusFailed, stepStatusSkipped, stepStatusPassed}, statuses(results))
+	})
+}
@@ -16,6 +16,13 @@ limitations under the License.
 
 package meta
 
+import (
+	"strings"
+
+	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
 type JobContext struct {
 	Name string `yaml:"name"`
 	// Workspace 容器工作目录 [必填]
@@ -24,6 +31,12 @@ type JobContext struct {
 	Envs EnvVar `yaml:"envs"`
 	// SecretEnvs 用户注入敏感信息环境变量, value不能在stdout stderr中输出 [optional]
 	SecretEnvs EnvVar `yaml:"secret_envs"`
+	// SecretFiles 以文件形式提供的敏感信息, 文件只有当前用户可读, 同名环境变量的值为文件路径 [optional]
+	SecretFiles EnvVar `yaml:"secret_files"`
+	// Credentials 其他需要在日志中脱敏的敏感信息, 如工作流的敏感参数 [optional]
+	Credentials []string `yaml:"credentials"`
+	// WaitServices 在执行步骤前等待服务容器就绪, aslan 在服务容器就绪后创建 JobServicesReadyFile [optional]
+	WaitServices bool `yaml:"wait_services"`
 	// WorkflowName
 	WorkflowName string `yaml:"workflow_name"`
 	// TaskID
@@ -33,6 +46,12 @@ type JobContext struct {
 
 	Steps   []*Step  `yaml:"steps"`
 	Outputs []string `yaml:"outputs"`
+	// OutputTypes is the type of the outputs, outputs not in it are strings.
+	OutputTypes map[string]job.OutputType `yaml:"output_types"`
+	// OutputStorage saves the output values too large for the termination message.
+	OutputStorage *step.S3 `yaml:"output_storage"`
+	// OutputStorageID is the id of OutputStorage, it is kept with the outputs saved in it.
+	OutputStorageID string `yaml:"output_storage_id"`
 }
 
 type Step struct {
@@ -40,6 +59,27 @@ type Step struct {
 	StepType  string      `yaml:"type"`
 	Onfailure bool        `yaml:"on_failure"`
 	Spec      interface{} `yaml:"spec"`
+	// Timeout 步骤超时时间, 单位分钟 [optional]
+	Timeout int64 `yaml:"timeout"`
+	// Retry 步骤失败或超时后的最大重试次数 [optional]
+	Retry int `yaml:"retry"`
+	// ContinueOnError 步骤失败时不影响任务结果 [optional]
+	ContinueOnError bool `yaml:"continue_on_error"`
+	// WorkingDir 步骤执行目录, 相对于工作目录 [optional]
+	WorkingDir string `yaml:"working_dir"`
 }
 
 type EnvVar []string
+
+// SecretValues returns all the values which must be masked in the logs.
+func (c *JobContext) SecretValues() []string {
+	values := append([]string{}, c.Credentials...)
+	for _, env := range append(append([]string{}, c.SecretEnvs...), c.SecretFiles...) {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		values = append(values, kv[1])
+	}
+	return values
+}
@@ -22,13 +22,16 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"strings"
+	"syscall"
 
 	"github.com/koderover/zadig/pkg/microservice/jobexecutor/config"
 	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/cmd"
 	"github.com/koderover/zadig/pkg/microservice/jobexecutor/core/service/meta"
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/util"
+	"github.com/koderover/zadig/pkg/util/mask"
 )
 
 type Step interface {
@@ -55,6 +58,11 @@ func RunStep(ctx context.Context, step *meta.Step, workspace, paths string, envs
 		if err != nil {
 			return err
 		}
+	case "image_build":
+		stepInstance, err = NewImageBuildStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
 	case "tools":
 		stepInstance, err = NewToolInstallStep(step.Spec, workspace, envs, secretEnvs)
 		if err != nil {
@@ -70,11 +78,41 @@ func RunStep(ctx context.Context, step *meta.Step, workspace, paths string, envs
 		if err != nil {
 			return err
 		}
+	case "html_report":
+		stepInstance, err = NewHtmlReportStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
 	case "tar_archive":
 		stepInstance, err = NewTararchiveStep(step.Spec, workspace, envs, secretEnvs)
 		if err != nil {
 			return err
 		}
+	case "cache_restore":
+		stepInstance, err = NewCacheRestoreStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
+	case "cache_save":
+		stepInstance, err = NewCacheSaveStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
+	case "artifact_upload":
+		stepInstance, err = NewArtifactUploadStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
+	case "artifact_download":
+		stepInstance, err = NewArtifactDownloadStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
+	case "attestation":
+		stepInstance, err = NewAttestationStep(step.Spec, workspace, envs, secretEnvs)
+		if err != nil {
+			return err
+		}
 	case "sonar_check":
 		stepInstance, err = NewSonarCheckStep(step.Spec, workspace, envs, secretEnvs)
 		if err != nil {
@@ -112,6 +150,7 @@ func prepareScriptsEnv() []string {
 
 func handleCmdOutput(pipe io.ReadCloser, needPersistentLog bool, logFile string, secretEnvs []string) {
 	reader := bufio.NewReader(pipe)
+	masker := mask.NewMasker(secretEnvValues(secretEnvs))
 
 	for {
 		lineBytes, err := reader.ReadBytes('\n')
@@ -124,10 +163,11 @@ func handleCmdOutput(pipe io.ReadCloser, needPersistentLog bool, logFile string,
 			break
 		}
 
-		fmt.Printf("%s", maskSecretEnvs(string(lineBytes), secretEnvs))
+		line := masker.Mask(string(lineBytes))
+		fmt.Printf("%s", line)
 
 		if needPersistentLog {
-			err := util.WriteFile(logFile, lineBytes, 0700)
+			err := util.WriteFile(logFile, []byte(line), 0700)
 			if err != nil {
 				log.Warnf("Failed to write file when processing cmd output: %s", err)
 			}
@@ -135,42 +175,18 @@ func handleCmdOutput(pipe io.ReadCloser, needPersistentLog bool, logFile string,
 	}
 }
 
-const (
-	secretEnvMask = "********"
-)
-
-func maskSecret(secrets []string, message string) string {
-	out := message
-
-	for _, val := range secrets {
-		if len(val) == 0 {
-			continue
-		}
-		out = strings.Replace(out, val, "********", -1)
-	}
-	return out
-}
-
-func maskSecretEnvs(message string, secretEnvs []string) string {
-	out := message
-
-	for _, val := range secretEnvs {
-		if len(val) == 0 {
-			continue
-		}
-		sl := strings.Split(val, "=")
-
-		if len(sl) != 2 {
-			continue
-		}
-
-		if len(sl[0]) == 0 || len(sl[1]) == 0 {
+// secretEnvValues returns the values of the secret envs in the form of key=value, values may contain "=".
+func secretEnvValues(secretEnvs []string) []string {
+	values := make([]string, 0, len(secretEnvs))
+	for _, env := range secretEnvs {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 || len(kv[1]) == 0 {
 			// invalid key value pair received
 			continue
 		}
-		out = strings.Replace(out, strings.Join(sl[1:], "="), secretEnvMask, -1)
+		values = append(values, kv[1])
 	}
-	return out
+	return values
 }
 
 func isDirEmpty(dir string) bool {
@@ -189,3 +205,38 @@ func setCmdsWorkDir(dir string, cmds []*cmd.Command) {
 		c.Cmd.Dir = dir
 	}
 }
+
+// startCmd starts the command in its own process group and kills the whole group once ctx is done,
+// so that the processes forked by the command don't outlive a timed out or cancelled step.
+// The returned function waits for the command to exit.
+func startCmd(ctx context.Context, cmd *exec.Cmd) (func() error, error) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+	return func() error {
+		err := cmd.Wait()
+		close(done)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}, nil
+}
+
+func runCmd(ctx context.Context, cmd *exec.Cmd) error {
+	wait, err := startCmd(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	return wait()
+}
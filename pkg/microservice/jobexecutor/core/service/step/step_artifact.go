@@ -0,0 +1,242 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+type ArtifactUploadStep struct {
+	spec       *step.StepArtifactUploadSpec
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewArtifactUploadStep(spec interface{}, workspace string, envs, secretEnvs []string) (*ArtifactUploadStep, error) {
+	artifactUploadStep := &ArtifactUploadStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return artifactUploadStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &artifactUploadStep.spec); err != nil {
+		return artifactUploadStep, fmt.Errorf("unmarshal spec %s to artifact upload spec failed", yamlBytes)
+	}
+	return artifactUploadStep, nil
+}
+
+// Run packs the paths relative to the workspace and uploads the archive along with its sha256 checksum.
+func (s *ArtifactUploadStep) Run(ctx context.Context) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("Upload artifact ended. Duration: %.2f seconds", time.Since(start).Seconds())
+	}()
+
+	envmaps := makeCacheEnvMap(s.envs, s.secretEnvs)
+	artifactPaths := make([]string, 0, len(s.spec.Paths))
+	for _, artifactPath := range s.spec.Paths {
+		artifactPath = expandCacheEnv(artifactPath, envmaps)
+		if artifactPath == "" {
+			continue
+		}
+		relPath, err := relWorkspacePath(s.workspace, artifactPath)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(s.workspace, relPath)); err != nil {
+			return fmt.Errorf("artifact path %s is not accessible: %s", artifactPath, err)
+		}
+		artifactPaths = append(artifactPaths, relPath)
+	}
+	if len(artifactPaths) == 0 {
+		return fmt.Errorf("no path to upload for artifact %s", s.spec.Name)
+	}
+
+	client, err := newArtifactClient(s.spec.S3Storage)
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "artifact")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir for artifact archive: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archive := filepath.Join(tmpDir, s.spec.Name+step.ArtifactArchiveSuffix)
+	cmd := exec.Command("tar", append([]string{"-czf", archive, "-C", s.workspace}, artifactPaths...)...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to compress artifact %s: %s", s.spec.Name, err)
+	}
+	checksum, err := fileChecksum(archive)
+	if err != nil {
+		return fmt.Errorf("failed to calculate the checksum of artifact %s: %s", s.spec.Name, err)
+	}
+	checksumFile := archive + step.ArtifactChecksumSuffix
+	if err := ioutil.WriteFile(checksumFile, []byte(checksum), 0644); err != nil {
+		return fmt.Errorf("failed to write the checksum of artifact %s: %s", s.spec.Name, err)
+	}
+
+	objectName := s.spec.ObjectName
+	if objectName == "" {
+		objectName = s.spec.Name
+	}
+	objectKey := step.GetArtifactObjectKey(s.spec.S3Storage.Subfolder, s.spec.ObjectDir, objectName)
+	if err := client.Upload(s.spec.S3Storage.Bucket, archive, objectKey); err != nil {
+		return fmt.Errorf("failed to upload artifact %s: %s", s.spec.Name, err)
+	}
+	if err := client.Upload(s.spec.S3Storage.Bucket, checksumFile, objectKey+step.ArtifactChecksumSuffix); err != nil {
+		return fmt.Errorf("failed to upload the checksum of artifact %s: %s", s.spec.Name, err)
+	}
+	log.Infof("Finish uploading artifact %s, sha256: %s.", objectName, checksum)
+	return nil
+}
+
+type ArtifactDownloadStep struct {
+	spec       *step.StepArtifactDownloadSpec
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewArtifactDownloadStep(spec interface{}, workspace string, envs, secretEnvs []string) (*ArtifactDownloadStep, error) {
+	artifactDownloadStep := &ArtifactDownloadStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return artifactDownloadStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &artifactDownloadStep.spec); err != nil {
+		return artifactDownloadStep, fmt.Errorf("unmarshal spec %s to artifact download spec failed", yamlBytes)
+	}
+	return artifactDownloadStep, nil
+}
+
+// Run downloads the artifact uploaded by an earlier job of the workflow task and verifies its checksum before extracting it.
+func (s *ArtifactDownloadStep) Run(ctx context.Context) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("Download artifact ended. Duration: %.2f seconds", time.Since(start).Seconds())
+	}()
+
+	destDir, err := relWorkspacePath(s.workspace, expandCacheEnv(s.spec.DestDir, makeCacheEnvMap(s.envs, s.secretEnvs)))
+	if err != nil {
+		return err
+	}
+	destDir = filepath.Join(s.workspace, destDir)
+	if err := os.MkdirAll(destDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create dest dir %s: %s", destDir, err)
+	}
+
+	client, err := newArtifactClient(s.spec.S3Storage)
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "artifact")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir for artifact archive: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archive := filepath.Join(tmpDir, s.spec.Name+step.ArtifactArchiveSuffix)
+	objectKey := step.GetArtifactObjectKey(s.spec.S3Storage.Subfolder, s.spec.ObjectDir, s.spec.Name)
+	if err := client.Download(s.spec.S3Storage.Bucket, objectKey, archive); err != nil {
+		return fmt.Errorf("failed to download artifact %s: %s", s.spec.Name, err)
+	}
+	if err := client.Download(s.spec.S3Storage.Bucket, objectKey+step.ArtifactChecksumSuffix, archive+step.ArtifactChecksumSuffix); err != nil {
+		return fmt.Errorf("failed to download the checksum of artifact %s: %s", s.spec.Name, err)
+	}
+	expected, err := ioutil.ReadFile(archive + step.ArtifactChecksumSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to read the checksum of artifact %s: %s", s.spec.Name, err)
+	}
+	checksum, err := fileChecksum(archive)
+	if err != nil {
+		return fmt.Errorf("failed to calculate the checksum of artifact %s: %s", s.spec.Name, err)
+	}
+	if checksum != strings.TrimSpace(string(expected)) {
+		return fmt.Errorf("checksum mismatch for artifact %s, expected: %s, actual: %s", s.spec.Name, strings.TrimSpace(string(expected)), checksum)
+	}
+
+	cmd := exec.Command("tar", "-xzf", archive, "-C", destDir)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to extract artifact %s: %s", s.spec.Name, err)
+	}
+	log.Infof("Finish downloading artifact %s to %s.", s.spec.Name, destDir)
+	return nil
+}
+
+func newArtifactClient(storage *step.S3) (*s3.Client, error) {
+	if storage == nil {
+		return nil, fmt.Errorf("object storage is not configured")
+	}
+	forcedPathStyle := true
+	if storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3.NewClient(storage.Endpoint, storage.Ak, storage.Sk, storage.Region, storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %s", err)
+	}
+	return client, nil
+}
+
+// relWorkspacePath returns the path relative to the workspace, paths outside the workspace are rejected
+// since artifacts are extracted to the workspace of another job.
+func relWorkspacePath(workspace, p string) (string, error) {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(workspace, p)
+	}
+	rel, err := filepath.Rel(workspace, filepath.Clean(p))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("path %s is outside the workspace %s", p, workspace)
+	}
+	return rel, nil
+}
+
+func fileChecksum(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/rsa"
+	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const syftExe = "syft"
+
+type AttestationStep struct {
+	spec       *step.StepAttestationSpec
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewAttestationStep(spec interface{}, workspace string, envs, secretEnvs []string) (*AttestationStep, error) {
+	attestationStep := &AttestationStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return attestationStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &attestationStep.spec); err != nil {
+		return attestationStep, fmt.Errorf("unmarshal spec %s to attestation spec failed", yamlBytes)
+	}
+	return attestationStep, nil
+}
+
+// Run generates the SBOM of the pushed image, signs the image digest together with the SBOM checksum,
+// pushes them to the registry as the attestation of the image and uploads the result to the object storage.
+func (s *AttestationStep) Run(ctx context.Context) error {
+	start := time.Now()
+	log.Infof("Start generating attestation.")
+	defer func() {
+		log.Infof("Attestation ended. Duration: %.2f seconds.", time.Since(start).Seconds())
+	}()
+
+	image := expandCacheEnv(s.spec.ImageName, makeCacheEnvMap(s.envs, s.secretEnvs))
+	if image == "" {
+		return fmt.Errorf("no image to attest")
+	}
+	if _, err := exec.LookPath(syftExe); err != nil {
+		return fmt.Errorf("%s is required to generate the SBOM, install it before this step: %s", syftExe, err)
+	}
+	// the signing key is written as a secret file before the job runs
+	encodedKey, err := ioutil.ReadFile(filepath.Join(job.JobSecretFileDir, step.GetSigningKeyFileName(s.spec.SigningKeyID)))
+	if err != nil {
+		return fmt.Errorf("failed to read the signing key: %s", err)
+	}
+	signingKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encodedKey)))
+	if err != nil {
+		return fmt.Errorf("failed to decode the signing key: %s", err)
+	}
+	publicKey, err := rsa.PublicKeyOfPrivateKey(signingKey)
+	if err != nil {
+		return fmt.Errorf("failed to load the signing key: %s", err)
+	}
+	if s.spec.DockerRegistry != nil && s.spec.DockerRegistry.UserName != "" {
+		cmd := dockerLogin(s.spec.DockerRegistry.UserName, s.spec.DockerRegistry.Password, s.spec.DockerRegistry.Host)
+		var out bytes.Buffer
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to login docker registry: %s %s", err, out.String())
+		}
+	}
+
+	repository := imageRepository(image)
+	digest, err := imageDigest(ctx, image, repository)
+	if err != nil {
+		return err
+	}
+	log.Infof("Image %s has digest %s.", image, digest)
+
+	tmpDir, err := os.MkdirTemp("", "attestation")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir for attestation: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	sbomFile := filepath.Join(tmpDir, "sbom."+s.spec.SBOMFormat+".json")
+	source := fmt.Sprintf("registry:%s@%s", repository, digest)
+	if s.spec.SBOMSource == step.SBOMSourceWorkspace {
+		source = "dir:" + s.workspace
+	}
+	cmd := exec.Command(syftExe, source, "--output", s.spec.SBOMFormat, "--file", sbomFile)
+	cmd.Dir = s.workspace
+	cmd.Env = s.envs
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := runCmd(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to generate SBOM from %s: %s", source, err)
+	}
+	sbomChecksum, err := fileChecksum(sbomFile)
+	if err != nil {
+		return fmt.Errorf("failed to calculate the checksum of the SBOM: %s", err)
+	}
+
+	payload, err := json.Marshal(&step.AttestationPayload{
+		DockerReference: repository,
+		Digest:          digest,
+		SBOMFormat:      s.spec.SBOMFormat,
+		SBOMChecksum:    sbomChecksum,
+		Timestamp:       time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	signature, err := rsa.SignByPrivateKey(payload, signingKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign image %s: %s", image, err)
+	}
+	encodedSignature := base64.StdEncoding.EncodeToString(signature)
+
+	attestationRef := repository + ":" + strings.Replace(digest, ":", "-", 1) + step.AttestationTagSuffix
+	if err := pushAttestation(ctx, tmpDir, attestationRef, payload, []byte(encodedSignature)); err != nil {
+		return err
+	}
+	log.Infof("Attestation of image %s is pushed to %s.", image, attestationRef)
+
+	client, err := newArtifactClient(s.spec.S3Storage)
+	if err != nil {
+		return err
+	}
+	sbomObjectKey := step.GetAttestationObjectKey(s.spec.S3Storage.Subfolder, s.spec.ObjectDir, filepath.Base(sbomFile))
+	if err := client.Upload(s.spec.S3Storage.Bucket, sbomFile, sbomObjectKey); err != nil {
+		return fmt.Errorf("failed to upload the SBOM: %s", err)
+	}
+	result, err := json.Marshal(&step.AttestationResult{
+		Image:              image,
+		Digest:             digest,
+		SBOMFormat:         s.spec.SBOMFormat,
+		SBOMObjectKey:      sbomObjectKey,
+		SBOMChecksum:       sbomChecksum,
+		Payload:            string(payload),
+		Signature:          encodedSignature,
+		SignatureAlgorithm: step.AttestationSignatureAlgorithm,
+		PublicKey:          string(publicKey),
+		AttestationRef:     attestationRef,
+	})
+	if err != nil {
+		return err
+	}
+	resultFile := filepath.Join(tmpDir, step.AttestationResultFile)
+	if err := ioutil.WriteFile(resultFile, result, 0644); err != nil {
+		return err
+	}
+	resultObjectKey := step.GetAttestationObjectKey(s.spec.S3Storage.Subfolder, s.spec.ObjectDir, step.AttestationResultFile)
+	if err := client.Upload(s.spec.S3Storage.Bucket, resultFile, resultObjectKey); err != nil {
+		return fmt.Errorf("failed to upload the attestation result: %s", err)
+	}
+	return nil
+}
+
+// imageDigest finds the registry digest of the pushed image, the image is pulled if the local one has no digest yet.
+func imageDigest(ctx context.Context, image, repository string) (string, error) {
+	inspect := func() (string, error) {
+		out, err := exec.CommandContext(ctx, dockerExe, "inspect", "--format", `{{join .RepoDigests "\n"}}`, image).Output()
+		if err != nil {
+			return "", err
+		}
+		for _, repoDigest := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			if parts := strings.SplitN(repoDigest, "@", 2); len(parts) == 2 && parts[0] == repository {
+				return parts[1], nil
+			}
+		}
+		return "", nil
+	}
+	if digest, err := inspect(); err == nil && digest != "" {
+		return digest, nil
+	}
+	cmd := exec.Command(dockerExe, "pull", image)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := runCmd(ctx, cmd); err != nil {
+		return "", fmt.Errorf("failed to pull image %s: %s", image, err)
+	}
+	digest, err := inspect()
+	if err != nil || digest == "" {
+		return "", fmt.Errorf("failed to get the digest of image %s: %v", image, err)
+	}
+	return digest, nil
+}
+
+// pushAttestation packs the payload and the signature into a scratch image and pushes it, see step.AttestationTagSuffix.
+func pushAttestation(ctx context.Context, dir, ref string, payload, signature []byte) error {
+	files := map[string][]byte{
+		"payload.json": payload,
+		"signature":    signature,
+		"Dockerfile":   []byte("FROM scratch\nCOPY . /\n"),
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			return err
+		}
+	}
+	for _, cmd := range []*exec.Cmd{
+		exec.Command(dockerExe, "build", "--rm=true", "-t", ref, dir),
+		dockerPush(ref),
+	} {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := runCmd(ctx, cmd); err != nil {
+			return fmt.Errorf("failed to push attestation %s: %s", ref, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,424 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+// cache keys are used as file names of the cache archives
+var cacheKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// errNoFileMatched is returned when hashFiles in a cache key matches no file, the key is treated as a cache miss.
+var errNoFileMatched = errors.New("no file matched")
+
+type CacheRestoreStep struct {
+	spec       *step.StepCacheSpec
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewCacheRestoreStep(spec interface{}, workspace string, envs, secretEnvs []string) (*CacheRestoreStep, error) {
+	cacheRestoreStep := &CacheRestoreStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return cacheRestoreStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &cacheRestoreStep.spec); err != nil {
+		return cacheRestoreStep, fmt.Errorf("unmarshal spec %s to cache restore spec failed", yamlBytes)
+	}
+	return cacheRestoreStep, nil
+}
+
+// Run restores the cache addressed by the key, or the most recently used cache matching one of the restore keys.
+// A cache miss or a broken cache storage never fails the job, it only means the job runs without cache.
+func (s *CacheRestoreStep) Run(ctx context.Context) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("Restore cache ended. Duration: %.2f seconds", time.Since(start).Seconds())
+	}()
+
+	envmaps := makeCacheEnvMap(s.envs, s.secretEnvs)
+	key, err := renderCacheKey(s.spec.Key, s.workspace, envmaps)
+	if errors.Is(err, errNoFileMatched) {
+		// an empty key never matches, only the restore keys are tried
+		log.Infof("Cache key %s matches no file, skip the exact match: %s", s.spec.Key, err)
+		key = ""
+	} else if err != nil {
+		return err
+	}
+	restoreKeys := make([]string, 0, len(s.spec.RestoreKeys))
+	for _, restoreKey := range s.spec.RestoreKeys {
+		prefix, err := renderCacheKey(restoreKey, s.workspace, envmaps)
+		if errors.Is(err, errNoFileMatched) {
+			log.Infof("Restore key %s matches no file, skip it: %s", restoreKey, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		restoreKeys = append(restoreKeys, prefix)
+	}
+
+	store, err := newCacheStore(s.spec)
+	if err != nil {
+		log.Warnf("Failed to access cache storage, skip restoring cache: %s", err)
+		return nil
+	}
+	entries, err := store.list()
+	if err != nil {
+		log.Warnf("Failed to list caches, skip restoring cache: %s", err)
+		return nil
+	}
+	entry := matchCache(entries, key, restoreKeys)
+	if entry == nil {
+		log.Infof("Cache not found for key: %s.", key)
+		return nil
+	}
+	if entry.key == key {
+		log.Infof("Cache hit for key: %s.", key)
+	} else {
+		log.Infof("Cache hit for restore key, restoring %s.", entry.key)
+	}
+
+	reader, err := store.open(entry.key)
+	if err != nil {
+		log.Warnf("Failed to read cache %s, skip restoring cache: %s", entry.key, err)
+		return nil
+	}
+	defer reader.Close()
+
+	// cached paths are archived relative to the root directory, see CacheSaveStep
+	cmd := exec.Command("tar", "-xzf", "-", "-C", "/")
+	cmd.Stdin = reader
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Warnf("Failed to extract cache %s: %s", entry.key, err)
+		return nil
+	}
+
+	if err := store.touch(entry.key); err != nil {
+		log.Warnf("Failed to refresh the last used time of cache %s: %s", entry.key, err)
+	}
+	log.Infof("Finish restoring cache %s, size: %.2f MiB.", entry.key, float64(entry.size)/1024/1024)
+	return nil
+}
+
+type CacheSaveStep struct {
+	spec       *step.StepCacheSpec
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewCacheSaveStep(spec interface{}, workspace string, envs, secretEnvs []string) (*CacheSaveStep, error) {
+	cacheSaveStep := &CacheSaveStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return cacheSaveStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &cacheSaveStep.spec); err != nil {
+		return cacheSaveStep, fmt.Errorf("unmarshal spec %s to cache save spec failed", yamlBytes)
+	}
+	return cacheSaveStep, nil
+}
+
+// Run saves the paths as the cache addressed by the key, caches are immutable so an existing key is never overwritten.
+// Once the total size of the caches exceeds the quota, the least recently used caches are evicted.
+func (s *CacheSaveStep) Run(ctx context.Context) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("Save cache ended. Duration: %.2f seconds", time.Since(start).Seconds())
+	}()
+
+	envmaps := makeCacheEnvMap(s.envs, s.secretEnvs)
+	key, err := renderCacheKey(s.spec.Key, s.workspace, envmaps)
+	if errors.Is(err, errNoFileMatched) {
+		log.Infof("Cache key %s matches no file, skip saving cache: %s", s.spec.Key, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cachePaths := make([]string, 0)
+	for _, cachePath := range s.spec.Paths {
+		cachePath = expandCacheEnv(cachePath, envmaps)
+		if cachePath == "" {
+			continue
+		}
+		if !filepath.IsAbs(cachePath) {
+			cachePath = filepath.Join(s.workspace, cachePath)
+		}
+		if _, err := os.Stat(cachePath); err != nil {
+			log.Warnf("Cache path %s is not accessible, skip it: %s", cachePath, err)
+			continue
+		}
+		cachePaths = append(cachePaths, strings.TrimPrefix(filepath.Clean(cachePath), "/"))
+	}
+	if len(cachePaths) == 0 {
+		log.Infof("No cache path exists, skip saving cache %s.", key)
+		return nil
+	}
+
+	store, err := newCacheStore(s.spec)
+	if err != nil {
+		log.Warnf("Failed to access cache storage, skip saving cache: %s", err)
+		return nil
+	}
+	entries, err := store.list()
+	if err != nil {
+		log.Warnf("Failed to list caches, skip saving cache: %s", err)
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.key == key {
+			log.Infof("Cache %s already exists, skip saving cache.", key)
+			return nil
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp("", "cache")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir for cache archive: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archive := filepath.Join(tmpDir, key+cacheArchiveSuffix)
+	cmd := exec.Command("tar", append([]string{"-czf", archive, "-C", "/"}, cachePaths...)...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Warnf("Failed to compress cache %s: %s", key, err)
+		return nil
+	}
+	info, err := os.Stat(archive)
+	if err != nil {
+		log.Warnf("Failed to stat cache archive %s: %s", archive, err)
+		return nil
+	}
+	if err := store.put(archive, key); err != nil {
+		log.Warnf("Failed to upload cache %s: %s", key, err)
+		return nil
+	}
+	log.Infof("Finish saving cache %s, size: %.2f MiB.", key, float64(info.Size())/1024/1024)
+
+	if s.spec.MaxSizeInMiB <= 0 {
+		return nil
+	}
+	entries = append(entries, &cacheEntry{key: key, size: info.Size(), lastUsed: time.Now()})
+	evicted := selectEvictedCaches(entries, s.spec.MaxSizeInMiB*1024*1024, key)
+	if len(evicted) == 0 {
+		return nil
+	}
+	log.Infof("Cache quota %d MiB exceeded, evicting least recently used caches: %s.", s.spec.MaxSizeInMiB, strings.Join(evicted, ","))
+	if err := store.remove(evicted); err != nil {
+		log.Warnf("Failed to evict caches: %s", err)
+	}
+	return nil
+}
+
+// renderCacheKey renders the key template and replaces the variables in it.
+// Besides the variables, `hashFiles` is available in the template, it takes glob patterns relative to the workspace
+// and returns the sha256 checksum of all the matched files.
+func renderCacheKey(keyTemplate, workspace string, envmaps map[string]string) (string, error) {
+	tmpl, err := template.New("cache-key").Funcs(template.FuncMap{
+		"hashFiles": hashFilesFunc(workspace),
+	}).Parse(keyTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse cache key %s: %s", keyTemplate, err)
+	}
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, nil); err != nil {
+		return "", fmt.Errorf("failed to render cache key %s: %w", keyTemplate, err)
+	}
+
+	key := strings.TrimSpace(expandCacheEnv(buf.String(), envmaps))
+	if !cacheKeyRegexp.MatchString(key) {
+		return "", fmt.Errorf("invalid cache key %q rendered from %s, only letters, digits, '.', '_' and '-' are allowed", key, keyTemplate)
+	}
+	return key, nil
+}
+
+func hashFilesFunc(workspace string) func(patterns ...string) (string, error) {
+	return func(patterns ...string) (string, error) {
+		files := make([]string, 0)
+		err := filepath.WalkDir(workspace, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(workspace, filePath)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			for _, pattern := range patterns {
+				if matchPathSegments(strings.Split(path.Clean(pattern), "/"), strings.Split(rel, "/")) {
+					files = append(files, rel)
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to walk workspace: %s", err)
+		}
+		if len(files) == 0 {
+			return "", fmt.Errorf("%w: %s", errNoFileMatched, strings.Join(patterns, ","))
+		}
+		sort.Strings(files)
+
+		hash := sha256.New()
+		for _, file := range files {
+			f, err := os.Open(filepath.Join(workspace, file))
+			if err != nil {
+				return "", err
+			}
+			fileHash := sha256.New()
+			_, err = io.Copy(fileHash, f)
+			f.Close()
+			if err != nil {
+				return "", err
+			}
+			hash.Write([]byte(file))
+			hash.Write(fileHash.Sum(nil))
+		}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+}
+
+// matchPathSegments matches a path against a glob pattern, both split by "/",
+// "**" in the pattern matches any number of directories.
+func matchPathSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchPathSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchCache returns the cache with exactly the key, or the most recently used one
+// matching the first restore key which has any match.
+func matchCache(entries []*cacheEntry, key string, restoreKeys []string) *cacheEntry {
+	for _, entry := range entries {
+		if entry.key == key {
+			return entry
+		}
+	}
+	for _, prefix := range restoreKeys {
+		var matched *cacheEntry
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.key, prefix) {
+				continue
+			}
+			if matched == nil || entry.lastUsed.After(matched.lastUsed) {
+				matched = entry
+			}
+		}
+		if matched != nil {
+			return matched
+		}
+	}
+	return nil
+}
+
+// selectEvictedCaches returns the least recently used caches to be removed to keep the total size within the quota,
+// the cache just saved is always kept.
+func selectEvictedCaches(entries []*cacheEntry, quota int64, keep string) []string {
+	var total int64
+	for _, entry := range entries {
+		total += entry.size
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+
+	evicted := make([]string, 0)
+	for _, entry := range entries {
+		if total <= quota {
+			break
+		}
+		if entry.key == keep {
+			continue
+		}
+		evicted = append(evicted, entry.key)
+		total -= entry.size
+	}
+	return evicted
+}
+
+func makeCacheEnvMap(envs, secretEnvs []string) map[string]string {
+	envmaps := make(map[string]string)
+	for _, env := range append(append([]string{}, envs...), secretEnvs...) {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		envmaps[kv[0]] = kv[1]
+	}
+	return envmaps
+}
+
+// expandCacheEnv expands the variables in str with the job variables, falling back to the environment of the job executor.
+func expandCacheEnv(str string, envmaps map[string]string) string {
+	return os.Expand(str, func(key string) string {
+		if value, ok := envmaps[key]; ok {
+			return value
+		}
+		return os.Getenv(key)
+	})
+}
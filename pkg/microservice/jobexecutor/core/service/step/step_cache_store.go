@@ -0,0 +1,209 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const cacheArchiveSuffix = ".tar.gz"
+
+type cacheEntry struct {
+	key      string
+	size     int64
+	lastUsed time.Time
+}
+
+// cacheStore keeps the cache archives of a project, each archive is named after its key.
+type cacheStore interface {
+	list() ([]*cacheEntry, error)
+	open(key string) (io.ReadCloser, error)
+	put(src, key string) error
+	// touch marks the cache as used just now, which the LRU eviction depends on
+	touch(key string) error
+	remove(keys []string) error
+}
+
+func newCacheStore(spec *step.StepCacheSpec) (cacheStore, error) {
+	if spec.CacheDir == "" {
+		return nil, fmt.Errorf("cache dir is empty")
+	}
+	if spec.MediumType == types.NFSMedium {
+		if err := os.MkdirAll(spec.CacheDir, os.ModePerm); err != nil {
+			return nil, fmt.Errorf("failed to create cache dir %s: %s", spec.CacheDir, err)
+		}
+		return &nfsCacheStore{dir: spec.CacheDir}, nil
+	}
+
+	if spec.S3Storage == nil {
+		return nil, fmt.Errorf("object storage is not configured")
+	}
+	forcedPathStyle := true
+	if spec.S3Storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3.NewClient(spec.S3Storage.Endpoint, spec.S3Storage.Ak, spec.S3Storage.Sk, spec.S3Storage.Region, spec.S3Storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %s", err)
+	}
+	dir := spec.CacheDir
+	if len(spec.S3Storage.Subfolder) > 0 {
+		dir = path.Join(spec.S3Storage.Subfolder, dir)
+	}
+	return &objectCacheStore{client: client, bucket: spec.S3Storage.Bucket, dir: strings.Trim(dir, "/")}, nil
+}
+
+type objectCacheStore struct {
+	client *s3.Client
+	bucket string
+	dir    string
+}
+
+func (o *objectCacheStore) objectKey(key string) string {
+	return path.Join(o.dir, key+cacheArchiveSuffix)
+}
+
+func (o *objectCacheStore) list() ([]*cacheEntry, error) {
+	objects, err := o.client.ListObjectsWithInfo(o.bucket, o.dir+"/")
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*cacheEntry, 0, len(objects))
+	for _, object := range objects {
+		name := strings.TrimPrefix(*object.Key, o.dir+"/")
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, cacheArchiveSuffix) {
+			continue
+		}
+		entry := &cacheEntry{key: strings.TrimSuffix(name, cacheArchiveSuffix)}
+		if object.Size != nil {
+			entry.size = *object.Size
+		}
+		if object.LastModified != nil {
+			entry.lastUsed = *object.LastModified
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (o *objectCacheStore) open(key string) (io.ReadCloser, error) {
+	object, err := o.client.GetFile(o.bucket, o.objectKey(key), &s3.DownloadOption{RetryNum: 2})
+	if err != nil {
+		return nil, err
+	}
+	return object.Body, nil
+}
+
+func (o *objectCacheStore) put(src, key string) error {
+	return o.client.Upload(o.bucket, src, o.objectKey(key))
+}
+
+func (o *objectCacheStore) touch(key string) error {
+	return o.client.TouchObject(o.bucket, o.objectKey(key))
+}
+
+func (o *objectCacheStore) remove(keys []string) error {
+	objectKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		objectKeys = append(objectKeys, o.objectKey(key))
+	}
+	return o.client.DeleteObjects(o.bucket, objectKeys)
+}
+
+type nfsCacheStore struct {
+	dir string
+}
+
+func (n *nfsCacheStore) file(key string) string {
+	return filepath.Join(n.dir, key+cacheArchiveSuffix)
+}
+
+func (n *nfsCacheStore) list() ([]*cacheEntry, error) {
+	files, err := os.ReadDir(n.dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*cacheEntry, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), cacheArchiveSuffix) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			// removed by another job in the meantime
+			continue
+		}
+		entries = append(entries, &cacheEntry{
+			key:      strings.TrimSuffix(file.Name(), cacheArchiveSuffix),
+			size:     info.Size(),
+			lastUsed: info.ModTime(),
+		})
+	}
+	return entries, nil
+}
+
+func (n *nfsCacheStore) open(key string) (io.ReadCloser, error) {
+	return os.Open(n.file(key))
+}
+
+// put copies the archive to a temp file first and renames it, so that jobs restoring concurrently never see a partial archive.
+func (n *nfsCacheStore) put(src, key string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(n.dir, ".tmp-"+key)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), n.file(key))
+}
+
+func (n *nfsCacheStore) touch(key string) error {
+	now := time.Now()
+	return os.Chtimes(n.file(key), now, now)
+}
+
+func (n *nfsCacheStore) remove(keys []string) error {
+	for _, key := range keys {
+		if err := os.Remove(n.file(key)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPathSegments(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "go.sum", name: "go.sum", want: true},
+		{pattern: "go.sum", name: "pkg/go.sum", want: false},
+		{pattern: "*.sum", name: "go.sum", want: true},
+		{pattern: "**/go.sum", name: "go.sum", want: true},
+		{pattern: "**/go.sum", name: "a/b/go.sum", want: true},
+		{pattern: "a/**/package-lock.json", name: "a/b/c/package-lock.json", want: true},
+		{pattern: "a/**/package-lock.json", name: "b/package-lock.json", want: false},
+		{pattern: "a/**", name: "a/b/c", want: true},
+		{pattern: "a/*", name: "a/b/c", want: false},
+		{pattern: "a/b", name: "a", want: false},
+	}
+	for _, tt := range tests {
+		got := matchPathSegments(strings.Split(tt.pattern, "/"), strings.Split(tt.name, "/"))
+		assert.Equal(t, tt.want, got, "pattern %s, name %s", tt.pattern, tt.name)
+	}
+}
+
+func TestMatchCache(t *testing.T) {
+	now := time.Now()
+	entries := []*cacheEntry{
+		{key: "go-linux-aaa", lastUsed: now.Add(-3 * time.Hour)},
+		{key: "go-linux-bbb", lastUsed: now.Add(-time.Hour)},
+		{key: "go-darwin-ccc", lastUsed: now},
+	}
+
+	tests := []struct {
+		name        string
+		key         string
+		restoreKeys []string
+		want        string
+	}{
+		{name: "exact match", key: "go-linux-aaa", restoreKeys: []string{"go-"}, want: "go-linux-aaa"},
+		{name: "most recently used of the restore key", key: "go-linux-ddd", restoreKeys: []string{"go-linux-"}, want: "go-linux-bbb"},
+		{name: "first restore key with a match wins", key: "go-linux-ddd", restoreKeys: []string{"node-", "go-linux-", "go-"}, want: "go-linux-bbb"},
+		{name: "empty key only tries restore keys", key: "", restoreKeys: []string{"go-"}, want: "go-darwin-ccc"},
+		{name: "miss", key: "node-aaa", restoreKeys: []string{"node-"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchCache(entries, tt.key, tt.restoreKeys)
+			if tt.want == "" {
+				assert.Nil(t, got)
+				return
+			}
+			if assert.NotNil(t, got) {
+				assert.Equal(t, tt.want, got.key)
+			}
+		})
+	}
+}
+
+func TestSelectEvictedCaches(t *testing.T) {
+	now := time.Now()
+	newEntries := func() []*cacheEntry {
+		return []*cacheEntry{
+			{key: "new", size: 40, lastUsed: now},
+			{key: "oldest", size: 30, lastUsed: now.Add(-3 * time.Hour)},
+			{key: "older", size: 20, lastUsed: now.Add(-2 * time.Hour)},
+			{key: "recent", size: 10, lastUsed: now.Add(-time.Hour)},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		quota int64
+		keep  string
+		want  []string
+	}{
+		{name: "within quota", quota: 100, keep: "new", want: []string{}},
+		{name: "evict the least recently used", quota: 70, keep: "new", want: []string{"oldest"}},
+		{name: "evict until within quota", quota: 50, keep: "new", want: []string{"oldest", "older"}},
+		{name: "the kept cache is never evicted", quota: 10, keep: "oldest", want: []string{"older", "recent", "new"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, selectEvictedCaches(newEntries(), tt.quota, tt.keep))
+		})
+	}
+}
+
+func TestRenderCacheKeyNoFileMatched(t *testing.T) {
+	workspace := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(workspace, "go.sum"), []byte("sum"), 0644))
+
+	key, err := renderCacheKey(`go-{{ hashFiles "**/go.sum" }}`, workspace, nil)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "go-"))
+
+	_, err = renderCacheKey(`node-{{ hashFiles "**/package-lock.json" }}`, workspace, nil)
+	assert.True(t, errors.Is(err, errNoFileMatched))
+}
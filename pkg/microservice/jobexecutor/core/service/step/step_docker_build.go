@@ -64,7 +64,7 @@ func (s *DockerBuildStep) Run(ctx context.Context) error {
 	if err := s.dockerLogin(); err != nil {
 		return err
 	}
-	return s.runDockerBuild()
+	return s.runDockerBuild(ctx)
 }
 
 func (s DockerBuildStep) dockerLogin() error {
@@ -87,7 +87,7 @@ func (s DockerBuildStep) dockerLogin() error {
 	return nil
 }
 
-func (s *DockerBuildStep) runDockerBuild() error {
+func (s *DockerBuildStep) runDockerBuild(ctx context.Context) error {
 	if s.spec == nil {
 		return nil
 	}
@@ -112,7 +112,7 @@ func (s *DockerBuildStep) runDockerBuild() error {
 		c.Stderr = os.Stderr
 		c.Dir = s.workspace
 		c.Env = envs
-		if err := c.Run(); err != nil {
+		if err := runCmd(ctx, c); err != nil {
 			return fmt.Errorf("failed to run docker build: %s", err)
 		}
 	}
@@ -188,12 +188,17 @@ func prepareDockerfile(dockerfileSource, dockerfileContent string) error {
 }
 
 func setProxy(ctx *step.StepDockerBuildSpec) {
-	if ctx.Proxy.EnableRepoProxy && ctx.Proxy.Type == "http" {
-		if !strings.Contains(strings.ToLower(ctx.BuildArgs), "--build-arg http_proxy=") {
-			ctx.BuildArgs = fmt.Sprintf("%s --build-arg http_proxy=%s", ctx.BuildArgs, ctx.Proxy.GetProxyURL())
+	ctx.BuildArgs = proxyBuildArgs(ctx.BuildArgs, ctx.Proxy)
+}
+
+func proxyBuildArgs(buildArgs string, proxy *step.Proxy) string {
+	if proxy.EnableRepoProxy && proxy.Type == "http" {
+		if !strings.Contains(strings.ToLower(buildArgs), "--build-arg http_proxy=") {
+			buildArgs = fmt.Sprintf("%s --build-arg http_proxy=%s", buildArgs, proxy.GetProxyURL())
 		}
-		if !strings.Contains(strings.ToLower(ctx.BuildArgs), "--build-arg https_proxy=") {
-			ctx.BuildArgs = fmt.Sprintf("%s --build-arg https_proxy=%s", ctx.BuildArgs, ctx.Proxy.GetProxyURL())
+		if !strings.Contains(strings.ToLower(buildArgs), "--build-arg https_proxy=") {
+			buildArgs = fmt.Sprintf("%s --build-arg https_proxy=%s", buildArgs, proxy.GetProxyURL())
 		}
 	}
+	return buildArgs
 }
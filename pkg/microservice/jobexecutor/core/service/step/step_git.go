@@ -37,6 +37,7 @@ import (
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/types/step"
+	"github.com/koderover/zadig/pkg/util/mask"
 )
 
 type GitStep struct {
@@ -64,7 +65,7 @@ func (s *GitStep) Run(ctx context.Context) error {
 	defer func() {
 		log.Infof("Git clone ended. Duration: %.2f seconds.", time.Since(start).Seconds())
 	}()
-	return s.runGitCmds()
+	return s.runGitCmds(ctx)
 }
 
 func (s *GitStep) RunGitGc(folder string) error {
@@ -77,7 +78,7 @@ func (s *GitStep) RunGitGc(folder string) error {
 	return cmd.Run()
 }
 
-func (s *GitStep) runGitCmds() error {
+func (s *GitStep) runGitCmds(ctx context.Context) error {
 	if err := os.MkdirAll(path.Join(config.Home(), "/.ssh"), os.ModePerm); err != nil {
 		return fmt.Errorf("create ssh folder error: %v", err)
 	}
@@ -151,6 +152,7 @@ func (s *GitStep) runGitCmds() error {
 		}
 	}
 
+	masker := mask.NewMasker(tokens)
 	for _, c := range cmds {
 		cmdOutReader, err := c.Cmd.StdoutPipe()
 		if err != nil {
@@ -160,7 +162,7 @@ func (s *GitStep) runGitCmds() error {
 		outScanner := bufio.NewScanner(cmdOutReader)
 		go func() {
 			for outScanner.Scan() {
-				fmt.Printf("%s\n", maskSecret(tokens, outScanner.Text()))
+				fmt.Printf("%s\n", masker.Mask(outScanner.Text()))
 			}
 		}()
 
@@ -172,7 +174,7 @@ func (s *GitStep) runGitCmds() error {
 		errScanner := bufio.NewScanner(cmdErrReader)
 		go func() {
 			for errScanner.Scan() {
-				fmt.Printf("%s\n", maskSecret(tokens, errScanner.Text()))
+				fmt.Printf("%s\n", masker.Mask(errScanner.Text()))
 			}
 		}()
 
@@ -180,8 +182,8 @@ func (s *GitStep) runGitCmds() error {
 		if !c.DisableTrace {
 			fmt.Printf("%s\n", strings.Join(c.Cmd.Args, " "))
 		}
-		if err := c.Cmd.Run(); err != nil {
-			if c.IgnoreError {
+		if err := runCmd(ctx, c.Cmd); err != nil {
+			if c.IgnoreError && ctx.Err() == nil {
 				continue
 			}
 			return err
@@ -0,0 +1,132 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/setting"
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const defaultHtmlReportFile = "index.html"
+
+type HtmlReportStep struct {
+	spec       *step.StepHtmlReportSpec
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewHtmlReportStep(spec interface{}, workspace string, envs, secretEnvs []string) (*HtmlReportStep, error) {
+	htmlReportStep := &HtmlReportStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return htmlReportStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &htmlReportStep.spec); err != nil {
+		return htmlReportStep, fmt.Errorf("unmarshal spec %s to html report spec failed", yamlBytes)
+	}
+	return htmlReportStep, nil
+}
+
+func (s *HtmlReportStep) Run(ctx context.Context) error {
+	if s.spec.S3DestDir == "" || s.spec.S3Storage == nil {
+		return nil
+	}
+	reportFile := s.spec.ReportFile
+	if reportFile == "" {
+		reportFile = defaultHtmlReportFile
+	}
+	envmaps := make(map[string]string)
+	for _, env := range s.envs {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		envmaps[kv[0]] = kv[1]
+	}
+	for _, secretEnv := range s.secretEnvs {
+		kv := strings.SplitN(secretEnv, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		envmaps[kv[0]] = kv[1]
+	}
+	relDir, err := resolveReportDir(s.workspace, s.spec.ReportDir, envmaps)
+	if err != nil {
+		return err
+	}
+	reportDir := filepath.Join(s.workspace, relDir)
+	log.Infof("Start archive html report %s.", filepath.Join(reportDir, reportFile))
+
+	if _, err := os.Stat(filepath.Join(reportDir, reportFile)); err != nil {
+		return fmt.Errorf("html report file %s not found: %s", reportFile, err)
+	}
+
+	forcedPathStyle := true
+	if s.spec.S3Storage.Provider == setting.ProviderSourceAli {
+		forcedPathStyle = false
+	}
+	client, err := s3.NewClient(s.spec.S3Storage.Endpoint, s.spec.S3Storage.Ak, s.spec.S3Storage.Sk, s.spec.S3Storage.Region, s.spec.S3Storage.Insecure, forcedPathStyle)
+	if err != nil {
+		return fmt.Errorf("failed to create s3 client to upload html report, err: %s", err)
+	}
+
+	destDir := s.spec.S3DestDir
+	if len(s.spec.S3Storage.Subfolder) > 0 {
+		destDir = strings.TrimLeft(path.Join(s.spec.S3Storage.Subfolder, destDir), "/")
+	}
+
+	// the report lies in the workspace root, only the entry file is uploaded instead of the whole workspace
+	if relDir == "." {
+		if err := client.Upload(s.spec.S3Storage.Bucket, filepath.Join(reportDir, reportFile), path.Join(destDir, reportFile)); err != nil {
+			return fmt.Errorf("failed to upload html report: %s", err)
+		}
+	} else if err := client.UploadDir(s.spec.S3Storage.Bucket, reportDir, destDir); err != nil {
+		return fmt.Errorf("failed to upload html report dir: %s", err)
+	}
+	log.Infof("Finish archive html report.")
+	return nil
+}
+
+// resolveReportDir expands the envs like $WORKSPACE in the report dir and returns it relative to the workspace,
+// the report dir out of the workspace is rejected.
+func resolveReportDir(workspace, reportDir string, envs map[string]string) (string, error) {
+	dir := replaceEnvWithValue(reportDir, envs)
+	if filepath.IsAbs(dir) {
+		rel, err := filepath.Rel(workspace, dir)
+		if err != nil {
+			return "", fmt.Errorf("html report dir %s is not in the workspace: %s", reportDir, err)
+		}
+		dir = rel
+	}
+	dir = filepath.Clean(dir)
+	if dir == ".." || strings.HasPrefix(dir, "../") {
+		return "", fmt.Errorf("html report dir %s is not in the workspace", reportDir)
+	}
+	return dir, nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveReportDir(t *testing.T) {
+	const workspace = "/workspace"
+	envs := map[string]string{"WORKSPACE": workspace, "REPORT": "report"}
+	tests := []struct {
+		name      string
+		reportDir string
+		want      string
+		wantErr   bool
+	}{
+		{name: "workspace root", reportDir: ".", want: "."},
+		{name: "empty", reportDir: "", want: "."},
+		{name: "relative dir", reportDir: "report/html", want: "report/html"},
+		{name: "workspace env", reportDir: "$WORKSPACE/report", want: "report"},
+		{name: "workspace env root", reportDir: "$WORKSPACE", want: "."},
+		{name: "custom env", reportDir: "out/$REPORT", want: "out/report"},
+		{name: "dotted name", reportDir: "..report", want: "..report"},
+		{name: "parent dir", reportDir: "../report", wantErr: true},
+		{name: "escaping dir", reportDir: "report/../../etc", wantErr: true},
+		{name: "absolute dir out of workspace", reportDir: "/etc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveReportDir(workspace, tt.reportDir, envs)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
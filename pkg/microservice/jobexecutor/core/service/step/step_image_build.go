@@ -0,0 +1,279 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/koderover/zadig/pkg/tool/log"
+	"github.com/koderover/zadig/pkg/types/job"
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+const (
+	buildctlExe         = "buildctl-daemonless.sh"
+	imageDigestKey      = "containerimage.digest"
+	defaultCacheTag     = "buildcache"
+	defaultDigestOutput = "IMAGE_DIGEST"
+)
+
+// ImageBuildStep builds the image with rootless buildkit, so that neither the host
+// docker daemon nor a dind sidecar is needed.
+type ImageBuildStep struct {
+	spec       *step.StepImageBuildSpec
+	envs       []string
+	secretEnvs []string
+	workspace  string
+}
+
+func NewImageBuildStep(spec interface{}, workspace string, envs, secretEnvs []string) (*ImageBuildStep, error) {
+	imageBuildStep := &ImageBuildStep{workspace: workspace, envs: envs, secretEnvs: secretEnvs}
+	yamlBytes, err := yaml.Marshal(spec)
+	if err != nil {
+		return imageBuildStep, fmt.Errorf("marshal spec %+v failed", spec)
+	}
+	if err := yaml.Unmarshal(yamlBytes, &imageBuildStep.spec); err != nil {
+		return imageBuildStep, fmt.Errorf("unmarshal spec %s to image build spec failed", yamlBytes)
+	}
+	return imageBuildStep, nil
+}
+
+func (s *ImageBuildStep) Run(ctx context.Context) error {
+	if s.spec == nil {
+		return nil
+	}
+	start := time.Now()
+	log.Infof("Start image build.")
+	defer func() {
+		log.Infof("Image build ended. Duration: %.2f seconds.", time.Since(start).Seconds())
+	}()
+
+	fmt.Printf("Preparing Dockerfile.\n")
+	if err := prepareDockerfile(s.spec.Source, s.spec.DockerTemplateContent); err != nil {
+		return fmt.Errorf("failed to prepare dockerfile: %s", err)
+	}
+
+	configDir, err := s.writeRegistryAuth()
+	if err != nil {
+		return fmt.Errorf("failed to write registry auth: %s", err)
+	}
+	defer os.RemoveAll(configDir)
+
+	metadataFile := filepath.Join(configDir, "metadata.json")
+	envmaps := make(map[string]string)
+	for _, env := range s.envs {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		envmaps[kv[0]] = kv[1]
+	}
+	image := replaceEnvWithValue(s.spec.ImageName, envmaps)
+
+	fmt.Printf("Running BuildKit build for platforms: %s.\n", strings.Join(s.spec.Platforms, ","))
+	cmd := exec.CommandContext(ctx, buildctlExe, s.buildCommand(image, metadataFile, envmaps)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Dir = s.workspace
+	cmd.Env = append(s.envs, fmt.Sprintf("DOCKER_CONFIG=%s", configDir))
+	if err := runCmd(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to run buildkit build: %s", err)
+	}
+
+	digest, err := readImageDigest(metadataFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Image %s is pushed with digest %s.\n", image, digest)
+	return s.writeDigestOutput(digest)
+}
+
+// buildCommand returns the buildctl arguments. They are passed to buildctl without a shell, so the
+// env references in the build args and the cache ref are expanded here.
+func (s *ImageBuildStep) buildCommand(image, metadataFile string, envmaps map[string]string) []string {
+	dockerfile := s.spec.GetDockerFile()
+	buildContext := s.spec.WorkDir
+	if buildContext == "" {
+		buildContext = "."
+	}
+	args := []string{
+		"build",
+		"--frontend", "dockerfile.v0",
+		"--local", "context=" + buildContext,
+		"--local", "dockerfile=" + filepath.Dir(dockerfile),
+		"--opt", "filename=" + filepath.Base(dockerfile),
+	}
+	if len(s.spec.Platforms) > 0 {
+		args = append(args, "--opt", "platform="+strings.Join(s.spec.Platforms, ","))
+	}
+
+	buildArgs := s.spec.BuildArgs
+	if s.spec.Proxy != nil {
+		buildArgs = proxyBuildArgs(buildArgs, s.spec.Proxy)
+	}
+	for _, arg := range parseBuildArgs(buildArgs) {
+		args = append(args, "--opt", "build-arg:"+expandEnv(arg, envmaps))
+	}
+
+	if s.spec.IgnoreCache {
+		args = append(args, "--no-cache")
+	}
+	if s.spec.EnableRegistryCache {
+		cacheRef := expandEnv(s.spec.CacheRef, envmaps)
+		if cacheRef == "" {
+			cacheRef = imageRepository(image) + ":" + defaultCacheTag
+		}
+		args = append(args,
+			"--export-cache", fmt.Sprintf("type=registry,ref=%s,mode=max", cacheRef),
+			"--import-cache", fmt.Sprintf("type=registry,ref=%s", cacheRef),
+		)
+	}
+	args = append(args,
+		"--output", fmt.Sprintf("type=image,name=%s,push=true", image),
+		"--metadata-file", metadataFile,
+	)
+	return args
+}
+
+// writeRegistryAuth writes a docker config file which buildkit reads the registry credential from.
+func (s *ImageBuildStep) writeRegistryAuth() (string, error) {
+	configDir, err := os.MkdirTemp("", "buildkit-")
+	if err != nil {
+		return "", err
+	}
+	auths := map[string]interface{}{}
+	if s.spec.DockerRegistry != nil && s.spec.DockerRegistry.UserName != "" {
+		host := strings.TrimPrefix(strings.TrimPrefix(s.spec.DockerRegistry.Host, "https://"), "http://")
+		auth := base64.StdEncoding.EncodeToString([]byte(s.spec.DockerRegistry.UserName + ":" + s.spec.DockerRegistry.Password))
+		auths[host] = map[string]string{"auth": auth}
+	}
+	content, err := json.Marshal(map[string]interface{}{"auths": auths})
+	if err != nil {
+		return configDir, err
+	}
+	return configDir, os.WriteFile(filepath.Join(configDir, "config.json"), content, 0600)
+}
+
+func (s *ImageBuildStep) writeDigestOutput(digest string) error {
+	outputName := s.spec.DigestOutput
+	if outputName == "" {
+		outputName = defaultDigestOutput
+	}
+	if err := os.MkdirAll(job.JobOutputDir, os.ModePerm); err != nil {
+		return fmt.Errorf("create output dir error: %s", err)
+	}
+	return os.WriteFile(path.Join(job.JobOutputDir, outputName), []byte(digest), 0644)
+}
+
+func readImageDigest(metadataFile string) (string, error) {
+	content, err := os.ReadFile(metadataFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read build metadata: %s", err)
+	}
+	metadata := map[string]interface{}{}
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return "", fmt.Errorf("failed to parse build metadata: %s", err)
+	}
+	digest, ok := metadata[imageDigestKey].(string)
+	if !ok || digest == "" {
+		return "", fmt.Errorf("image digest not found in build metadata")
+	}
+	return digest, nil
+}
+
+// parseBuildArgs converts docker style args like "--build-arg a=b" to the "a=b" list.
+func parseBuildArgs(buildArgs string) []string {
+	resp := make([]string, 0)
+	fields := splitArgs(buildArgs)
+	for i := 0; i < len(fields); i++ {
+		switch {
+		case fields[i] == "--build-arg" && i+1 < len(fields):
+			resp = append(resp, fields[i+1])
+			i++
+		case strings.HasPrefix(fields[i], "--build-arg="):
+			resp = append(resp, strings.TrimPrefix(fields[i], "--build-arg="))
+		}
+	}
+	return resp
+}
+
+// splitArgs splits the string by white spaces like a shell does, a quoted part is kept in one field
+// without the quotes.
+func splitArgs(s string) []string {
+	fields := make([]string, 0)
+	var (
+		field   strings.Builder
+		inField bool
+		quote   rune
+	)
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			field.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inField = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// expandEnv replaces $KEY and ${KEY} in the string with the value in envs, unknown keys are kept as is.
+func expandEnv(s string, envs map[string]string) string {
+	return os.Expand(s, func(key string) string {
+		if value, ok := envs[key]; ok {
+			return value
+		}
+		return "$" + key
+	})
+}
+
+// imageRepository strips the tag or digest of the image.
+func imageRepository(image string) string {
+	if index := strings.Index(image, "@"); index > 0 {
+		image = image[:index]
+	}
+	if index := strings.LastIndex(image, ":"); index > strings.LastIndex(image, "/") {
+		image = image[:index]
+	}
+	return image
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/types/step"
+)
+
+func TestParseBuildArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		buildArgs string
+		want      []string
+	}{
+		{
+			name: "empty",
+			want: []string{},
+		},
+		{
+			name:      "separated by space",
+			buildArgs: "--build-arg A=1 --build-arg B=2",
+			want:      []string{"A=1", "B=2"},
+		},
+		{
+			name:      "joined by equal sign",
+			buildArgs: "--build-arg=A=1  --build-arg B=2",
+			want:      []string{"A=1", "B=2"},
+		},
+		{
+			name:      "other docker flags are ignored",
+			buildArgs: "--no-cache --build-arg A=1 --pull",
+			want:      []string{"A=1"},
+		},
+		{
+			name:      "flag without value",
+			buildArgs: "--build-arg A=1 --build-arg",
+			want:      []string{"A=1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseBuildArgs(tt.buildArgs))
+		})
+	}
+}
+
+func TestImageRepository(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "nginx", want: "nginx"},
+		{image: "nginx:1.25", want: "nginx"},
+		{image: "registry.example.com/app/api:20230101-1", want: "registry.example.com/app/api"},
+		{image: "registry.example.com:5000/app/api", want: "registry.example.com:5000/app/api"},
+		{image: "registry.example.com:5000/app/api:v1", want: "registry.example.com:5000/app/api"},
+		{image: "registry.example.com/app/api:v1@sha256:0123abcd", want: "registry.example.com/app/api"},
+		{image: "registry.example.com/app/api@sha256:0123abcd", want: "registry.example.com/app/api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.want, imageRepository(tt.image))
+		})
+	}
+}
+
+func TestImageBuildStepBuildCommand(t *testing.T) {
+	const (
+		image        = "registry.example.com/app/api:v1"
+		metadataFile = "/tmp/metadata.json"
+	)
+	tests := []struct {
+		name string
+		spec *step.StepImageBuildSpec
+		envs map[string]string
+		want []string
+	}{
+		{
+			name: "default dockerfile",
+			spec: &step.StepImageBuildSpec{},
+			want: []string{
+				"build", "--frontend", "dockerfile.v0", "--local", "context=.", "--local", "dockerfile=.", "--opt", "filename=Dockerfile",
+				"--output", "type=image,name=registry.example.com/app/api:v1,push=true", "--metadata-file", "/tmp/metadata.json",
+			},
+		},
+		{
+			name: "platforms, build args and no cache",
+			spec: &step.StepImageBuildSpec{
+				WorkDir:     "api",
+				DockerFile:  "build/api.Dockerfile",
+				BuildArgs:   "--build-arg VERSION=1",
+				Platforms:   []string{"linux/amd64", "linux/arm64"},
+				IgnoreCache: true,
+			},
+			want: []string{
+				"build", "--frontend", "dockerfile.v0", "--local", "context=api", "--local", "dockerfile=build", "--opt",
+				"filename=api.Dockerfile", "--opt", "platform=linux/amd64,linux/arm64", "--opt", "build-arg:VERSION=1",
+				"--no-cache", "--output", "type=image,name=registry.example.com/app/api:v1,push=true", "--metadata-file",
+				"/tmp/metadata.json",
+			},
+		},
+		{
+			name: "quoted build args and env references",
+			spec: &step.StepImageBuildSpec{BuildArgs: `--build-arg MESSAGE="hello world" --build-arg 'VERSION=$TAG' --build-arg CMD=a;rm`},
+			envs: map[string]string{"TAG": "v1.0.0"},
+			want: []string{
+				"build", "--frontend", "dockerfile.v0", "--local", "context=.", "--local", "dockerfile=.", "--opt", "filename=Dockerfile",
+				"--opt", "build-arg:MESSAGE=hello world", "--opt", "build-arg:VERSION=v1.0.0", "--opt", "build-arg:CMD=a;rm",
+				"--output", "type=image,name=registry.example.com/app/api:v1,push=true", "--metadata-file", "/tmp/metadata.json",
+			},
+		},
+		{
+			name: "default registry cache",
+			spec: &step.StepImageBuildSpec{EnableRegistryCache: true},
+			want: []string{
+				"build", "--frontend", "dockerfile.v0", "--local", "context=.", "--local", "dockerfile=.", "--opt", "filename=Dockerfile",
+				"--export-cache", "type=registry,ref=registry.example.com/app/api:buildcache,mode=max", "--import-cache",
+				"type=registry,ref=registry.example.com/app/api:buildcache", "--output", "type=image,name=registry.example.com/app/api:v1,push=true",
+				"--metadata-file", "/tmp/metadata.json",
+			},
+		},
+		{
+			name: "custom registry cache",
+			spec: &step.StepImageBuildSpec{EnableRegistryCache: true, CacheRef: "registry.example.com/cache/api:main"},
+			want: []string{
+				"build", "--frontend", "dockerfile.v0", "--local", "context=.", "--local", "dockerfile=.", "--opt", "filename=Dockerfile",
+				"--export-cache", "type=registry,ref=registry.example.com/cache/api:main,mode=max", "--import-cache", "type=registry,ref=registry.example.com/cache/api:main",
+				"--output", "type=image,name=registry.example.com/app/api:v1,push=true", "--metadata-file", "/tmp/metadata.json",
+			},
+		},
+		{
+			name: "repo proxy",
+			spec: &step.StepImageBuildSpec{Proxy: &step.Proxy{EnableRepoProxy: true, Type: "http", Address: "proxy.example.com", Port: 8080}},
+			want: []string{
+				"build", "--frontend", "dockerfile.v0", "--local", "context=.", "--local", "dockerfile=.", "--opt", "filename=Dockerfile",
+				"--opt", "build-arg:http_proxy=http://proxy.example.com:8080", "--opt", "build-arg:https_proxy=http://proxy.example.com:8080",
+				"--output", "type=image,name=registry.example.com/app/api:v1,push=true", "--metadata-file", "/tmp/metadata.json",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ImageBuildStep{spec: tt.spec}
+			assert.Equal(t, tt.want, s.buildCommand(image, metadataFile, tt.envs))
+		})
+	}
+}
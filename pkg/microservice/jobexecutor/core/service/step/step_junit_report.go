@@ -18,6 +18,7 @@ package step
 
 import (
 	"context"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
@@ -32,6 +33,7 @@ import (
 	"github.com/koderover/zadig/pkg/setting"
 	"github.com/koderover/zadig/pkg/tool/log"
 	"github.com/koderover/zadig/pkg/tool/s3"
+	"github.com/koderover/zadig/pkg/types"
 	"github.com/koderover/zadig/pkg/types/step"
 	"gopkg.in/yaml.v2"
 )
@@ -61,19 +63,67 @@ func NewJunitReportStep(spec interface{}, workspace string, envs, secretEnvs []s
 }
 
 func (s *JunitReportStep) Run(ctx context.Context) error {
-	log.Info("Start merge ginkgo test results.")
 	if err := os.MkdirAll(s.spec.DestDir, os.ModePerm); err != nil {
 		return fmt.Errorf("create dest dir: %s error: %s", s.spec.DestDir, err)
 	}
-	reportDir := filepath.Join(s.workspace, s.spec.ReportDir)
-	failedCaseCount, err := mergeGinkgoTestResults(s.spec.FileName, reportDir, s.spec.DestDir, time.Now())
-	if err != nil {
-		return fmt.Errorf("failed to merge test result: %s", err)
+
+	var failedCaseCount int
+	if s.spec.ReportDir != "" {
+		log.Info("Start merge ginkgo test results.")
+		reportDir := filepath.Join(s.workspace, s.spec.ReportDir)
+		if s.spec.ReportFormat == types.TestReportFormatGoTestJSON {
+			convertDir, err := os.MkdirTemp("", "go-test-")
+			if err != nil {
+				return fmt.Errorf("create go test result dir error: %s", err)
+			}
+			defer os.RemoveAll(convertDir)
+			if err := convertGoTestResults(reportDir, convertDir); err != nil {
+				return fmt.Errorf("failed to convert go test result: %s", err)
+			}
+			reportDir = convertDir
+		}
+		var err error
+		failedCaseCount, err = mergeGinkgoTestResults(s.spec.FileName, reportDir, s.spec.DestDir, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to merge test result: %s", err)
+		}
+		log.Info("Finish merge ginkgo test results.")
+
+		if err := s.archive(s.spec.FileName); err != nil {
+			return err
+		}
 	}
-	log.Info("Finish merge ginkgo test results.")
 
-	log.Infof("Start archive %s.", s.spec.FileName)
-	if s.spec.S3DestDir == "" || s.spec.FileName == "" {
+	if s.spec.CoverageFormat != "" && s.spec.CoveragePath != "" {
+		log.Infof("Start parse %s coverage report %s.", s.spec.CoverageFormat, s.spec.CoveragePath)
+		coverage, err := parseCoverageReport(s.spec.CoverageFormat, filepath.Join(s.workspace, s.spec.CoveragePath))
+		if err != nil {
+			return fmt.Errorf("failed to parse coverage report: %s", err)
+		}
+		content, err := json.Marshal(coverage)
+		if err != nil {
+			return fmt.Errorf("failed to marshal coverage: %s", err)
+		}
+		if err := ioutil.WriteFile(path.Join(s.spec.DestDir, s.spec.CoverageFileName), content, 0644); err != nil {
+			return fmt.Errorf("failed to write coverage: %s", err)
+		}
+		log.Infof("Line coverage: %.2f%%.", coverage.LineRate())
+
+		if err := s.archive(s.spec.CoverageFileName); err != nil {
+			return err
+		}
+	}
+
+	if failedCaseCount > 0 {
+		return fmt.Errorf("%d case(s) failed", failedCaseCount)
+	}
+	return nil
+}
+
+// archive uploads the file in DestDir to S3DestDir.
+func (s *JunitReportStep) archive(fileName string) error {
+	log.Infof("Start archive %s.", fileName)
+	if s.spec.S3DestDir == "" || fileName == "" {
 		return nil
 	}
 	forcedPathStyle := true
@@ -85,33 +135,31 @@ func (s *JunitReportStep) Run(ctx context.Context) error {
 		return fmt.Errorf("failed to create s3 client to upload file, err: %s", err)
 	}
 
-	absFilePath := path.Join(s.spec.DestDir, s.spec.FileName)
+	absFilePath := path.Join(s.spec.DestDir, fileName)
 
+	s3DestDir := s.spec.S3DestDir
 	if len(s.spec.S3Storage.Subfolder) > 0 {
-		s.spec.S3DestDir = strings.TrimLeft(path.Join(s.spec.S3Storage.Subfolder, s.spec.S3DestDir), "/")
+		s3DestDir = strings.TrimLeft(path.Join(s.spec.S3Storage.Subfolder, s3DestDir), "/")
 	}
 
 	info, err := os.Stat(absFilePath)
 	if err != nil {
-		return fmt.Errorf("failed to upload file path [%s] to destination [%s], the error is: %s", absFilePath, s.spec.S3DestDir, err)
+		return fmt.Errorf("failed to upload file path [%s] to destination [%s], the error is: %s", absFilePath, s3DestDir, err)
 	}
 	// if the given path is a directory
 	if info.IsDir() {
-		err := client.UploadDir(s.spec.S3Storage.Bucket, absFilePath, s.spec.S3DestDir)
+		err := client.UploadDir(s.spec.S3Storage.Bucket, absFilePath, s3DestDir)
 		if err != nil {
 			return err
 		}
 	} else {
-		key := filepath.Join(s.spec.S3DestDir, info.Name())
+		key := filepath.Join(s3DestDir, info.Name())
 		err := client.Upload(s.spec.S3Storage.Bucket, absFilePath, key)
 		if err != nil {
 			return err
 		}
 	}
-	log.Infof("Finish archive %s.", s.spec.FileName)
-	if failedCaseCount > 0 {
-		return fmt.Errorf("%d case(s) failed", failedCaseCount)
-	}
+	log.Infof("Finish archive %s.", fileName)
 	return nil
 }
 
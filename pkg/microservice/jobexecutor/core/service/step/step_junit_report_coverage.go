@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/koderover/zadig/pkg/types"
+)
+
+type coberturaReport struct {
+	LinesCovered    int                `xml:"lines-covered,attr"`
+	LinesValid      int                `xml:"lines-valid,attr"`
+	BranchesCovered int                `xml:"branches-covered,attr"`
+	BranchesValid   int                `xml:"branches-valid,attr"`
+	Packages        []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name    string           `xml:"name,attr"`
+	Classes []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Lines []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Hits              int64  `xml:"hits,attr"`
+	Branch            bool   `xml:"branch,attr"`
+	ConditionCoverage string `xml:"condition-coverage,attr"`
+}
+
+type jacocoReport struct {
+	Packages []jacocoPackage `xml:"package"`
+	Counters []jacocoCounter `xml:"counter"`
+}
+
+type jacocoPackage struct {
+	Name     string          `xml:"name,attr"`
+	Counters []jacocoCounter `xml:"counter"`
+}
+
+type jacocoCounter struct {
+	Type    string `xml:"type,attr"`
+	Missed  int    `xml:"missed,attr"`
+	Covered int    `xml:"covered,attr"`
+}
+
+func parseCoverageReport(format, file string) (*types.TestCoverage, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case types.CoverageFormatCobertura:
+		return parseCoberturaReport(content)
+	case types.CoverageFormatJaCoCo:
+		return parseJaCoCoReport(content)
+	default:
+		return nil, fmt.Errorf("unsupported coverage format: %s", format)
+	}
+}
+
+func parseCoberturaReport(content []byte) (*types.TestCoverage, error) {
+	report := &coberturaReport{}
+	if err := xml.Unmarshal(content, report); err != nil {
+		return nil, fmt.Errorf("unmarshal cobertura report error: %s", err)
+	}
+
+	coverage := &types.TestCoverage{Format: types.CoverageFormatCobertura, Packages: []*types.PackageCoverage{}}
+	for _, pkg := range report.Packages {
+		pkgCoverage := &types.PackageCoverage{Name: pkg.Name}
+		for _, class := range pkg.Classes {
+			for _, line := range class.Lines {
+				pkgCoverage.LinesValid++
+				if line.Hits > 0 {
+					pkgCoverage.LinesCovered++
+				}
+				if line.Branch {
+					covered, valid := parseConditionCoverage(line.ConditionCoverage)
+					pkgCoverage.BranchesCovered += covered
+					pkgCoverage.BranchesValid += valid
+				}
+			}
+		}
+		coverage.LinesCovered += pkgCoverage.LinesCovered
+		coverage.LinesValid += pkgCoverage.LinesValid
+		coverage.BranchesCovered += pkgCoverage.BranchesCovered
+		coverage.BranchesValid += pkgCoverage.BranchesValid
+		coverage.Packages = append(coverage.Packages, pkgCoverage)
+	}
+	// prefer the summary of the report, which is accurate when a line is shared by classes
+	if report.LinesValid > 0 {
+		coverage.LinesCovered = report.LinesCovered
+		coverage.LinesValid = report.LinesValid
+		coverage.BranchesCovered = report.BranchesCovered
+		coverage.BranchesValid = report.BranchesValid
+	}
+	return coverage, nil
+}
+
+// parseConditionCoverage parses the condition coverage like "50% (1/2)".
+func parseConditionCoverage(conditionCoverage string) (int, int) {
+	start := strings.Index(conditionCoverage, "(")
+	end := strings.Index(conditionCoverage, ")")
+	if start < 0 || end < start {
+		return 0, 0
+	}
+	var covered, valid int
+	if _, err := fmt.Sscanf(conditionCoverage[start+1:end], "%d/%d", &covered, &valid); err != nil {
+		return 0, 0
+	}
+	return covered, valid
+}
+
+func parseJaCoCoReport(content []byte) (*types.TestCoverage, error) {
+	report := &jacocoReport{}
+	if err := xml.Unmarshal(content, report); err != nil {
+		return nil, fmt.Errorf("unmarshal jacoco report error: %s", err)
+	}
+
+	coverage := &types.TestCoverage{Format: types.CoverageFormatJaCoCo, Packages: []*types.PackageCoverage{}}
+	coverage.LinesCovered, coverage.LinesValid = jacocoCounterValue(report.Counters, "LINE")
+	coverage.BranchesCovered, coverage.BranchesValid = jacocoCounterValue(report.Counters, "BRANCH")
+	for _, pkg := range report.Packages {
+		pkgCoverage := &types.PackageCoverage{Name: strings.ReplaceAll(pkg.Name, "/", ".")}
+		pkgCoverage.LinesCovered, pkgCoverage.LinesValid = jacocoCounterValue(pkg.Counters, "LINE")
+		pkgCoverage.BranchesCovered, pkgCoverage.BranchesValid = jacocoCounterValue(pkg.Counters, "BRANCH")
+		coverage.Packages = append(coverage.Packages, pkgCoverage)
+	}
+	return coverage, nil
+}
+
+func jacocoCounterValue(counters []jacocoCounter, counterType string) (int, int) {
+	for _, counter := range counters {
+		if counter.Type == counterType {
+			return counter.Covered, counter.Covered + counter.Missed
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/types"
+)
+
+const coberturaSample = `<?xml version="1.0" ?>
+<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">
+<coverage line-rate="0.6" branch-rate="0.5" lines-covered="3" lines-valid="5" branches-covered="2" branches-valid="4" version="1.9" timestamp="1672531200">
+  <packages>
+    <package name="github.com/example/app/api" line-rate="0.666">
+      <classes>
+        <class name="handler.go" filename="api/handler.go">
+          <lines>
+            <line number="10" hits="3"/>
+            <line number="11" hits="0"/>
+            <line number="12" hits="1" branch="true" condition-coverage="50% (1/2)"/>
+          </lines>
+        </class>
+      </classes>
+    </package>
+    <package name="github.com/example/app/store" line-rate="0.5">
+      <classes>
+        <class name="store.go" filename="store/store.go">
+          <lines>
+            <line number="5" hits="2" branch="true" condition-coverage="50% (1/2)"/>
+            <line number="6" hits="0"/>
+          </lines>
+        </class>
+      </classes>
+    </package>
+  </packages>
+</coverage>`
+
+const jacocoSample = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<report name="app">
+  <sessioninfo id="session" start="1672531200000" dump="1672531260000"/>
+  <package name="com/example/app/api">
+    <class name="com/example/app/api/Handler" sourcefilename="Handler.java">
+      <counter type="LINE" missed="1" covered="9"/>
+    </class>
+    <counter type="INSTRUCTION" missed="12" covered="88"/>
+    <counter type="BRANCH" missed="2" covered="6"/>
+    <counter type="LINE" missed="1" covered="9"/>
+  </package>
+  <package name="com/example/app/store">
+    <counter type="LINE" missed="5" covered="5"/>
+  </package>
+  <counter type="INSTRUCTION" missed="30" covered="120"/>
+  <counter type="BRANCH" missed="2" covered="6"/>
+  <counter type="LINE" missed="6" covered="14"/>
+</report>`
+
+func TestParseCoberturaReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *types.TestCoverage
+		wantErr bool
+	}{
+		{
+			name:    "report with summary",
+			content: coberturaSample,
+			want: &types.TestCoverage{
+				Format:          types.CoverageFormatCobertura,
+				LinesCovered:    3,
+				LinesValid:      5,
+				BranchesCovered: 2,
+				BranchesValid:   4,
+				Packages: []*types.PackageCoverage{
+					{Name: "github.com/example/app/api", LinesCovered: 2, LinesValid: 3, BranchesCovered: 1, BranchesValid: 2},
+					{Name: "github.com/example/app/store", LinesCovered: 1, LinesValid: 2, BranchesCovered: 1, BranchesValid: 2},
+				},
+			},
+		},
+		{
+			name: "report without summary",
+			content: `<coverage><packages><package name="app"><classes>
+				<class><lines><line hits="1"/><line hits="0" branch="true" condition-coverage="0% (0/2)"/></lines></class>
+				<class><lines><line hits="4"/></lines></class>
+			</classes></package></packages></coverage>`,
+			want: &types.TestCoverage{
+				Format:          types.CoverageFormatCobertura,
+				LinesCovered:    2,
+				LinesValid:      3,
+				BranchesCovered: 0,
+				BranchesValid:   2,
+				Packages: []*types.PackageCoverage{
+					{Name: "app", LinesCovered: 2, LinesValid: 3, BranchesCovered: 0, BranchesValid: 2},
+				},
+			},
+		},
+		{
+			name:    "empty report",
+			content: `<coverage/>`,
+			want:    &types.TestCoverage{Format: types.CoverageFormatCobertura, Packages: []*types.PackageCoverage{}},
+		},
+		{
+			name:    "invalid xml",
+			content: `<coverage`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCoberturaReport([]byte(tt.content))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseConditionCoverage(t *testing.T) {
+	tests := []struct {
+		conditionCoverage string
+		wantCovered       int
+		wantValid         int
+	}{
+		{conditionCoverage: "50% (1/2)", wantCovered: 1, wantValid: 2},
+		{conditionCoverage: "100% (4/4)", wantCovered: 4, wantValid: 4},
+		{conditionCoverage: "0% (0/6)", wantCovered: 0, wantValid: 6},
+		{conditionCoverage: "", wantCovered: 0, wantValid: 0},
+		{conditionCoverage: "50%", wantCovered: 0, wantValid: 0},
+		{conditionCoverage: "50% )1/2(", wantCovered: 0, wantValid: 0},
+		{conditionCoverage: "50% (a/b)", wantCovered: 0, wantValid: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.conditionCoverage, func(t *testing.T) {
+			covered, valid := parseConditionCoverage(tt.conditionCoverage)
+			assert.Equal(t, tt.wantCovered, covered)
+			assert.Equal(t, tt.wantValid, valid)
+		})
+	}
+}
+
+func TestParseJaCoCoReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *types.TestCoverage
+		wantErr bool
+	}{
+		{
+			name:    "report with packages",
+			content: jacocoSample,
+			want: &types.TestCoverage{
+				Format:          types.CoverageFormatJaCoCo,
+				LinesCovered:    14,
+				LinesValid:      20,
+				BranchesCovered: 6,
+				BranchesValid:   8,
+				Packages: []*types.PackageCoverage{
+					{Name: "com.example.app.api", LinesCovered: 9, LinesValid: 10, BranchesCovered: 6, BranchesValid: 8},
+					{Name: "com.example.app.store", LinesCovered: 5, LinesValid: 10},
+				},
+			},
+		},
+		{
+			name:    "report without counters",
+			content: `<report name="empty"/>`,
+			want:    &types.TestCoverage{Format: types.CoverageFormatJaCoCo, Packages: []*types.PackageCoverage{}},
+		},
+		{
+			name:    "invalid xml",
+			content: `<report><counter type="LINE" missed="x"/></report>`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJaCoCoReport([]byte(tt.content))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/koderover/zadig/pkg/microservice/reaper/core/service/meta"
+	"github.com/koderover/zadig/pkg/tool/log"
+)
+
+// goTestEvent is the event printed by `go test -json`, see `go doc test2json`.
+type goTestEvent struct {
+	Time    time.Time `json:"Time"`
+	Action  string    `json:"Action"`
+	Package string    `json:"Package"`
+	Test    string    `json:"Test"`
+	Elapsed float64   `json:"Elapsed"`
+	Output  string    `json:"Output"`
+}
+
+type goTestPackage struct {
+	name    string
+	failed  bool
+	elapsed float64
+	tests   []string
+	cases   map[string]*meta.TestCase
+	outputs map[string]*strings.Builder
+}
+
+// convertGoTestResults converts the go test json files in reportDir to junit files in destDir,
+// so they are merged like the other junit results.
+func convertGoTestResults(reportDir, destDir string) error {
+	files, err := os.ReadDir(reportDir)
+	if err != nil || len(files) == 0 {
+		return fmt.Errorf("test result files not found in path %s", reportDir)
+	}
+
+	packages := make(map[string]*goTestPackage)
+	packageNames := make([]string, 0)
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		events, err := readGoTestEvents(filepath.Join(reportDir, file.Name()))
+		if err != nil {
+			log.Warningf("Read go test result file [%s], error: %v", file.Name(), err)
+			continue
+		}
+		for _, event := range events {
+			pkg, ok := packages[event.Package]
+			if !ok {
+				pkg = &goTestPackage{
+					name:    event.Package,
+					cases:   make(map[string]*meta.TestCase),
+					outputs: make(map[string]*strings.Builder),
+				}
+				packages[event.Package] = pkg
+				packageNames = append(packageNames, event.Package)
+			}
+			pkg.addEvent(event)
+		}
+	}
+
+	for index, name := range packageNames {
+		suite := packages[name].toTestSuite()
+		xmlBytes, err := xml.MarshalIndent(suite, "  ", "    ")
+		if err != nil {
+			return err
+		}
+		fileName := filepath.Join(destDir, fmt.Sprintf("go-test-%d.xml", index))
+		if err := os.WriteFile(fileName, append([]byte(xml.Header), xmlBytes...), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readGoTestEvents(file string) ([]*goTestEvent, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events := make([]*goTestEvent, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// the build output of go test is not json, skip it
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		event := &goTestEvent{}
+		if err := json.Unmarshal([]byte(line), event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+func (p *goTestPackage) addEvent(event *goTestEvent) {
+	if event.Test == "" {
+		switch event.Action {
+		case "fail":
+			p.failed = true
+			p.elapsed = event.Elapsed
+		case "pass", "skip":
+			p.elapsed = event.Elapsed
+		case "output":
+			p.output("").WriteString(event.Output)
+		}
+		return
+	}
+
+	testCase, ok := p.cases[event.Test]
+	if !ok {
+		testCase = &meta.TestCase{Name: event.Test, ClassName: p.name}
+		p.cases[event.Test] = testCase
+		p.tests = append(p.tests, event.Test)
+	}
+	switch event.Action {
+	case "output":
+		p.output(event.Test).WriteString(event.Output)
+	case "pass":
+		testCase.Time = event.Elapsed
+	case "skip":
+		testCase.Time = event.Elapsed
+		testCase.Skipped = &meta.Skipped{}
+	case "fail":
+		testCase.Time = event.Elapsed
+		testCase.Failure = &meta.Failure{Message: "Failed"}
+	}
+}
+
+func (p *goTestPackage) output(test string) *strings.Builder {
+	if _, ok := p.outputs[test]; !ok {
+		p.outputs[test] = &strings.Builder{}
+	}
+	return p.outputs[test]
+}
+
+func (p *goTestPackage) toTestSuite() *meta.TestSuite {
+	suite := &meta.TestSuite{
+		Name:      p.name,
+		Time:      p.elapsed,
+		TestCases: []meta.TestCase{},
+	}
+	for _, test := range p.tests {
+		testCase := p.cases[test]
+		if testCase.Failure != nil {
+			testCase.Failure.Text = p.output(test).String()
+			suite.Failures++
+		}
+		// skipped cases are not counted in tests, the same as the ginkgo junit reports
+		if testCase.Skipped != nil {
+			suite.Skips++
+		} else {
+			suite.Tests++
+		}
+		suite.TestCases = append(suite.TestCases, *testCase)
+	}
+	// the package failed without any failed test, e.g. it does not compile
+	if p.failed && suite.Failures == 0 {
+		suite.Tests++
+		suite.Failures++
+		suite.TestCases = append(suite.TestCases, meta.TestCase{
+			Name:      "[setup failed]",
+			ClassName: p.name,
+			Time:      p.elapsed,
+			Failure:   &meta.Failure{Message: "Failed", Text: p.output("").String()},
+		})
+	}
+	return suite
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/koderover/zadig/pkg/microservice/reaper/core/service/meta"
+)
+
+const goTestSample = `go: downloading github.com/stretchr/testify v1.8.1
+{"Time":"2023-01-01T00:00:00Z","Action":"start","Package":"example.com/app/api"}
+{"Time":"2023-01-01T00:00:00Z","Action":"run","Package":"example.com/app/api","Test":"TestGet"}
+{"Time":"2023-01-01T00:00:00Z","Action":"output","Package":"example.com/app/api","Test":"TestGet","Output":"=== RUN   TestGet\n"}
+{"Time":"2023-01-01T00:00:00Z","Action":"pass","Package":"example.com/app/api","Test":"TestGet","Elapsed":0.5}
+{"Time":"2023-01-01T00:00:00Z","Action":"run","Package":"example.com/app/api","Test":"TestPost"}
+{"Time":"2023-01-01T00:00:00Z","Action":"output","Package":"example.com/app/api","Test":"TestPost","Output":"    api_test.go:20: unexpected status 500\n"}
+{"Time":"2023-01-01T00:00:00Z","Action":"fail","Package":"example.com/app/api","Test":"TestPost","Elapsed":0.25}
+{"Time":"2023-01-01T00:00:00Z","Action":"run","Package":"example.com/app/api","Test":"TestDelete"}
+{"Time":"2023-01-01T00:00:00Z","Action":"skip","Package":"example.com/app/api","Test":"TestDelete","Elapsed":0}
+{"Time":"2023-01-01T00:00:00Z","Action":"fail","Package":"example.com/app/api","Elapsed":1.5}
+`
+
+const goTestBuildFailedSample = `{"Time":"2023-01-01T00:00:00Z","Action":"output","Package":"example.com/app/store","Output":"store.go:3:1: syntax error\n"}
+{"Time":"2023-01-01T00:00:00Z","Action":"fail","Package":"example.com/app/store","Elapsed":0.1}
+`
+
+func TestConvertGoTestResults(t *testing.T) {
+	reportDir, destDir := t.TempDir(), t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(reportDir, "nested"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(reportDir, "api.json"), []byte(goTestSample), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(reportDir, "store.json"), []byte(goTestBuildFailedSample), 0644))
+
+	assert.NoError(t, convertGoTestResults(reportDir, destDir))
+
+	readSuite := func(name string) *meta.TestSuite {
+		content, err := os.ReadFile(filepath.Join(destDir, name))
+		assert.NoError(t, err)
+		suite := &meta.TestSuite{}
+		assert.NoError(t, xml.Unmarshal(content, suite))
+		return suite
+	}
+
+	api := readSuite("go-test-0.xml")
+	assert.Equal(t, 2, api.Tests)
+	assert.Equal(t, 1, api.Failures)
+	assert.Equal(t, 1, api.Skips)
+	assert.Equal(t, 1.5, api.Time)
+	assert.Len(t, api.TestCases, 3)
+	assert.Equal(t, "TestGet", api.TestCases[0].Name)
+	assert.Equal(t, "example.com/app/api", api.TestCases[0].ClassName)
+	assert.Equal(t, 0.5, api.TestCases[0].Time)
+	assert.Nil(t, api.TestCases[0].Failure)
+	assert.Equal(t, "TestPost", api.TestCases[1].Name)
+	assert.Equal(t, &meta.Failure{Message: "Failed", Text: "    api_test.go:20: unexpected status 500\n"}, api.TestCases[1].Failure)
+	assert.Equal(t, "TestDelete", api.TestCases[2].Name)
+	assert.NotNil(t, api.TestCases[2].Skipped)
+
+	store := readSuite("go-test-1.xml")
+	assert.Equal(t, 1, store.Tests)
+	assert.Equal(t, 1, store.Failures)
+	assert.Len(t, store.TestCases, 1)
+	assert.Equal(t, "[setup failed]", store.TestCases[0].Name)
+	assert.Equal(t, "store.go:3:1: syntax error\n", store.TestCases[0].Failure.Text)
+}
+
+func TestConvertGoTestResultsEmptyDir(t *testing.T) {
+	assert.Error(t, convertGoTestResults(t.TempDir(), t.TempDir()))
+}
@@ -110,11 +110,12 @@ func (s *ShellStep) Run(ctx context.Context) error {
 		handleCmdOutput(cmdStdErrReader, needPersistentLog, fileName, s.secretEnvs)
 	}()
 
-	if err := cmd.Start(); err != nil {
+	wait, err := startCmd(ctx, cmd)
+	if err != nil {
 		return err
 	}
 
 	wg.Wait()
 
-	return cmd.Wait()
+	return wait()
 }
@@ -50,7 +50,7 @@ func (s *ToolInstallStep) Run(ctx context.Context) error {
 
 	for _, tool := range s.spec.Installs {
 		log.Infof("Installing %s %s.", tool.Name, tool.Version)
-		if err := s.runIntallationScripts(tool); err != nil {
+		if err := s.runIntallationScripts(ctx, tool); err != nil {
 			return err
 		}
 	}
@@ -58,7 +58,7 @@ func (s *ToolInstallStep) Run(ctx context.Context) error {
 	return nil
 }
 
-func (s *ToolInstallStep) runIntallationScripts(tool *step.Tool) error {
+func (s *ToolInstallStep) runIntallationScripts(ctx context.Context, tool *step.Tool) error {
 	if tool == nil {
 		return nil
 	}
@@ -141,7 +141,7 @@ func (s *ToolInstallStep) runIntallationScripts(tool *step.Tool) error {
 	cmd.Stderr = os.Stderr
 	cmd.Env = s.envs
 
-	if err := cmd.Run(); err != nil {
+	if err := runCmd(ctx, cmd); err != nil {
 		return err
 	}
 
@@ -44,6 +44,15 @@ func Execute(ctx context.Context) error {
 	// os.Stdout = f
 	// os.Stderr = f
 
+	// secrets must be masked before anything is printed, errors are returned after the job status can be reported.
+	jobCtx, prepareErr := job.LoadJobContext()
+	if prepareErr == nil {
+		var restoreOutput func()
+		if restoreOutput, prepareErr = job.MaskOutput(jobCtx); prepareErr == nil {
+			defer restoreOutput()
+		}
+	}
+
 	log.Init(&log.Config{
 		Level:       commonconfig.LogLevel(),
 		NoCaller:    true,
@@ -74,8 +83,13 @@ func Execute(ctx context.Context) error {
 		time.Sleep(30 * time.Second)
 	}()
 
+	if prepareErr != nil {
+		err = prepareErr
+		return err
+	}
+
 	var j *job.Job
-	j, err = job.NewJob()
+	j, err = job.NewJob(jobCtx)
 	if err != nil {
 		return err
 	}
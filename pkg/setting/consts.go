@@ -374,6 +374,7 @@ const (
 	ProductStatusDeleting = "deleting"
 	ProductStatusUnknown  = "unknown"
 	ProductStatusUnstable = "Unstable"
+	ProductStatusSleeping = "sleeping"
 )
 
 // DeliveryVersion status
@@ -536,6 +537,11 @@ const (
 	WorkflowCronjob   = "workflow"
 	WorkflowV4Cronjob = "workflow_v4"
 	TestingCronjob    = "test"
+	EnvSleepCronjob   = "env_sleep"
+
+	// actions of the env sleep cronjob
+	EnvSleepActionSleep = "sleep"
+	EnvSleepActionWake  = "wake"
 
 	TopicProcess      = "task.process"
 	TopicCancel       = "task.cancel"
@@ -543,6 +549,12 @@ const (
 	TopicItReport     = "task.it.report"
 	TopicNotification = "task.notification"
 	TopicCronjob      = "cronjob"
+
+	// topics shared by aslan replicas to route workflow v4 operations to the replica running the task
+	TopicWorkflowCancel     = "workflow.cancel"
+	TopicWorkflowApprove    = "workflow.approve"
+	TopicWorkflowPause      = "workflow.pause"
+	TopicWorkflowManualGate = "workflow.manualgate"
 )
 
 // S3 related constants
@@ -587,6 +599,12 @@ const (
 	ZadigDockerfilePath = "zadig-dockerfile"
 )
 
+// Image builder constant
+const (
+	ImageBuilderDocker   = "docker"
+	ImageBuilderBuildKit = "buildkit"
+)
+
 // Yaml template constant
 const (
 	RegExpParameter = `{{.(\w)+}}`
@@ -700,6 +718,8 @@ const AttachedClusterNamespace = "koderover-agent"
 
 const ArtifactResultOut = "artifactResultOut.tar.gz"
 
+const TestCoverageOut = "coverage.json"
+
 const (
 	DefaultReleaseNaming     = "$Service$"
 	ReleaseNamingPlaceholder = "$Namespace$-$Service$"
@@ -770,3 +790,11 @@ const (
 	VariableSourceRuntime = "runtime"
 	VariableSourceOther   = "other"
 )
+
+// dependency cache used by cache_restore/cache_save steps
+const (
+	// DependencyCachePrefix is the object storage prefix under which dependency caches of each project are stored
+	DependencyCachePrefix = "dependency-cache"
+	// DependencyCacheMountDir is where the NFS cache volume is mounted when dependency caches use NFS
+	DependencyCacheMountDir = "/zadig/dependency-cache"
+)
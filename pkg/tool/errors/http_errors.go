@@ -290,6 +290,12 @@ var (
 
 	// ErrApproveTask ...
 	ErrApproveTask = NewHTTPError(6169, "批准工作流任务失败")
+	// ErrPauseTask ...
+	ErrPauseTask = NewHTTPError(6170, "暂停工作流任务失败")
+	// ErrResumeTask ...
+	ErrResumeTask = NewHTTPError(6171, "恢复工作流任务失败")
+	// ErrSubmitManualGate ...
+	ErrSubmitManualGate = NewHTTPError(6172, "提交人工确认失败")
 
 	//-----------------------------------------------------------------------------------------------
 	// Keystore APIs Range: 6180 - 6189
@@ -816,4 +822,14 @@ var (
 	ErrCreateMeegoHook = NewHTTPError(6982, "创建飞书 hook 失败")
 	ErrUpdateMeegoHook = NewHTTPError(6983, "更新飞书 hook 失败")
 	ErrDeleteMeegoHook = NewHTTPError(6984, "删除飞书 hook 失败")
+
+	//-----------------------------------------------------------------------------------------------
+	// step template releated Error Range: 6990 - 6999
+	//-----------------------------------------------------------------------------------------------
+	ErrCreateStepTemplate = NewHTTPError(6990, "创建步骤模板失败")
+	ErrUpdateStepTemplate = NewHTTPError(6991, "更新步骤模板失败")
+	ErrListStepTemplate   = NewHTTPError(6992, "列出步骤模板失败")
+	ErrGetStepTemplate    = NewHTTPError(6993, "获取步骤模板失败")
+	ErrDeleteStepTemplate = NewHTTPError(6994, "删除步骤模板失败")
+	ErrLintStepTemplate   = NewHTTPError(6995, "检查步骤模板失败")
 )
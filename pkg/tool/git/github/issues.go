@@ -0,0 +1,42 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package github
+
+import (
+	"context"
+
+	"github.com/google/go-github/v35/github"
+)
+
+// CreateIssueComment comments on an issue or a pull request
+func (c *Client) CreateIssueComment(ctx context.Context, owner, repo string, number int, body string) (*github.IssueComment, error) {
+	created, err := wrap(c.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: &body}))
+	if ic, ok := created.(*github.IssueComment); ok {
+		return ic, err
+	}
+
+	return nil, err
+}
+
+func (c *Client) EditIssueComment(ctx context.Context, owner, repo string, commentID int64, body string) (*github.IssueComment, error) {
+	edited, err := wrap(c.Issues.EditComment(ctx, owner, repo, commentID, &github.IssueComment{Body: &body}))
+	if ic, ok := edited.(*github.IssueComment); ok {
+		return ic, err
+	}
+
+	return nil, err
+}
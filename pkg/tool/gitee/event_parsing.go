@@ -271,6 +271,13 @@ type PullRequestEventPullRequest struct {
 	Additions      int                   `json:"additions"`
 	Deletions      int                   `json:"deletions"`
 	ChangedFiles   int                   `json:"changed_files"`
+	Labels         []EventLabel          `json:"labels"`
+}
+
+type EventLabel struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
 }
 
 type PullRequestEventBase struct {
@@ -18,7 +18,9 @@ package updater
 
 import (
 	"context"
+	"fmt"
 
+	batchv1 "k8s.io/api/batch/v1"
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -46,3 +48,15 @@ func DeleteCronJobs(namespace string, selector labels.Selector, clientset *kuber
 func CreateOrPatchCronJob(cj *batchv1beta1.CronJob, cl client.Client) error {
 	return createOrPatchObject(cj, cl)
 }
+
+func SuspendCronJob(ns, name string, suspend bool, cl client.Client, versionLessThan121 bool) error {
+	patchBytes := []byte(fmt.Sprintf(`{"spec":{"suspend": %t}}`, suspend))
+	objectMeta := metav1.ObjectMeta{
+		Namespace: ns,
+		Name:      name,
+	}
+	if versionLessThan121 {
+		return patchObject(&batchv1beta1.CronJob{ObjectMeta: objectMeta}, patchBytes, cl)
+	}
+	return patchObject(&batchv1.CronJob{ObjectMeta: objectMeta}, patchBytes, cl)
+}
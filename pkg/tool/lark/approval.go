@@ -207,6 +207,23 @@ func (client *Client) GetApprovalInstance(args *GetApprovalInstanceArgs) (*Appro
 	return nil, errors.New("not found timeline")
 }
 
+// GetApprovalInstanceStatus returns the status of the instance, e.g. PENDING, APPROVED, REJECTED
+func (client *Client) GetApprovalInstanceStatus(instanceID string) (string, error) {
+	req := larkapproval.NewGetInstanceReqBuilder().
+		InstanceId(instanceID).
+		Build()
+
+	resp, err := client.Approval.Instance.Get(context.Background(), req)
+	if err != nil {
+		return "", errors.Wrap(err, "send request")
+	}
+
+	if !resp.Success() {
+		return "", resp.CodeError
+	}
+	return getStringFromPointer(resp.Data.Status), nil
+}
+
 type CancelApprovalInstanceArgs struct {
 	ApprovalID string
 	InstanceID string
@@ -0,0 +1,98 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rsa
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SignByPrivateKey signs the sha256 digest of the data with a PEM encoded RSA private key in PKCS1, PKCS8 or OpenSSH form.
+func SignByPrivateKey(data, key []byte) ([]byte, error) {
+	privateKey, err := parsePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	hashed := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashed[:])
+}
+
+// VerifyByPublicKey verifies the signature made by SignByPrivateKey with a PEM encoded public key.
+func VerifyByPublicKey(data, signature, key []byte) error {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return errors.New("public key error")
+	}
+	publicKeyInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	publicKey, ok := publicKeyInterface.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("not a rsa public key")
+	}
+	hashed := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], signature)
+}
+
+// PublicKeyOfPrivateKey returns the PEM encoded public key of the private key, so that the signatures can be verified by others.
+func PublicKeyOfPrivateKey(key []byte) ([]byte, error) {
+	privateKey, err := parsePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	x509PublicKey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: publicKeyPrefix, Bytes: x509PublicKey}), nil
+}
+
+const openSSHPrivateKeyType = "OPENSSH PRIVATE KEY"
+
+func parsePrivateKey(key []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("private key error")
+	}
+	var privateKeyInterface interface{}
+	var err error
+	if block.Type == openSSHPrivateKeyType {
+		// keys generated by recent versions of ssh-keygen
+		privateKeyInterface, err = ssh.ParseRawPrivateKey(key)
+	} else {
+		if privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+			return privateKey, nil
+		}
+		privateKeyInterface, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := privateKeyInterface.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not a rsa private key")
+	}
+	return privateKey, nil
+}
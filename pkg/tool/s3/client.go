@@ -295,3 +295,35 @@ func (c *Client) ListFiles(bucketName, prefix string, recursive bool) ([]string,
 
 	return ret, nil
 }
+
+// ListObjectsWithInfo lists all the objects with given prefix recursively, the size and last modified time of the objects are kept.
+func (c *Client) ListObjectsWithInfo(bucketName, prefix string) ([]*s3.Object, error) {
+	ret := make([]*s3.Object, 0)
+
+	input := &s3.ListObjectsInput{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(prefix),
+	}
+	err := c.ListObjectsPages(input, func(output *s3.ListObjectsOutput, lastPage bool) bool {
+		ret = append(ret, output.Contents...)
+		return true
+	})
+	if err != nil {
+		log.Errorf("bucket [%s] listing objects with prefix [%v] failed, error: %v", bucketName, prefix, err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TouchObject refreshes the last modified time of an object by copying it onto itself.
+func (c *Client) TouchObject(bucketName, key string) error {
+	opt := &s3.CopyObjectInput{
+		Bucket:            aws.String(bucketName),
+		CopySource:        aws.String(bucketName + "/" + key),
+		Key:               aws.String(key),
+		MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+	}
+	_, err := c.S3.CopyObject(opt)
+
+	return err
+}
@@ -18,6 +18,7 @@ package job
 
 import (
 	"fmt"
+	"path"
 	"strings"
 
 	"github.com/koderover/zadig/pkg/setting"
@@ -26,13 +27,77 @@ import (
 const (
 	JobOutputDir       = "/zadig/results/"
 	JobTerminationFile = "/zadig/termination"
+	// JobSecretFileDir keeps the credentials provided as files.
+	JobSecretFileDir = "/zadig/secrets/"
+	// JobStepResultFile keeps the results of the steps which have run, aslan reads it to show the step durations.
+	JobStepResultFile = "/zadig/step_results"
+	// JobServicesReadyFile is created by aslan once all the service containers of the job are ready,
+	// the job executor waits for it before running the steps.
+	JobServicesReadyFile = "/zadig/services_ready"
+	// MaxInlineOutputLength is the max length of an output value written into the termination message,
+	// larger values are uploaded to the object storage.
+	MaxInlineOutputLength = 512
+)
+
+type OutputType string
+
+const (
+	// OutputTypeString is the content of the output file.
+	OutputTypeString OutputType = "string"
+	// OutputTypeJSON is the content of the output file, which must be valid json.
+	OutputTypeJSON OutputType = "json"
+	// OutputTypeFile is the content of the file whose path in the workspace is written in the output file.
+	OutputTypeFile OutputType = "file"
 )
 
 type JobOutput struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
+	// ObjectKey is set when the value is saved in the object storage instead of the termination message.
+	ObjectKey string `json:"object_key,omitempty"`
+	// StorageID is the id of the object storage the value is saved in.
+	StorageID string `json:"storage_id,omitempty"`
+}
+
+// StepResult is the result of a step in the job executor, the status is one of the step statuses of aslan,
+// such as passed, failed, timeout and skipped.
+type StepResult struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+	// Attempts is the times the step has run, it is larger than 1 only if the step is retried.
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// GetOutputObjectKey returns the object key of a large output value in the object storage.
+func GetOutputObjectKey(subfolder, workflowName string, taskID int64, jobName, outputName string) string {
+	return strings.TrimLeft(path.Join(subfolder, workflowName, fmt.Sprint(taskID), jobName, "outputs", outputName), "/")
+}
+
+// OutputRefPrefix marks the output values saved in the object storage, only the reference
+// <prefix><storage id>/<object key> is kept in the workflow task.
+const OutputRefPrefix = "zadig-output-ref://"
+
+func GetOutputRef(storageID, objectKey string) string {
+	return OutputRefPrefix + storageID + "/" + objectKey
+}
+
+// ParseOutputRef returns the storage id and object key of an output reference, ok is false if the value is not a reference.
+func ParseOutputRef(value string) (storageID, objectKey string, ok bool) {
+	if !strings.HasPrefix(value, OutputRefPrefix) {
+		return "", "", false
+	}
+	storageID, objectKey, ok = strings.Cut(strings.TrimPrefix(value, OutputRefPrefix), "/")
+	return storageID, objectKey, ok && objectKey != ""
 }
 
 func GetJobOutputKey(key, outputName string) string {
 	return fmt.Sprintf(setting.RenderValueTemplate, strings.Join([]string{"job", key, "output", outputName}, "."))
 }
+
+// GetJobAttemptLogName returns the name the log of a failed attempt of the job is saved as.
+func GetJobAttemptLogName(jobName string, attempt int) string {
+	return fmt.Sprintf("%s-attempt-%d", jobName, attempt)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOutputRef(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		wantStorageID string
+		wantObjectKey string
+		wantOK        bool
+	}{
+		{
+			name:  "plain value",
+			value: "registry.example.com/app:v1",
+		},
+		{
+			name:          "reference",
+			value:         GetOutputRef("64b0c2a4f1d2e3a4b5c6d7e8", "sub/wf/1/build/outputs/REPORT"),
+			wantStorageID: "64b0c2a4f1d2e3a4b5c6d7e8",
+			wantObjectKey: "sub/wf/1/build/outputs/REPORT",
+			wantOK:        true,
+		},
+		{
+			name:          "reference without storage id",
+			value:         GetOutputRef("", "wf/1/build/outputs/REPORT"),
+			wantObjectKey: "wf/1/build/outputs/REPORT",
+			wantOK:        true,
+		},
+		{
+			name:  "reference without object key",
+			value: OutputRefPrefix + "64b0c2a4f1d2e3a4b5c6d7e8",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storageID, objectKey, ok := ParseOutputRef(tt.value)
+			assert.Equal(t, tt.wantOK, ok)
+			if !ok {
+				return
+			}
+			assert.Equal(t, tt.wantStorageID, storageID)
+			assert.Equal(t, tt.wantObjectKey, objectKey)
+		})
+	}
+}
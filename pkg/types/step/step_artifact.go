@@ -0,0 +1,60 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	ArtifactArchiveSuffix  = ".tar.gz"
+	ArtifactChecksumSuffix = ".sha256"
+)
+
+// StepArtifactUploadSpec packs the paths into a named artifact of the workflow task,
+// artifact_download steps of the later jobs in the same task fetch it by the name.
+// Every job expanded from a matrix uploads its own artifact, named with the matrix values appended, e.g. bin.linux.amd64.
+type StepArtifactUploadSpec struct {
+	Name string `bson:"name"                       json:"name"                              yaml:"name"`
+	// Paths are relative to the workspace, files and directories outside the workspace are not allowed.
+	Paths     []string `bson:"paths"                      json:"paths"                             yaml:"paths"`
+	ObjectDir string   `bson:"object_dir"                 json:"object_dir"                        yaml:"object_dir"`
+	S3Storage *S3      `bson:"s3_storage"                 json:"s3_storage"                        yaml:"s3_storage"`
+	// ObjectName is the name the artifact is kept as, it is the name with the matrix values of the job appended.
+	ObjectName string `bson:"object_name"                json:"object_name"                       yaml:"object_name"`
+}
+
+type StepArtifactDownloadSpec struct {
+	Name string `bson:"name"                       json:"name"                              yaml:"name"`
+	// DestDir is relative to the workspace, the artifact is extracted to the workspace if it is empty.
+	DestDir   string `bson:"dest_dir"                   json:"dest_dir"                          yaml:"dest_dir"`
+	ObjectDir string `bson:"object_dir"                 json:"object_dir"                        yaml:"object_dir"`
+	S3Storage *S3    `bson:"s3_storage"                 json:"s3_storage"                        yaml:"s3_storage"`
+}
+
+// GetArtifactObjectDir returns where the artifacts of a workflow task are kept, they are under the directory of the task,
+// so they are cleaned up along with the task by the workflow task retention strategy.
+func GetArtifactObjectDir(workflowName string, taskID int64) string {
+	return path.Join(workflowName, fmt.Sprint(taskID), "artifacts")
+}
+
+// GetArtifactObjectKey returns the object key of the artifact archive in the subfolder of the storage.
+func GetArtifactObjectKey(subfolder, objectDir, name string) string {
+	return strings.TrimLeft(path.Join(subfolder, objectDir, name+ArtifactArchiveSuffix), "/")
+}
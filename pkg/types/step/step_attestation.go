@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	SBOMFormatSPDX      = "spdx-json"
+	SBOMFormatCycloneDX = "cyclonedx-json"
+
+	SBOMSourceImage     = "image"
+	SBOMSourceWorkspace = "workspace"
+
+	AttestationResultFile         = "attestation.json"
+	AttestationSignatureAlgorithm = "RSA-SHA256"
+	// AttestationTagSuffix is appended to the digest of the image to tag its attestation, the attestation of image
+	// foo@sha256:abc is pushed as foo:sha256-abc.zadig-att. It is a plain image holding the signed payload and the signature,
+	// not a cosign attestation, so it is verified with the public key recorded in the delivery version rather than by cosign.
+	AttestationTagSuffix = ".zadig-att"
+
+	signingKeyFilePrefix = "ZADIG_SIGNING_KEY_"
+)
+
+// StepAttestationSpec generates the SBOM of a pushed image, signs the image digest and attaches both to the registry.
+// The images built by buildkit are not supported since the attestation is pushed with the docker daemon.
+type StepAttestationSpec struct {
+	ImageName  string `bson:"image_name"                 json:"image_name"                        yaml:"image_name"`
+	SBOMFormat string `bson:"sbom_format"                json:"sbom_format"                       yaml:"sbom_format"`
+	SBOMSource string `bson:"sbom_source"                json:"sbom_source"                       yaml:"sbom_source"`
+	// SigningKeyID is the id of the private key, the key is provided to the job as the secret file named by GetSigningKeyFileName.
+	SigningKeyID   string          `bson:"signing_key_id"             json:"signing_key_id"                    yaml:"signing_key_id"`
+	DockerRegistry *DockerRegistry `bson:"docker_registry"            json:"docker_registry"                   yaml:"docker_registry"`
+	ObjectDir      string          `bson:"object_dir"                 json:"object_dir"                        yaml:"object_dir"`
+	S3Storage      *S3             `bson:"s3_storage"                 json:"s3_storage"                        yaml:"s3_storage"`
+}
+
+// AttestationResult is uploaded to the object dir of the step as AttestationResultFile.
+type AttestationResult struct {
+	Image         string `json:"image"`
+	Digest        string `json:"digest"`
+	SBOMFormat    string `json:"sbom_format"`
+	SBOMObjectKey string `json:"sbom_object_key"`
+	SBOMChecksum  string `json:"sbom_checksum"`
+	// Payload is the signed content, the signature is base64 encoded.
+	Payload            string `json:"payload"`
+	Signature          string `json:"signature"`
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	PublicKey          string `json:"public_key"`
+	AttestationRef     string `json:"attestation_ref"`
+}
+
+// AttestationPayload is the signed content, it binds the image digest and the SBOM together.
+type AttestationPayload struct {
+	DockerReference string `json:"docker_reference"`
+	Digest          string `json:"digest"`
+	SBOMFormat      string `json:"sbom_format"`
+	SBOMChecksum    string `json:"sbom_checksum"`
+	Timestamp       int64  `json:"timestamp"`
+}
+
+// GetSigningKeyFileName returns the name of the secret file holding the base64 encoded signing key.
+func GetSigningKeyFileName(signingKeyID string) string {
+	return signingKeyFilePrefix + signingKeyID
+}
+
+func GetAttestationObjectDir(workflowName string, taskID int64, jobName, stepName string) string {
+	return path.Join(workflowName, fmt.Sprint(taskID), "attestations", jobName, stepName)
+}
+
+// GetAttestationObjectKey returns the object key of the attestation file in the subfolder of the storage.
+func GetAttestationObjectKey(subfolder, objectDir, name string) string {
+	return strings.TrimLeft(path.Join(subfolder, objectDir, name), "/")
+}
@@ -0,0 +1,35 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import "github.com/koderover/zadig/pkg/types"
+
+// StepCacheSpec is shared by the cache_restore and cache_save steps.
+// Key is a template rendered in the job, e.g. `go-{{ hashFiles "go.sum" }}`, the cache archive is
+// addressed by the rendered key. RestoreKeys are prefixes tried in order when the key misses,
+// the most recently used cache matching a prefix is restored. RestoreKeys are ignored by cache_save.
+// CacheDir is the object storage prefix or the NFS directory holding the caches of the project,
+// cache_save evicts the least recently used caches in it once MaxSizeInMiB is exceeded.
+type StepCacheSpec struct {
+	Key          string           `bson:"key"                   json:"key"                   yaml:"key"`
+	RestoreKeys  []string         `bson:"restore_keys"          json:"restore_keys"          yaml:"restore_keys"`
+	Paths        []string         `bson:"paths"                 json:"paths"                 yaml:"paths"`
+	MediumType   types.MediumType `bson:"medium_type"           json:"medium_type"           yaml:"medium_type"`
+	CacheDir     string           `bson:"cache_dir"             json:"cache_dir"             yaml:"cache_dir"`
+	MaxSizeInMiB int64            `bson:"max_size_in_mib"       json:"max_size_in_mib"       yaml:"max_size_in_mib"`
+	S3Storage    *S3              `bson:"s3_storage"            json:"s3_storage"            yaml:"s3_storage"`
+}
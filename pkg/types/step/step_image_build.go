@@ -0,0 +1,51 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package step
+
+import (
+	"fmt"
+
+	"github.com/koderover/zadig/pkg/setting"
+)
+
+// StepImageBuildSpec builds and pushes an image with buildkit, no docker daemon is required.
+type StepImageBuildSpec struct {
+	Source                string          `bson:"source"                              json:"source"                                 yaml:"source"`
+	WorkDir               string          `bson:"work_dir"                            json:"work_dir"                               yaml:"work_dir"`
+	DockerFile            string          `bson:"docker_file"                         json:"docker_file"                            yaml:"docker_file"`
+	ImageName             string          `bson:"image_name"                          json:"image_name"                             yaml:"image_name"`
+	BuildArgs             string          `bson:"build_args"                          json:"build_args"                             yaml:"build_args"`
+	DockerTemplateContent string          `bson:"docker_template_content"             json:"docker_template_content"                yaml:"docker_template_content"`
+	Proxy                 *Proxy          `bson:"proxy"                               json:"proxy"                                  yaml:"proxy"`
+	IgnoreCache           bool            `bson:"ignore_cache"                        json:"ignore_cache"                           yaml:"ignore_cache"`
+	Platforms             []string        `bson:"platforms"                           json:"platforms"                              yaml:"platforms"`
+	EnableRegistryCache   bool            `bson:"enable_registry_cache"               json:"enable_registry_cache"                  yaml:"enable_registry_cache"`
+	CacheRef              string          `bson:"cache_ref"                           json:"cache_ref"                              yaml:"cache_ref"`
+	DigestOutput          string          `bson:"digest_output"                       json:"digest_output"                          yaml:"digest_output"`
+	DockerRegistry        *DockerRegistry `bson:"docker_registry"                     json:"docker_registry"                        yaml:"docker_registry"`
+}
+
+func (s *StepImageBuildSpec) GetDockerFile() string {
+	// if the source of the dockerfile is from template, we write our own dockerfile
+	if s.Source == setting.DockerfileSourceTemplate {
+		return fmt.Sprintf("/%s", setting.ZadigDockerfilePath)
+	}
+	if s.DockerFile == "" {
+		return "Dockerfile"
+	}
+	return s.DockerFile
+}
@@ -18,9 +18,17 @@ package step
 
 type StepJunitReportSpec struct {
 	ReportDir string `bson:"report_dir"                 json:"report_dir"                        yaml:"report_dir"`
-	DestDir   string `bson:"dest_dir"                   json:"dest_dir"                          yaml:"dest_dir"`
-	S3DestDir string `bson:"s3_dest_dir"                json:"s3_dest_dir"                       yaml:"s3_dest_dir"`
-	FileName  string `bson:"file_name"                  json:"file_name"                         yaml:"file_name"`
-	TestName  string `bson:"test_name"                  json:"test_name"                         yaml:"test_name"`
-	S3Storage *S3    `bson:"s3_storage"                 json:"s3_storage"                        yaml:"s3_storage"`
+	// ReportFormat is the format of the files in ReportDir, junit or go-test-json, default is junit
+	ReportFormat string `bson:"report_format"              json:"report_format"                     yaml:"report_format"`
+	DestDir      string `bson:"dest_dir"                   json:"dest_dir"                          yaml:"dest_dir"`
+	S3DestDir    string `bson:"s3_dest_dir"                json:"s3_dest_dir"                       yaml:"s3_dest_dir"`
+	FileName     string `bson:"file_name"                  json:"file_name"                         yaml:"file_name"`
+	TestName     string `bson:"test_name"                  json:"test_name"                         yaml:"test_name"`
+	S3Storage    *S3    `bson:"s3_storage"                 json:"s3_storage"                        yaml:"s3_storage"`
+	// CoverageFormat is the format of the coverage report, cobertura or jacoco, no coverage is collected if empty
+	CoverageFormat string `bson:"coverage_format"            json:"coverage_format"                   yaml:"coverage_format"`
+	// CoveragePath is the coverage report file, relative to the workspace
+	CoveragePath string `bson:"coverage_path"              json:"coverage_path"                     yaml:"coverage_path"`
+	// CoverageFileName is the name of the coverage summary uploaded to S3DestDir
+	CoverageFileName string `bson:"coverage_file_name"         json:"coverage_file_name"                yaml:"coverage_file_name"`
 }
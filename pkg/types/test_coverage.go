@@ -0,0 +1,59 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+const (
+	CoverageFormatCobertura = "cobertura"
+	CoverageFormatJaCoCo    = "jacoco"
+
+	TestReportFormatJunit      = "junit"
+	TestReportFormatGoTestJSON = "go-test-json"
+)
+
+// TestCoverage is the coverage summary parsed from a cobertura or jacoco report.
+type TestCoverage struct {
+	Format          string             `bson:"format"           json:"format"`
+	LinesCovered    int                `bson:"lines_covered"    json:"lines_covered"`
+	LinesValid      int                `bson:"lines_valid"      json:"lines_valid"`
+	BranchesCovered int                `bson:"branches_covered" json:"branches_covered"`
+	BranchesValid   int                `bson:"branches_valid"   json:"branches_valid"`
+	Packages        []*PackageCoverage `bson:"packages"         json:"packages"`
+}
+
+type PackageCoverage struct {
+	Name            string `bson:"name"             json:"name"`
+	LinesCovered    int    `bson:"lines_covered"    json:"lines_covered"`
+	LinesValid      int    `bson:"lines_valid"      json:"lines_valid"`
+	BranchesCovered int    `bson:"branches_covered" json:"branches_covered"`
+	BranchesValid   int    `bson:"branches_valid"   json:"branches_valid"`
+}
+
+// LineRate returns the percentage of the covered lines.
+func (c *TestCoverage) LineRate() float64 {
+	return coverageRate(c.LinesCovered, c.LinesValid)
+}
+
+func (c *PackageCoverage) LineRate() float64 {
+	return coverageRate(c.LinesCovered, c.LinesValid)
+}
+
+func coverageRate(covered, valid int) float64 {
+	if valid == 0 {
+		return 0
+	}
+	return float64(covered) * 100 / float64(valid)
+}
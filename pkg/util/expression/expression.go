@@ -0,0 +1,498 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package expression evaluates the `when` conditions of workflow jobs and steps.
+//
+// An expression is made of string, number and boolean literals, variables such as
+// workflow.params.env or trigger.branch, the operators == != < <= > >= && || ! and
+// the functions contains, startsWith, endsWith and matches, e.g.
+//
+//	trigger.event == "pr" && contains(trigger.labels, "deploy")
+//	matches(trigger.changed_files, "^docs/") || workflow.params.force == "true"
+//
+// A variable can be a string, a bool or a list of strings, an undefined variable is an empty string.
+// startsWith, endsWith and matches return true if any element of a list matches.
+package expression
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Eval evaluates the expression with the variables and returns its truth value.
+// an empty expression is always true.
+func Eval(expression string, variables map[string]interface{}) (bool, error) {
+	if strings.TrimSpace(expression) == "" {
+		return true, nil
+	}
+	node, err := Parse(expression)
+	if err != nil {
+		return false, err
+	}
+	value, err := node.eval(variables)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate %q: %v", expression, err)
+	}
+	return truthy(value), nil
+}
+
+// Node is a parsed expression.
+type Node interface {
+	eval(variables map[string]interface{}) (interface{}, error)
+}
+
+// Parse parses the expression, it is used to check the syntax before running.
+func Parse(expression string) (Node, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", expression, err)
+	}
+	p := &parser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", expression, err)
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("invalid expression %q: unexpected %q", expression, p.peek().value)
+	}
+	return node, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenIdent
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+func tokenize(s string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")"})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, value: ","})
+			i++
+		case c == '"' || c == '\'':
+			value, n, err := readString(s[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value})
+			i += n
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, value: s[i:j]})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(s) && isIdentPart(s[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, value: s[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!"} {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, value: op})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// readString reads a quoted string and returns its value and the length consumed.
+func readString(s string) (string, int, error) {
+	quote := s[0]
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 >= len(s) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i++
+			sb.WriteByte(s[i])
+		case quote:
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// job keys and names may contain '.' and '-'.
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '.' || c == '-'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOperator && p.peek().value == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOperator && p.peek().value == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek().kind == tokenOperator && p.peek().value == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return left, nil
+	}
+	switch t.value {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{op: t.value, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return &literalNode{value: t.value}, nil
+	case tokenNumber:
+		return &literalNode{value: t.value}, nil
+	case tokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRParen {
+			return nil, fmt.Errorf("missing )")
+		}
+		return node, nil
+	case tokenIdent:
+		switch t.value {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		}
+		if p.peek().kind != tokenLParen {
+			return &variableNode{name: t.value}, nil
+		}
+		p.next()
+		fn, ok := functions[t.value]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %s", t.value)
+		}
+		args := []Node{}
+		for p.peek().kind != tokenRParen {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+		if p.next().kind != tokenRParen {
+			return nil, fmt.Errorf("missing ) after arguments of %s", t.value)
+		}
+		if len(args) != 2 {
+			return nil, fmt.Errorf("function %s needs 2 arguments, got %d", t.value, len(args))
+		}
+		return &callNode{name: t.value, fn: fn, args: args}, nil
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q", t.value)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type variableNode struct {
+	name string
+}
+
+func (n *variableNode) eval(variables map[string]interface{}) (interface{}, error) {
+	value, ok := variables[n.name]
+	if !ok {
+		return "", nil
+	}
+	return value, nil
+}
+
+type notNode struct {
+	operand Node
+}
+
+func (n *notNode) eval(variables map[string]interface{}) (interface{}, error) {
+	value, err := n.operand.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right Node
+}
+
+func (n *logicalNode) eval(variables map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" && !truthy(left) {
+		return false, nil
+	}
+	if n.op == "||" && truthy(left) {
+		return true, nil
+	}
+	right, err := n.right.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right Node
+}
+
+func (n *compareNode) eval(variables map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	l, r := toString(left), toString(right)
+	switch n.op {
+	case "==":
+		return l == r, nil
+	case "!=":
+		return l != r, nil
+	}
+	lf, lerr := strconv.ParseFloat(l, 64)
+	rf, rerr := strconv.ParseFloat(r, 64)
+	if lerr != nil || rerr != nil {
+		return nil, fmt.Errorf("%s needs numbers, got %q and %q", n.op, l, r)
+	}
+	switch n.op {
+	case "<":
+		return lf < rf, nil
+	case "<=":
+		return lf <= rf, nil
+	case ">":
+		return lf > rf, nil
+	default:
+		return lf >= rf, nil
+	}
+}
+
+type function func(subject interface{}, arg string) (bool, error)
+
+var functions = map[string]function{
+	"contains": func(subject interface{}, arg string) (bool, error) {
+		if list, ok := subject.([]string); ok {
+			for _, item := range list {
+				if item == arg {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return strings.Contains(toString(subject), arg), nil
+	},
+	"startsWith": anyOf(func(s, arg string) (bool, error) {
+		return strings.HasPrefix(s, arg), nil
+	}),
+	"endsWith": anyOf(func(s, arg string) (bool, error) {
+		return strings.HasSuffix(s, arg), nil
+	}),
+	"matches": anyOf(func(s, arg string) (bool, error) {
+		return regexp.MatchString(arg, s)
+	}),
+}
+
+// anyOf applies f to a string, or to each element of a list until one of them returns true.
+func anyOf(f func(s, arg string) (bool, error)) function {
+	return func(subject interface{}, arg string) (bool, error) {
+		list, ok := subject.([]string)
+		if !ok {
+			return f(toString(subject), arg)
+		}
+		for _, item := range list {
+			matched, err := f(item, arg)
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	}
+}
+
+type callNode struct {
+	name string
+	fn   function
+	args []Node
+}
+
+func (n *callNode) eval(variables map[string]interface{}) (interface{}, error) {
+	subject, err := n.args[0].eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	arg, err := n.args[1].eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	result, err := n.fn(subject, toString(arg))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", n.name, err)
+	}
+	return result, nil
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case []string:
+		return strings.Join(v, ",")
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v != "" && v != "false"
+	case []string:
+		return len(v) > 0
+	}
+	return value != nil
+}
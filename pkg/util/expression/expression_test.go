@@ -0,0 +1,87 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package expression
+
+import (
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	variables := map[string]interface{}{
+		"workflow.params.env":    "prod",
+		"workflow.params.count":  "3",
+		"trigger.event":          "pr",
+		"trigger.branch":         "main",
+		"trigger.is_pr":          true,
+		"trigger.labels":         []string{"deploy", "urgent"},
+		"trigger.changed_files":  []string{"docs/README.md", "pkg/main.go"},
+		"job.build.output.IMAGE": "koderover/aslan:latest",
+	}
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{``, true},
+		{`true`, true},
+		{`!true`, false},
+		{`workflow.params.env == "prod"`, true},
+		{`workflow.params.env != 'prod'`, false},
+		{`workflow.params.count > 2 && workflow.params.count <= 3`, true},
+		{`trigger.event == "push" || trigger.branch == "main"`, true},
+		{`trigger.is_pr && !(trigger.branch == "dev")`, true},
+		{`contains(trigger.labels, "deploy")`, true},
+		{`contains(trigger.labels, "dep")`, false},
+		{`contains(job.build.output.IMAGE, "aslan")`, true},
+		{`startsWith(trigger.changed_files, "docs/")`, true},
+		{`endsWith(trigger.changed_files, ".java")`, false},
+		{`matches(trigger.changed_files, "^pkg/.*\\.go$")`, true},
+		{`trigger.tag`, false},
+		{`trigger.tag == ""`, true},
+	}
+	for _, tt := range tests {
+		got, err := Eval(tt.expression, variables)
+		if err != nil {
+			t.Errorf("Eval(%q) error: %v", tt.expression, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, expression := range []string{
+		`trigger.event ==`,
+		`(trigger.event == "pr"`,
+		`trigger.event == "pr`,
+		`unknown(trigger.labels, "a")`,
+		`contains(trigger.labels)`,
+		`trigger.event = "pr"`,
+	} {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("Parse(%q) should fail", expression)
+		}
+	}
+}
+
+func TestEvalError(t *testing.T) {
+	if _, err := Eval(`trigger.branch > 1`, map[string]interface{}{"trigger.branch": "main"}); err == nil {
+		t.Errorf("comparing a string with a number should fail")
+	}
+}
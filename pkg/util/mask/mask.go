@@ -0,0 +1,154 @@
+/*
+Copyright 2023 The KodeRover Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mask
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	Mask = "********"
+
+	// encoded variants and single lines of multi-line secrets shorter than it are not masked on their own,
+	// otherwise short common strings would be masked everywhere in the logs.
+	minVariantLength = 4
+	// a line longer than it is masked and written without waiting for the line break.
+	maxLineLength = 64 * 1024
+)
+
+// Masker replaces secrets in logs with the mask. Besides the secret itself, the base64 and URL encoded forms
+// of it are masked, and so is every line of a multi-line secret such as a private key, since logs are
+// printed line by line.
+type Masker struct {
+	replacer *strings.Replacer
+}
+
+func NewMasker(secrets []string) *Masker {
+	candidates := make(map[string]struct{})
+	add := func(value string, minLength int) {
+		if len(value) >= minLength {
+			candidates[value] = struct{}{}
+		}
+	}
+	for _, secret := range secrets {
+		add(secret, 1)
+		if secret == "" {
+			continue
+		}
+		add(base64.StdEncoding.EncodeToString([]byte(secret)), minVariantLength)
+		add(base64.RawStdEncoding.EncodeToString([]byte(secret)), minVariantLength)
+		add(base64.URLEncoding.EncodeToString([]byte(secret)), minVariantLength)
+		add(base64.RawURLEncoding.EncodeToString([]byte(secret)), minVariantLength)
+		add(url.QueryEscape(secret), minVariantLength)
+		add(url.PathEscape(secret), minVariantLength)
+		if strings.Contains(secret, "\n") {
+			// escaped in json or yaml outputs
+			add(strings.ReplaceAll(secret, "\n", `\n`), minVariantLength)
+			for _, line := range strings.Split(secret, "\n") {
+				add(strings.TrimSpace(line), minVariantLength)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return &Masker{}
+	}
+
+	// longer values first, so that a secret containing another one is masked as a whole.
+	values := make([]string, 0, len(candidates))
+	for value := range candidates {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	oldnew := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		oldnew = append(oldnew, value, Mask)
+	}
+	return &Masker{replacer: strings.NewReplacer(oldnew...)}
+}
+
+// Mask is safe to be called on a nil Masker, which masks nothing.
+func (m *Masker) Mask(message string) string {
+	if m == nil || m.replacer == nil {
+		return message
+	}
+	return m.replacer.Replace(message)
+}
+
+// Writer masks what is written to it line by line, so that a secret written in several pieces is still masked.
+type Writer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	masker *Masker
+	buf    []byte
+}
+
+func NewWriter(w io.Writer, masker *Masker) *Writer {
+	return &Writer{w: w, masker: masker}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.write(w.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) > maxLineLength {
+		if err := w.write(w.buf); err != nil {
+			return 0, err
+		}
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// Flush writes the incomplete line left in the buffer.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.write(w.buf)
+	w.buf = nil
+	return err
+}
+
+func (w *Writer) write(line []byte) error {
+	_, err := io.WriteString(w.w, w.masker.Mask(string(line)))
+	return err
+}